@@ -8,24 +8,104 @@ import (
 
 var configFile = "config.toml"
 
-// Settings contains the settings for SMTP, Twilio, and Website from the config.toml file.
+// Settings contains the settings for SMTP, the SMS providers, Voice, Push,
+// Webhook, Outbox, Storm, Incidents, Flapping, Degraded, Certificates and
+// Website from the config.toml file.
 var Settings struct {
 	SMTP struct {
 		EmailAddress string `valid:"-"`
+		FromName     string `valid:"-"`
 		UserName     string `valid:"-"`
 		Password     string `valid:"-"`
 		Server       string `valid:"-"`
 		Port         string `valid:"int,required"`
+		TLSMode      string `valid:"-"`
+		Auth         string `valid:"-"`
+		CAFile       string `valid:"-"`
+	}
+	SMS struct {
+		Provider string `valid:"-"`
 	}
 	Twilio struct {
 		AccountSid string `valid:"-"`
 		AuthToken  string `valid:"-"`
 		Number     string `valid:"-"`
+		URL        string `valid:"-"`
+	}
+	Vonage struct {
+		APIKey    string `valid:"-"`
+		APISecret string `valid:"-"`
+		From      string `valid:"-"`
+		URL       string `valid:"-"`
+	}
+	SNS struct {
+		Region          string `valid:"-"`
+		AccessKeyID     string `valid:"-"`
+		SecretAccessKey string `valid:"-"`
+		SenderID        string `valid:"-"`
+		URL             string `valid:"-"`
+	}
+	MessageBird struct {
+		AccessKey  string `valid:"-"`
+		Originator string `valid:"-"`
+		URL        string `valid:"-"`
+	}
+	SMSHTTP struct {
+		URL         string   `valid:"-"`
+		Method      string   `valid:"-"`
+		ContentType string   `valid:"-"`
+		Body        string   `valid:"-"`
+		Headers     []string `valid:"-"`
+	}
+	Voice struct {
+		DelayMinutes int    `valid:"-"`
+		From         string `valid:"-"`
+		URL          string `valid:"-"`
+	}
+	Push struct {
+		TelegramURL       string `valid:"-"`
+		TelegramBotToken  string `valid:"-"`
+		MatrixURL         string `valid:"-"`
+		MatrixAccessToken string `valid:"-"`
+		NtfyURL           string `valid:"-"`
+		NtfyToken         string `valid:"-"`
+		GotifyURL         string `valid:"-"`
+	}
+	Webhook struct {
+		URL    string `valid:"-"`
+		Secret string `valid:"-"`
+	}
+	Outbox struct {
+		MaxAttempts    int `valid:"-"`
+		BackoffSeconds int `valid:"-"`
+	}
+	Storm struct {
+		GatherSeconds   int `valid:"-"`
+		GroupSeconds    int `valid:"-"`
+		ContactPerHour  int `valid:"-"`
+		GlobalPerMinute int `valid:"-"`
+	}
+	Incidents struct {
+		PagerDutyURL string `valid:"-"`
+		OpsgenieURL  string `valid:"-"`
+	}
+	Flapping struct {
+		Transitions   int `valid:"-"`
+		WindowMinutes int `valid:"-"`
+	}
+	Degraded struct {
+		ResponseMilliseconds int `valid:"-"`
+		Pings                int `valid:"-"`
+	}
+	Certificates struct {
+		WarnDays int `valid:"-"`
 	}
 	Website struct {
-		HTTPPort    string `valid:"int,required"`
-		CookieKey   string `valid:"ascii,required"`
-		SecureHTTPS bool   `valid:"bool"`
+		HTTPPort         string `valid:"int,required"`
+		CookieKey        string `valid:"ascii,required"`
+		SecureHTTPS      bool   `valid:"bool"`
+		BaseURL          string `valid:"-"`
+		AcknowledgeHours int    `valid:"-"`
 	}
 }
 
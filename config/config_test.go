@@ -28,6 +28,10 @@ func TestSmtpConfiguration(t *testing.T) {
 	if smtpSettings.Port != "587" {
 		t.Fatal("Config Email Port mismatch:\n", smtpSettings.Port)
 	}
+
+	if smtpSettings.TLSMode != "starttls" || smtpSettings.Auth != "plain" {
+		t.Error("Config Email TLSMode or Auth mismatch:\n", smtpSettings.TLSMode, smtpSettings.Auth)
+	}
 }
 
 func TestTwilioConfiguration(t *testing.T) {
@@ -46,6 +50,21 @@ func TestTwilioConfiguration(t *testing.T) {
 	}
 }
 
+func TestSmsConfiguration(t *testing.T) {
+	if config.Settings.SMS.Provider != "twilio" {
+		t.Error("Config SMS Provider mismatch:\n", config.Settings.SMS.Provider)
+	}
+
+	if config.Settings.Vonage.URL != "https://rest.nexmo.com/sms/json" || config.Settings.SNS.Region != "us-east-1" {
+		t.Error("Config Vonage URL or SNS Region mismatch:\n", config.Settings.Vonage.URL, config.Settings.SNS.Region)
+	}
+
+	smsHTTP := config.Settings.SMSHTTP
+	if smsHTTP.Method != "POST" || len(smsHTTP.Headers) != 1 || smsHTTP.Headers[0] != "Authorization: Bearer yourtoken" {
+		t.Error("Config SMSHTTP mismatch:\n", smsHTTP.Method, smsHTTP.Headers)
+	}
+}
+
 func TestWebsiteConfiguration(t *testing.T) {
 	websiteSettings := config.Settings.Website
 
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"database/sql"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apexskier/httpauth"
+	"github.com/asaskevich/govalidator"
+	"github.com/gorilla/csrf"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+	"github.com/turnkey-commerce/go-ping-sites/pinger"
+	"github.com/turnkey-commerce/go-ping-sites/viewmodels"
+)
+
+// acknowledgeController handles the links to acknowledge an outage in the
+// notifications. The signed link is what allows it, so the user doesn't have
+// to be logged in.
+type acknowledgeController struct {
+	DB         *sql.DB
+	template   *template.Template
+	authorizer httpauth.Authorizer
+	pinger     *pinger.Pinger
+}
+
+// get shows the outage with a form to acknowledge it, rather than
+// acknowledging it straight away, so that a link scanner that follows the
+// links in the emails can't acknowledge it.
+func (controller *acknowledgeController) get(rw http.ResponseWriter, req *http.Request) (int, error) {
+	form := &viewmodels.AcknowledgeEditViewModel{T: req.URL.Query().Get("t"), Sig: req.URL.Query().Get("sig")}
+	site, linkError, err := controller.getSiteFromLink(req, form)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+	vm := viewmodels.GetAcknowledgeViewModel(site, form, linkError, isAuthenticated, user, make(map[string]string))
+	vm.CsrfField = csrf.TemplateField(req)
+	return http.StatusOK, controller.template.Execute(rw, vm)
+}
+
+func (controller *acknowledgeController) post(rw http.ResponseWriter, req *http.Request) (int, error) {
+	err := req.ParseForm()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	decoder := schema.NewDecoder()
+	// Ignore unknown keys to prevent errors from the CSRF token.
+	decoder.IgnoreUnknownKeys(true)
+	form := new(viewmodels.AcknowledgeEditViewModel)
+	err = decoder.Decode(form, req.PostForm)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	site, linkError, err := controller.getSiteFromLink(req, form)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	_, err = govalidator.ValidateStruct(form)
+	valErrors := govalidator.ErrorsByField(err)
+	if linkError != "" || site.AcknowledgedBy != "" || len(valErrors) > 0 {
+		isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+		vm := viewmodels.GetAcknowledgeViewModel(site, form, linkError, isAuthenticated, user, valErrors)
+		vm.CsrfField = csrf.TemplateField(req)
+		return http.StatusOK, controller.template.Execute(rw, vm)
+	}
+
+	err = acknowledgeSite(controller.pinger, site, strings.TrimSpace(form.Name))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// Show the acknowledgement on the page of the link.
+	http.Redirect(rw, req, req.URL.Path+"?t="+form.T+"&sig="+form.Sig, http.StatusSeeOther)
+	return http.StatusSeeOther, nil
+}
+
+// callPost acknowledges the outage when 1 is pressed on the voice call. The
+// keypad input is posted by Twilio to the signed link with the number that was
+// called, and the response is the TwiML that tells the contact the result.
+func (controller *acknowledgeController) callPost(rw http.ResponseWriter, req *http.Request) (int, error) {
+	err := req.ParseForm()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	form := &viewmodels.AcknowledgeEditViewModel{T: req.URL.Query().Get("t"), Sig: req.URL.Query().Get("sig")}
+	site, linkError, err := controller.getSiteFromLink(req, form)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	var message string
+	switch {
+	case linkError != "":
+		message = linkError
+	case site.AcknowledgedBy != "":
+		message = "The outage was already acknowledged by " + site.AcknowledgedBy + ". Goodbye."
+	case req.PostForm.Get("Digits") != "1":
+		message = "The outage was not acknowledged. Goodbye."
+	default:
+		called := req.PostForm.Get("To")
+		name, err := database.GetChannelContactName(controller.DB, database.VoiceChannel, called)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if name == "" {
+			name = called
+		}
+		err = acknowledgeSite(controller.pinger, site, name+" (voice call)")
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		message = "The outage of " + site.Name + " is acknowledged. Goodbye."
+	}
+	rw.Header().Set("Content-Type", "text/xml")
+	_, err = rw.Write([]byte(notifier.SayTwiML(message)))
+	return http.StatusOK, err
+}
+
+// getSiteFromLink checks the signed link and gets the site. The link error is
+// shown to the user if the link can't acknowledge the current outage of the
+// site, and the site is nil if the link isn't valid.
+func (controller *acknowledgeController) getSiteFromLink(req *http.Request,
+	form *viewmodels.AcknowledgeEditViewModel) (*database.Site, string, error) {
+	vars := mux.Vars(req)
+	siteID, err := strconv.ParseInt(vars["siteID"], 10, 64)
+	if err != nil {
+		return nil, "The acknowledge link is not valid.", nil
+	}
+	issued, err := notifier.VerifyAcknowledgeLink(siteID, form.T, form.Sig, time.Now())
+	if err != nil {
+		return nil, "Sorry, " + err.Error() + ".", nil
+	}
+	site := new(database.Site)
+	err = site.GetSite(controller.DB, siteID)
+	if err != nil {
+		return nil, "", err
+	}
+	if site.IsSiteUp {
+		return site, "The site is back up, so there is no outage to acknowledge.", nil
+	}
+	if site.LastStatusChange.Unix() > issued.Unix() {
+		return site, "The link is for an earlier outage of the site.", nil
+	}
+	return site, "", nil
+}
+
+// acknowledgeSite records the acknowledgement of the site's outage and stops
+// the pinger's reminders and escalation for it.
+func acknowledgeSite(p *pinger.Pinger, site *database.Site, acknowledgedBy string) error {
+	return p.Acknowledge(site, acknowledgedBy, time.Now())
+}
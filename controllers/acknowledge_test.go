@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/turnkey-commerce/go-ping-sites/config"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+	"github.com/turnkey-commerce/go-ping-sites/viewmodels"
+)
+
+// TestAcknowledgeLinkOfOutage tests that the link of the down notification
+// acknowledges the outage once its status change has been saved, and that a
+// link issued before it is for an earlier outage.
+func TestAcknowledgeLinkOfOutage(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+	saved := config.Settings.Website.BaseURL
+	defer func() { config.Settings.Website.BaseURL = saved }()
+	config.Settings.Website.BaseURL = "http://localhost:8000"
+
+	site := database.Site{Name: "Test", IsActive: true, URL: "http://www.example.com",
+		PingIntervalSeconds: 60, TimeoutSeconds: 30, IsSiteUp: true}
+	err = site.CreateSite(db)
+	if err != nil {
+		t.Fatal("Failed to create new site:", err)
+	}
+	// The ping that found the site down, a while before the status is saved.
+	pinged := time.Now().Add(-5 * time.Second)
+	n := notifier.NewNotifier(site, "Test: Site is down.", "Test: Site is Down", nil)
+	n.AddAcknowledgeLink(pinged)
+	err = site.UpdateSiteStatus(db, false, pinged, nil)
+	if err != nil {
+		t.Fatal("Failed to update the site status:", err)
+	}
+
+	controller := &acknowledgeController{DB: db}
+	linkError := func(link string) string {
+		u, err := url.Parse(link)
+		if err != nil {
+			t.Fatal("Failed to parse the acknowledge link:", err)
+		}
+		req, _ := http.NewRequest("GET", link, nil)
+		req = mux.SetURLVars(req, map[string]string{"siteID": strconv.FormatInt(site.SiteID, 10)})
+		form := &viewmodels.AcknowledgeEditViewModel{T: u.Query().Get("t"), Sig: u.Query().Get("sig")}
+		_, message, err := controller.getSiteFromLink(req, form)
+		if err != nil {
+			t.Fatal("Failed to get the site from the link:", err)
+		}
+		return message
+	}
+	if message := linkError(n.AcknowledgeURL); message != "" {
+		t.Error("The link of the outage should acknowledge it:", message)
+	}
+	if message := linkError(notifier.AcknowledgeLink(site.SiteID, pinged.Add(-time.Minute))); message != "The link is for an earlier outage of the site." {
+		t.Error("The link issued before the outage should not acknowledge it:", message)
+	}
+}
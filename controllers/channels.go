@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"database/sql"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+	"github.com/turnkey-commerce/go-ping-sites/viewmodels"
+)
+
+// testChannel sends the test message to the endpoint of the recipient, records
+// it in the delivery log and returns the result to show on the page.
+func testChannel(db *sql.DB, n *notifier.Notifier, recipient string, channelType string,
+	address string) viewmodels.ChannelTestViewModel {
+	result := viewmodels.ChannelTestViewModel{Channel: channelType, Address: address}
+	if channel, ok := n.Channels[channelType]; ok {
+		result.Channel = channel.Name()
+	}
+	err := n.SendTo(channelType, address)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	notifier.RecordDelivery(db, database.DeliveryAttempt{Recipient: recipient, ChannelType: channelType,
+		Address: address}, n.ForChannel(channelType), err)
+	return result
+}
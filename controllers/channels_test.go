@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+	"github.com/turnkey-commerce/go-ping-sites/pinger"
+)
+
+// TestSendTestMessages tests that the test message of a contact and of a site
+// is sent to each of their active channels and recorded in the delivery log.
+func TestSendTestMessages(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+
+	email := &notifier.ChannelMock{}
+	sms := &notifier.ChannelMock{}
+	slack := &notifier.ChannelMock{}
+	p := &pinger.Pinger{Channels: notifier.Channels{database.EmailChannel: email,
+		database.SmsChannel: sms, database.SlackChannel: slack}}
+	templates := populateFileTemplates("../templates")
+	mockUserGetter := MockCurrentUserGetter{Username: "jules"}
+
+	contact := database.Contact{Name: "Joe Contact"}
+	contact.SetChannel(database.EmailChannel, "joe@test.com", true)
+	contact.SetChannel(database.SmsChannel, "5125551212", true)
+	contact.SetChannel(database.SlackChannel, "https://hooks.slack.com/joe", false)
+	err = contact.CreateContact(db)
+	if err != nil {
+		t.Fatal("Failed to create new contact:", err)
+	}
+	site := database.Site{Name: "Test", IsActive: true, URL: "http://www.example.com",
+		PingIntervalSeconds: 60, TimeoutSeconds: 30}
+	site.SetChannel(database.SlackChannel, "https://hooks.slack.com/site", true)
+	err = site.CreateSite(db)
+	if err != nil {
+		t.Fatal("Failed to create new site:", err)
+	}
+	err = site.SetSiteChannels(db)
+	if err != nil {
+		t.Fatal("Failed to save the site channels:", err)
+	}
+
+	cc := &contactsController{DB: db, editTemplate: templates.Lookup("contact_edit.gohtml"),
+		authorizer: mockUserGetter, pinger: p}
+	req, _ := http.NewRequest("POST", "/settings/contacts/1/test", nil)
+	req = mux.SetURLVars(req, map[string]string{"contactID": strconv.FormatInt(contact.ContactID, 10)})
+	status, err := cc.testPost(httptest.NewRecorder(), req)
+	if err != nil || status != http.StatusOK {
+		t.Fatal("Failed to send the contact test message:", status, err)
+	}
+
+	stc := &sitesController{DB: db, detailsTemplate: templates.Lookup("site_details.gohtml"),
+		authorizer: mockUserGetter, pinger: p}
+	req, _ = http.NewRequest("POST", "/settings/sites/1/test", nil)
+	req = mux.SetURLVars(req, map[string]string{"siteID": strconv.FormatInt(site.SiteID, 10)})
+	status, err = stc.testPost(httptest.NewRecorder(), req)
+	if err != nil || status != http.StatusOK {
+		t.Fatal("Failed to send the site test message:", status, err)
+	}
+
+	if len(email.Sent) != 1 || len(sms.Sent) != 1 {
+		t.Error("Contact test message should be sent to the email and SMS, sent:", email.Sent, sms.Sent)
+	}
+	if len(slack.Sent) != 1 || slack.Sent[0] != "https://hooks.slack.com/site" {
+		t.Error("Only the active Slack channel of the site should be sent, sent:", slack.Sent)
+	}
+
+	var attempts database.DeliveryAttempts
+	err = attempts.GetDeliveryAttempts(db, database.DeliveryFilter{Limit: 10})
+	if err != nil {
+		t.Fatal("Failed to get the delivery attempts:", err)
+	}
+	if len(attempts) != 3 {
+		t.Fatal("Expected a delivery attempt for each active channel, got:", len(attempts))
+	}
+	recorded := make(map[string]bool)
+	for _, a := range attempts {
+		if !a.Success {
+			t.Error("Test message should be recorded as delivered:", a.ChannelType, a.Error)
+		}
+		recorded[a.Recipient+" "+a.ChannelType+" "+a.Address] = true
+	}
+	for _, want := range []string{"Joe Contact email joe@test.com", "Joe Contact sms 5125551212",
+		"Test slack https://hooks.slack.com/site"} {
+		if !recorded[want] {
+			t.Error("Delivery attempt not recorded:", want)
+		}
+	}
+}
@@ -5,13 +5,14 @@ import (
 	"html/template"
 	"net/http"
 	"strconv"
+	"strings"
 
-	"github.com/apexskier/httpauth"
 	"github.com/asaskevich/govalidator"
 	"github.com/gorilla/csrf"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/schema"
 	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
 	"github.com/turnkey-commerce/go-ping-sites/pinger"
 	"github.com/turnkey-commerce/go-ping-sites/viewmodels"
 )
@@ -22,7 +23,7 @@ type contactsController struct {
 	editTemplate   *template.Template
 	newTemplate    *template.Template
 	deleteTemplate *template.Template
-	authorizer     httpauth.Authorizer
+	authorizer     CurrentUserGetter
 	pinger         *pinger.Pinger
 }
 
@@ -35,6 +36,21 @@ func (controller *contactsController) get(rw http.ResponseWriter, req *http.Requ
 	}
 	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
 	vm := viewmodels.GetContactsViewModel(contacts, isAuthenticated, user, err)
+	// Send a test message to the contact's channels if requested.
+	if req.Method == http.MethodPost {
+		contactID, err := strconv.ParseInt(req.FormValue("contactID"), 10, 64)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		contact := new(database.Contact)
+		err = contact.GetContact(controller.DB, contactID)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		vm.TestContactID = contact.ContactID
+		vm.TestResults = sendTestMessages(controller, contact)
+	}
+	vm.CsrfField = csrf.TemplateField(req)
 	return http.StatusOK, controller.getTemplate.Execute(rw, vm)
 }
 
@@ -54,14 +70,17 @@ func (controller *contactsController) editGet(rw http.ResponseWriter, req *http.
 	contactEdit := new(viewmodels.ContactsEditViewModel)
 	contactEdit.Name = contact.Name
 	contactEdit.ContactID = contact.ContactID
-	contactEdit.EmailAddress = contact.EmailAddress
-	contactEdit.SmsNumber = contact.SmsNumber
-	contactEdit.EmailActive = contact.EmailActive
-	contactEdit.SmsActive = contact.SmsActive
+	contactEdit.Digest = contact.Digest
+	mapContactChannels(contact, contactEdit)
+	mapContactSchedules(contact, contactEdit)
 	contactEdit.SelectedSites, err = getContactSiteIDs(controller, contact)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
+	contactEdit.Subscriptions, err = getContactSubscriptions(controller, contact)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 
 	sites, errGet := getAllSites(controller)
 	if errGet != nil {
@@ -138,6 +157,11 @@ func (controller *contactsController) editPost(rw http.ResponseWriter, req *http
 		}
 	}
 
+	err = setContactSubscriptions(controller, contact.ContactID, formContact)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
 	// Refresh the pinger with the changes.
 	// TODO: Check whether this contact is associated with any active site first.
 	err = controller.pinger.UpdateSiteSettings()
@@ -206,6 +230,10 @@ func (controller *contactsController) newPost(rw http.ResponseWriter, req *http.
 			return http.StatusInternalServerError, err
 		}
 	}
+	err = setContactSubscriptions(controller, contact.ContactID, formContact)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 
 	// Refresh the pinger with the changes.
 	// TODO: Check whether this contact has been added to any site first.
@@ -234,7 +262,9 @@ func (controller *contactsController) deleteGet(rw http.ResponseWriter, req *htt
 	contactDelete := new(viewmodels.ContactsEditViewModel)
 	contactDelete.Name = contact.Name
 	contactDelete.ContactID = contact.ContactID
-	contactDelete.EmailAddress = contact.EmailAddress
+	if email, ok := contact.Channel(database.EmailChannel); ok {
+		contactDelete.EmailAddress = email.Address
+	}
 	var noSites = []database.Site{}
 	vm := viewmodels.EditContactViewModel(contactDelete, noSites, isAuthenticated,
 		user, make(map[string]string))
@@ -303,12 +333,126 @@ func validateContactForm(contact *viewmodels.ContactsEditViewModel) (valErrors m
 	return valErrors
 }
 
+// testPost sends a test message to each of the active channels of the contact
+// and shows the results on the edit page.
+func (controller *contactsController) testPost(rw http.ResponseWriter, req *http.Request) (int, error) {
+	vars := mux.Vars(req)
+	contactID, err := strconv.ParseInt(vars["contactID"], 10, 64)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	contact := new(database.Contact)
+	err = contact.GetContact(controller.DB, contactID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	results := sendTestMessages(controller, contact)
+
+	contactEdit := new(viewmodels.ContactsEditViewModel)
+	contactEdit.Name = contact.Name
+	contactEdit.ContactID = contact.ContactID
+	contactEdit.Digest = contact.Digest
+	mapContactChannels(contact, contactEdit)
+	mapContactSchedules(contact, contactEdit)
+	contactEdit.SelectedSites, err = getContactSiteIDs(controller, contact)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	contactEdit.Subscriptions, err = getContactSubscriptions(controller, contact)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	sites, err := getAllSites(controller)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+	vm := viewmodels.EditContactViewModel(contactEdit, sites, isAuthenticated, user, make(map[string]string))
+	vm.TestResults = results
+	vm.TestSent = true
+	vm.CsrfField = csrf.TemplateField(req)
+	return http.StatusOK, controller.editTemplate.Execute(rw, vm)
+}
+
 func mapContacts(contact *database.Contact, formContact *viewmodels.ContactsEditViewModel) {
 	contact.Name = formContact.Name
-	contact.EmailAddress = formContact.EmailAddress
-	contact.EmailActive = formContact.EmailActive
-	contact.SmsNumber = formContact.SmsNumber
-	contact.SmsActive = formContact.SmsActive
+	contact.SetChannel(database.EmailChannel, strings.TrimSpace(formContact.EmailAddress), formContact.EmailActive)
+	contact.SetChannel(database.SmsChannel, strings.TrimSpace(formContact.SmsNumber), formContact.SmsActive)
+	contact.SetChannel(database.VoiceChannel, strings.TrimSpace(formContact.VoiceNumber), formContact.VoiceActive)
+	contact.SetChannel(database.SlackChannel, strings.TrimSpace(formContact.SlackWebhook), formContact.SlackActive)
+	contact.SetChannel(database.TeamsChannel, strings.TrimSpace(formContact.TeamsWebhook), formContact.TeamsActive)
+	contact.SetChannel(database.TelegramChannel, strings.TrimSpace(formContact.TelegramChatID), formContact.TelegramActive)
+	contact.SetChannel(database.DiscordChannel, strings.TrimSpace(formContact.DiscordWebhook), formContact.DiscordActive)
+	contact.SetChannel(database.MatrixChannel, strings.TrimSpace(formContact.MatrixRoom), formContact.MatrixActive)
+	contact.SetChannel(database.NtfyChannel, strings.TrimSpace(formContact.NtfyTopic), formContact.NtfyActive)
+	contact.SetChannel(database.GotifyChannel, strings.TrimSpace(formContact.GotifyToken), formContact.GotifyActive)
+	contact.Timezone = strings.TrimSpace(formContact.Timezone)
+	contact.Digest = formContact.Digest
+	contact.Schedules = viewmodels.MapSchedulesVMtoDB(formContact.Schedules)
+}
+
+// mapContactChannels maps the email, SMS and voice numbers, chat webhooks and
+// push channels of the contact to the form.
+func mapContactChannels(contact *database.Contact, contactEdit *viewmodels.ContactsEditViewModel) {
+	if email, ok := contact.Channel(database.EmailChannel); ok {
+		contactEdit.EmailAddress = email.Address
+		contactEdit.EmailActive = email.IsActive
+	}
+	if sms, ok := contact.Channel(database.SmsChannel); ok {
+		contactEdit.SmsNumber = sms.Address
+		contactEdit.SmsActive = sms.IsActive
+	}
+	if voice, ok := contact.Channel(database.VoiceChannel); ok {
+		contactEdit.VoiceNumber = voice.Address
+		contactEdit.VoiceActive = voice.IsActive
+	}
+	if slack, ok := contact.Channel(database.SlackChannel); ok {
+		contactEdit.SlackWebhook = slack.Address
+		contactEdit.SlackActive = slack.IsActive
+	}
+	if teams, ok := contact.Channel(database.TeamsChannel); ok {
+		contactEdit.TeamsWebhook = teams.Address
+		contactEdit.TeamsActive = teams.IsActive
+	}
+	if telegram, ok := contact.Channel(database.TelegramChannel); ok {
+		contactEdit.TelegramChatID = telegram.Address
+		contactEdit.TelegramActive = telegram.IsActive
+	}
+	if discord, ok := contact.Channel(database.DiscordChannel); ok {
+		contactEdit.DiscordWebhook = discord.Address
+		contactEdit.DiscordActive = discord.IsActive
+	}
+	if matrix, ok := contact.Channel(database.MatrixChannel); ok {
+		contactEdit.MatrixRoom = matrix.Address
+		contactEdit.MatrixActive = matrix.IsActive
+	}
+	if ntfy, ok := contact.Channel(database.NtfyChannel); ok {
+		contactEdit.NtfyTopic = ntfy.Address
+		contactEdit.NtfyActive = ntfy.IsActive
+	}
+	if gotify, ok := contact.Channel(database.GotifyChannel); ok {
+		contactEdit.GotifyToken = gotify.Address
+		contactEdit.GotifyActive = gotify.IsActive
+	}
+}
+
+// sendTestMessages sends a test message through each of the active channels of
+// the contact with the senders that the notifications use.
+func sendTestMessages(controller *contactsController, contact *database.Contact) []viewmodels.ChannelTestViewModel {
+	n := notifier.NewTestNotifier(database.Site{}, controller.pinger.Channels)
+	var results []viewmodels.ChannelTestViewModel
+	for _, endpoint := range contact.ActiveChannels() {
+		results = append(results, testChannel(controller.DB, n, contact.Name, endpoint.ChannelType, endpoint.Address))
+	}
+	return results
+}
+
+// mapContactSchedules maps the time zone and the schedules of the contact to the
+// form.
+func mapContactSchedules(contact *database.Contact, contactEdit *viewmodels.ContactsEditViewModel) {
+	contactEdit.Timezone = contact.Timezone
+	contactEdit.Schedules = viewmodels.MapSchedulesDBtoVM(contact.Schedules)
 }
 
 func getAllSites(controller *contactsController) (database.Sites, error) {
@@ -334,6 +478,30 @@ func getContactSiteIDs(controller *contactsController, contact *database.Contact
 	return siteIDs, nil
 }
 
+// getContactSubscriptions gets what the contact is notified of for each of its
+// sites for the form.
+func getContactSubscriptions(controller *contactsController, contact *database.Contact) ([]viewmodels.SubscriptionViewModel, error) {
+	subs, err := contact.GetContactSubscriptions(controller.DB)
+	if err != nil {
+		return nil, err
+	}
+	return viewmodels.MapSubscriptionsDBtoVM(subs), nil
+}
+
+// setContactSubscriptions saves what the contact is notified of for each of the
+// sites selected on the form.
+func setContactSubscriptions(controller *contactsController, contactID int64, formContact *viewmodels.ContactsEditViewModel) error {
+	for _, siteSelID := range formContact.SelectedSites {
+		site := database.Site{SiteID: siteSelID}
+		err := site.SetContactSubscription(controller.DB, contactID,
+			viewmodels.MapSubscriptionVMtoDB(formContact.Subscriptions, siteSelID))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func addContactToSite(controller *contactsController, contactID int64, siteID int64) error {
 	var site database.Site
 	err := site.GetSite(controller.DB, siteID)
@@ -67,6 +67,19 @@ func Register(db *sql.DB, authorizer httpauth.Authorizer, authBackend httpauth.A
 	loc.authorizer = authorizer
 	router.HandleFunc("/logout", loc.get)
 
+	// The acknowledge links in the notifications are signed so they don't need a login.
+	akc := new(acknowledgeController)
+	akc.template = templates.Lookup("acknowledge.gohtml")
+	akc.authorizer = authorizer
+	akc.pinger = pinger
+	akc.DB = db
+	router.Handle("/acknowledge/{siteID}", appHandler(akc.get)).Methods("GET")
+	router.Handle("/acknowledge/{siteID}", appHandler(akc.post)).Methods("POST")
+	// The keypad input of the voice calls is posted by Twilio without a CSRF
+	// token, so it has its own router outside of the CSRF protection.
+	callRouter := mux.NewRouter()
+	callRouter.Handle("/calls/{siteID}/acknowledge", appHandler(akc.callPost)).Methods("POST")
+
 	sc := new(settingsController)
 	sc.template = templates.Lookup("settings.gohtml")
 	sc.authorizer = authorizer
@@ -109,6 +122,73 @@ func Register(db *sql.DB, authorizer httpauth.Authorizer, authBackend httpauth.A
 	settingsSub.Handle("/contacts/{contactID}/delete", authorizeRole(appHandler(cc.deletePost), authorizer, "admin")).Methods("POST")
 	settingsSub.Handle("/contacts/new", authorizeRole(appHandler(cc.newGet), authorizer, "admin")).Methods("GET")
 	settingsSub.Handle("/contacts/new", authorizeRole(appHandler(cc.newPost), authorizer, "admin")).Methods("POST")
+	settingsSub.Handle("/contacts/{contactID}/test", authorizeRole(appHandler(cc.testPost), authorizer, "admin")).Methods("POST")
+
+	// /settings/maintenance
+	mc := new(maintenanceController)
+	mc.getTemplate = templates.Lookup("maintenance.gohtml")
+	mc.editTemplate = templates.Lookup("maintenance_edit.gohtml")
+	mc.newTemplate = templates.Lookup("maintenance_new.gohtml")
+	mc.deleteTemplate = templates.Lookup("maintenance_delete.gohtml")
+	mc.authorizer = authorizer
+	mc.pinger = pinger
+	mc.DB = db
+	settingsSub.Handle("/maintenance", authorizeRole(appHandler(mc.get), authorizer, "admin"))
+	settingsSub.Handle("/maintenance/{maintenanceWindowID}/edit", authorizeRole(appHandler(mc.editGet), authorizer, "admin")).Methods("GET")
+	settingsSub.Handle("/maintenance/{maintenanceWindowID}/edit", authorizeRole(appHandler(mc.editPost), authorizer, "admin")).Methods("POST")
+	settingsSub.Handle("/maintenance/{maintenanceWindowID}/delete", authorizeRole(appHandler(mc.deleteGet), authorizer, "admin")).Methods("GET")
+	settingsSub.Handle("/maintenance/{maintenanceWindowID}/delete", authorizeRole(appHandler(mc.deletePost), authorizer, "admin")).Methods("POST")
+	settingsSub.Handle("/maintenance/new", authorizeRole(appHandler(mc.newGet), authorizer, "admin")).Methods("GET")
+	settingsSub.Handle("/maintenance/new", authorizeRole(appHandler(mc.newPost), authorizer, "admin")).Methods("POST")
+
+	// /settings/escalation
+	ec := new(escalationController)
+	ec.getTemplate = templates.Lookup("escalation.gohtml")
+	ec.editTemplate = templates.Lookup("escalation_edit.gohtml")
+	ec.newTemplate = templates.Lookup("escalation_new.gohtml")
+	ec.deleteTemplate = templates.Lookup("escalation_delete.gohtml")
+	ec.authorizer = authorizer
+	ec.pinger = pinger
+	ec.DB = db
+	settingsSub.Handle("/escalation", authorizeRole(appHandler(ec.get), authorizer, "admin"))
+	settingsSub.Handle("/escalation/{escalationPolicyID}/edit", authorizeRole(appHandler(ec.editGet), authorizer, "admin")).Methods("GET")
+	settingsSub.Handle("/escalation/{escalationPolicyID}/edit", authorizeRole(appHandler(ec.editPost), authorizer, "admin")).Methods("POST")
+	settingsSub.Handle("/escalation/{escalationPolicyID}/delete", authorizeRole(appHandler(ec.deleteGet), authorizer, "admin")).Methods("GET")
+	settingsSub.Handle("/escalation/{escalationPolicyID}/delete", authorizeRole(appHandler(ec.deletePost), authorizer, "admin")).Methods("POST")
+	settingsSub.Handle("/escalation/new", authorizeRole(appHandler(ec.newGet), authorizer, "admin")).Methods("GET")
+	settingsSub.Handle("/escalation/new", authorizeRole(appHandler(ec.newPost), authorizer, "admin")).Methods("POST")
+
+	// /settings/templates
+	tc := new(templatesController)
+	tc.getTemplate = templates.Lookup("templates.gohtml")
+	tc.editTemplate = templates.Lookup("template_edit.gohtml")
+	tc.deleteTemplate = templates.Lookup("template_delete.gohtml")
+	tc.authorizer = authorizer
+	tc.pinger = pinger
+	tc.DB = db
+	settingsSub.Handle("/templates", authorizeRole(appHandler(tc.get), authorizer, "admin"))
+	settingsSub.Handle("/templates/{channelType}/{event}/edit", authorizeRole(appHandler(tc.editGet), authorizer, "admin")).Methods("GET")
+	settingsSub.Handle("/templates/{channelType}/{event}/edit", authorizeRole(appHandler(tc.editPost), authorizer, "admin")).Methods("POST")
+	settingsSub.Handle("/templates/{channelType}/{event}/delete", authorizeRole(appHandler(tc.deleteGet), authorizer, "admin")).Methods("GET")
+	settingsSub.Handle("/templates/{channelType}/{event}/delete", authorizeRole(appHandler(tc.deletePost), authorizer, "admin")).Methods("POST")
+
+	// /settings/outbox
+	oc := new(outboxController)
+	oc.getTemplate = templates.Lookup("outbox.gohtml")
+	oc.detailsTemplate = templates.Lookup("outbox_message.gohtml")
+	oc.authorizer = authorizer
+	oc.DB = db
+	settingsSub.Handle("/outbox", authorizeRole(appHandler(oc.get), authorizer, "admin"))
+	settingsSub.Handle("/outbox/{outboxMessageID}", authorizeRole(appHandler(oc.getDetails), authorizer, "admin")).Methods("GET")
+	settingsSub.Handle("/outbox/{outboxMessageID}/resend", authorizeRole(appHandler(oc.resendPost), authorizer, "admin")).Methods("POST")
+
+	// /settings/notifications
+	nc := new(notificationsController)
+	nc.getTemplate = templates.Lookup("notifications.gohtml")
+	nc.authorizer = authorizer
+	nc.pinger = pinger
+	nc.DB = db
+	settingsSub.Handle("/notifications", authorizeRole(appHandler(nc.get), authorizer, "admin"))
 
 	// /settings/sites
 	stc := new(sitesController)
@@ -123,11 +203,14 @@ func Register(db *sql.DB, authorizer httpauth.Authorizer, authBackend httpauth.A
 	settingsSub.Handle("/sites/new", authorizeRole(appHandler(stc.newGet), authorizer, "admin")).Methods("GET")
 	settingsSub.Handle("/sites/new", authorizeRole(appHandler(stc.newPost), authorizer, "admin")).Methods("POST")
 	settingsSub.Handle("/sites/{siteID}", authorizeRole(appHandler(stc.getDetails), authorizer, "admin"))
+	settingsSub.Handle("/sites/{siteID}/test", authorizeRole(appHandler(stc.testPost), authorizer, "admin")).Methods("POST")
+	settingsSub.Handle("/sites/{siteID}/acknowledge", authorizeRole(appHandler(stc.acknowledgePost), authorizer, "admin")).Methods("POST")
 	settingsSub.Handle("/sites/{siteID}/edit", authorizeRole(appHandler(stc.editGet), authorizer, "admin")).Methods("GET")
 	settingsSub.Handle("/sites/{siteID}/edit", authorizeRole(appHandler(stc.editPost), authorizer, "admin")).Methods("POST")
 
 	// Wrap the router in the CSRF protection.
 	http.Handle("/", CSRF(router))
+	http.Handle("/calls/", callRouter)
 
 	http.HandleFunc("/img/", serveResource(publicFiles))
 	http.HandleFunc("/css/", serveResource(publicFiles))
@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"database/sql"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/apexskier/httpauth"
+	"github.com/asaskevich/govalidator"
+	"github.com/gorilla/csrf"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/pinger"
+	"github.com/turnkey-commerce/go-ping-sites/viewmodels"
+)
+
+type escalationController struct {
+	DB             *sql.DB
+	getTemplate    *template.Template
+	editTemplate   *template.Template
+	newTemplate    *template.Template
+	deleteTemplate *template.Template
+	authorizer     httpauth.Authorizer
+	pinger         *pinger.Pinger
+}
+
+func (controller *escalationController) get(rw http.ResponseWriter, req *http.Request) (int, error) {
+	var policies database.EscalationPolicies
+	err := policies.GetEscalationPolicies(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+	vm := viewmodels.GetEscalationPoliciesViewModel(policies, isAuthenticated, user)
+	return http.StatusOK, controller.getTemplate.Execute(rw, vm)
+}
+
+func (controller *escalationController) editGet(rw http.ResponseWriter, req *http.Request) (int, error) {
+	policy, err := controller.getPolicyFromRoute(req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	policyEdit := new(viewmodels.EscalationEditViewModel)
+	viewmodels.MapEscalationDBtoVM(policy, policyEdit)
+
+	var contacts database.Contacts
+	err = contacts.GetContacts(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+	vm := viewmodels.EditEscalationViewModel(policyEdit, contacts, isAuthenticated, user, make(map[string]string))
+	vm.CsrfField = csrf.TemplateField(req)
+	return http.StatusOK, controller.editTemplate.Execute(rw, vm)
+}
+
+func (controller *escalationController) editPost(rw http.ResponseWriter, req *http.Request) (int, error) {
+	formPolicy, err := decodeEscalationForm(req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	valErrors := validateEscalationForm(formPolicy)
+	if len(valErrors) > 0 {
+		var contacts database.Contacts
+		err = contacts.GetContacts(controller.DB)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+		vm := viewmodels.EditEscalationViewModel(formPolicy, contacts, isAuthenticated, user, valErrors)
+		vm.CsrfField = csrf.TemplateField(req)
+		return http.StatusOK, controller.editTemplate.Execute(rw, vm)
+	}
+
+	// Get the escalation policy to update
+	policy := new(database.EscalationPolicy)
+	err = policy.GetEscalationPolicy(controller.DB, formPolicy.EscalationPolicyID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	err = viewmodels.MapEscalationVMtoDB(formPolicy, policy)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	err = policy.UpdateEscalationPolicy(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// Refresh the pinger with the changes.
+	err = controller.pinger.UpdateSiteSettings()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	http.Redirect(rw, req, "/settings/escalation", http.StatusSeeOther)
+	return http.StatusSeeOther, nil
+}
+
+func (controller *escalationController) newGet(rw http.ResponseWriter, req *http.Request) (int, error) {
+	var contacts database.Contacts
+	err := contacts.GetContacts(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	policyEdit := new(viewmodels.EscalationEditViewModel)
+	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+	vm := viewmodels.NewEscalationViewModel(policyEdit, contacts, isAuthenticated, user, make(map[string]string))
+	vm.CsrfField = csrf.TemplateField(req)
+	return http.StatusOK, controller.newTemplate.Execute(rw, vm)
+}
+
+func (controller *escalationController) newPost(rw http.ResponseWriter, req *http.Request) (int, error) {
+	formPolicy, err := decodeEscalationForm(req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	valErrors := validateEscalationForm(formPolicy)
+	if len(valErrors) > 0 {
+		var contacts database.Contacts
+		err = contacts.GetContacts(controller.DB)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+		vm := viewmodels.NewEscalationViewModel(formPolicy, contacts, isAuthenticated, user, valErrors)
+		vm.CsrfField = csrf.TemplateField(req)
+		return http.StatusOK, controller.newTemplate.Execute(rw, vm)
+	}
+
+	policy := database.EscalationPolicy{}
+	err = viewmodels.MapEscalationVMtoDB(formPolicy, &policy)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	err = policy.CreateEscalationPolicy(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	http.Redirect(rw, req, "/settings/escalation", http.StatusSeeOther)
+	return http.StatusSeeOther, nil
+}
+
+func (controller *escalationController) deleteGet(rw http.ResponseWriter, req *http.Request) (int, error) {
+	policy, err := controller.getPolicyFromRoute(req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	policyDelete := new(viewmodels.EscalationEditViewModel)
+	viewmodels.MapEscalationDBtoVM(policy, policyDelete)
+	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+	var noContacts = database.Contacts{}
+	vm := viewmodels.EditEscalationViewModel(policyDelete, noContacts, isAuthenticated, user, make(map[string]string))
+	vm.CsrfField = csrf.TemplateField(req)
+	return http.StatusOK, controller.deleteTemplate.Execute(rw, vm)
+}
+
+func (controller *escalationController) deletePost(rw http.ResponseWriter, req *http.Request) (int, error) {
+	formPolicy, err := decodeEscalationForm(req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	policy := new(database.EscalationPolicy)
+	err = policy.GetEscalationPolicy(controller.DB, formPolicy.EscalationPolicyID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	err = policy.DeleteEscalationPolicy(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// Refresh the pinger with the changes.
+	err = controller.pinger.UpdateSiteSettings()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	http.Redirect(rw, req, "/settings/escalation", http.StatusSeeOther)
+	return http.StatusSeeOther, nil
+}
+
+func (controller *escalationController) getPolicyFromRoute(req *http.Request) (*database.EscalationPolicy, error) {
+	vars := mux.Vars(req)
+	escalationPolicyID, err := strconv.ParseInt(vars["escalationPolicyID"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	policy := new(database.EscalationPolicy)
+	err = policy.GetEscalationPolicy(controller.DB, escalationPolicyID)
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func decodeEscalationForm(req *http.Request) (*viewmodels.EscalationEditViewModel, error) {
+	err := req.ParseForm()
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := schema.NewDecoder()
+	// Ignore unknown keys to prevent errors from the CSRF token.
+	decoder.IgnoreUnknownKeys(true)
+	formPolicy := new(viewmodels.EscalationEditViewModel)
+	err = decoder.Decode(formPolicy, req.PostForm)
+	if err != nil {
+		return nil, err
+	}
+	return formPolicy, nil
+}
+
+// validateEscalationForm checks the inputs for errors
+func validateEscalationForm(policy *viewmodels.EscalationEditViewModel) (valErrors map[string]string) {
+	valErrors = make(map[string]string)
+
+	_, err := govalidator.ValidateStruct(policy)
+	valErrors = govalidator.ErrorsByField(err)
+
+	validateEscalation(policy, valErrors)
+
+	return valErrors
+}
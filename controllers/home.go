@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"html/template"
 	"net/http"
+	"time"
 
 	"github.com/apexskier/httpauth"
 	"github.com/turnkey-commerce/go-ping-sites/database"
@@ -39,8 +40,23 @@ func (controller *homeController) get(rw http.ResponseWriter, req *http.Request)
 			sites[i].FirstPing = firstPing
 		}
 	}
+	now := time.Now()
+	maintenance, err := database.GetActiveMaintenanceWindows(controller.DB, now)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	dependencies, err := database.GetSiteDependencies(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	lastPingDown, err := database.GetSitesLastPingDown(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
 	messages := controller.authorizer.Messages(rw, req)
 	vm := viewmodels.GetHomeViewModel(sites, isAuthenticated, user, messages)
+	vm.AddDependencies(sites, dependencies, lastPingDown)
+	vm.AddMaintenance(maintenance, now)
 	return http.StatusOK, controller.template.Execute(rw, vm)
 }
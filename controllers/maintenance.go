@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"database/sql"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apexskier/httpauth"
+	"github.com/asaskevich/govalidator"
+	"github.com/gorilla/csrf"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/pinger"
+	"github.com/turnkey-commerce/go-ping-sites/viewmodels"
+)
+
+type maintenanceController struct {
+	DB             *sql.DB
+	getTemplate    *template.Template
+	editTemplate   *template.Template
+	newTemplate    *template.Template
+	deleteTemplate *template.Template
+	authorizer     httpauth.Authorizer
+	pinger         *pinger.Pinger
+}
+
+func (controller *maintenanceController) get(rw http.ResponseWriter, req *http.Request) (int, error) {
+	var windows database.MaintenanceWindows
+	err := windows.GetMaintenanceWindows(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+	vm := viewmodels.GetMaintenanceWindowsViewModel(windows, time.Now(), isAuthenticated, user)
+	return http.StatusOK, controller.getTemplate.Execute(rw, vm)
+}
+
+func (controller *maintenanceController) editGet(rw http.ResponseWriter, req *http.Request) (int, error) {
+	window, err := controller.getWindowFromRoute(req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	windowEdit := new(viewmodels.MaintenanceEditViewModel)
+	viewmodels.MapMaintenanceDBtoVM(window, windowEdit)
+
+	sites, err := controller.getAllSites()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+	vm := viewmodels.EditMaintenanceViewModel(windowEdit, sites, isAuthenticated, user, make(map[string]string))
+	vm.CsrfField = csrf.TemplateField(req)
+	return http.StatusOK, controller.editTemplate.Execute(rw, vm)
+}
+
+func (controller *maintenanceController) editPost(rw http.ResponseWriter, req *http.Request) (int, error) {
+	formWindow, err := decodeMaintenanceForm(req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	valErrors := validateMaintenanceForm(formWindow)
+	if len(valErrors) > 0 {
+		sites, errGet := controller.getAllSites()
+		if errGet != nil {
+			return http.StatusInternalServerError, errGet
+		}
+		isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+		vm := viewmodels.EditMaintenanceViewModel(formWindow, sites, isAuthenticated, user, valErrors)
+		vm.CsrfField = csrf.TemplateField(req)
+		return http.StatusOK, controller.editTemplate.Execute(rw, vm)
+	}
+
+	// Get the maintenance window to update
+	window := new(database.MaintenanceWindow)
+	err = window.GetMaintenanceWindow(controller.DB, formWindow.MaintenanceWindowID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	err = viewmodels.MapMaintenanceVMtoDB(formWindow, window)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	err = window.UpdateMaintenanceWindow(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// Refresh the pinger with the changes.
+	err = controller.pinger.UpdateSiteSettings()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	http.Redirect(rw, req, "/settings/maintenance", http.StatusSeeOther)
+	return http.StatusSeeOther, nil
+}
+
+func (controller *maintenanceController) newGet(rw http.ResponseWriter, req *http.Request) (int, error) {
+	sites, err := controller.getAllSites()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	// Default to a one hour window starting at the next hour.
+	start := time.Now().Truncate(time.Hour).Add(time.Hour)
+	windowEdit := new(viewmodels.MaintenanceEditViewModel)
+	windowEdit.StartTime = start.Format(viewmodels.MaintenanceTimeFormat)
+	windowEdit.EndTime = start.Add(time.Hour).Format(viewmodels.MaintenanceTimeFormat)
+	windowEdit.DurationMinutes = "60"
+	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+	vm := viewmodels.NewMaintenanceViewModel(windowEdit, sites, isAuthenticated, user, make(map[string]string))
+	vm.CsrfField = csrf.TemplateField(req)
+	return http.StatusOK, controller.newTemplate.Execute(rw, vm)
+}
+
+func (controller *maintenanceController) newPost(rw http.ResponseWriter, req *http.Request) (int, error) {
+	formWindow, err := decodeMaintenanceForm(req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	valErrors := validateMaintenanceForm(formWindow)
+	if len(valErrors) > 0 {
+		sites, errGet := controller.getAllSites()
+		if errGet != nil {
+			return http.StatusInternalServerError, errGet
+		}
+		isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+		vm := viewmodels.NewMaintenanceViewModel(formWindow, sites, isAuthenticated, user, valErrors)
+		vm.CsrfField = csrf.TemplateField(req)
+		return http.StatusOK, controller.newTemplate.Execute(rw, vm)
+	}
+
+	window := database.MaintenanceWindow{}
+	err = viewmodels.MapMaintenanceVMtoDB(formWindow, &window)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	err = window.CreateMaintenanceWindow(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// Refresh the pinger with the changes.
+	err = controller.pinger.UpdateSiteSettings()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	http.Redirect(rw, req, "/settings/maintenance", http.StatusSeeOther)
+	return http.StatusSeeOther, nil
+}
+
+func (controller *maintenanceController) deleteGet(rw http.ResponseWriter, req *http.Request) (int, error) {
+	window, err := controller.getWindowFromRoute(req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	windowDelete := new(viewmodels.MaintenanceEditViewModel)
+	viewmodels.MapMaintenanceDBtoVM(window, windowDelete)
+	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+	var noSites = []database.Site{}
+	vm := viewmodels.EditMaintenanceViewModel(windowDelete, noSites, isAuthenticated, user, make(map[string]string))
+	vm.CsrfField = csrf.TemplateField(req)
+	return http.StatusOK, controller.deleteTemplate.Execute(rw, vm)
+}
+
+func (controller *maintenanceController) deletePost(rw http.ResponseWriter, req *http.Request) (int, error) {
+	formWindow, err := decodeMaintenanceForm(req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	window := new(database.MaintenanceWindow)
+	err = window.GetMaintenanceWindow(controller.DB, formWindow.MaintenanceWindowID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	err = window.DeleteMaintenanceWindow(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// Refresh the pinger with the changes.
+	err = controller.pinger.UpdateSiteSettings()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	http.Redirect(rw, req, "/settings/maintenance", http.StatusSeeOther)
+	return http.StatusSeeOther, nil
+}
+
+func (controller *maintenanceController) getWindowFromRoute(req *http.Request) (*database.MaintenanceWindow, error) {
+	vars := mux.Vars(req)
+	maintenanceWindowID, err := strconv.ParseInt(vars["maintenanceWindowID"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	window := new(database.MaintenanceWindow)
+	err = window.GetMaintenanceWindow(controller.DB, maintenanceWindowID)
+	if err != nil {
+		return nil, err
+	}
+	return window, nil
+}
+
+func (controller *maintenanceController) getAllSites() (database.Sites, error) {
+	var sites database.Sites
+	err := sites.GetSites(controller.DB, false, false)
+	if err != nil {
+		return nil, err
+	}
+	return sites, nil
+}
+
+func decodeMaintenanceForm(req *http.Request) (*viewmodels.MaintenanceEditViewModel, error) {
+	err := req.ParseForm()
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := schema.NewDecoder()
+	// Ignore unknown keys to prevent errors from the CSRF token.
+	decoder.IgnoreUnknownKeys(true)
+	formWindow := new(viewmodels.MaintenanceEditViewModel)
+	err = decoder.Decode(formWindow, req.PostForm)
+	if err != nil {
+		return nil, err
+	}
+	return formWindow, nil
+}
+
+// validateMaintenanceForm checks the inputs for errors
+func validateMaintenanceForm(window *viewmodels.MaintenanceEditViewModel) (valErrors map[string]string) {
+	valErrors = make(map[string]string)
+
+	_, err := govalidator.ValidateStruct(window)
+	valErrors = govalidator.ErrorsByField(err)
+
+	validateMaintenance(window, valErrors)
+
+	return valErrors
+}
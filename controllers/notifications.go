@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"database/sql"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/apexskier/httpauth"
+	"github.com/gorilla/schema"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/pinger"
+	"github.com/turnkey-commerce/go-ping-sites/viewmodels"
+)
+
+// notificationsLimit is the most delivery attempts shown on the notifications page.
+const notificationsLimit = 500
+
+type notificationsController struct {
+	DB          *sql.DB
+	getTemplate *template.Template
+	authorizer  httpauth.Authorizer
+	pinger      *pinger.Pinger
+}
+
+func (controller *notificationsController) get(rw http.ResponseWriter, req *http.Request) (int, error) {
+	filter, err := decodeNotificationsFilter(req)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	var attempts database.DeliveryAttempts
+	err = attempts.GetDeliveryAttempts(controller.DB,
+		viewmodels.MapNotificationsFilterVMtoDB(*filter, notificationsLimit))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	var sites database.Sites
+	err = sites.GetSites(controller.DB, false, false)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+	vm := viewmodels.GetNotificationsViewModel(attempts, *filter, sites, controller.channels(),
+		isAuthenticated, user)
+	return http.StatusOK, controller.getTemplate.Execute(rw, vm)
+}
+
+// channels returns the channels that the delivery log can be filtered by.
+func (controller *notificationsController) channels() []viewmodels.NotificationChannelViewModel {
+	var channels []viewmodels.NotificationChannelViewModel
+	for channelType, channel := range controller.pinger.Channels {
+		channels = append(channels, viewmodels.NotificationChannelViewModel{ChannelType: channelType,
+			Name: channel.Name()})
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i].Name < channels[j].Name })
+	return channels
+}
+
+func decodeNotificationsFilter(req *http.Request) (*viewmodels.NotificationsFilterViewModel, error) {
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+	filter := new(viewmodels.NotificationsFilterViewModel)
+	err := decoder.Decode(filter, req.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+	filter.Recipient = strings.TrimSpace(filter.Recipient)
+	return filter, nil
+}
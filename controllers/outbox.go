@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"database/sql"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/apexskier/httpauth"
+	"github.com/gorilla/csrf"
+	"github.com/gorilla/mux"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+	"github.com/turnkey-commerce/go-ping-sites/viewmodels"
+)
+
+// outboxLimit is the most messages shown on the outbox page.
+const outboxLimit = 200
+
+type outboxController struct {
+	DB              *sql.DB
+	getTemplate     *template.Template
+	detailsTemplate *template.Template
+	authorizer      httpauth.Authorizer
+}
+
+func (controller *outboxController) get(rw http.ResponseWriter, req *http.Request) (int, error) {
+	status := req.URL.Query().Get("status")
+	if !isOutboxStatus(status) {
+		status = ""
+	}
+	var messages database.OutboxMessages
+	err := messages.GetOutboxMessages(controller.DB, status, outboxLimit)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+	vm := viewmodels.GetOutboxViewModel(messages, status, isAuthenticated, user)
+	return http.StatusOK, controller.getTemplate.Execute(rw, vm)
+}
+
+func (controller *outboxController) getDetails(rw http.ResponseWriter, req *http.Request) (int, error) {
+	message, err := controller.getOutboxMessageFromRoute(req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+	vm := viewmodels.GetOutboxMessageDetailsViewModel(*message, isAuthenticated, user)
+	vm.CsrfField = csrf.TemplateField(req)
+	return http.StatusOK, controller.detailsTemplate.Execute(rw, vm)
+}
+
+func (controller *outboxController) resendPost(rw http.ResponseWriter, req *http.Request) (int, error) {
+	message, err := controller.getOutboxMessageFromRoute(req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	err = message.ResendOutboxMessage(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	notifier.WakeOutbox()
+
+	http.Redirect(rw, req, "/settings/outbox", http.StatusSeeOther)
+	return http.StatusSeeOther, nil
+}
+
+func (controller *outboxController) getOutboxMessageFromRoute(req *http.Request) (*database.OutboxMessage, error) {
+	vars := mux.Vars(req)
+	outboxMessageID, err := strconv.ParseInt(vars["outboxMessageID"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	message := new(database.OutboxMessage)
+	err = message.GetOutboxMessage(controller.DB, outboxMessageID)
+	if err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func isOutboxStatus(status string) bool {
+	for _, s := range viewmodels.OutboxStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
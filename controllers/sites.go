@@ -6,12 +6,12 @@ import (
 	"net/http"
 	"strconv"
 
-	"github.com/apexskier/httpauth"
 	"github.com/asaskevich/govalidator"
 	"github.com/gorilla/csrf"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/schema"
 	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
 	"github.com/turnkey-commerce/go-ping-sites/pinger"
 	"github.com/turnkey-commerce/go-ping-sites/viewmodels"
 )
@@ -22,31 +22,82 @@ type sitesController struct {
 	editTemplate           *template.Template
 	newTemplate            *template.Template
 	changeContactsTemplate *template.Template
-	authorizer             httpauth.Authorizer
+	authorizer             CurrentUserGetter
 	pinger                 *pinger.Pinger
 }
 
 func (controller *sitesController) getDetails(rw http.ResponseWriter, req *http.Request) (int, error) {
+	vm, _, err := controller.getDetailsViewModel(rw, req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, controller.detailsTemplate.Execute(rw, vm)
+}
+
+// testPost sends a test message to each of the active channels of the site and
+// shows the results on the details page.
+func (controller *sitesController) testPost(rw http.ResponseWriter, req *http.Request) (int, error) {
+	vm, site, err := controller.getDetailsViewModel(rw, req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	n := notifier.NewTestNotifier(*site, controller.pinger.Channels)
+	for _, endpoint := range site.Channels {
+		if endpoint.IsActive {
+			vm.TestResults = append(vm.TestResults, testChannel(controller.DB, n, site.Name,
+				endpoint.ChannelType, endpoint.Address))
+		}
+	}
+	return http.StatusOK, controller.detailsTemplate.Execute(rw, vm)
+}
+
+// getDetailsViewModel loads the site in the request with its contacts, parents
+// and channels for the details page.
+func (controller *sitesController) getDetailsViewModel(rw http.ResponseWriter,
+	req *http.Request) (viewmodels.SiteViewModel, *database.Site, error) {
+	var vm viewmodels.SiteViewModel
 	vars := mux.Vars(req)
 	siteID, err := strconv.ParseInt(vars["siteID"], 10, 64)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return vm, nil, err
 	}
 
 	site := new(database.Site)
 	err = site.GetSite(controller.DB, siteID)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return vm, nil, err
 	}
 	// Also get the contacts to display in a table.
 	err = site.GetSiteContacts(controller.DB, siteID)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return vm, nil, err
+	}
+	err = site.GetSiteParents(controller.DB)
+	if err != nil {
+		return vm, nil, err
+	}
+	parents, err := controller.getParentSites(site.SiteID, site.ParentSiteIDs)
+	if err != nil {
+		return vm, nil, err
+	}
+	err = site.GetSiteChannels(controller.DB)
+	if err != nil {
+		return vm, nil, err
 	}
 
 	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
-	vm := viewmodels.GetSiteDetailsViewModel(site, isAuthenticated, user)
-	return http.StatusOK, controller.detailsTemplate.Execute(rw, vm)
+	vm = viewmodels.GetSiteDetailsViewModel(site, isAuthenticated, user)
+	vm.AllParents = parents
+	if site.EscalationPolicyID != 0 {
+		policy := new(database.EscalationPolicy)
+		err = policy.GetEscalationPolicy(controller.DB, site.EscalationPolicyID)
+		if err != nil {
+			return vm, nil, err
+		}
+		vm.Site.EscalationPolicy = policy.Name
+	}
+	vm.CsrfField = csrf.TemplateField(req)
+	return vm, site, nil
 }
 
 func (controller *sitesController) editGet(rw http.ResponseWriter, req *http.Request) (int, error) {
@@ -61,6 +112,10 @@ func (controller *sitesController) editGet(rw http.ResponseWriter, req *http.Req
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
+	err = site.GetSiteChannels(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 	// Get all of the contacts to display in the table.
 	var contacts database.Contacts
 	err = contacts.GetContacts(controller.DB)
@@ -74,8 +129,23 @@ func (controller *sitesController) editGet(rw http.ResponseWriter, req *http.Req
 	}
 
 	selectedContacts := []int64{}
+	subscriptions := make(map[int64]database.Subscription)
 	for _, contact := range site.Contacts {
 		selectedContacts = append(selectedContacts, contact.ContactID)
+		subscriptions[contact.ContactID] = contact.Subscription
+	}
+	// And the parent sites it depends on.
+	err = site.GetSiteParents(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	parents, err := controller.getParentSites(site.SiteID, site.ParentSiteIDs)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	policies, err := controller.getEscalationPolicies(site.EscalationPolicyID)
+	if err != nil {
+		return http.StatusInternalServerError, err
 	}
 
 	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
@@ -83,8 +153,11 @@ func (controller *sitesController) editGet(rw http.ResponseWriter, req *http.Req
 	viewmodels.MapSiteDBtoVM(site, siteEdit)
 
 	siteEdit.SelectedContacts = selectedContacts
+	siteEdit.Subscriptions = viewmodels.MapSubscriptionsDBtoVM(subscriptions)
 
 	vm := viewmodels.EditSiteViewModel(siteEdit, contacts, isAuthenticated, user, make(map[string]string))
+	vm.AllParents = parents
+	vm.AllPolicies = policies
 	vm.CsrfField = csrf.TemplateField(req)
 	return http.StatusOK, controller.editTemplate.Execute(rw, vm)
 }
@@ -105,6 +178,11 @@ func (controller *sitesController) editPost(rw http.ResponseWriter, req *http.Re
 	}
 
 	valErrors := validateSiteForm(formSite)
+	dependencies, err := database.GetSiteDependencies(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	validateSiteParents(formSite, dependencies, valErrors)
 	if len(valErrors) > 0 {
 		isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
 		var contacts database.Contacts
@@ -112,7 +190,17 @@ func (controller *sitesController) editPost(rw http.ResponseWriter, req *http.Re
 		if err != nil {
 			return http.StatusInternalServerError, err
 		}
+		parents, errGet := controller.getParentSites(formSite.SiteID, formSite.SelectedParents)
+		if errGet != nil {
+			return http.StatusInternalServerError, errGet
+		}
+		policies, errGet := controller.getEscalationPolicies(formSite.EscalationPolicyID)
+		if errGet != nil {
+			return http.StatusInternalServerError, errGet
+		}
 		vm := viewmodels.EditSiteViewModel(formSite, contacts, isAuthenticated, user, valErrors)
+		vm.AllParents = parents
+		vm.AllPolicies = policies
 		vm.CsrfField = csrf.TemplateField(req)
 		return http.StatusOK, controller.editTemplate.Execute(rw, vm)
 	}
@@ -123,6 +211,10 @@ func (controller *sitesController) editPost(rw http.ResponseWriter, req *http.Re
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
+	err = site.GetSiteChannels(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 
 	err = viewmodels.MapSiteVMtoDB(formSite, site)
 	if err != nil {
@@ -152,6 +244,19 @@ func (controller *sitesController) editPost(rw http.ResponseWriter, req *http.Re
 			}
 		}
 	}
+	err = setSiteSubscriptions(controller, site, formSite)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	err = site.SetSiteParents(controller.DB, formSite.SelectedParents)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	err = site.SetSiteChannels(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 
 	// Refresh the pinger with the changes.
 	err = controller.pinger.UpdateSiteSettings()
@@ -177,7 +282,17 @@ func (controller *sitesController) newGet(rw http.ResponseWriter, req *http.Requ
 	siteNew.PingIntervalSeconds = "60"
 	siteNew.TimeoutSeconds = "15"
 	siteNew.SelectedContacts = []int64{}
+	parents, err := controller.getParentSites(0, nil)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	policies, err := controller.getEscalationPolicies(0)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 	vm := viewmodels.NewSiteViewModel(siteNew, contacts, isAuthenticated, user, make(map[string]string))
+	vm.AllParents = parents
+	vm.AllPolicies = policies
 	vm.CsrfField = csrf.TemplateField(req)
 	return http.StatusOK, controller.newTemplate.Execute(rw, vm)
 }
@@ -198,6 +313,11 @@ func (controller *sitesController) newPost(rw http.ResponseWriter, req *http.Req
 	}
 
 	valErrors := validateSiteForm(formSite)
+	dependencies, err := database.GetSiteDependencies(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	validateSiteParents(formSite, dependencies, valErrors)
 	if len(valErrors) > 0 {
 		isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
 		var contacts database.Contacts
@@ -205,7 +325,17 @@ func (controller *sitesController) newPost(rw http.ResponseWriter, req *http.Req
 		if err != nil {
 			return http.StatusInternalServerError, err
 		}
+		parents, errGet := controller.getParentSites(formSite.SiteID, formSite.SelectedParents)
+		if errGet != nil {
+			return http.StatusInternalServerError, errGet
+		}
+		policies, errGet := controller.getEscalationPolicies(formSite.EscalationPolicyID)
+		if errGet != nil {
+			return http.StatusInternalServerError, errGet
+		}
 		vm := viewmodels.NewSiteViewModel(formSite, contacts, isAuthenticated, user, valErrors)
+		vm.AllParents = parents
+		vm.AllPolicies = policies
 		vm.CsrfField = csrf.TemplateField(req)
 		return http.StatusOK, controller.newTemplate.Execute(rw, vm)
 	}
@@ -224,6 +354,19 @@ func (controller *sitesController) newPost(rw http.ResponseWriter, req *http.Req
 			return http.StatusInternalServerError, err
 		}
 	}
+	err = setSiteSubscriptions(controller, &site, formSite)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	err = site.SetSiteParents(controller.DB, formSite.SelectedParents)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	err = site.SetSiteChannels(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 
 	// Refresh the pinger with the changes.
 	err = controller.pinger.UpdateSiteSettings()
@@ -235,14 +378,74 @@ func (controller *sitesController) newPost(rw http.ResponseWriter, req *http.Req
 	return http.StatusSeeOther, nil
 }
 
+// acknowledgePost acknowledges the outage of the site as the current user.
+func (controller *sitesController) acknowledgePost(rw http.ResponseWriter, req *http.Request) (int, error) {
+	vars := mux.Vars(req)
+	siteID, err := strconv.ParseInt(vars["siteID"], 10, 64)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	site := new(database.Site)
+	err = site.GetSite(controller.DB, siteID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !site.IsSiteUp && site.AcknowledgedBy == "" {
+		_, user := getCurrentUser(rw, req, controller.authorizer)
+		err = acknowledgeSite(controller.pinger, site, user.Username)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+
+	http.Redirect(rw, req, "/settings/sites/"+vars["siteID"], http.StatusSeeOther)
+	return http.StatusSeeOther, nil
+}
+
+// getParentSites gets the sites that can be chosen as parents of the site.
+func (controller *sitesController) getParentSites(siteID int64, parentSiteIDs []int64) ([]viewmodels.SiteParentViewModel, error) {
+	var sites database.Sites
+	err := sites.GetSites(controller.DB, false, false)
+	if err != nil {
+		return nil, err
+	}
+	return viewmodels.PopulateParentSitesVM(sites, siteID, parentSiteIDs), nil
+}
+
+// getEscalationPolicies gets the escalation policies that can be chosen for the site.
+func (controller *sitesController) getEscalationPolicies(escalationPolicyID int64) ([]viewmodels.EscalationPolicyOptionViewModel, error) {
+	var policies database.EscalationPolicies
+	err := policies.GetEscalationPolicies(controller.DB)
+	if err != nil {
+		return nil, err
+	}
+	return viewmodels.PopulateEscalationPoliciesVM(policies, escalationPolicyID), nil
+}
+
 //validateSiteForm checks the inputs for errors
 func validateSiteForm(site *viewmodels.SitesEditViewModel) (valErrors map[string]string) {
 	valErrors = make(map[string]string)
 	_, err := govalidator.ValidateStruct(site)
 	valErrors = govalidator.ErrorsByField(err)
+
+	validateSite(site, valErrors)
+
 	return valErrors
 }
 
+// setSiteSubscriptions saves what each of the contacts selected on the form is
+// notified of for the site.
+func setSiteSubscriptions(controller *sitesController, site *database.Site, formSite *viewmodels.SitesEditViewModel) error {
+	for _, contactSelID := range formSite.SelectedContacts {
+		err := site.SetContactSubscription(controller.DB, contactSelID,
+			viewmodels.MapSubscriptionVMtoDB(formSite.Subscriptions, contactSelID))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func int64InSlice(a int64, list []int64) bool {
 	for _, b := range list {
 		if b == a {
@@ -251,3 +454,12 @@ func int64InSlice(a int64, list []int64) bool {
 	}
 	return false
 }
+
+func stringInSlice(a string, list []string) bool {
+	for _, b := range list {
+		if b == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"html/template"
+	"net/http"
+	"sort"
+
+	"github.com/apexskier/httpauth"
+	"github.com/asaskevich/govalidator"
+	"github.com/gorilla/csrf"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+	"github.com/turnkey-commerce/go-ping-sites/pinger"
+	"github.com/turnkey-commerce/go-ping-sites/viewmodels"
+)
+
+type templatesController struct {
+	DB             *sql.DB
+	getTemplate    *template.Template
+	editTemplate   *template.Template
+	deleteTemplate *template.Template
+	authorizer     httpauth.Authorizer
+	pinger         *pinger.Pinger
+}
+
+func (controller *templatesController) get(rw http.ResponseWriter, req *http.Request) (int, error) {
+	var templates database.NotificationTemplates
+	err := templates.GetNotificationTemplates(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+	vm := viewmodels.GetTemplatesViewModel(templates, controller.channels(), isAuthenticated, user)
+	return http.StatusOK, controller.getTemplate.Execute(rw, vm)
+}
+
+func (controller *templatesController) editGet(rw http.ResponseWriter, req *http.Request) (int, error) {
+	t, channel, isCustom, err := controller.getTemplateFromRoute(req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	templateEdit := new(viewmodels.TemplateEditViewModel)
+	viewmodels.MapTemplateDBtoVM(t, templateEdit)
+	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+	vm := viewmodels.EditTemplateViewModel(templateEdit, channel, isCustom, isAuthenticated, user,
+		make(map[string]string))
+	vm.CsrfField = csrf.TemplateField(req)
+	return http.StatusOK, controller.editTemplate.Execute(rw, vm)
+}
+
+func (controller *templatesController) editPost(rw http.ResponseWriter, req *http.Request) (int, error) {
+	t, channel, isCustom, err := controller.getTemplateFromRoute(req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	formTemplate, err := decodeTemplateForm(req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	// The channel and event are from the route rather than the form.
+	formTemplate.ChannelType = mux.Vars(req)["channelType"]
+	formTemplate.Event = t.Event
+
+	valErrors := validateTemplateForm(formTemplate)
+	if len(valErrors) > 0 || formTemplate.Preview != "" {
+		isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+		vm := viewmodels.EditTemplateViewModel(formTemplate, channel, isCustom, isAuthenticated, user, valErrors)
+		if len(valErrors) == 0 {
+			vm.Preview, err = previewTemplate(formTemplate)
+			if err != nil {
+				return http.StatusInternalServerError, err
+			}
+		}
+		vm.CsrfField = csrf.TemplateField(req)
+		return http.StatusOK, controller.editTemplate.Execute(rw, vm)
+	}
+
+	viewmodels.MapTemplateVMtoDB(formTemplate, t)
+	err = t.SaveNotificationTemplate(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// Refresh the pinger with the changes.
+	err = controller.pinger.UpdateSiteSettings()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	http.Redirect(rw, req, "/settings/templates", http.StatusSeeOther)
+	return http.StatusSeeOther, nil
+}
+
+func (controller *templatesController) deleteGet(rw http.ResponseWriter, req *http.Request) (int, error) {
+	t, channel, isCustom, err := controller.getTemplateFromRoute(req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	templateDelete := new(viewmodels.TemplateEditViewModel)
+	viewmodels.MapTemplateDBtoVM(t, templateDelete)
+	isAuthenticated, user := getCurrentUser(rw, req, controller.authorizer)
+	vm := viewmodels.EditTemplateViewModel(templateDelete, channel, isCustom, isAuthenticated, user,
+		make(map[string]string))
+	vm.CsrfField = csrf.TemplateField(req)
+	return http.StatusOK, controller.deleteTemplate.Execute(rw, vm)
+}
+
+func (controller *templatesController) deletePost(rw http.ResponseWriter, req *http.Request) (int, error) {
+	t, _, _, err := controller.getTemplateFromRoute(req)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	err = t.DeleteNotificationTemplate(controller.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// Refresh the pinger with the changes.
+	err = controller.pinger.UpdateSiteSettings()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	http.Redirect(rw, req, "/settings/templates", http.StatusSeeOther)
+	return http.StatusSeeOther, nil
+}
+
+// channels returns the channels that can have their own templates, after the
+// templates for all of the channels.
+func (controller *templatesController) channels() []viewmodels.TemplateChannelViewModel {
+	var channelTypes []string
+	for channelType := range controller.pinger.Channels {
+		channelTypes = append(channelTypes, channelType)
+	}
+	sort.Strings(channelTypes)
+	channels := []viewmodels.TemplateChannelViewModel{{ChannelType: viewmodels.AllChannels, Name: "All Channels"}}
+	for _, channelType := range channelTypes {
+		channels = append(channels, viewmodels.TemplateChannelViewModel{ChannelType: channelType,
+			Name: controller.pinger.Channels[channelType].Name()})
+	}
+	return channels
+}
+
+// getTemplateFromRoute returns the template of the channel and event in the
+// route, which is the default wording if it hasn't been customized.
+func (controller *templatesController) getTemplateFromRoute(req *http.Request) (*database.NotificationTemplate,
+	viewmodels.TemplateChannelViewModel, bool, error) {
+	vars := mux.Vars(req)
+	var channel viewmodels.TemplateChannelViewModel
+	for _, ch := range controller.channels() {
+		if ch.ChannelType == vars["channelType"] {
+			channel = ch
+		}
+	}
+	if channel.ChannelType == "" {
+		return nil, channel, false, errors.New("unknown channel type " + vars["channelType"])
+	}
+	if !isTemplateEvent(vars["event"]) {
+		return nil, channel, false, errors.New("unknown notification event " + vars["event"])
+	}
+	channelType := channel.ChannelType
+	if channelType == viewmodels.AllChannels {
+		channelType = ""
+	}
+	t := new(database.NotificationTemplate)
+	err := t.GetNotificationTemplate(controller.DB, channelType, vars["event"])
+	if err == sql.ErrNoRows {
+		*t = notifier.DefaultTemplate(channelType, vars["event"])
+		return t, channel, false, nil
+	}
+	if err != nil {
+		return nil, channel, false, err
+	}
+	return t, channel, true, nil
+}
+
+func isTemplateEvent(event string) bool {
+	for _, e := range database.TemplateEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// previewTemplate renders the template for a notification about an example site.
+func previewTemplate(templateVM *viewmodels.TemplateEditViewModel) (*viewmodels.TemplatePreviewViewModel, error) {
+	t := database.NotificationTemplate{}
+	viewmodels.MapTemplateVMtoDB(templateVM, &t)
+	subject, body, err := notifier.RenderTemplate(t, notifier.NewPreviewNotifier(t.Event).TemplateData())
+	if err != nil {
+		return nil, err
+	}
+	return &viewmodels.TemplatePreviewViewModel{Subject: subject, Body: body}, nil
+}
+
+func decodeTemplateForm(req *http.Request) (*viewmodels.TemplateEditViewModel, error) {
+	err := req.ParseForm()
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := schema.NewDecoder()
+	// Ignore unknown keys to prevent errors from the CSRF token.
+	decoder.IgnoreUnknownKeys(true)
+	formTemplate := new(viewmodels.TemplateEditViewModel)
+	err = decoder.Decode(formTemplate, req.PostForm)
+	if err != nil {
+		return nil, err
+	}
+	return formTemplate, nil
+}
+
+// validateTemplateForm checks the inputs for errors
+func validateTemplateForm(templateVM *viewmodels.TemplateEditViewModel) (valErrors map[string]string) {
+	valErrors = make(map[string]string)
+
+	_, err := govalidator.ValidateStruct(templateVM)
+	valErrors = govalidator.ErrorsByField(err)
+
+	validateTemplate(templateVM, valErrors)
+
+	return valErrors
+}
@@ -2,9 +2,15 @@ package controllers
 
 import (
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
+	"github.com/asaskevich/govalidator"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+	"github.com/turnkey-commerce/go-ping-sites/pinger"
 	"github.com/turnkey-commerce/go-ping-sites/viewmodels"
 )
 
@@ -27,10 +33,209 @@ func validateContact(contact *viewmodels.ContactsEditViewModel, valErrors map[st
 	if contact.SmsActive && len(strings.TrimSpace(contact.SmsNumber)) == 0 {
 		valErrors["SmsNumber"] = "Text Message Number must be provided if it is active."
 	}
+	validateWebhook("SlackWebhook", "Slack Webhook URL", contact.SlackWebhook, contact.SlackActive, valErrors)
+	validateWebhook("TeamsWebhook", "Teams Webhook URL", contact.TeamsWebhook, contact.TeamsActive, valErrors)
+	validateWebhook("DiscordWebhook", "Discord Webhook URL", contact.DiscordWebhook, contact.DiscordActive, valErrors)
+	validatePushAddress("TelegramChatID", "Telegram Chat ID", contact.TelegramChatID, contact.TelegramActive,
+		`^(-?[0-9]+|@[A-Za-z0-9_]{5,})$`, "a number or a @channelusername", valErrors)
+	validatePushAddress("MatrixRoom", "Matrix Room ID", contact.MatrixRoom, contact.MatrixActive,
+		`^![^:\s]+:\S+$`, "like !roomid:matrix.org", valErrors)
+	validatePushAddress("NtfyTopic", "ntfy Topic", contact.NtfyTopic, contact.NtfyActive,
+		`^[A-Za-z0-9_-]{1,64}$`, "up to 64 letters, digits, dashes and underscores", valErrors)
+	validatePushAddress("GotifyToken", "Gotify Application Token", contact.GotifyToken, contact.GotifyActive,
+		`^[A-Za-z0-9._-]+$`, "the token of a Gotify application", valErrors)
 	if contact.SmsActive && len(strings.TrimSpace(contact.SmsNumber)) > 0 {
 		var validSmsNumber = regexp.MustCompile(`^\+?[1-9][0-9]{1,14}$`)
 		if !validSmsNumber.MatchString(contact.SmsNumber) {
 			valErrors["SmsNumber"] = "The Text Message Number must be provided in E.164 format. For example in the USA it would be +15125551212."
 		}
 	}
+	if contact.VoiceActive {
+		var validVoiceNumber = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+		if !validVoiceNumber.MatchString(strings.TrimSpace(contact.VoiceNumber)) {
+			valErrors["VoiceNumber"] = "The Voice Call Number must be provided in E.164 format if it is active. For example in the USA it would be +15125551212."
+		}
+	}
+	if contact.Digest != "" {
+		if !stringInSlice(contact.Digest, database.DigestFrequencies) {
+			valErrors["Digest"] = "Summary Email must be daily, weekly or monthly."
+		} else if !contact.EmailActive {
+			valErrors["Digest"] = "Summary Email needs the Email to be active."
+		}
+	}
+	validateSchedules(contact, valErrors)
+	validateSubscriptions(contact.Subscriptions, contact.SelectedSites, valErrors)
+}
+
+// validateSubscriptions checks that each of the selected sites or contacts is
+// notified of at least one event on at least one channel.
+func validateSubscriptions(subs []viewmodels.SubscriptionViewModel, selectedIDs []int64, valErrors map[string]string) {
+	for _, sub := range subs {
+		if !int64InSlice(sub.ID, selectedIDs) {
+			continue
+		}
+		if len(sub.Events) == 0 || len(sub.ChannelTypes) == 0 {
+			valErrors["Subscriptions"] = "At least one event and one channel must be chosen for each assigned contact and site."
+		}
+	}
+}
+
+// validateSchedules validates the time zone and the notification hours of the
+// contact.
+func validateSchedules(contact *viewmodels.ContactsEditViewModel, valErrors map[string]string) {
+	if tz := strings.TrimSpace(contact.Timezone); tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			valErrors["Timezone"] = "Time Zone must be a name such as America/Chicago or UTC."
+		}
+	}
+	for _, sch := range contact.Schedules {
+		if !sch.HasSchedule() {
+			continue
+		}
+		_, errStart := time.Parse(database.ScheduleTimeFormat, strings.TrimSpace(sch.StartTime))
+		_, errEnd := time.Parse(database.ScheduleTimeFormat, strings.TrimSpace(sch.EndTime))
+		if errStart != nil || errEnd != nil {
+			valErrors["Schedules"] = "Notification Hours must have both a start and an end time such as 08:00 and 22:00."
+		} else if len(sch.Days) == 0 {
+			valErrors["Schedules"] = "Notification Hours must have at least one day chosen."
+		}
+	}
+}
+
+func validateSite(site *viewmodels.SitesEditViewModel, valErrors map[string]string) {
+	url := strings.TrimSpace(site.URL)
+	if len(url) > 0 && !govalidator.IsURL(url) && !pinger.IsProtocolURL(url) {
+		valErrors["URL"] = "URL must be a web address or an smtp, imap or ftp server address such as smtp://mail.example.com:587?starttls=true."
+	}
+	if strings.HasPrefix(strings.TrimSpace(site.ReminderMinutes), "-") {
+		valErrors["ReminderMinutes"] = "Reminder Interval must not be negative."
+	}
+	if strings.HasPrefix(strings.TrimSpace(site.MaxReminders), "-") {
+		valErrors["MaxReminders"] = "Maximum Reminders must not be negative."
+	}
+	validateWebhook("SlackWebhook", "Slack Webhook URL", site.SlackWebhook, false, valErrors)
+	validateWebhook("TeamsWebhook", "Teams Webhook URL", site.TeamsWebhook, false, valErrors)
+	validateIntegrationKey("PagerDutyKey", "PagerDuty Routing Key", site.PagerDutyKey, valErrors)
+	validateIntegrationKey("OpsgenieKey", "Opsgenie API Key", site.OpsgenieKey, valErrors)
+	validateSubscriptions(site.Subscriptions, site.SelectedContacts, valErrors)
+	if len(strings.TrimSpace(site.TransactionSteps)) > 0 {
+		if _, err := pinger.ParseTransaction(site.TransactionSteps); err != nil {
+			valErrors["TransactionSteps"] = "Transaction Steps are not valid: " + err.Error()
+		}
+	}
+}
+
+// validateWebhook checks that the webhook is an http or https URL, and that it
+// is provided if it is active.
+func validateWebhook(field string, label string, webhook string, isActive bool, valErrors map[string]string) {
+	webhook = strings.TrimSpace(webhook)
+	if len(webhook) == 0 {
+		if isActive {
+			valErrors[field] = label + " must be provided if it is active."
+		}
+		return
+	}
+	if !govalidator.IsRequestURL(webhook) ||
+		!(strings.HasPrefix(webhook, "https://") || strings.HasPrefix(webhook, "http://")) {
+		valErrors[field] = label + " must be a web address such as https://hooks.slack.com/services/..."
+	}
+}
+
+// validatePushAddress checks that the address of a push channel matches the
+// format of the service, and that it is provided if it is active.
+func validatePushAddress(field string, label string, address string, isActive bool,
+	format string, description string, valErrors map[string]string) {
+	address = strings.TrimSpace(address)
+	if len(address) == 0 {
+		if isActive {
+			valErrors[field] = label + " must be provided if it is active."
+		}
+		return
+	}
+	if !regexp.MustCompile(format).MatchString(address) {
+		valErrors[field] = label + " must be " + description + "."
+	}
+}
+
+// validateIntegrationKey checks that the key of an incident management service
+// only has the letters, digits and dashes that the services use.
+func validateIntegrationKey(field string, label string, key string, valErrors map[string]string) {
+	var validKey = regexp.MustCompile(`^[A-Za-z0-9-]*$`)
+	if !validKey.MatchString(strings.TrimSpace(key)) {
+		valErrors[field] = label + " must only contain letters, digits and dashes."
+	}
+}
+
+func validateMaintenance(window *viewmodels.MaintenanceEditViewModel, valErrors map[string]string) {
+	if window.IsRecurring {
+		if err := database.ValidateCronSchedule(window.CronSchedule); err != nil {
+			valErrors["CronSchedule"] = "Schedule is not valid: " + err.Error()
+		}
+		duration, err := strconv.Atoi(strings.TrimSpace(window.DurationMinutes))
+		if err != nil || duration < 1 {
+			valErrors["DurationMinutes"] = "Duration must be a whole number of minutes greater than 0."
+		}
+		return
+	}
+	start, err := time.ParseInLocation(viewmodels.MaintenanceTimeFormat, window.StartTime, time.Local)
+	if err != nil {
+		valErrors["StartTime"] = "Start Time must be provided."
+	}
+	end, err := time.ParseInLocation(viewmodels.MaintenanceTimeFormat, window.EndTime, time.Local)
+	if err != nil {
+		valErrors["EndTime"] = "End Time must be provided."
+		return
+	}
+	if !end.After(start) {
+		valErrors["EndTime"] = "End Time must be after the Start Time."
+	}
+}
+
+// validateEscalation checks that the policy has a tier with contacts and that
+// each of the later tiers is notified after the one before it.
+func validateEscalation(policy *viewmodels.EscalationEditViewModel, valErrors map[string]string) {
+	tiers := policy.EscalationTiers()
+	if len(tiers) == 0 {
+		valErrors["Tiers"] = "At least one tier must have contacts."
+		return
+	}
+	if len(policy.Tiers[0].ContactIDs) == 0 {
+		valErrors["Tiers"] = "The first tier must have contacts."
+		return
+	}
+	previous := 0
+	for i, tier := range tiers[1:] {
+		delay, err := strconv.Atoi(strings.TrimSpace(tier.DelayMinutes))
+		if err != nil || delay <= previous {
+			valErrors["Tiers"] = "The delay of tier " + strconv.Itoa(i+2) +
+				" must be a whole number of minutes greater than the tier before it."
+			return
+		}
+		previous = delay
+	}
+}
+
+// validateTemplate checks that the subject and body are templates that can be
+// executed for a notification of the event, which catches the unknown fields.
+func validateTemplate(templateVM *viewmodels.TemplateEditViewModel, valErrors map[string]string) {
+	data := notifier.NewPreviewNotifier(templateVM.Event).TemplateData()
+	subject := database.NotificationTemplate{Subject: templateVM.Subject}
+	if _, _, err := notifier.RenderTemplate(subject, data); err != nil {
+		valErrors["Subject"] = "The subject isn't a valid template: " + err.Error()
+	}
+	body := database.NotificationTemplate{Body: templateVM.Body}
+	if _, _, err := notifier.RenderTemplate(body, data); err != nil {
+		valErrors["Body"] = "The body isn't a valid template: " + err.Error()
+	}
+}
+
+func validateSiteParents(site *viewmodels.SitesEditViewModel, dependencies database.SiteDependencies,
+	valErrors map[string]string) {
+	for _, parentSiteID := range site.SelectedParents {
+		if parentSiteID == site.SiteID ||
+			(site.SiteID != 0 && dependencies.DependsOn(parentSiteID, site.SiteID)) {
+			valErrors["SelectedParents"] = "Parent Sites can't include the site itself or a site that depends on it."
+			return
+		}
+	}
 }
@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/turnkey-commerce/go-ping-sites/database"
 	"github.com/turnkey-commerce/go-ping-sites/viewmodels"
 )
 
@@ -117,3 +118,260 @@ func TestValidateContactValid(t *testing.T) {
 		t.Error("No errors should be flagged for the set of inputs.")
 	}
 }
+
+func TestValidateSiteURL(t *testing.T) {
+	s := new(viewmodels.SitesEditViewModel)
+	s.Name = "Mail Relay"
+	s.PingIntervalSeconds = "60"
+	s.TimeoutSeconds = "15"
+
+	s.URL = "not a url"
+	valErrors := validateSiteForm(s)
+	if !strings.Contains(valErrors["URL"], "URL must be a web address") {
+		t.Error("URL Validation should show error for invalid URL.")
+	}
+
+	for _, url := range []string{"http://www.example.com", "smtp://mail.example.com:587?starttls=true",
+		"imaps://mail.example.com", "ftp://ftp.example.com"} {
+		s.URL = url
+		valErrors = validateSiteForm(s)
+		if len(valErrors) > 0 {
+			t.Error("No errors should be flagged for the URL", url, valErrors)
+		}
+	}
+
+	s.URL = "http://www.example.com"
+	s.TransactionSteps = "[[Steps]]\nURL = "
+	valErrors = validateSiteForm(s)
+	if !strings.Contains(valErrors["TransactionSteps"], "Transaction Steps are not valid") {
+		t.Error("Transaction Steps Validation should show error for invalid TOML.")
+	}
+}
+
+// TestValidateMaintenance tests the validation of the one-off and recurring windows.
+func TestValidateMaintenance(t *testing.T) {
+	m := new(viewmodels.MaintenanceEditViewModel)
+	m.Description = "Deploy"
+	m.StartTime = "2016-03-01T22:00"
+	m.EndTime = "2016-03-01T21:00"
+	valErrors := validateMaintenanceForm(m)
+	if valErrors["EndTime"] != "End Time must be after the Start Time." {
+		t.Error("Maintenance Validation should show error for end before start.")
+	}
+
+	m.EndTime = "2016-03-01T23:00"
+	valErrors = validateMaintenanceForm(m)
+	if len(valErrors) > 0 {
+		t.Error("No errors should be flagged for the one-off window", valErrors)
+	}
+
+	m.IsRecurring = true
+	m.CronSchedule = "0 22 * *"
+	m.DurationMinutes = "0"
+	valErrors = validateMaintenanceForm(m)
+	if !strings.Contains(valErrors["CronSchedule"], "Schedule is not valid") ||
+		valErrors["DurationMinutes"] == "" {
+		t.Error("Maintenance Validation should show errors for the schedule and duration.", valErrors)
+	}
+
+	m.CronSchedule = "0 22 * * 2"
+	m.DurationMinutes = "90"
+	valErrors = validateMaintenanceForm(m)
+	if len(valErrors) > 0 {
+		t.Error("No errors should be flagged for the recurring window", valErrors)
+	}
+}
+
+// TestValidateEscalation tests that the policy needs a first tier of contacts
+// and that the delays of the later tiers increase.
+func TestValidateEscalation(t *testing.T) {
+	p := new(viewmodels.EscalationEditViewModel)
+	p.Name = "Production"
+	valErrors := validateEscalationForm(p)
+	if valErrors["Tiers"] != "At least one tier must have contacts." {
+		t.Error("Escalation Validation should show error for no tiers.", valErrors)
+	}
+
+	p.Tiers = []viewmodels.EscalationTierEditViewModel{
+		{ContactIDs: []int64{1}},
+		{DelayMinutes: "30", ContactIDs: []int64{2}},
+		{DelayMinutes: "15", ContactIDs: []int64{3}},
+	}
+	valErrors = validateEscalationForm(p)
+	if !strings.Contains(valErrors["Tiers"], "The delay of tier 3") {
+		t.Error("Escalation Validation should show error for a decreasing delay.", valErrors)
+	}
+
+	p.Tiers[2].DelayMinutes = "60"
+	p.Tiers = append(p.Tiers, viewmodels.EscalationTierEditViewModel{DelayMinutes: "5"})
+	valErrors = validateEscalationForm(p)
+	if len(valErrors) > 0 {
+		t.Error("No errors should be flagged for the escalation policy", valErrors)
+	}
+
+	p.Tiers[0].ContactIDs = nil
+	valErrors = validateEscalationForm(p)
+	if valErrors["Tiers"] != "The first tier must have contacts." {
+		t.Error("Escalation Validation should show error for an empty first tier.", valErrors)
+	}
+}
+
+// TestValidateTemplate tests that the templates must parse and only use the
+// fields of the notifications.
+func TestValidateTemplate(t *testing.T) {
+	nt := &viewmodels.TemplateEditViewModel{ChannelType: "all", Event: database.DownEvent,
+		Subject: "{{.Site.Name}} is {{.Status}}", Body: "{{.Error}}\nRunbook: http://wiki/{{.Site.Name}}"}
+	valErrors := validateTemplateForm(nt)
+	if len(valErrors) > 0 {
+		t.Error("No errors should be flagged for the template", valErrors)
+	}
+
+	nt.Subject = "{{.Site.Name"
+	nt.Body = "{{.Outage}}"
+	valErrors = validateTemplateForm(nt)
+	if !strings.HasPrefix(valErrors["Subject"], "The subject isn't a valid template") ||
+		!strings.HasPrefix(valErrors["Body"], "The body isn't a valid template") {
+		t.Error("Template Validation should show errors for the invalid templates.", valErrors)
+	}
+
+	nt.Subject = ""
+	nt.Body = ""
+	valErrors = validateTemplateForm(nt)
+	if _, ok := valErrors["Body"]; !ok || len(valErrors) != 1 {
+		t.Error("Template Validation should only require the body.", valErrors)
+	}
+}
+
+// TestValidateSiteParents tests that a site can't depend on itself or on a site
+// that depends on it.
+func TestValidateSiteParents(t *testing.T) {
+	dependencies := database.SiteDependencies{2: {1}, 3: {2}}
+	s := new(viewmodels.SitesEditViewModel)
+	s.SiteID = 1
+
+	for _, parents := range [][]int64{{1}, {3}} {
+		valErrors := make(map[string]string)
+		s.SelectedParents = parents
+		validateSiteParents(s, dependencies, valErrors)
+		if valErrors["SelectedParents"] == "" {
+			t.Error("Parent Sites Validation should show error for", parents)
+		}
+	}
+
+	valErrors := make(map[string]string)
+	s.SiteID = 3
+	s.SelectedParents = []int64{1, 2}
+	validateSiteParents(s, dependencies, valErrors)
+	if len(valErrors) > 0 {
+		t.Error("No errors should be flagged for the parent sites", valErrors)
+	}
+}
+
+// TestValidateWebhooks tests the chat webhook URLs of the contacts and sites.
+func TestValidateWebhooks(t *testing.T) {
+	c := &viewmodels.ContactsEditViewModel{Name: "Jack", SlackActive: true}
+	valErrors := validateContactForm(c)
+	if !strings.Contains(valErrors["SlackWebhook"], "must be provided if it is active") {
+		t.Error("Slack Webhook should be required if active.")
+	}
+
+	c.SlackWebhook = "hooks.slack.com/services/T000"
+	c.TeamsWebhook = "ftp://example.com/hook"
+	valErrors = validateContactForm(c)
+	if !strings.Contains(valErrors["SlackWebhook"], "must be a web address") ||
+		!strings.Contains(valErrors["TeamsWebhook"], "must be a web address") {
+		t.Error("Webhooks should be web addresses:", valErrors)
+	}
+
+	c.SlackWebhook = "https://hooks.slack.com/services/T000/B000/XXXX"
+	c.TeamsWebhook = ""
+	valErrors = validateContactForm(c)
+	if len(valErrors) > 0 {
+		t.Error("No errors should be flagged for the webhooks:", valErrors)
+	}
+
+	c.TelegramActive = true
+	c.MatrixRoom = "#room:matrix.org"
+	c.NtfyTopic = "phone-alerts"
+	c.NtfyActive = true
+	valErrors = validateContactForm(c)
+	if len(valErrors) != 2 || !strings.Contains(valErrors["TelegramChatID"], "must be provided if it is active") ||
+		!strings.Contains(valErrors["MatrixRoom"], "must be like !roomid:matrix.org") {
+		t.Error("Telegram Chat ID and Matrix Room ID should be flagged:", valErrors)
+	}
+
+	s := &viewmodels.SitesEditViewModel{Name: "Test", URL: "http://www.example.com",
+		PingIntervalSeconds: "60", TimeoutSeconds: "15", TeamsWebhook: "not a url"}
+	valErrors = validateSiteForm(s)
+	if !strings.Contains(valErrors["TeamsWebhook"], "must be a web address") {
+		t.Error("Site Teams Webhook should be a web address.")
+	}
+
+	s.TeamsWebhook = ""
+	s.PagerDutyKey = "R0UT1NGKEY"
+	s.OpsgenieKey = "not a key"
+	valErrors = validateSiteForm(s)
+	if len(valErrors) != 1 || !strings.Contains(valErrors["OpsgenieKey"], "must only contain") {
+		t.Error("Only the Opsgenie API Key should be flagged:", valErrors)
+	}
+}
+
+// TestValidateSubscriptions tests that an assigned site must have at least one
+// event and one channel chosen, while the unassigned ones are ignored.
+func TestValidateSubscriptions(t *testing.T) {
+	c := &viewmodels.ContactsEditViewModel{Name: "Jack", SelectedSites: []int64{1},
+		Subscriptions: []viewmodels.SubscriptionViewModel{
+			{ID: 1, Events: []string{database.DownEvent}},
+			{ID: 2},
+		}}
+	valErrors := validateContactForm(c)
+	if !strings.Contains(valErrors["Subscriptions"], "At least one event and one channel") {
+		t.Error("Subscriptions Validation should show error for no channels.", valErrors)
+	}
+
+	c.Subscriptions[0].ChannelTypes = []string{database.SmsChannel}
+	valErrors = validateContactForm(c)
+	if len(valErrors) > 0 {
+		t.Error("No errors should be flagged for the subscriptions", valErrors)
+	}
+}
+
+// TestValidateDigest tests that the summary email needs a known frequency and
+// an active email.
+func TestValidateDigest(t *testing.T) {
+	c := &viewmodels.ContactsEditViewModel{Name: "Jack", Digest: "hourly", EmailActive: true,
+		EmailAddress: "jack@example.com"}
+	valErrors := validateContactForm(c)
+	if !strings.Contains(valErrors["Digest"], "must be daily, weekly or monthly") {
+		t.Error("Digest Validation should show error for the frequency.", valErrors)
+	}
+
+	c.Digest = database.WeeklyDigest
+	c.EmailActive = false
+	valErrors = validateContactForm(c)
+	if !strings.Contains(valErrors["Digest"], "needs the Email to be active") {
+		t.Error("Digest Validation should show error for the inactive email.", valErrors)
+	}
+
+	c.EmailActive = true
+	valErrors = validateContactForm(c)
+	if len(valErrors) > 0 {
+		t.Error("No errors should be flagged for the digest", valErrors)
+	}
+}
+
+// TestValidateVoiceNumber tests that an active voice number is in the E.164
+// format.
+func TestValidateVoiceNumber(t *testing.T) {
+	c := &viewmodels.ContactsEditViewModel{Name: "Jack", VoiceActive: true, VoiceNumber: "512-555-1212"}
+	valErrors := validateContactForm(c)
+	if !strings.Contains(valErrors["VoiceNumber"], "E.164 format") {
+		t.Error("Voice Validation should show error for the number.", valErrors)
+	}
+
+	c.VoiceNumber = "+15125551212"
+	valErrors = validateContactForm(c)
+	if len(valErrors) > 0 {
+		t.Error("No errors should be flagged for the voice number", valErrors)
+	}
+}
@@ -0,0 +1,283 @@
+package database
+
+import "database/sql"
+
+// The channel types of the email and SMS endpoints of the contacts.
+const (
+	EmailChannel = "email"
+	SmsChannel   = "sms"
+)
+
+// The channel types of the chat incoming webhooks, which can be set for the
+// contacts or for the sites.
+const (
+	SlackChannel = "slack"
+	TeamsChannel = "teams"
+)
+
+// WebhookChannel is the channel type of the webhooks that are posted the
+// signed JSON payload of the status changes.
+const WebhookChannel = "webhook"
+
+// The channel types of the push notification services. The address is the
+// Telegram chat ID, the Discord webhook URL, the Matrix room ID, the ntfy topic
+// or the Gotify application token.
+const (
+	TelegramChannel = "telegram"
+	DiscordChannel  = "discord"
+	MatrixChannel   = "matrix"
+	NtfyChannel     = "ntfy"
+	GotifyChannel   = "gotify"
+)
+
+// VoiceChannel is the channel type of the phone numbers that are called about
+// the outages of the critical sites that aren't acknowledged after the other
+// channels were notified.
+const VoiceChannel = "voice"
+
+// The channel types of the incident management services, the address is the
+// PagerDuty routing key or the Opsgenie API key.
+const (
+	PagerDutyChannel = "pagerduty"
+	OpsgenieChannel  = "opsgenie"
+)
+
+// ContactChannel is an endpoint that a contact is notified on, such as an email
+// address. The ChannelType selects the notifier channel that sends to it.
+type ContactChannel struct {
+	ContactChannelID int64
+	ContactID        int64
+	ChannelType      string
+	Address          string
+	IsActive         bool
+}
+
+// SiteChannel is an endpoint that is notified about the site regardless of its
+// contacts, such as the webhook of a team's chat channel.
+type SiteChannel struct {
+	SiteChannelID int64
+	SiteID        int64
+	ChannelType   string
+	Address       string
+	IsActive      bool
+}
+
+// GetContactChannels gets the channel endpoints of the contact. The schedules
+// of the channels are read with them.
+func (c *Contact) GetContactChannels(db *sql.DB) error {
+	rows, err := db.Query(`SELECT ContactChannelID, ChannelType, Address, IsActive
+		FROM ContactChannels WHERE ContactID = $1 ORDER BY ContactChannelID`, c.ContactID)
+	if err != nil {
+		return err
+	}
+
+	// nil out the slice in case it is rereading it from the DB.
+	c.Channels = nil
+	defer rows.Close()
+	for rows.Next() {
+		var ContactChannelID int64
+		var ChannelType string
+		var Address string
+		var IsActive bool
+		err = rows.Scan(&ContactChannelID, &ChannelType, &Address, &IsActive)
+		if err != nil {
+			return err
+		}
+		c.Channels = append(c.Channels, ContactChannel{ContactChannelID: ContactChannelID,
+			ContactID: c.ContactID, ChannelType: ChannelType, Address: Address, IsActive: IsActive})
+	}
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+	return c.GetContactSchedules(db)
+}
+
+// SetContactChannels replaces the channel endpoints of the contact in the DB
+// with its Channels.
+func (c *Contact) SetContactChannels(db *sql.DB) error {
+	channels := append([]ContactChannel(nil), c.Channels...)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM ContactChannels WHERE ContactID = $1", c.ContactID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for i, ch := range channels {
+		result, err := tx.Exec(
+			`INSERT INTO ContactChannels (ContactID, ChannelType, Address, IsActive)
+			VALUES ($1, $2, $3, $4)`,
+			c.ContactID,
+			ch.ChannelType,
+			ch.Address,
+			ch.IsActive,
+		)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		channels[i].ContactID = c.ContactID
+		channels[i].ContactChannelID, err = result.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	c.Channels = channels
+	return nil
+}
+
+// ActiveChannels returns the channel endpoints that the contact is notified on.
+func (c Contact) ActiveChannels() []ContactChannel {
+	var channels []ContactChannel
+	for _, ch := range c.Channels {
+		if ch.IsActive && ch.Address != "" {
+			channels = append(channels, ch)
+		}
+	}
+	return channels
+}
+
+// Channel returns the first of the contact's channels of the channel type.
+func (c Contact) Channel(channelType string) (ContactChannel, bool) {
+	for _, ch := range c.Channels {
+		if ch.ChannelType == channelType {
+			return ch, true
+		}
+	}
+	return ContactChannel{}, false
+}
+
+// SetChannel sets the address of the first of the contact's channels of the
+// channel type, adding it if there isn't one. An empty address removes it.
+func (c *Contact) SetChannel(channelType string, address string, isActive bool) {
+	for i, ch := range c.Channels {
+		if ch.ChannelType != channelType {
+			continue
+		}
+		if address == "" {
+			c.Channels = append(c.Channels[:i:i], c.Channels[i+1:]...)
+			return
+		}
+		c.Channels[i].Address = address
+		c.Channels[i].IsActive = isActive
+		return
+	}
+	if address != "" {
+		c.Channels = append(c.Channels, ContactChannel{ContactID: c.ContactID,
+			ChannelType: channelType, Address: address, IsActive: isActive})
+	}
+}
+
+// GetChannelContactName gets the name of the contact with the channel
+// endpoint, which is empty if no contact has it.
+func GetChannelContactName(db *sql.DB, channelType string, address string) (string, error) {
+	var name string
+	err := db.QueryRow(`SELECT c.Name FROM Contacts c
+		JOIN ContactChannels ch ON ch.ContactID = c.ContactID
+		WHERE ch.ChannelType = $1 AND ch.Address = $2 ORDER BY c.Name LIMIT 1`, channelType, address).
+		Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return name, err
+}
+
+// GetSiteChannels gets the channel endpoints that are notified about the site.
+func (s *Site) GetSiteChannels(db *sql.DB) error {
+	rows, err := db.Query(`SELECT SiteChannelID, ChannelType, Address, IsActive
+		FROM SiteChannels WHERE SiteID = $1 ORDER BY SiteChannelID`, s.SiteID)
+	if err != nil {
+		return err
+	}
+
+	// nil out the slice in case it is rereading it from the DB.
+	s.Channels = nil
+	defer rows.Close()
+	for rows.Next() {
+		var SiteChannelID int64
+		var ChannelType string
+		var Address string
+		var IsActive bool
+		err = rows.Scan(&SiteChannelID, &ChannelType, &Address, &IsActive)
+		if err != nil {
+			return err
+		}
+		s.Channels = append(s.Channels, SiteChannel{SiteChannelID: SiteChannelID,
+			SiteID: s.SiteID, ChannelType: ChannelType, Address: Address, IsActive: IsActive})
+	}
+	return rows.Err()
+}
+
+// SetSiteChannels replaces the channel endpoints of the site in the DB with
+// the site's Channels.
+func (s *Site) SetSiteChannels(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM SiteChannels WHERE SiteID = $1", s.SiteID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for i, ch := range s.Channels {
+		result, err := tx.Exec(
+			`INSERT INTO SiteChannels (SiteID, ChannelType, Address, IsActive)
+			VALUES ($1, $2, $3, $4)`,
+			s.SiteID,
+			ch.ChannelType,
+			ch.Address,
+			ch.IsActive,
+		)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		s.Channels[i].SiteID = s.SiteID
+		s.Channels[i].SiteChannelID, err = result.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Channel returns the first of the site's channels of the channel type.
+func (s Site) Channel(channelType string) (SiteChannel, bool) {
+	for _, ch := range s.Channels {
+		if ch.ChannelType == channelType {
+			return ch, true
+		}
+	}
+	return SiteChannel{}, false
+}
+
+// SetChannel sets the address of the first of the site's channels of the
+// channel type, adding it if there isn't one. An empty address removes it.
+func (s *Site) SetChannel(channelType string, address string, isActive bool) {
+	for i, ch := range s.Channels {
+		if ch.ChannelType != channelType {
+			continue
+		}
+		if address == "" {
+			s.Channels = append(s.Channels[:i:i], s.Channels[i+1:]...)
+			return
+		}
+		s.Channels[i].Address = address
+		s.Channels[i].IsActive = isActive
+		return
+	}
+	if address != "" {
+		s.Channels = append(s.Channels, SiteChannel{SiteID: s.SiteID,
+			ChannelType: channelType, Address: address, IsActive: isActive})
+	}
+}
@@ -0,0 +1,170 @@
+package database_test
+
+import (
+	"testing"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// TestContactChannels tests that the other channels of a contact are kept along
+// with the email and SMS channels when the contact is updated.
+func TestContactChannels(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+
+	c := database.Contact{Name: "Joe Contact"}
+	c.SetChannel(database.EmailChannel, "joe@test.com", true)
+	c.SetChannel("webhook", "http://hooks.example.com", true)
+	err = c.CreateContact(db)
+	if err != nil {
+		t.Fatal("Failed to create new contact:", err)
+	}
+
+	var saved database.Contact
+	err = saved.GetContact(db, c.ContactID)
+	if err != nil {
+		t.Fatal("Failed to get contact:", err)
+	}
+	if len(saved.Channels) != 2 || saved.Channels[0].ChannelType != database.EmailChannel ||
+		saved.Channels[0].Address != "joe@test.com" || saved.Channels[1].Address != "http://hooks.example.com" {
+		t.Error("Contact channels not saved as expected:", saved.Channels)
+	}
+
+	// Changing the email and adding an SMS number keeps the webhook.
+	saved.SetChannel(database.EmailChannel, "joe@example.com", true)
+	saved.SetChannel(database.SmsChannel, "5125551212", true)
+	err = saved.UpdateContact(db)
+	if err != nil {
+		t.Fatal("Failed to update contact:", err)
+	}
+	var updated database.Contact
+	err = updated.GetContact(db, c.ContactID)
+	if err != nil {
+		t.Fatal("Failed to get contact:", err)
+	}
+	email, _ := updated.Channel(database.EmailChannel)
+	sms, _ := updated.Channel(database.SmsChannel)
+	if len(updated.Channels) != 3 || email.Address != "joe@example.com" || sms.Address != "5125551212" ||
+		!sms.IsActive || updated.Channels[1].ChannelType != "webhook" {
+		t.Error("Contact channels not updated as expected:", updated.Channels)
+	}
+
+	// Clearing the email removes its channel and inactive channels aren't notified.
+	updated.SetChannel(database.EmailChannel, "", false)
+	updated.SetChannel(database.SmsChannel, "5125551212", false)
+	active := updated.ActiveChannels()
+	if len(active) != 1 || active[0].ChannelType != "webhook" {
+		t.Error("Only the webhook channel should be active:", active)
+	}
+}
+
+// TestUpgradeContactChannels tests that the email and SMS of the existing
+// contacts are moved to the channels by the upgrade.
+func TestUpgradeContactChannels(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+
+	// Put the DB back to the version before the channels, the Sites table is
+	// copied and the empty Contacts and SiteContacts tables are made without
+	// the columns that were added since.
+	_, err = db.Exec(`DROP TABLE ContactChannels;
+		DROP TABLE SiteChannels;
+		DROP TABLE EscalationTierContacts;
+		DROP TABLE EscalationTiers;
+		DROP TABLE EscalationPolicies;
+		DROP TABLE NotificationTemplates;
+		DROP TABLE OutboxMessages;
+		DROP TABLE DeliveryAttempts;
+		DROP TABLE ContactSchedules;
+		DROP TABLE DigestRuns;
+		DROP TABLE SiteContacts;
+		DROP TABLE Contacts;
+		CREATE TABLE Contacts (
+			ContactId INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, Name TEXT NOT NULL UNIQUE,
+			EmailAddress TEXT NOT NULL, SmsNumber INTEGER, SmsActive INTEGER NOT NULL DEFAULT 0,
+			EmailActive INTEGER NOT NULL DEFAULT 1);
+		CREATE TABLE SiteContacts (ContactId INTEGER NOT NULL, SiteId INTEGER NOT NULL,
+			PRIMARY KEY (ContactId, SiteId));
+		CREATE TABLE SitesV8 AS SELECT SiteId, Name, IsActive, URL, PingIntervalSeconds,
+			TimeoutSeconds, IsSiteUp, LastStatusChange, LastPing, FirstPing, ContentExpected,
+			ContentUnexpected, TransactionSteps, IsFlapping FROM Sites;
+		DROP TABLE Sites;
+		ALTER TABLE SitesV8 RENAME TO Sites;
+		INSERT INTO Contacts (Name, EmailAddress, SmsNumber, EmailActive, SmsActive)
+		VALUES ('Joe Contact', 'joe@test.com', 5125551212, 1, 0), ('Jill Contact', '', NULL, 0, 0);
+		PRAGMA user_version = 8;`)
+	if err != nil {
+		t.Fatal("Failed to downgrade database:", err)
+	}
+	db.Close()
+
+	db, err = database.InitializeDB("./test.db", "")
+	if err != nil {
+		t.Fatal("Failed to upgrade database:", err)
+	}
+	defer db.Close()
+
+	var contacts database.Contacts
+	err = contacts.GetContacts(db)
+	if err != nil {
+		t.Fatal("Failed to get contacts:", err)
+	}
+	if len(contacts) != 2 || len(contacts[0].Channels) != 0 {
+		t.Fatal("Contact without email or SMS should have no channels:", contacts)
+	}
+	joe := contacts[1]
+	email, _ := joe.Channel(database.EmailChannel)
+	sms, _ := joe.Channel(database.SmsChannel)
+	if len(joe.Channels) != 2 || email.Address != "joe@test.com" || !email.IsActive ||
+		sms.Address != "5125551212" || sms.IsActive {
+		t.Error("Contact email and SMS not moved to the channels:", joe)
+	}
+}
+
+// TestSiteChannels tests setting and getting the chat webhooks of a site.
+func TestSiteChannels(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+
+	s := database.Site{Name: "Test", IsActive: true, URL: "http://www.example.com",
+		PingIntervalSeconds: 60, TimeoutSeconds: 30}
+	err = s.CreateSite(db)
+	if err != nil {
+		t.Fatal("Failed to create new site:", err)
+	}
+	s.SetChannel(database.SlackChannel, "https://hooks.slack.com/services/T000", true)
+	s.SetChannel(database.TeamsChannel, "https://example.webhook.office.com/webhookb2/1", true)
+	err = s.SetSiteChannels(db)
+	if err != nil {
+		t.Fatal("Failed to set site channels:", err)
+	}
+
+	// Clearing the Slack webhook removes it.
+	var saved database.Site
+	saved.SiteID = s.SiteID
+	err = saved.GetSiteChannels(db)
+	if err != nil {
+		t.Fatal("Failed to get site channels:", err)
+	}
+	saved.SetChannel(database.SlackChannel, "", true)
+	err = saved.SetSiteChannels(db)
+	if err != nil {
+		t.Fatal("Failed to set site channels:", err)
+	}
+	err = saved.GetSiteChannels(db)
+	if err != nil {
+		t.Fatal("Failed to get site channels:", err)
+	}
+	teams, ok := saved.Channel(database.TeamsChannel)
+	if len(saved.Channels) != 1 || !ok || teams.Address != "https://example.webhook.office.com/webhookb2/1" {
+		t.Error("Only the Teams webhook should be left:", saved.Channels)
+	}
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"time"
@@ -18,25 +19,39 @@ type Site struct {
 	PingIntervalSeconds int
 	TimeoutSeconds      int
 	IsSiteUp            bool
+	IsFlapping          bool
+	IsCritical          bool
 	ContentExpected     string
 	ContentUnexpected   string
+	TransactionSteps    string
+	ReminderMinutes     int
+	MaxReminders        int
+	EscalationPolicyID  int64
+	AcknowledgedBy      string
+	AcknowledgedAt      time.Time
 	LastStatusChange    time.Time
 	LastPing            time.Time
 	FirstPing           time.Time
 	Contacts            []Contact
 	Pings               []Ping
+	MaintenanceWindows  []MaintenanceWindow
+	ParentSiteIDs       []int64
+	Channels            []SiteChannel
+	EscalationTiers     []EscalationTier
 }
 
-// Contact is one of the contacts for a particular site.
+// Contact is one of the contacts for a particular site. The Channels are all
+// of its channel endpoints as stored in the DB, including the email and SMS.
 type Contact struct {
 	ContactID    int64
 	Name         string
-	EmailAddress string
-	SmsNumber    string
-	SmsActive    bool
-	EmailActive  bool
+	Timezone     string
+	Digest       string
 	SiteCount    int
 	Sites        []Site
+	Channels     []ContactChannel
+	Schedules    []ContactSchedule
+	Subscription Subscription
 }
 
 // Sites is a slice of sites
@@ -52,6 +67,15 @@ type Ping struct {
 	Duration       int
 	HTTPStatusCode int
 	SiteDown       bool
+	Maintenance    bool
+	Steps          []PingStep
+}
+
+// PingStep is the timing in milliseconds of one step of a multi-step check,
+// such as reading the greeting banner of a mail server.
+type PingStep struct {
+	Name     string
+	Duration int
 }
 
 // Report contains information about performance where AvgResponse is the average
@@ -74,8 +98,9 @@ func (s *Site) CreateSite(db *sql.DB) error {
 	result, err := db.Exec(
 		`INSERT INTO Sites (Name, IsActive, URL, PingIntervalSeconds, TimeoutSeconds,
 			IsSiteUp, LastStatusChange, LastPing, FirstPing, ContentExpected,
-			ContentUnexpected)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			ContentUnexpected, TransactionSteps, ReminderMinutes, MaxReminders,
+			EscalationPolicyID, IsCritical)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`,
 		s.Name,
 		s.IsActive,
 		s.URL,
@@ -87,6 +112,11 @@ func (s *Site) CreateSite(db *sql.DB) error {
 		s.FirstPing,
 		s.ContentExpected,
 		s.ContentUnexpected,
+		s.TransactionSteps,
+		s.ReminderMinutes,
+		s.MaxReminders,
+		s.EscalationPolicyID,
+		s.IsCritical,
 	)
 	if err != nil {
 		return err
@@ -105,8 +135,10 @@ func (s *Site) UpdateSite(db *sql.DB) error {
 	_, err := db.Exec(
 		`Update Sites SET Name = $1, URL = $2, IsActive = $3,
 		  	PingIntervalSeconds = $4, TimeoutSeconds = $5, 
-		  	ContentExpected = $6, ContentUnexpected = $7
-			WHERE SiteId = $8`,
+		  	ContentExpected = $6, ContentUnexpected = $7, TransactionSteps = $8,
+			ReminderMinutes = $9, MaxReminders = $10, EscalationPolicyID = $11,
+			IsCritical = $12
+			WHERE SiteId = $13`,
 		s.Name,
 		s.URL,
 		s.IsActive,
@@ -114,26 +146,80 @@ func (s *Site) UpdateSite(db *sql.DB) error {
 		s.TimeoutSeconds,
 		s.ContentExpected,
 		s.ContentUnexpected,
+		s.TransactionSteps,
+		s.ReminderMinutes,
+		s.MaxReminders,
+		s.EscalationPolicyID,
+		s.IsCritical,
+		s.SiteID,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+//UpdateSiteStatus updates the up/down status and last status change of a Site,
+// the change is at the time of the ping that found it so that it matches the
+// acknowledge links of the notification. The acknowledgement of the previous outage is cleared. The messages of the
+// notification are saved to the outbox in the same transaction, so a status
+// change is never saved without its notification.
+func (s *Site) UpdateSiteStatus(db *sql.DB, isSiteUp bool, changedAt time.Time, messages OutboxMessages) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		`UPDATE Sites SET IsSiteUp = $1, LastStatusChange = $2,
+			AcknowledgedBy = '', AcknowledgedAt = $3
+			WHERE SiteId = $4`,
+		isSiteUp,
+		changedAt,
+		time.Time{},
+		s.SiteID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = insertOutboxMessages(tx, messages)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateSiteFlapping updates whether the site is flapping between up and down.
+func (s *Site) UpdateSiteFlapping(db *sql.DB, isFlapping bool) error {
+	_, err := db.Exec(
+		`UPDATE Sites SET IsFlapping = $1
+			WHERE SiteId = $2`,
+		isFlapping,
 		s.SiteID,
 	)
 	if err != nil {
 		return err
 	}
+
 	return nil
 }
 
-//UpdateSiteStatus updates the up/down status and last status change of a Site.
-func (s *Site) UpdateSiteStatus(db *sql.DB, isSiteUp bool) error {
+// AcknowledgeSite records who acknowledged the current outage of the site and
+// when, which lasts until the site's status changes.
+func (s *Site) AcknowledgeSite(db *sql.DB, acknowledgedBy string, acknowledgedAt time.Time) error {
 	_, err := db.Exec(
-		`UPDATE Sites SET IsSiteUp = $1, LastStatusChange = $2
+		`UPDATE Sites SET AcknowledgedBy = $1, AcknowledgedAt = $2
 			WHERE SiteId = $3`,
-		isSiteUp,
-		time.Now(),
+		acknowledgedBy,
+		acknowledgedAt,
 		s.SiteID,
 	)
 	if err != nil {
 		return err
 	}
+	s.AcknowledgedBy = acknowledgedBy
+	s.AcknowledgedAt = acknowledgedAt
 
 	return nil
 }
@@ -157,12 +243,15 @@ func (s *Site) UpdateSiteFirstPing(db *sql.DB, firstPingTime time.Time) error {
 func (s *Site) GetSite(db *sql.DB, siteID int64) error {
 	err := db.QueryRow(`SELECT SiteID, Name, IsActive, URL, PingIntervalSeconds,
 		TimeoutSeconds, IsSiteUp, LastStatusChange, LastPing, FirstPing,
-		ContentExpected, ContentUnExpected
+		ContentExpected, ContentUnExpected, TransactionSteps, IsFlapping,
+		ReminderMinutes, MaxReminders, EscalationPolicyID, AcknowledgedBy, AcknowledgedAt,
+		IsCritical
 		FROM Sites
 		WHERE SiteID = $1`, siteID).
 		Scan(&s.SiteID, &s.Name, &s.IsActive, &s.URL, &s.PingIntervalSeconds, &s.TimeoutSeconds,
 			&s.IsSiteUp, &s.LastStatusChange, &s.LastPing, &s.FirstPing, &s.ContentExpected,
-			&s.ContentUnexpected)
+			&s.ContentUnexpected, &s.TransactionSteps, &s.IsFlapping, &s.ReminderMinutes, &s.MaxReminders,
+			&s.EscalationPolicyID, &s.AcknowledgedBy, &s.AcknowledgedAt, &s.IsCritical)
 	if err != nil {
 		return err
 	}
@@ -171,13 +260,17 @@ func (s *Site) GetSite(db *sql.DB, siteID int64) error {
 
 const getActiveSitesQueryString string = `SELECT SiteID, Name, IsActive, URL,
 	PingIntervalSeconds, TimeoutSeconds, IsSiteUp, LastStatusChange, LastPing,
-	FirstPing, ContentExpected, ContentUnexpected
+	FirstPing, ContentExpected, ContentUnexpected, TransactionSteps, IsFlapping,
+	ReminderMinutes, MaxReminders, EscalationPolicyID, AcknowledgedBy, AcknowledgedAt,
+	IsCritical
 	FROM Sites WHERE IsActive = $1
 	ORDER BY Name`
 
 const getAllSitesQueryString string = `SELECT SiteID, Name, IsActive, URL,
   PingIntervalSeconds, TimeoutSeconds, IsSiteUp, LastStatusChange, LastPing,
-	FirstPing, ContentExpected, ContentUnexpected
+	FirstPing, ContentExpected, ContentUnexpected, TransactionSteps, IsFlapping,
+	ReminderMinutes, MaxReminders, EscalationPolicyID, AcknowledgedBy, AcknowledgedAt,
+	IsCritical
 	FROM Sites
 	ORDER BY Name`
 
@@ -210,9 +303,18 @@ func (s *Sites) GetSites(db *sql.DB, activeOnly bool, withContacts bool) error {
 		var FirstPing time.Time
 		var ContentExpected string
 		var ContentUnexpected string
+		var TransactionSteps string
+		var IsFlapping bool
+		var ReminderMinutes int
+		var MaxReminders int
+		var EscalationPolicyID int64
+		var AcknowledgedBy string
+		var AcknowledgedAt time.Time
+		var IsCritical bool
 		err = rows.Scan(&SiteID, &Name, &IsActive, &URL, &PingIntervalSeconds, &TimeoutSeconds,
 			&IsSiteUp, &LastStatusChange, &LastPing, &FirstPing, &ContentExpected,
-			&ContentUnexpected)
+			&ContentUnexpected, &TransactionSteps, &IsFlapping, &ReminderMinutes, &MaxReminders,
+			&EscalationPolicyID, &AcknowledgedBy, &AcknowledgedAt, &IsCritical)
 		if err != nil {
 			return err
 		}
@@ -220,7 +322,10 @@ func (s *Sites) GetSites(db *sql.DB, activeOnly bool, withContacts bool) error {
 			PingIntervalSeconds: PingIntervalSeconds, TimeoutSeconds: TimeoutSeconds,
 			IsSiteUp: IsSiteUp, LastStatusChange: LastStatusChange, LastPing: LastPing,
 			FirstPing: FirstPing, ContentExpected: ContentExpected,
-			ContentUnexpected: ContentUnexpected}
+			ContentUnexpected: ContentUnexpected, TransactionSteps: TransactionSteps,
+			IsFlapping: IsFlapping, ReminderMinutes: ReminderMinutes, MaxReminders: MaxReminders,
+			EscalationPolicyID: EscalationPolicyID, AcknowledgedBy: AcknowledgedBy,
+			AcknowledgedAt: AcknowledgedAt, IsCritical: IsCritical}
 		if withContacts {
 			err = site.GetSiteContacts(db, site.SiteID)
 			if err != nil {
@@ -234,7 +339,7 @@ func (s *Sites) GetSites(db *sql.DB, activeOnly bool, withContacts bool) error {
 
 // GetSiteContacts gets the collection of contacts for a given site.
 func (s *Site) GetSiteContacts(db *sql.DB, siteID int64) error {
-	rows, err := db.Query(`SELECT c.ContactID, Name, EmailAddress, SmsNumber, EmailActive, SmsActive
+	rows, err := db.Query(`SELECT c.ContactID, Name, s.Events, s.ChannelTypes
 		FROM Contacts c JOIN  SiteContacts s  ON s.ContactID = c.ContactID WHERE s.siteID = $1
 		ORDER BY Name`, siteID)
 	if err != nil {
@@ -246,18 +351,21 @@ func (s *Site) GetSiteContacts(db *sql.DB, siteID int64) error {
 	defer rows.Close()
 	for rows.Next() {
 		var ContactID int64
-		var Name string
-		var EmailAddress string
-		var SmsNumber string
-		var EmailActive bool
-		var SmsActive bool
-		err = rows.Scan(&ContactID, &Name, &EmailAddress, &SmsNumber, &EmailActive, &SmsActive)
+		var Name, Events, ChannelTypes string
+		err = rows.Scan(&ContactID, &Name, &Events, &ChannelTypes)
 		if err != nil {
 			return err
 		}
 		s.Contacts = append(s.Contacts, Contact{ContactID: ContactID, Name: Name,
-			EmailAddress: EmailAddress, SmsNumber: SmsNumber, EmailActive: EmailActive,
-			SmsActive: SmsActive})
+			Subscription: Subscription{Events: splitList(Events), ChannelTypes: splitList(ChannelTypes)}})
+	}
+	rows.Close()
+
+	for i := range s.Contacts {
+		err = s.Contacts[i].GetContactChannels(db)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -300,15 +408,12 @@ func (c *Contact) GetContactSites(db *sql.DB) error {
 	return nil
 }
 
-// CreateContact inserts a new contact in the DB.
+// CreateContact inserts a new contact with its channels and schedules in the DB.
 func (c *Contact) CreateContact(db *sql.DB) error {
 	result, err := db.Exec(
-		"INSERT INTO Contacts (Name, EmailAddress, SmsNumber, EmailActive, SmsActive) VALUES ($1, $2, $3, $4, $5)",
+		"INSERT INTO Contacts (Name, Digest) VALUES ($1, $2)",
 		c.Name,
-		c.EmailAddress,
-		c.SmsNumber,
-		c.EmailActive,
-		c.SmsActive,
+		c.Digest,
 	)
 	if err != nil {
 		return err
@@ -319,26 +424,30 @@ func (c *Contact) CreateContact(db *sql.DB) error {
 		return err
 	}
 
-	return nil
+	err = c.SetContactChannels(db)
+	if err != nil {
+		return err
+	}
+	return c.SetContactSchedules(db)
 }
 
-// UpdateContact updates the contact information in the DB.
+// UpdateContact updates the contact information with its channels and schedules in the DB.
 func (c *Contact) UpdateContact(db *sql.DB) error {
 	_, err := db.Exec(
-		`Update Contacts SET Name = $1, EmailAddress = $2, SmsNumber = $3,
-		  EmailActive = $4, SmsActive = $5
-			WHERE ContactID = $6`,
+		`Update Contacts SET Name = $1, Digest = $2
+			WHERE ContactID = $3`,
 		c.Name,
-		c.EmailAddress,
-		c.SmsNumber,
-		c.EmailActive,
-		c.SmsActive,
+		c.Digest,
 		c.ContactID,
 	)
 	if err != nil {
 		return err
 	}
-	return nil
+	err = c.SetContactChannels(db)
+	if err != nil {
+		return err
+	}
+	return c.SetContactSchedules(db)
 }
 
 // DeleteContact deletes the contact from the DB.
@@ -357,6 +466,33 @@ func (c *Contact) DeleteContact(db *sql.DB) error {
 		return err
 	}
 
+	_, err = db.Exec(
+		`DELETE FROM ContactChannels WHERE ContactID = $1`,
+		c.ContactID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = db.Exec(
+		`DELETE FROM EscalationTierContacts WHERE ContactID = $1`,
+		c.ContactID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = db.Exec(
+		`DELETE FROM ContactSchedules WHERE ContactID = $1`,
+		c.ContactID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	_, err = db.Exec(
 		`DELETE FROM Contacts WHERE ContactID = $1;`,
 		c.ContactID,
@@ -372,14 +508,12 @@ func (c *Contact) DeleteContact(db *sql.DB) error {
 
 // GetContact gets the contact details for a given contact.
 func (c *Contact) GetContact(db *sql.DB, contactID int64) error {
-	err := db.QueryRow(`SELECT ContactID, Name, EmailAddress, SmsNumber, SmsActive,
-		EmailActive FROM Contacts WHERE ContactID = $1`, contactID).
-		Scan(&c.ContactID, &c.Name, &c.EmailAddress, &c.SmsNumber, &c.SmsActive,
-			&c.EmailActive)
+	err := db.QueryRow(`SELECT ContactID, Name, Digest FROM Contacts WHERE ContactID = $1`, contactID).
+		Scan(&c.ContactID, &c.Name, &c.Digest)
 	if err != nil {
 		return err
 	}
-	return nil
+	return c.GetContactChannels(db)
 }
 
 // AddContactToSite associates a contact with a site.
@@ -414,32 +548,34 @@ func (s Site) RemoveContactFromSite(db *sql.DB, contactID int64) error {
 
 // GetContacts gets all contacts
 func (c *Contacts) GetContacts(db *sql.DB) error {
-	rows, err := db.Query(`SELECT Contacts.ContactID, Name, EmailAddress, SmsNumber,
-		EmailActive, SmsActive, count(Distinct SiteContacts.SiteID) AS SiteCount
+	rows, err := db.Query(`SELECT Contacts.ContactID, Name,
+		count(Distinct SiteContacts.SiteID) AS SiteCount
 		FROM Contacts LEFT JOIN SiteContacts ON Contacts.ContactId = SiteContacts.ContactId
-		GROUP BY Contacts.ContactId, Name, EmailAddress, SmsNumber, EmailActive, SmsActive
+		GROUP BY Contacts.ContactId, Name
 	  ORDER BY Name`)
 	if err != nil {
 		return err
 	}
 
 	defer rows.Close()
+	start := len(*c)
 	for rows.Next() {
 		var ContactID int64
 		var Name string
-		var EmailAddress string
-		var SmsNumber string
-		var EmailActive bool
-		var SmsActive bool
 		var SiteCount int
-		err = rows.Scan(&ContactID, &Name, &EmailAddress, &SmsNumber, &EmailActive,
-			&SmsActive, &SiteCount)
+		err = rows.Scan(&ContactID, &Name, &SiteCount)
+		if err != nil {
+			return err
+		}
+		*c = append(*c, Contact{ContactID: ContactID, Name: Name, SiteCount: SiteCount})
+	}
+	rows.Close()
+
+	for i := start; i < len(*c); i++ {
+		err = (*c)[i].GetContactChannels(db)
 		if err != nil {
 			return err
 		}
-		*c = append(*c, Contact{ContactID: ContactID, Name: Name,
-			EmailAddress: EmailAddress, SmsNumber: SmsNumber, EmailActive: EmailActive,
-			SmsActive: SmsActive, SiteCount: SiteCount})
 	}
 
 	return nil
@@ -447,15 +583,21 @@ func (c *Contacts) GetContacts(db *sql.DB) error {
 
 //CreatePing inserts a new ping row and last check for the site in the DB.
 func (p Ping) CreatePing(db *sql.DB) error {
-	var err error
+	steps, err := encodePingSteps(p.Steps)
+	if err != nil {
+		return err
+	}
 	_, err = db.Exec(
-		`INSERT INTO Pings (SiteID, TimeRequest, Duration, HttpStatusCode, SiteDown)
-			VALUES ($1, $2, $3, $4, $5)`,
+		`INSERT INTO Pings (SiteID, TimeRequest, Duration, HttpStatusCode, SiteDown,
+			Maintenance, Steps)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
 		p.SiteID,
 		p.TimeRequest,
 		p.Duration,
 		p.HTTPStatusCode,
 		p.SiteDown,
+		p.Maintenance,
+		steps,
 	)
 	if err != nil {
 		return err
@@ -497,7 +639,8 @@ func (s *Site) GetFirstPing(db *sql.DB) (time.Time, error) {
 
 // GetSitePings gets the pings for a given site for a given time interval.
 func (s *Site) GetSitePings(db *sql.DB, siteID int64, startTime time.Time, endTime time.Time) error {
-	rows, err := db.Query(`SELECT SiteID, TimeRequest, Duration, HttpStatusCode, SiteDown
+	rows, err := db.Query(`SELECT SiteID, TimeRequest, Duration, HttpStatusCode, SiteDown,
+		Maintenance, Steps
 		FROM Pings WHERE SiteID = $1 AND TimeRequest >= $2 AND TimeRequest <=$3
 		ORDER BY TimeRequest`, siteID, startTime, endTime)
 	if err != nil {
@@ -513,19 +656,53 @@ func (s *Site) GetSitePings(db *sql.DB, siteID int64, startTime time.Time, endTi
 		var Duration int
 		var HTTPStatusCode int
 		var SiteDown bool
-		err = rows.Scan(&SiteID, &TimeRequest, &Duration, &HTTPStatusCode, &SiteDown)
+		var Maintenance bool
+		var Steps string
+		err = rows.Scan(&SiteID, &TimeRequest, &Duration, &HTTPStatusCode, &SiteDown,
+			&Maintenance, &Steps)
+		if err != nil {
+			return err
+		}
+		pingSteps, err := decodePingSteps(Steps)
 		if err != nil {
 			return err
 		}
 		s.Pings = append(s.Pings, Ping{SiteID: SiteID, TimeRequest: TimeRequest,
-			Duration: Duration, HTTPStatusCode: HTTPStatusCode, SiteDown: SiteDown})
+			Duration: Duration, HTTPStatusCode: HTTPStatusCode, SiteDown: SiteDown,
+			Maintenance: Maintenance, Steps: pingSteps})
 	}
 
 	return nil
 }
 
+// encodePingSteps stores the step timings as JSON, or as an empty string for
+// the usual single request ping.
+func encodePingSteps(steps []PingStep) (string, error) {
+	if len(steps) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(steps)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// decodePingSteps reads the step timings saved by encodePingSteps.
+func decodePingSteps(encoded string) ([]PingStep, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	var steps []PingStep
+	err := json.Unmarshal([]byte(encoded), &steps)
+	if err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
 // GetYTDReports gets reports for the active sites. Site status is based on the SiteDown
-// flag in the pings table.
+// flag in the pings table. Pings taken during a maintenance window are left out.
 func GetYTDReports(db *sql.DB, year int) (map[string]Reports, error) {
 	yearStr := strconv.Itoa(year) + "-01-01"
 	nextYearStr := strconv.Itoa(year+1) + "-01-01"
@@ -534,12 +711,12 @@ func GetYTDReports(db *sql.DB, year int) (map[string]Reports, error) {
 	FROM(
 	select Name, strftime("%m", timeRequest) as 'month', AVG(duration) as AvgResponse, count(*) as PingsUp, 0 as PingsDown
 	     FROM pings INNER JOIN sites on sites.siteID = pings.siteID
-		   WHERE sitedown = 0 AND timeRequest > date($1, 'start of year') AND timeRequest < date($2, 'start of year')
+		   WHERE sitedown = 0 AND maintenance = 0 AND timeRequest > date($1, 'start of year') AND timeRequest < date($2, 'start of year')
 		   group by strftime("%m", timeRequest), name
 	UNION ALL
 		   select Name, strftime("%m", timeRequest) as 'month', 0 as AvgResponse, 0 as PingsUp, count(*) as PingsDown
 	       from pings INNER JOIN sites on sites.siteID = pings.siteID
-		   WHERE siteDown = 1 AND timeRequest > date($1, 'start of year') AND timeRequest < date($2, 'start of year')
+		   WHERE siteDown = 1 AND maintenance = 0 AND timeRequest > date($1, 'start of year') AND timeRequest < date($2, 'start of year')
 		   group by strftime("%m", timeRequest), name
 	)
 	group by name, month
@@ -608,6 +785,9 @@ func CompareSites(s1 Site, s2 Site) bool {
 	} else if s1.ContentUnexpected != s2.ContentUnexpected {
 		fmt.Println("ContentUnexpected !=")
 		return false
+	} else if s1.TransactionSteps != s2.TransactionSteps {
+		fmt.Println("TransactionSteps !=")
+		return false
 	} else if !s1.FirstPing.Equal(s2.FirstPing) {
 		fmt.Println("FirstPing !=")
 		return false
@@ -617,6 +797,15 @@ func CompareSites(s1 Site, s2 Site) bool {
 	} else if s1.IsSiteUp != s2.IsSiteUp {
 		fmt.Println("IsSiteUp !=")
 		return false
+	} else if s1.IsFlapping != s2.IsFlapping {
+		fmt.Println("IsFlapping !=")
+		return false
+	} else if s1.ReminderMinutes != s2.ReminderMinutes || s1.MaxReminders != s2.MaxReminders {
+		fmt.Println("Reminders !=")
+		return false
+	} else if s1.EscalationPolicyID != s2.EscalationPolicyID {
+		fmt.Println("EscalationPolicyID !=")
+		return false
 	} else if !s1.LastPing.Equal(s2.LastPing) {
 		fmt.Println("LastPing !=")
 		return false
@@ -44,6 +44,17 @@ func TestCreateDb(t *testing.T) {
 	if firstPing != zeroTime {
 		t.Error("GetFirstPing should return a zero time for an empty ping table, but returned: ", err)
 	}
+
+	// Verify that the email and SMS of the seeded contacts are their channels.
+	var contacts database.Contacts
+	err = contacts.GetContacts(db)
+	if err != nil {
+		t.Fatal("Failed to get the contacts:", err)
+	}
+	if len(contacts) != 2 || len(contacts[0].ActiveChannels()) != 1 ||
+		contacts[0].ActiveChannels()[0].Address != "15125551213" {
+		t.Error("Seeded contact channels not loaded as expected:", contacts)
+	}
 }
 
 // TestCreateSiteAndContacts tests creating a site and adding new contacts
@@ -85,7 +96,8 @@ func TestCreateSiteAndContacts(t *testing.T) {
 	sUpdate := database.Site{SiteID: 1, Name: "Test Update", IsActive: false,
 		URL: "http://www.example.com", PingIntervalSeconds: 30, TimeoutSeconds: 15,
 		ContentExpected: "Updated Content", ContentUnexpected: "Updated Unexpected",
-		IsSiteUp: true,
+		IsSiteUp: true, TransactionSteps: "[[Steps]]\nURL = \"/login\"",
+		ReminderMinutes: 30, MaxReminders: 4,
 	}
 	site.Name = sUpdate.Name
 	site.URL = sUpdate.URL
@@ -95,6 +107,9 @@ func TestCreateSiteAndContacts(t *testing.T) {
 	site.ContentExpected = sUpdate.ContentExpected
 	site.ContentUnexpected = sUpdate.ContentUnexpected
 	site.IsSiteUp = sUpdate.IsSiteUp
+	site.TransactionSteps = sUpdate.TransactionSteps
+	site.ReminderMinutes = sUpdate.ReminderMinutes
+	site.MaxReminders = sUpdate.MaxReminders
 	err = site.UpdateSite(db)
 	if err != nil {
 		t.Fatal("Failed to update site:", err)
@@ -112,8 +127,9 @@ func TestCreateSiteAndContacts(t *testing.T) {
 	}
 
 	// Create first contact - ContactID is for referencing the contact get test
-	c := database.Contact{Name: "Joe Contact", EmailAddress: "joe@test.com", SmsNumber: "5125551212",
-		SmsActive: false, EmailActive: false, ContactID: 1}
+	c := database.Contact{Name: "Joe Contact", ContactID: 1,
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "joe@test.com"},
+			{ChannelType: database.SmsChannel, Address: "5125551212"}}}
 	err = c.CreateContact(db)
 	if err != nil {
 		t.Fatal("Failed to create new contact:", err)
@@ -125,8 +141,9 @@ func TestCreateSiteAndContacts(t *testing.T) {
 	}
 
 	// Create second contact
-	c2 := database.Contact{Name: "Jill Contact", EmailAddress: "jill@test.com", SmsNumber: "5125551213",
-		SmsActive: false, EmailActive: false}
+	c2 := database.Contact{Name: "Jill Contact",
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "jill@test.com"},
+			{ChannelType: database.SmsChannel, Address: "5125551213"}}}
 	err = c2.CreateContact(db)
 	if err != nil {
 		t.Fatal("Failed to create new site:", err)
@@ -181,17 +198,19 @@ func TestCreateSiteAndContacts(t *testing.T) {
 	}
 
 	// Update the first contact.
-	c1Update := database.Contact{Name: "Jane Contact", EmailAddress: "jane@test.com", SmsNumber: "5125551313",
-		SmsActive: true, EmailActive: true, ContactID: 1}
+	c1Update := database.Contact{Name: "Jane Contact", ContactID: 1,
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "jane@test.com", IsActive: true},
+			{ChannelType: database.SmsChannel, Address: "5125551313", IsActive: true}}}
 	c1Get.Name = c1Update.Name
-	c1Get.EmailAddress = c1Update.EmailAddress
-	c1Get.SmsNumber = c1Update.SmsNumber
-	c1Get.EmailActive = c1Update.EmailActive
-	c1Get.SmsActive = c1Update.SmsActive
+	for _, ch := range c1Update.Channels {
+		c1Get.SetChannel(ch.ChannelType, ch.Address, ch.IsActive)
+	}
 	err = c1Get.UpdateContact(db)
 	if err != nil {
 		t.Error("Failed to update the first contact.")
 	}
+	// The saved channels are returned along with the contact.
+	c1Update.Channels = c1Get.Channels
 
 	// Get the first contact again after update
 	c1Get2 := database.Contact{}
@@ -224,8 +243,9 @@ func TestDeleteContact(t *testing.T) {
 	}
 
 	// Create first contact - ContactID is for referencing the contact get test
-	c := database.Contact{Name: "Joe Contact", EmailAddress: "joe@test.com", SmsNumber: "5125551212",
-		SmsActive: false, EmailActive: false, ContactID: 1}
+	c := database.Contact{Name: "Joe Contact", ContactID: 1,
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "joe@test.com"},
+			{ChannelType: database.SmsChannel, Address: "5125551212"}}}
 	err = c.CreateContact(db)
 	if err != nil {
 		t.Fatal("Failed to create new contact:", err)
@@ -237,8 +257,9 @@ func TestDeleteContact(t *testing.T) {
 	}
 
 	// Create second contact
-	c2 := database.Contact{Name: "Jill Contact", EmailAddress: "jill@test.com", SmsNumber: "5125551213",
-		SmsActive: false, EmailActive: false}
+	c2 := database.Contact{Name: "Jill Contact",
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "jill@test.com"},
+			{ChannelType: database.SmsChannel, Address: "5125551213"}}}
 	err = c2.CreateContact(db)
 	if err != nil {
 		t.Fatal("Failed to create new site:", err)
@@ -342,7 +363,7 @@ func TestUpdateSiteStatus(t *testing.T) {
 	}
 
 	// Update the status of the site to down
-	err = s.UpdateSiteStatus(db, false)
+	err = s.UpdateSiteStatus(db, false, time.Now(), nil)
 	if err != nil {
 		t.Fatal("Failed to update site status:", err)
 	}
@@ -358,8 +379,22 @@ func TestUpdateSiteStatus(t *testing.T) {
 		t.Errorf("Site status should be down.")
 	}
 
+	// Acknowledge the outage, which is cleared when the site is back up.
+	acknowledgedAt := time.Date(2015, time.November, 10, 23, 30, 0, 0, time.UTC)
+	err = s.AcknowledgeSite(db, "jack", acknowledgedAt)
+	if err != nil {
+		t.Fatal("Failed to acknowledge site:", err)
+	}
+	err = updatedSite.GetSite(db, s.SiteID)
+	if err != nil {
+		t.Fatal("Failed to retrieve updated site:", err)
+	}
+	if updatedSite.AcknowledgedBy != "jack" || !updatedSite.AcknowledgedAt.Equal(acknowledgedAt) {
+		t.Errorf("Site acknowledgement not saved: %s at %s.", updatedSite.AcknowledgedBy, updatedSite.AcknowledgedAt)
+	}
+
 	// Update the status of the site to up
-	err = s.UpdateSiteStatus(db, true)
+	err = s.UpdateSiteStatus(db, true, time.Now(), nil)
 	if err != nil {
 		t.Fatal("Failed to update site status:", err)
 	}
@@ -380,10 +415,30 @@ func TestUpdateSiteStatus(t *testing.T) {
 		t.Errorf("Site status should be up.")
 	}
 
+	if updatedSite.AcknowledgedBy != "" || !updatedSite.AcknowledgedAt.IsZero() {
+		t.Errorf("Site acknowledgement should be cleared when the status changes.")
+	}
+
 	if updatedSite.FirstPing != firstPingTime {
 		t.Errorf("Site first ping time %s does not match input %s.", updatedSite.FirstPing, firstPingTime)
 	}
 
+	// Mark the site as flapping.
+	err = s.UpdateSiteFlapping(db, true)
+	if err != nil {
+		t.Fatal("Failed to update site flapping:", err)
+	}
+
+	var sites database.Sites
+	err = sites.GetSites(db, true, false)
+	if err != nil {
+		t.Fatal("Failed to retrieve sites:", err)
+	}
+
+	if !sites[0].IsFlapping {
+		t.Errorf("Site should be flapping.")
+	}
+
 }
 
 // TestCreateAndGetUnattachedContacts tests the creation of contacts not associated with a site.
@@ -395,24 +450,27 @@ func TestCreateAndGetUnattachedContacts(t *testing.T) {
 	defer db.Close()
 
 	// Create first contact
-	c := database.Contact{Name: "Joe Contact", EmailAddress: "joe@test.com", SmsNumber: "5125551212",
-		SmsActive: false, EmailActive: false, SiteCount: 0}
+	c := database.Contact{Name: "Joe Contact", SiteCount: 0,
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "joe@test.com"},
+			{ChannelType: database.SmsChannel, Address: "5125551212"}}}
 	err = c.CreateContact(db)
 	if err != nil {
 		t.Fatal("Failed to create new contact:", err)
 	}
 
 	// Create second contact
-	c2 := database.Contact{Name: "Jack Contact", EmailAddress: "jack@test.com", SmsNumber: "5125551213",
-		SmsActive: false, EmailActive: false, SiteCount: 0}
+	c2 := database.Contact{Name: "Jack Contact", SiteCount: 0,
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "jack@test.com"},
+			{ChannelType: database.SmsChannel, Address: "5125551213"}}}
 	err = c2.CreateContact(db)
 	if err != nil {
 		t.Fatal("Failed to create new contact:", err)
 	}
 
 	// Create third contact with name conflict.
-	c3 := database.Contact{Name: "Jack Contact", EmailAddress: "jack@test.com", SmsNumber: "5125551213",
-		SmsActive: false, EmailActive: false}
+	c3 := database.Contact{Name: "Jack Contact",
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "jack@test.com"},
+			{ChannelType: database.SmsChannel, Address: "5125551213"}}}
 	err = c3.CreateContact(db)
 	if err == nil {
 		t.Fatal("Conflicting contact should throw error.")
@@ -450,9 +508,10 @@ func TestCreatePings(t *testing.T) {
 		t.Fatal("Failed to create new site:", err)
 	}
 
-	// Create a ping result
+	// Create a ping result with step timings
 	p1 := database.Ping{SiteID: s.SiteID, TimeRequest: time.Date(2015, time.November, 10, 23, 22, 22, 00, time.UTC),
-		Duration: 280, HTTPStatusCode: 200, SiteDown: false}
+		Duration: 280, HTTPStatusCode: 200, SiteDown: false,
+		Steps: []database.PingStep{{Name: "connect", Duration: 30}, {Name: "banner", Duration: 250}}}
 	err = p1.CreatePing(db)
 	if err != nil {
 		t.Fatal("Failed to create new ping:", err)
@@ -552,8 +611,9 @@ func TestCreateAndGetMultipleSites(t *testing.T) {
 	}
 
 	// Create first contact
-	c1 := database.Contact{Name: "Joe Contact", EmailAddress: "joe@test.com", SmsNumber: "5125551212",
-		SmsActive: false, EmailActive: false}
+	c1 := database.Contact{Name: "Joe Contact",
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "joe@test.com"},
+			{ChannelType: database.SmsChannel, Address: "5125551212"}}}
 	err = c1.CreateContact(db)
 	if err != nil {
 		t.Fatal("Failed to create new contact:", err)
@@ -569,8 +629,9 @@ func TestCreateAndGetMultipleSites(t *testing.T) {
 	}
 
 	// Create second contact
-	c2 := database.Contact{Name: "Jack Contact", EmailAddress: "jack@test.com", SmsNumber: "5125551213",
-		SmsActive: false, EmailActive: false}
+	c2 := database.Contact{Name: "Jack Contact",
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "jack@test.com"},
+			{ChannelType: database.SmsChannel, Address: "5125551213"}}}
 	err = c2.CreateContact(db)
 	if err != nil {
 		t.Fatal("Failed to create new contact:", err)
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DeliveryAttempt records one attempt to deliver a notification to an
+// endpoint, whether it succeeded and the error of the provider if it didn't.
+// The Summary is the start of the notification's message and the SiteName is
+// filled in when the attempts are read.
+type DeliveryAttempt struct {
+	DeliveryAttemptID int64
+	OutboxMessageID   int64
+	SiteID            int64
+	SiteName          string
+	Recipient         string
+	ChannelType       string
+	Address           string
+	Event             string
+	Subject           string
+	Summary           string
+	Success           bool
+	Error             string
+	AttemptedAt       time.Time
+}
+
+// DeliveryAttempts is a slice of delivery attempts.
+type DeliveryAttempts []DeliveryAttempt
+
+// DeliveryFilter narrows the delivery attempts that are read. A zero SiteID
+// or blank field matches all, and Success is "yes" or "no" to match only the
+// attempts that succeeded or failed.
+type DeliveryFilter struct {
+	SiteID      int64
+	ChannelType string
+	Recipient   string
+	Success     string
+	Limit       int
+}
+
+// CreateDeliveryAttempt saves the delivery attempt.
+func (a *DeliveryAttempt) CreateDeliveryAttempt(db *sql.DB) error {
+	result, err := db.Exec(`INSERT INTO DeliveryAttempts (OutboxMessageId, SiteId, Recipient, ChannelType,
+		Address, Event, Subject, Summary, Success, Error, AttemptedAt)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		a.OutboxMessageID, a.SiteID, a.Recipient, a.ChannelType, a.Address, a.Event, a.Subject,
+		a.Summary, a.Success, a.Error, a.AttemptedAt)
+	if err != nil {
+		return err
+	}
+	a.DeliveryAttemptID, err = result.LastInsertId()
+	return err
+}
+
+// GetDeliveryAttempts gets the most recent delivery attempts that match the
+// filter, newest first.
+func (d *DeliveryAttempts) GetDeliveryAttempts(db *sql.DB, filter DeliveryFilter) error {
+	success := -1
+	switch filter.Success {
+	case "yes":
+		success = 1
+	case "no":
+		success = 0
+	}
+	rows, err := db.Query(`SELECT d.DeliveryAttemptId, d.OutboxMessageId, d.SiteId, COALESCE(s.Name, ''),
+		d.Recipient, d.ChannelType, d.Address, d.Event, d.Subject, d.Summary, d.Success, d.Error, d.AttemptedAt
+		FROM DeliveryAttempts d LEFT JOIN Sites s ON s.SiteId = d.SiteId
+		WHERE ($1 = 0 OR d.SiteId = $1) AND ($2 = '' OR d.ChannelType = $2)
+		AND ($3 = '' OR d.Recipient LIKE '%' || $3 || '%') AND ($4 < 0 OR d.Success = $4)
+		ORDER BY d.DeliveryAttemptId DESC LIMIT $5`,
+		filter.SiteID, filter.ChannelType, filter.Recipient, success, filter.Limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a DeliveryAttempt
+		err = rows.Scan(&a.DeliveryAttemptID, &a.OutboxMessageID, &a.SiteID, &a.SiteName, &a.Recipient,
+			&a.ChannelType, &a.Address, &a.Event, &a.Subject, &a.Summary, &a.Success, &a.Error, &a.AttemptedAt)
+		if err != nil {
+			return err
+		}
+		*d = append(*d, a)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,75 @@
+package database_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// TestDeliveryAttempts tests that the delivery attempts are read newest first
+// with the name of their site and narrowed by the filter.
+func TestDeliveryAttempts(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+
+	s := database.Site{Name: "Test", IsActive: true, URL: "http://www.test.com",
+		PingIntervalSeconds: 60, TimeoutSeconds: 30}
+	err = s.CreateSite(db)
+	if err != nil {
+		t.Fatal("Failed to create new site:", err)
+	}
+
+	now := time.Now()
+	attempts := []database.DeliveryAttempt{
+		{SiteID: s.SiteID, Recipient: "Joe Contact", ChannelType: database.EmailChannel, Address: "joe@test.com",
+			Event: database.DownEvent, Subject: "Test: Site is Down", Success: true, AttemptedAt: now},
+		{SiteID: s.SiteID, Recipient: "Jack Contact", ChannelType: database.SmsChannel, Address: "5125551212",
+			Event: database.DownEvent, Subject: "Test: Site is Down", Error: "no response", AttemptedAt: now},
+		{SiteID: s.SiteID, Recipient: "Joe Contact", ChannelType: database.EmailChannel, Address: "joe@test.com",
+			Event: database.UpEvent, Subject: "Test: Site is Up", Success: true, AttemptedAt: now},
+	}
+	for i := range attempts {
+		err = attempts[i].CreateDeliveryAttempt(db)
+		if err != nil {
+			t.Fatal("Failed to create delivery attempt:", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter database.DeliveryFilter
+		want   []int
+	}{
+		{"all", database.DeliveryFilter{}, []int{2, 1, 0}},
+		{"site", database.DeliveryFilter{SiteID: s.SiteID}, []int{2, 1, 0}},
+		{"other site", database.DeliveryFilter{SiteID: s.SiteID + 1}, nil},
+		{"channel", database.DeliveryFilter{ChannelType: database.SmsChannel}, []int{1}},
+		{"recipient", database.DeliveryFilter{Recipient: "joe"}, []int{2, 0}},
+		{"failed", database.DeliveryFilter{Success: "no"}, []int{1}},
+		{"delivered", database.DeliveryFilter{Success: "yes"}, []int{2, 0}},
+		{"limit", database.DeliveryFilter{Limit: 1}, []int{2}},
+	}
+	for _, test := range tests {
+		if test.filter.Limit == 0 {
+			test.filter.Limit = 10
+		}
+		var got database.DeliveryAttempts
+		err = got.GetDeliveryAttempts(db, test.filter)
+		if err != nil {
+			t.Fatal("Failed to get delivery attempts:", err)
+		}
+		if len(got) != len(test.want) {
+			t.Error(test.name+": delivery attempts not as expected:", got)
+			continue
+		}
+		for i, j := range test.want {
+			if got[i].DeliveryAttemptID != attempts[j].DeliveryAttemptID || got[i].SiteName != "Test" {
+				t.Error(test.name+": delivery attempts not as expected:", got)
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package database
+
+import "database/sql"
+
+// SiteDependencies maps the ID of each site to the IDs of the parent sites it
+// depends on, such as a load balancer or a shared database.
+type SiteDependencies map[int64][]int64
+
+// GetSiteParents gets the IDs of the parent sites that the site depends on.
+func (s *Site) GetSiteParents(db *sql.DB) error {
+	rows, err := db.Query(`SELECT ParentSiteID FROM SiteDependencies
+		WHERE SiteID = $1 ORDER BY ParentSiteID`, s.SiteID)
+	if err != nil {
+		return err
+	}
+
+	// nil out the slice in case it is rereading it from the DB.
+	s.ParentSiteIDs = nil
+	defer rows.Close()
+	for rows.Next() {
+		var ParentSiteID int64
+		err = rows.Scan(&ParentSiteID)
+		if err != nil {
+			return err
+		}
+		s.ParentSiteIDs = append(s.ParentSiteIDs, ParentSiteID)
+	}
+	return nil
+}
+
+// SetSiteParents replaces the parent sites that the site depends on.
+func (s *Site) SetSiteParents(db *sql.DB, parentSiteIDs []int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM SiteDependencies WHERE SiteID = $1", s.SiteID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, parentSiteID := range parentSiteIDs {
+		_, err = tx.Exec(
+			"INSERT INTO SiteDependencies (SiteID, ParentSiteID) VALUES ($1, $2)",
+			s.SiteID,
+			parentSiteID,
+		)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	s.ParentSiteIDs = parentSiteIDs
+	return nil
+}
+
+// GetSiteDependencies gets the parent sites of all of the sites.
+func GetSiteDependencies(db *sql.DB) (SiteDependencies, error) {
+	rows, err := db.Query(`SELECT SiteID, ParentSiteID FROM SiteDependencies
+		ORDER BY SiteID, ParentSiteID`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	dependencies := make(SiteDependencies)
+	for rows.Next() {
+		var SiteID int64
+		var ParentSiteID int64
+		err = rows.Scan(&SiteID, &ParentSiteID)
+		if err != nil {
+			return nil, err
+		}
+		dependencies[SiteID] = append(dependencies[SiteID], ParentSiteID)
+	}
+	return dependencies, nil
+}
+
+// DependsOn returns true if the site depends on the other site, directly or
+// through its parents.
+func (d SiteDependencies) DependsOn(siteID int64, otherSiteID int64) bool {
+	visited := make(map[int64]bool)
+	pending := append([]int64{}, d[siteID]...)
+	for len(pending) > 0 {
+		parentSiteID := pending[0]
+		pending = pending[1:]
+		if parentSiteID == otherSiteID {
+			return true
+		}
+		if visited[parentSiteID] {
+			continue
+		}
+		visited[parentSiteID] = true
+		pending = append(pending, d[parentSiteID]...)
+	}
+	return false
+}
+
+// GetSitesLastPingDown gets whether the latest ping of each site failed. A site
+// held up because its parent is down still records its failing pings.
+func GetSitesLastPingDown(db *sql.DB) (map[int64]bool, error) {
+	rows, err := db.Query(`SELECT p.SiteID, p.SiteDown FROM Pings p
+		JOIN Sites s ON s.SiteID = p.SiteID AND s.LastPing = p.TimeRequest`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	down := make(map[int64]bool)
+	for rows.Next() {
+		var SiteID int64
+		var SiteDown bool
+		err = rows.Scan(&SiteID, &SiteDown)
+		if err != nil {
+			return nil, err
+		}
+		down[SiteID] = SiteDown
+	}
+	return down, nil
+}
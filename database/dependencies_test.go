@@ -0,0 +1,86 @@
+package database_test
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// TestSiteDependencies tests setting and getting the parent sites of a site.
+func TestSiteDependencies(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+
+	var sites database.Sites
+	for i, name := range []string{"Load Balancer", "Database", "App", "Reports"} {
+		s := database.Site{Name: name, IsActive: true, URL: "http://www.example.com/" + strconv.Itoa(i),
+			PingIntervalSeconds: 60, TimeoutSeconds: 30}
+		err = s.CreateSite(db)
+		if err != nil {
+			t.Fatal("Failed to create new site:", err)
+		}
+		sites = append(sites, s)
+	}
+	lb, dbSite, app, reports := sites[0], sites[1], sites[2], sites[3]
+
+	err = app.SetSiteParents(db, []int64{lb.SiteID, dbSite.SiteID})
+	if err != nil {
+		t.Fatal("Failed to set parent sites:", err)
+	}
+	err = reports.SetSiteParents(db, []int64{app.SiteID})
+	if err != nil {
+		t.Fatal("Failed to set parent sites:", err)
+	}
+
+	var saved database.Site
+	saved.SiteID = app.SiteID
+	err = saved.GetSiteParents(db)
+	if err != nil {
+		t.Fatal("Failed to get parent sites:", err)
+	}
+	if !reflect.DeepEqual(saved.ParentSiteIDs, []int64{lb.SiteID, dbSite.SiteID}) {
+		t.Error("Parent sites not saved as expected:", saved.ParentSiteIDs)
+	}
+
+	// Replacing the parents removes the ones that aren't selected.
+	err = app.SetSiteParents(db, []int64{dbSite.SiteID})
+	if err != nil {
+		t.Fatal("Failed to set parent sites:", err)
+	}
+	dependencies, err := database.GetSiteDependencies(db)
+	if err != nil {
+		t.Fatal("Failed to get site dependencies:", err)
+	}
+	if !reflect.DeepEqual(dependencies[app.SiteID], []int64{dbSite.SiteID}) {
+		t.Error("Parent sites not replaced as expected:", dependencies)
+	}
+
+	if !dependencies.DependsOn(reports.SiteID, dbSite.SiteID) {
+		t.Error("Reports should depend on the database through the app.")
+	}
+	if dependencies.DependsOn(reports.SiteID, lb.SiteID) || dependencies.DependsOn(dbSite.SiteID, app.SiteID) {
+		t.Error("Dependencies should only follow the parent sites.")
+	}
+
+	start := time.Now().Add(-time.Minute)
+	for i, down := range []bool{false, true} {
+		p := database.Ping{SiteID: app.SiteID, TimeRequest: start.Add(time.Duration(i) * time.Second), SiteDown: down}
+		err = p.CreatePing(db)
+		if err != nil {
+			t.Fatal("Failed to create ping:", err)
+		}
+	}
+	lastDown, err := database.GetSitesLastPingDown(db)
+	if err != nil {
+		t.Fatal("Failed to get the last pings:", err)
+	}
+	if !lastDown[app.SiteID] || lastDown[lb.SiteID] {
+		t.Error("Only the latest ping of the app should be down:", lastDown)
+	}
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// The frequencies of the summary emails of the sites that the contacts can get.
+const (
+	DailyDigest   = "daily"
+	WeeklyDigest  = "weekly"
+	MonthlyDigest = "monthly"
+)
+
+// DigestFrequencies are the frequencies of the summaries in the order they are
+// shown on the settings.
+var DigestFrequencies = []string{DailyDigest, WeeklyDigest, MonthlyDigest}
+
+// DigestEvent is the event of the summary emails in the delivery log.
+const DigestEvent = "digest"
+
+// SiteSummary is the uptime of a site over a period from its pings, leaving out
+// the ones taken during a maintenance window. The AvgResponse is of the pings
+// where the site was up in milliseconds. An incident is each time the site went
+// down, and the LongestOutage runs to the end of the period if it's still down.
+type SiteSummary struct {
+	SiteID        int64
+	Name          string
+	PingsUp       int
+	PingsDown     int
+	AvgResponse   float64
+	Incidents     int
+	LongestOutage time.Duration
+}
+
+// UptimePercent returns the percent of the pings where the site was up, which
+// is 0 if there are no pings.
+func (s SiteSummary) UptimePercent() float64 {
+	if s.PingsUp+s.PingsDown == 0 {
+		return 0
+	}
+	return 100.0 * float64(s.PingsUp) / float64(s.PingsUp+s.PingsDown)
+}
+
+// GetSiteSummaries gets the summaries of the active sites from the start of the
+// period up to the end.
+func GetSiteSummaries(db *sql.DB, start time.Time, end time.Time) ([]SiteSummary, error) {
+	rows, err := db.Query(`SELECT s.SiteID, s.Name, p.TimeRequest, p.Duration, p.SiteDown
+		FROM Sites s LEFT JOIN Pings p ON p.SiteID = s.SiteID AND p.Maintenance = 0
+			AND p.TimeRequest >= $1 AND p.TimeRequest < $2
+		WHERE s.IsActive = 1
+		ORDER BY s.Name, s.SiteID, p.TimeRequest`, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []SiteSummary
+	var totalResponse float64
+	var downSince time.Time
+	// finish adds the outage that the last site is still in at the end.
+	finish := func() {
+		if len(summaries) == 0 {
+			return
+		}
+		last := &summaries[len(summaries)-1]
+		if !downSince.IsZero() && end.Sub(downSince) > last.LongestOutage {
+			last.LongestOutage = end.Sub(downSince)
+		}
+		if last.PingsUp > 0 {
+			last.AvgResponse = totalResponse / float64(last.PingsUp)
+		}
+	}
+	for rows.Next() {
+		var siteID int64
+		var name string
+		var timeRequest sql.NullTime
+		var duration sql.NullInt64
+		var siteDown sql.NullBool
+		err = rows.Scan(&siteID, &name, &timeRequest, &duration, &siteDown)
+		if err != nil {
+			return nil, err
+		}
+		if len(summaries) == 0 || summaries[len(summaries)-1].SiteID != siteID {
+			finish()
+			summaries = append(summaries, SiteSummary{SiteID: siteID, Name: name})
+			totalResponse = 0
+			downSince = time.Time{}
+		}
+		if !timeRequest.Valid {
+			continue
+		}
+		summary := &summaries[len(summaries)-1]
+		if siteDown.Bool {
+			summary.PingsDown++
+			if downSince.IsZero() {
+				summary.Incidents++
+				downSince = timeRequest.Time
+			}
+			continue
+		}
+		summary.PingsUp++
+		totalResponse += float64(duration.Int64)
+		if !downSince.IsZero() {
+			if outage := timeRequest.Time.Sub(downSince); outage > summary.LongestOutage {
+				summary.LongestOutage = outage
+			}
+			downSince = time.Time{}
+		}
+	}
+	finish()
+	return summaries, rows.Err()
+}
+
+// GetDigestContacts gets the contacts with their channels that get the
+// summaries of the frequency.
+func GetDigestContacts(db *sql.DB, frequency string) (Contacts, error) {
+	rows, err := db.Query(`SELECT ContactID, Name, Digest FROM Contacts
+		WHERE Digest = $1 ORDER BY Name`, frequency)
+	if err != nil {
+		return nil, err
+	}
+	var contacts Contacts
+	defer rows.Close()
+	for rows.Next() {
+		var c Contact
+		err = rows.Scan(&c.ContactID, &c.Name, &c.Digest)
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, c)
+	}
+	rows.Close()
+
+	for i := range contacts {
+		err = contacts[i].GetContactChannels(db)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return contacts, nil
+}
+
+// GetDigestRun gets the end of the last period that the summaries of the
+// frequency were sent for, which is zero if they never were.
+func GetDigestRun(db *sql.DB, frequency string) (time.Time, error) {
+	var periodEnd time.Time
+	err := db.QueryRow(`SELECT PeriodEnd FROM DigestRuns WHERE Frequency = $1`, frequency).
+		Scan(&periodEnd)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return periodEnd, err
+}
+
+// SetDigestRun saves the end of the last period that the summaries of the
+// frequency were sent for.
+func SetDigestRun(db *sql.DB, frequency string, periodEnd time.Time) error {
+	_, err := db.Exec(`INSERT OR REPLACE INTO DigestRuns (Frequency, PeriodEnd) VALUES ($1, $2)`,
+		frequency, periodEnd)
+	return err
+}
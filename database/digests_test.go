@@ -0,0 +1,117 @@
+package database_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// TestSiteSummaries tests the uptime, response time, incidents and longest
+// outage of the sites over a period.
+func TestSiteSummaries(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+
+	s1 := database.Site{Name: "Alpha", IsActive: true, URL: "http://www.alpha.com",
+		PingIntervalSeconds: 60, TimeoutSeconds: 30}
+	s2 := database.Site{Name: "Beta", IsActive: true, URL: "http://www.beta.com",
+		PingIntervalSeconds: 60, TimeoutSeconds: 30}
+	for _, s := range []*database.Site{&s1, &s2} {
+		err = s.CreateSite(db)
+		if err != nil {
+			t.Fatal("Failed to create new site:", err)
+		}
+	}
+
+	start := time.Date(2026, 10, 12, 0, 0, 0, 0, time.Local)
+	end := start.AddDate(0, 0, 7)
+	at := func(hours float64) time.Time { return start.Add(time.Duration(hours * float64(time.Hour))) }
+	pings := []database.Ping{
+		// Before the period.
+		{SiteID: s1.SiteID, TimeRequest: at(-1), Duration: 900, SiteDown: true},
+		{SiteID: s1.SiteID, TimeRequest: at(1), Duration: 100},
+		{SiteID: s1.SiteID, TimeRequest: at(2), SiteDown: true},
+		{SiteID: s1.SiteID, TimeRequest: at(3), SiteDown: true},
+		{SiteID: s1.SiteID, TimeRequest: at(5), Duration: 300},
+		// Down during maintenance isn't counted.
+		{SiteID: s1.SiteID, TimeRequest: at(6), SiteDown: true, Maintenance: true},
+		{SiteID: s1.SiteID, TimeRequest: at(7), SiteDown: true},
+		{SiteID: s1.SiteID, TimeRequest: at(8), Duration: 200},
+		// Still down at the end of the period.
+		{SiteID: s1.SiteID, TimeRequest: end.Add(-time.Hour), SiteDown: true},
+		// After the period.
+		{SiteID: s1.SiteID, TimeRequest: end.Add(time.Hour), Duration: 900},
+	}
+	for _, p := range pings {
+		err = p.CreatePing(db)
+		if err != nil {
+			t.Fatal("Failed to create ping:", err)
+		}
+	}
+
+	summaries, err := database.GetSiteSummaries(db, start, end)
+	if err != nil {
+		t.Fatal("Failed to get site summaries:", err)
+	}
+	if len(summaries) != 2 || summaries[0].Name != "Alpha" || summaries[1].Name != "Beta" {
+		t.Fatal("Summaries of the active sites not as expected:", summaries)
+	}
+	alpha := summaries[0]
+	if alpha.PingsUp != 3 || alpha.PingsDown != 4 || alpha.AvgResponse != 200 || alpha.Incidents != 3 ||
+		alpha.LongestOutage != 3*time.Hour {
+		t.Error("Summary of the site not as expected:", alpha)
+	}
+	if uptime := alpha.UptimePercent(); uptime < 42.8 || uptime > 42.9 {
+		t.Error("Uptime of the site should be 3 of 7 pings, got", uptime)
+	}
+	if summaries[1].PingsUp != 0 || summaries[1].UptimePercent() != 0 {
+		t.Error("Site without pings should have an empty summary:", summaries[1])
+	}
+}
+
+// TestDigestRuns tests saving the last period that the digests were sent for.
+func TestDigestRuns(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+
+	last, err := database.GetDigestRun(db, database.WeeklyDigest)
+	if err != nil || !last.IsZero() {
+		t.Fatal("There should be no weekly digest run yet:", last, err)
+	}
+	periodEnd := time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local)
+	for _, end := range []time.Time{periodEnd.AddDate(0, 0, -7), periodEnd} {
+		err = database.SetDigestRun(db, database.WeeklyDigest, end)
+		if err != nil {
+			t.Fatal("Failed to save the digest run:", err)
+		}
+	}
+	last, err = database.GetDigestRun(db, database.WeeklyDigest)
+	if err != nil || !last.Equal(periodEnd) {
+		t.Error("The weekly digest run should be the last period:", last, err)
+	}
+
+	c := database.Contact{Name: "Joe Contact", Digest: database.WeeklyDigest,
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "joe@test.com", IsActive: true}}}
+	err = c.CreateContact(db)
+	if err != nil {
+		t.Fatal("Failed to create new contact:", err)
+	}
+	contacts, err := database.GetDigestContacts(db, database.WeeklyDigest)
+	if err != nil {
+		t.Fatal("Failed to get the digest contacts:", err)
+	}
+	if len(contacts) != 1 || len(contacts[0].Channels) != 1 || contacts[0].Channels[0].Address != "joe@test.com" {
+		t.Error("The contact should get the weekly digest:", contacts)
+	}
+	contacts, err = database.GetDigestContacts(db, database.DailyDigest)
+	if err != nil || len(contacts) != 0 {
+		t.Error("No contacts should get the daily digest:", contacts, err)
+	}
+}
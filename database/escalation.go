@@ -0,0 +1,231 @@
+package database
+
+import "database/sql"
+
+// EscalationPolicy notifies its tiers of contacts in order while an outage of
+// a site isn't acknowledged. The first tier is notified when the site goes
+// down and each of the others once the site has been down for its delay.
+type EscalationPolicy struct {
+	EscalationPolicyID int64
+	Name               string
+	Tiers              []EscalationTier
+	SiteCount          int
+}
+
+// EscalationPolicies is a slice of escalation policies.
+type EscalationPolicies []EscalationPolicy
+
+// EscalationTier is one of the tiers of contacts of an escalation policy, the
+// DelayMinutes is from the start of the outage.
+type EscalationTier struct {
+	EscalationTierID   int64
+	EscalationPolicyID int64
+	TierOrder          int
+	DelayMinutes       int
+	Contacts           []Contact
+}
+
+// CreateEscalationPolicy inserts a new escalation policy with its tiers in the DB.
+func (p *EscalationPolicy) CreateEscalationPolicy(db *sql.DB) error {
+	result, err := db.Exec(`INSERT INTO EscalationPolicies (Name) VALUES ($1)`, p.Name)
+	if err != nil {
+		return err
+	}
+	p.EscalationPolicyID, err = result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	return p.setEscalationTiers(db)
+}
+
+// UpdateEscalationPolicy updates the escalation policy and replaces its tiers in the DB.
+func (p *EscalationPolicy) UpdateEscalationPolicy(db *sql.DB) error {
+	_, err := db.Exec(`UPDATE EscalationPolicies SET Name = $1 WHERE EscalationPolicyID = $2`,
+		p.Name, p.EscalationPolicyID)
+	if err != nil {
+		return err
+	}
+	return p.setEscalationTiers(db)
+}
+
+// DeleteEscalationPolicy deletes the escalation policy and its tiers, the sites
+// that used it go back to notifying their contacts.
+func (p *EscalationPolicy) DeleteEscalationPolicy(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	statements := []string{
+		`DELETE FROM EscalationTierContacts WHERE EscalationTierID IN
+			(SELECT EscalationTierID FROM EscalationTiers WHERE EscalationPolicyID = $1)`,
+		`DELETE FROM EscalationTiers WHERE EscalationPolicyID = $1`,
+		`UPDATE Sites SET EscalationPolicyID = 0 WHERE EscalationPolicyID = $1`,
+		`DELETE FROM EscalationPolicies WHERE EscalationPolicyID = $1`,
+	}
+	for _, statement := range statements {
+		_, err = tx.Exec(statement, p.EscalationPolicyID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// setEscalationTiers replaces the tiers of the policy with p.Tiers, numbering
+// them in order.
+func (p *EscalationPolicy) setEscalationTiers(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM EscalationTierContacts WHERE EscalationTierID IN
+		(SELECT EscalationTierID FROM EscalationTiers WHERE EscalationPolicyID = $1)`, p.EscalationPolicyID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM EscalationTiers WHERE EscalationPolicyID = $1`, p.EscalationPolicyID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for i := range p.Tiers {
+		tier := &p.Tiers[i]
+		tier.EscalationPolicyID = p.EscalationPolicyID
+		tier.TierOrder = i + 1
+		result, err := tx.Exec(`INSERT INTO EscalationTiers (EscalationPolicyID, TierOrder, DelayMinutes)
+			VALUES ($1, $2, $3)`, tier.EscalationPolicyID, tier.TierOrder, tier.DelayMinutes)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		tier.EscalationTierID, err = result.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		for _, c := range tier.Contacts {
+			_, err = tx.Exec(`INSERT INTO EscalationTierContacts (EscalationTierID, ContactID)
+				VALUES ($1, $2)`, tier.EscalationTierID, c.ContactID)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// GetEscalationPolicy gets the escalation policy with its tiers and their contacts.
+func (p *EscalationPolicy) GetEscalationPolicy(db *sql.DB, escalationPolicyID int64) error {
+	err := db.QueryRow(`SELECT p.EscalationPolicyID, p.Name,
+		(SELECT COUNT(*) FROM Sites s WHERE s.EscalationPolicyID = p.EscalationPolicyID)
+		FROM EscalationPolicies p WHERE p.EscalationPolicyID = $1`, escalationPolicyID).
+		Scan(&p.EscalationPolicyID, &p.Name, &p.SiteCount)
+	if err != nil {
+		return err
+	}
+	return p.getEscalationTiers(db)
+}
+
+// GetEscalationPolicies gets all of the escalation policies with their tiers.
+func (e *EscalationPolicies) GetEscalationPolicies(db *sql.DB) error {
+	rows, err := db.Query(`SELECT p.EscalationPolicyID, p.Name,
+		(SELECT COUNT(*) FROM Sites s WHERE s.EscalationPolicyID = p.EscalationPolicyID)
+		FROM EscalationPolicies p ORDER BY p.Name`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var p EscalationPolicy
+		err = rows.Scan(&p.EscalationPolicyID, &p.Name, &p.SiteCount)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		*e = append(*e, p)
+	}
+	rows.Close()
+
+	for i := range *e {
+		err = (*e)[i].getEscalationTiers(db)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getEscalationTiers gets the tiers of the policy in order, with the channels
+// of their contacts.
+func (p *EscalationPolicy) getEscalationTiers(db *sql.DB) error {
+	rows, err := db.Query(`SELECT EscalationTierID, TierOrder, DelayMinutes FROM EscalationTiers
+		WHERE EscalationPolicyID = $1 ORDER BY TierOrder`, p.EscalationPolicyID)
+	if err != nil {
+		return err
+	}
+	// nil out the slice in case it is rereading it from the DB.
+	p.Tiers = nil
+	for rows.Next() {
+		tier := EscalationTier{EscalationPolicyID: p.EscalationPolicyID}
+		err = rows.Scan(&tier.EscalationTierID, &tier.TierOrder, &tier.DelayMinutes)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		p.Tiers = append(p.Tiers, tier)
+	}
+	rows.Close()
+
+	for i := range p.Tiers {
+		err = p.Tiers[i].getTierContacts(db)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *EscalationTier) getTierContacts(db *sql.DB) error {
+	rows, err := db.Query(`SELECT c.ContactID, c.Name FROM Contacts c
+		JOIN EscalationTierContacts tc ON tc.ContactID = c.ContactID
+		WHERE tc.EscalationTierID = $1 ORDER BY c.Name`, t.EscalationTierID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var c Contact
+		err = rows.Scan(&c.ContactID, &c.Name)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		t.Contacts = append(t.Contacts, c)
+	}
+	rows.Close()
+
+	for i := range t.Contacts {
+		err = t.Contacts[i].GetContactChannels(db)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetSiteEscalationTiers gets the tiers of the site's escalation policy, there
+// are none if the site doesn't use one.
+func (s *Site) GetSiteEscalationTiers(db *sql.DB) error {
+	s.EscalationTiers = nil
+	if s.EscalationPolicyID == 0 {
+		return nil
+	}
+	p := EscalationPolicy{EscalationPolicyID: s.EscalationPolicyID}
+	err := p.getEscalationTiers(db)
+	if err != nil {
+		return err
+	}
+	s.EscalationTiers = p.Tiers
+	return nil
+}
@@ -0,0 +1,103 @@
+package database_test
+
+import (
+	"testing"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// TestEscalationPolicies tests that the tiers of a policy are saved in order
+// with their contacts, and that deleting the policy leaves its sites without one.
+func TestEscalationPolicies(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+
+	oncall := database.Contact{Name: "On Call",
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "oncall@test.com", IsActive: true}}}
+	manager := database.Contact{Name: "Manager",
+		Channels: []database.ContactChannel{{ChannelType: database.SmsChannel, Address: "5125551212", IsActive: true}}}
+	for _, c := range []*database.Contact{&oncall, &manager} {
+		err = c.CreateContact(db)
+		if err != nil {
+			t.Fatal("Failed to create new contact:", err)
+		}
+	}
+
+	p := database.EscalationPolicy{Name: "Production", Tiers: []database.EscalationTier{
+		{DelayMinutes: 0, Contacts: []database.Contact{oncall}},
+		{DelayMinutes: 15, Contacts: []database.Contact{oncall, manager}},
+	}}
+	err = p.CreateEscalationPolicy(db)
+	if err != nil {
+		t.Fatal("Failed to create escalation policy:", err)
+	}
+
+	s := database.Site{Name: "Test", IsActive: true, URL: "http://www.test.com",
+		PingIntervalSeconds: 60, TimeoutSeconds: 30, EscalationPolicyID: p.EscalationPolicyID}
+	err = s.CreateSite(db)
+	if err != nil {
+		t.Fatal("Failed to create new site:", err)
+	}
+
+	var saved database.EscalationPolicy
+	err = saved.GetEscalationPolicy(db, p.EscalationPolicyID)
+	if err != nil {
+		t.Fatal("Failed to get escalation policy:", err)
+	}
+	if saved.Name != "Production" || saved.SiteCount != 1 || len(saved.Tiers) != 2 ||
+		saved.Tiers[1].TierOrder != 2 || saved.Tiers[1].DelayMinutes != 15 ||
+		len(saved.Tiers[1].Contacts) != 2 || saved.Tiers[1].Contacts[0].Name != "Manager" {
+		t.Error("Escalation policy not saved as expected:", saved)
+	}
+	if len(saved.Tiers[1].Contacts[0].ActiveChannels()) != 1 {
+		t.Error("Tier contacts should have their channels:", saved.Tiers[1].Contacts[0])
+	}
+
+	var site database.Site
+	err = site.GetSite(db, s.SiteID)
+	if err != nil {
+		t.Fatal("Failed to get site:", err)
+	}
+	err = site.GetSiteEscalationTiers(db)
+	if err != nil {
+		t.Fatal("Failed to get site escalation tiers:", err)
+	}
+	if site.EscalationPolicyID != p.EscalationPolicyID || len(site.EscalationTiers) != 2 {
+		t.Error("Site escalation tiers not as expected:", site.EscalationTiers)
+	}
+
+	// Updating replaces the tiers and deleting a contact removes it from them.
+	saved.Tiers = saved.Tiers[1:]
+	err = saved.UpdateEscalationPolicy(db)
+	if err != nil {
+		t.Fatal("Failed to update escalation policy:", err)
+	}
+	err = manager.DeleteContact(db)
+	if err != nil {
+		t.Fatal("Failed to delete contact:", err)
+	}
+	var policies database.EscalationPolicies
+	err = policies.GetEscalationPolicies(db)
+	if err != nil {
+		t.Fatal("Failed to get escalation policies:", err)
+	}
+	if len(policies) != 1 || len(policies[0].Tiers) != 1 || policies[0].Tiers[0].TierOrder != 1 ||
+		len(policies[0].Tiers[0].Contacts) != 1 || policies[0].Tiers[0].Contacts[0].Name != "On Call" {
+		t.Error("Escalation policy not updated as expected:", policies)
+	}
+
+	err = saved.DeleteEscalationPolicy(db)
+	if err != nil {
+		t.Fatal("Failed to delete escalation policy:", err)
+	}
+	err = site.GetSite(db, s.SiteID)
+	if err != nil {
+		t.Fatal("Failed to get site:", err)
+	}
+	if site.EscalationPolicyID != 0 {
+		t.Error("Site should have no escalation policy after it's deleted:", site.EscalationPolicyID)
+	}
+}
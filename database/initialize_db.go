@@ -114,7 +114,17 @@ func createSchema(db *sql.DB) error {
 //Seed represents the initial seed to the DB.~
 var Seed struct {
 	Sites    []Site
-	Contacts []Contact
+	Contacts []SeedContact
+}
+
+// SeedContact is a contact of the seed file, its email and SMS are added as
+// the channels of the contact.
+type SeedContact struct {
+	Name         string
+	EmailAddress string
+	SmsNumber    string
+	SmsActive    bool
+	EmailActive  bool
 }
 
 // seedInitialSites gets some initial sites from a config file
@@ -133,7 +143,10 @@ func seedInitialSites(db *sql.DB, seedFile string) error {
 			return err
 		}
 	}
-	for _, c := range Seed.Contacts {
+	for _, sc := range Seed.Contacts {
+		c := Contact{Name: sc.Name}
+		c.SetChannel(EmailChannel, sc.EmailAddress, sc.EmailActive)
+		c.SetChannel(SmsChannel, sc.SmsNumber, sc.SmsActive)
 		err = c.CreateContact(db)
 		if err != nil {
 			return err
@@ -168,8 +181,219 @@ const upgradeStatementsV3 = `
 	ON pings (TimeRequest, SiteDown);
 `
 
+const upgradeStatementsV4 = `
+	ALTER TABLE "Pings" ADD COLUMN "Steps" TEXT NOT NULL DEFAULT '';
+`
+
+const upgradeStatementsV5 = `
+	ALTER TABLE "Sites" ADD COLUMN "TransactionSteps" TEXT NOT NULL DEFAULT '';
+`
+
+const upgradeStatementsV6 = `
+	CREATE TABLE "MaintenanceWindows" (
+		"MaintenanceWindowId" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		"SiteId"              INTEGER NOT NULL DEFAULT 0,
+		"Description"         TEXT NOT NULL DEFAULT '',
+		"StartTime"           TIMESTAMP,
+		"EndTime"             TIMESTAMP,
+		"CronSchedule"        TEXT NOT NULL DEFAULT '',
+		"DurationMinutes"     INTEGER NOT NULL DEFAULT 0
+	);
+	ALTER TABLE "Pings" ADD COLUMN "Maintenance" INTEGER NOT NULL DEFAULT 0;
+`
+
+const upgradeStatementsV7 = `
+	CREATE TABLE "SiteDependencies" (
+		"SiteId"       INTEGER NOT NULL,
+		"ParentSiteId" INTEGER NOT NULL,
+		FOREIGN KEY("SiteId")       REFERENCES "Sites"("SiteId"),
+		FOREIGN KEY("ParentSiteId") REFERENCES "Sites"("SiteId")
+		PRIMARY KEY("SiteId","ParentSiteId")
+	);
+`
+
+const upgradeStatementsV8 = `
+	ALTER TABLE "Sites" ADD COLUMN "IsFlapping" INTEGER NOT NULL DEFAULT 0;
+`
+
+// The email and SMS of the contacts move to the ContactChannels, the columns
+// are left on the Contacts so the existing data isn't lost.
+const upgradeStatementsV9 = `
+	CREATE TABLE "ContactChannels" (
+		"ContactChannelId" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		"ContactId"        INTEGER NOT NULL,
+		"ChannelType"      TEXT NOT NULL,
+		"Address"          TEXT NOT NULL,
+		"IsActive"         INTEGER NOT NULL DEFAULT 1,
+		FOREIGN KEY("ContactId") REFERENCES "Contacts"("ContactId")
+	);
+	INSERT INTO "ContactChannels" (ContactId, ChannelType, Address, IsActive)
+		SELECT ContactId, 'email', EmailAddress, EmailActive FROM "Contacts"
+		WHERE EmailAddress <> '';
+	INSERT INTO "ContactChannels" (ContactId, ChannelType, Address, IsActive)
+		SELECT ContactId, 'sms', CAST(SmsNumber AS TEXT), SmsActive FROM "Contacts"
+		WHERE SmsNumber IS NOT NULL AND SmsNumber <> '';
+`
+
+const upgradeStatementsV10 = `
+	CREATE TABLE "SiteChannels" (
+		"SiteChannelId" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		"SiteId"        INTEGER NOT NULL,
+		"ChannelType"   TEXT NOT NULL,
+		"Address"       TEXT NOT NULL,
+		"IsActive"      INTEGER NOT NULL DEFAULT 1,
+		FOREIGN KEY("SiteId") REFERENCES "Sites"("SiteId")
+	);
+`
+
+// The reminders sent while a site stays down, none are sent if the
+// ReminderMinutes is 0 and there's no limit if the MaxReminders is 0.
+const upgradeStatementsV11 = `
+	ALTER TABLE "Sites" ADD COLUMN "ReminderMinutes" INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE "Sites" ADD COLUMN "MaxReminders" INTEGER NOT NULL DEFAULT 0;
+`
+
+// The escalation policies notify their tiers of contacts in turn, a site with
+// an EscalationPolicyId of 0 notifies its SiteContacts.
+const upgradeStatementsV12 = `
+	CREATE TABLE "EscalationPolicies" (
+		"EscalationPolicyId" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		"Name"               TEXT NOT NULL UNIQUE
+	);
+	CREATE TABLE "EscalationTiers" (
+		"EscalationTierId"   INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		"EscalationPolicyId" INTEGER NOT NULL,
+		"TierOrder"          INTEGER NOT NULL,
+		"DelayMinutes"       INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY("EscalationPolicyId") REFERENCES "EscalationPolicies"("EscalationPolicyId")
+	);
+	CREATE TABLE "EscalationTierContacts" (
+		"EscalationTierId" INTEGER NOT NULL,
+		"ContactId"        INTEGER NOT NULL,
+		FOREIGN KEY("EscalationTierId") REFERENCES "EscalationTiers"("EscalationTierId"),
+		FOREIGN KEY("ContactId")        REFERENCES "Contacts"("ContactId")
+		PRIMARY KEY("EscalationTierId","ContactId")
+	);
+	ALTER TABLE "Sites" ADD COLUMN "EscalationPolicyId" INTEGER NOT NULL DEFAULT 0;
+`
+
+// Who acknowledged the current outage of the site and when, cleared when the
+// status of the site changes.
+const upgradeStatementsV13 = `
+	ALTER TABLE "Sites" ADD COLUMN "AcknowledgedBy" TEXT NOT NULL DEFAULT '';
+	ALTER TABLE "Sites" ADD COLUMN "AcknowledgedAt" TIMESTAMP NOT NULL DEFAULT '0001-01-01 00:00:00+00:00';
+`
+
+// The custom notification templates by channel type and event, an empty
+// ChannelType is the template for all of the channels.
+const upgradeStatementsV14 = `
+	CREATE TABLE "NotificationTemplates" (
+		"ChannelType" TEXT NOT NULL,
+		"Event"       TEXT NOT NULL,
+		"Subject"     TEXT NOT NULL DEFAULT '',
+		"Body"        TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY("ChannelType","Event")
+	);
+`
+
+// The outbox of the notifications to deliver, each message is one endpoint with
+// the notification as JSON in the Payload.
+const upgradeStatementsV15 = `
+	CREATE TABLE "OutboxMessages" (
+		"OutboxMessageId" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		"SiteId"          INTEGER NOT NULL DEFAULT 0,
+		"ChannelType"     TEXT NOT NULL,
+		"Address"         TEXT NOT NULL,
+		"Recipient"       TEXT NOT NULL DEFAULT '',
+		"Subject"         TEXT NOT NULL DEFAULT '',
+		"Payload"         TEXT NOT NULL,
+		"Status"          TEXT NOT NULL DEFAULT 'pending',
+		"Attempts"        INTEGER NOT NULL DEFAULT 0,
+		"LastError"       TEXT NOT NULL DEFAULT '',
+		"CreatedAt"       TIMESTAMP NOT NULL,
+		"NextAttempt"     TIMESTAMP NOT NULL,
+		"SentAt"          TIMESTAMP NOT NULL DEFAULT '0001-01-01 00:00:00+00:00'
+	);
+	CREATE INDEX "OutboxMessagesStatus" ON "OutboxMessages" ("Status");
+`
+
+const upgradeStatementsV16 = `
+	CREATE TABLE "DeliveryAttempts" (
+		"DeliveryAttemptId" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		"OutboxMessageId"   INTEGER NOT NULL DEFAULT 0,
+		"SiteId"            INTEGER NOT NULL DEFAULT 0,
+		"Recipient"         TEXT NOT NULL DEFAULT '',
+		"ChannelType"       TEXT NOT NULL,
+		"Address"           TEXT NOT NULL,
+		"Event"             TEXT NOT NULL DEFAULT '',
+		"Subject"           TEXT NOT NULL DEFAULT '',
+		"Summary"           TEXT NOT NULL DEFAULT '',
+		"Success"           BOOLEAN NOT NULL,
+		"Error"             TEXT NOT NULL DEFAULT '',
+		"AttemptedAt"       TIMESTAMP NOT NULL
+	);
+	CREATE INDEX "DeliveryAttemptsSite" ON "DeliveryAttempts" ("SiteId");
+`
+
+// The Timezone of a contact is an IANA name such as America/Chicago, blank for
+// the server's time zone. The ContactSchedules are the hours that the contact
+// can be notified on a channel type, the Days are a bit mask of the weekdays
+// from Sunday. A critical site notifies its contacts regardless of them.
+const upgradeStatementsV17 = `
+	ALTER TABLE "Contacts" ADD COLUMN "Timezone" TEXT NOT NULL DEFAULT '';
+	ALTER TABLE "Sites" ADD COLUMN "IsCritical" BOOLEAN NOT NULL DEFAULT 0;
+	CREATE TABLE "ContactSchedules" (
+		"ContactId"   INTEGER NOT NULL,
+		"ChannelType" TEXT NOT NULL,
+		"Days"        INTEGER NOT NULL,
+		"StartTime"   TEXT NOT NULL,
+		"EndTime"     TEXT NOT NULL,
+		PRIMARY KEY ("ContactId", "ChannelType"),
+		FOREIGN KEY ("ContactId") REFERENCES "Contacts" ("ContactId")
+	);
+`
+
+// The events that each contact is notified of for a site and the channel types
+// used for them as comma separated lists, which are all of them when empty.
+const upgradeStatementsV18 = `
+	ALTER TABLE "SiteContacts" ADD COLUMN "Events" TEXT NOT NULL DEFAULT '';
+	ALTER TABLE "SiteContacts" ADD COLUMN "ChannelTypes" TEXT NOT NULL DEFAULT '';
+`
+
+// The Digest of a contact is how often it's emailed a summary of the sites,
+// blank for never. The DigestRuns have the end of the last period that the
+// summaries of each frequency were sent for.
+const upgradeStatementsV19 = `
+	ALTER TABLE "Contacts" ADD COLUMN "Digest" TEXT NOT NULL DEFAULT '';
+	CREATE TABLE "DigestRuns" (
+		"Frequency" TEXT NOT NULL PRIMARY KEY,
+		"PeriodEnd" TIMESTAMP NOT NULL
+	);
+`
+
+// The RecipientKey of an outbox message identifies the contact or site it's
+// for, since the names of the recipients aren't unique.
+const upgradeStatementsV20 = `
+	ALTER TABLE "OutboxMessages" ADD COLUMN "RecipientKey" TEXT NOT NULL DEFAULT '';
+`
+
+// The email and SMS of the contacts were moved to the ContactChannels in V9,
+// so the Contacts are rebuilt without their old columns.
+const upgradeStatementsV21 = `
+	CREATE TABLE "ContactsV21" (
+		"ContactId" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		"Name"      TEXT NOT NULL UNIQUE,
+		"Timezone"  TEXT NOT NULL DEFAULT '',
+		"Digest"    TEXT NOT NULL DEFAULT ''
+	);
+	INSERT INTO "ContactsV21" (ContactId, Name, Timezone, Digest)
+		SELECT ContactId, Name, Timezone, Digest FROM "Contacts";
+	DROP TABLE "Contacts";
+	ALTER TABLE "ContactsV21" RENAME TO "Contacts";
+`
+
 // If new upgrade statements are added then this must be incremented by 1.
-const databaseVersion int32 = 3
+const databaseVersion int32 = 21
 
 //upgradeDB applies any upgrades since the initial schema of the DB.
 func upgradeDB(db *sql.DB) error {
@@ -205,6 +429,150 @@ func upgradeDB(db *sql.DB) error {
 		}
 	}
 
+	if currentVersion < 4 {
+		_, err = db.Exec(upgradeStatementsV4)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if currentVersion < 5 {
+		_, err = db.Exec(upgradeStatementsV5)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if currentVersion < 6 {
+		_, err = db.Exec(upgradeStatementsV6)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if currentVersion < 7 {
+		_, err = db.Exec(upgradeStatementsV7)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if currentVersion < 8 {
+		_, err = db.Exec(upgradeStatementsV8)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if currentVersion < 9 {
+		_, err = db.Exec(upgradeStatementsV9)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if currentVersion < 10 {
+		_, err = db.Exec(upgradeStatementsV10)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if currentVersion < 11 {
+		_, err = db.Exec(upgradeStatementsV11)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if currentVersion < 12 {
+		_, err = db.Exec(upgradeStatementsV12)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if currentVersion < 13 {
+		_, err = db.Exec(upgradeStatementsV13)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if currentVersion < 14 {
+		_, err = db.Exec(upgradeStatementsV14)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if currentVersion < 15 {
+		_, err = db.Exec(upgradeStatementsV15)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if currentVersion < 16 {
+		_, err = db.Exec(upgradeStatementsV16)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if currentVersion < 17 {
+		_, err = db.Exec(upgradeStatementsV17)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if currentVersion < 18 {
+		_, err = db.Exec(upgradeStatementsV18)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if currentVersion < 19 {
+		_, err = db.Exec(upgradeStatementsV19)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if currentVersion < 20 {
+		_, err = db.Exec(upgradeStatementsV20)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if currentVersion < 21 {
+		_, err = db.Exec(upgradeStatementsV21)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
 	_, err = db.Exec(fmt.Sprintf("PRAGMA user_version = %d", databaseVersion))
 	if err != nil {
 		tx.Rollback()
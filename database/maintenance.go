@@ -0,0 +1,317 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaintenanceWindow is a period when a site, or all sites if SiteID is 0, is
+// expected to be unavailable. A one-off window runs from StartTime to EndTime,
+// a recurring window starts at the times matching the cron-style CronSchedule
+// and lasts DurationMinutes.
+type MaintenanceWindow struct {
+	MaintenanceWindowID int64
+	SiteID              int64
+	SiteName            string
+	Description         string
+	StartTime           time.Time
+	EndTime             time.Time
+	CronSchedule        string
+	DurationMinutes     int
+}
+
+// MaintenanceWindows is a slice of maintenance windows.
+type MaintenanceWindows []MaintenanceWindow
+
+// IsRecurring returns true if the window repeats on a cron schedule.
+func (w MaintenanceWindow) IsRecurring() bool {
+	return w.CronSchedule != ""
+}
+
+// ActiveUntil returns when the window that is active at the given time ends.
+// The boolean result is false if the window isn't active.
+func (w MaintenanceWindow) ActiveUntil(t time.Time) (time.Time, bool) {
+	if !w.IsRecurring() {
+		if !t.Before(w.StartTime) && t.Before(w.EndTime) {
+			return w.EndTime, true
+		}
+		return time.Time{}, false
+	}
+	schedule, err := parseCronSchedule(w.CronSchedule)
+	if err != nil {
+		return time.Time{}, false
+	}
+	// Look back over the duration of the window for a matching start minute,
+	// the most recent start wins if recurring windows overlap.
+	minute := t.Truncate(time.Minute)
+	for i := 0; i < w.DurationMinutes; i++ {
+		start := minute.Add(-time.Duration(i) * time.Minute)
+		if schedule.matches(start) {
+			return start.Add(time.Duration(w.DurationMinutes) * time.Minute), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// IsActive returns true if the window is active at the given time.
+func (w MaintenanceWindow) IsActive(t time.Time) bool {
+	_, active := w.ActiveUntil(t)
+	return active
+}
+
+// InMaintenance returns true if any of the site's maintenance windows is
+// active at the given time.
+func (s Site) InMaintenance(t time.Time) bool {
+	for _, w := range s.MaintenanceWindows {
+		if w.IsActive(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateMaintenanceWindow inserts a new maintenance window in the DB.
+func (w *MaintenanceWindow) CreateMaintenanceWindow(db *sql.DB) error {
+	result, err := db.Exec(
+		`INSERT INTO MaintenanceWindows (SiteID, Description, StartTime, EndTime,
+			CronSchedule, DurationMinutes)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+		w.SiteID,
+		w.Description,
+		w.StartTime,
+		w.EndTime,
+		w.CronSchedule,
+		w.DurationMinutes,
+	)
+	if err != nil {
+		return err
+	}
+
+	w.MaintenanceWindowID, err = result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateMaintenanceWindow updates the maintenance window in the DB.
+func (w *MaintenanceWindow) UpdateMaintenanceWindow(db *sql.DB) error {
+	_, err := db.Exec(
+		`UPDATE MaintenanceWindows SET SiteID = $1, Description = $2, StartTime = $3,
+			EndTime = $4, CronSchedule = $5, DurationMinutes = $6
+			WHERE MaintenanceWindowID = $7`,
+		w.SiteID,
+		w.Description,
+		w.StartTime,
+		w.EndTime,
+		w.CronSchedule,
+		w.DurationMinutes,
+		w.MaintenanceWindowID,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteMaintenanceWindow deletes the maintenance window from the DB.
+func (w *MaintenanceWindow) DeleteMaintenanceWindow(db *sql.DB) error {
+	_, err := db.Exec(
+		`DELETE FROM MaintenanceWindows WHERE MaintenanceWindowID = $1`,
+		w.MaintenanceWindowID,
+	)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+const maintenanceWindowColumns = `m.MaintenanceWindowID, m.SiteID, IFNULL(s.Name, ''),
+	m.Description, m.StartTime, m.EndTime, m.CronSchedule, m.DurationMinutes
+	FROM MaintenanceWindows m LEFT JOIN Sites s ON s.SiteID = m.SiteID`
+
+// GetMaintenanceWindow gets the details for a given maintenance window.
+func (w *MaintenanceWindow) GetMaintenanceWindow(db *sql.DB, maintenanceWindowID int64) error {
+	err := db.QueryRow(`SELECT `+maintenanceWindowColumns+`
+		WHERE m.MaintenanceWindowID = $1`, maintenanceWindowID).
+		Scan(&w.MaintenanceWindowID, &w.SiteID, &w.SiteName, &w.Description,
+			&w.StartTime, &w.EndTime, &w.CronSchedule, &w.DurationMinutes)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetMaintenanceWindows gets all of the maintenance windows, global ones first.
+func (m *MaintenanceWindows) GetMaintenanceWindows(db *sql.DB) error {
+	rows, err := db.Query(`SELECT ` + maintenanceWindowColumns + `
+		ORDER BY m.SiteID, m.Description`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	return m.scanMaintenanceWindows(rows)
+}
+
+// GetSiteMaintenanceWindows gets the maintenance windows that apply to the
+// site, including the global ones.
+func (s *Site) GetSiteMaintenanceWindows(db *sql.DB) error {
+	rows, err := db.Query(`SELECT `+maintenanceWindowColumns+`
+		WHERE m.SiteID = $1 OR m.SiteID = 0
+		ORDER BY m.SiteID, m.Description`, s.SiteID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var windows MaintenanceWindows
+	err = windows.scanMaintenanceWindows(rows)
+	if err != nil {
+		return err
+	}
+	s.MaintenanceWindows = windows
+	return nil
+}
+
+func (m *MaintenanceWindows) scanMaintenanceWindows(rows *sql.Rows) error {
+	for rows.Next() {
+		var w MaintenanceWindow
+		err := rows.Scan(&w.MaintenanceWindowID, &w.SiteID, &w.SiteName, &w.Description,
+			&w.StartTime, &w.EndTime, &w.CronSchedule, &w.DurationMinutes)
+		if err != nil {
+			return err
+		}
+		*m = append(*m, w)
+	}
+	return nil
+}
+
+// GetActiveMaintenanceWindows gets the maintenance windows that are active
+// at the given time.
+func GetActiveMaintenanceWindows(db *sql.DB, t time.Time) (MaintenanceWindows, error) {
+	var all MaintenanceWindows
+	err := all.GetMaintenanceWindows(db)
+	if err != nil {
+		return nil, err
+	}
+	var active MaintenanceWindows
+	for _, w := range all {
+		if w.IsActive(t) {
+			active = append(active, w)
+		}
+	}
+	return active, nil
+}
+
+// ValidateCronSchedule checks a cron-style schedule of the five fields minute,
+// hour, day of month, month and day of week.
+func ValidateCronSchedule(expression string) error {
+	_, err := parseCronSchedule(expression)
+	return err
+}
+
+// cronSchedule holds the allowed values of each field of a cron expression.
+type cronSchedule struct {
+	minutes     map[int]bool
+	hours       map[int]bool
+	daysOfMonth map[int]bool
+	months      map[int]bool
+	daysOfWeek  map[int]bool
+	// As with cron, if both day fields are restricted a day matching either
+	// one matches.
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+func parseCronSchedule(expression string) (cronSchedule, error) {
+	var c cronSchedule
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return c, errors.New("a schedule needs five fields: minute hour day-of-month month day-of-week")
+	}
+	var err error
+	if c.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return c, fmt.Errorf("minute: %v", err)
+	}
+	if c.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return c, fmt.Errorf("hour: %v", err)
+	}
+	if c.daysOfMonth, err = parseCronField(fields[2], 1, 31); err != nil {
+		return c, fmt.Errorf("day of month: %v", err)
+	}
+	if c.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return c, fmt.Errorf("month: %v", err)
+	}
+	if c.daysOfWeek, err = parseCronField(fields[4], 0, 7); err != nil {
+		return c, fmt.Errorf("day of week: %v", err)
+	}
+	// Sunday can be written as 0 or 7.
+	if c.daysOfWeek[7] {
+		c.daysOfWeek[0] = true
+	}
+	c.anyDayOfMonth = strings.HasPrefix(fields[2], "*")
+	c.anyDayOfWeek = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// parseCronField reads a comma separated list of *, values and ranges, each
+// with an optional /step. A value with a step, such as 5/15, runs from the
+// value up to the maximum like it does in cron.
+func parseCronField(field string, min int, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		hasStep := false
+		if i := strings.Index(part, "/"); i >= 0 {
+			hasStep = true
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:i]
+		}
+		low, high := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			low, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			high = low
+			if hasStep {
+				high = max
+			}
+			if len(bounds) == 2 {
+				high, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid value %q", part)
+				}
+			}
+		}
+		if low < min || high > max || low > high {
+			return nil, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for v := low; v <= high; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+func (c cronSchedule) matches(t time.Time) bool {
+	if !c.minutes[t.Minute()] || !c.hours[t.Hour()] || !c.months[int(t.Month())] {
+		return false
+	}
+	dayOfMonth := c.daysOfMonth[t.Day()]
+	dayOfWeek := c.daysOfWeek[int(t.Weekday())]
+	if !c.anyDayOfMonth && !c.anyDayOfWeek {
+		return dayOfMonth || dayOfWeek
+	}
+	return dayOfMonth && dayOfWeek
+}
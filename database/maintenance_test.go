@@ -0,0 +1,201 @@
+package database_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// TestMaintenanceWindows tests creating, updating, getting and deleting the
+// maintenance windows.
+func TestMaintenanceWindows(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+
+	s := database.Site{Name: "Test", IsActive: true, URL: "http://www.example.com",
+		PingIntervalSeconds: 60, TimeoutSeconds: 30}
+	err = s.CreateSite(db)
+	if err != nil {
+		t.Fatal("Failed to create new site:", err)
+	}
+	s2 := database.Site{Name: "Other", IsActive: true, URL: "http://www.example.org",
+		PingIntervalSeconds: 60, TimeoutSeconds: 30}
+	err = s2.CreateSite(db)
+	if err != nil {
+		t.Fatal("Failed to create new site:", err)
+	}
+
+	start := time.Date(2016, 3, 1, 22, 0, 0, 0, time.UTC)
+	site := database.MaintenanceWindow{SiteID: s.SiteID, Description: "Deploy",
+		StartTime: start, EndTime: start.Add(time.Hour)}
+	err = site.CreateMaintenanceWindow(db)
+	if err != nil {
+		t.Fatal("Failed to create site maintenance window:", err)
+	}
+	global := database.MaintenanceWindow{Description: "Patching",
+		CronSchedule: "0 2 * * 0", DurationMinutes: 30}
+	err = global.CreateMaintenanceWindow(db)
+	if err != nil {
+		t.Fatal("Failed to create global maintenance window:", err)
+	}
+
+	var saved database.MaintenanceWindow
+	err = saved.GetMaintenanceWindow(db, site.MaintenanceWindowID)
+	if err != nil {
+		t.Fatal("Failed to get maintenance window:", err)
+	}
+	if saved.SiteName != "Test" || saved.Description != "Deploy" ||
+		!saved.StartTime.Equal(start) || saved.IsRecurring() {
+		t.Error("Maintenance window not saved as expected:", saved)
+	}
+
+	saved.Description = "Deploy night"
+	err = saved.UpdateMaintenanceWindow(db)
+	if err != nil {
+		t.Fatal("Failed to update maintenance window:", err)
+	}
+
+	var windows database.MaintenanceWindows
+	err = windows.GetMaintenanceWindows(db)
+	if err != nil {
+		t.Fatal("Failed to get maintenance windows:", err)
+	}
+	if len(windows) != 2 || windows[0].Description != "Patching" ||
+		windows[1].Description != "Deploy night" {
+		t.Error("Maintenance windows not returned as expected:", windows)
+	}
+
+	// The other site only gets the global window.
+	err = s2.GetSiteMaintenanceWindows(db)
+	if err != nil {
+		t.Fatal("Failed to get site maintenance windows:", err)
+	}
+	if len(s2.MaintenanceWindows) != 1 || s2.MaintenanceWindows[0].SiteID != 0 {
+		t.Error("Site should only have the global maintenance window:", s2.MaintenanceWindows)
+	}
+
+	active, err := database.GetActiveMaintenanceWindows(db, start.Add(30*time.Minute))
+	if err != nil {
+		t.Fatal("Failed to get active maintenance windows:", err)
+	}
+	if len(active) != 1 || active[0].MaintenanceWindowID != site.MaintenanceWindowID {
+		t.Error("Only the site maintenance window should be active:", active)
+	}
+
+	err = saved.DeleteMaintenanceWindow(db)
+	if err != nil {
+		t.Fatal("Failed to delete maintenance window:", err)
+	}
+	windows = nil
+	err = windows.GetMaintenanceWindows(db)
+	if err != nil {
+		t.Fatal("Failed to get maintenance windows:", err)
+	}
+	if len(windows) != 1 {
+		t.Error("Maintenance window should have been deleted:", windows)
+	}
+}
+
+// TestMaintenanceWindowIsActive tests the one-off and recurring windows.
+func TestMaintenanceWindowIsActive(t *testing.T) {
+	start := time.Date(2016, 3, 1, 22, 0, 0, 0, time.UTC)
+	oneOff := database.MaintenanceWindow{StartTime: start, EndTime: start.Add(time.Hour)}
+	if oneOff.IsActive(start.Add(-time.Minute)) || !oneOff.IsActive(start) ||
+		!oneOff.IsActive(start.Add(59*time.Minute)) || oneOff.IsActive(start.Add(time.Hour)) {
+		t.Error("One-off window should be active from its start until its end.")
+	}
+
+	// Every Tuesday at 10 PM for 90 minutes, March 1 2016 is a Tuesday.
+	weekly := database.MaintenanceWindow{CronSchedule: "0 22 * * 2", DurationMinutes: 90}
+	until, active := weekly.ActiveUntil(start.Add(time.Hour))
+	if !active || !until.Equal(start.Add(90*time.Minute)) {
+		t.Error("Weekly window should be active until 11:30 PM, got", until, active)
+	}
+	if weekly.IsActive(start.Add(90*time.Minute)) || weekly.IsActive(start.Add(24*time.Hour)) {
+		t.Error("Weekly window should only be active for its duration on Tuesdays.")
+	}
+
+	// Both day fields restricted, so either one matching is enough.
+	monthly := database.MaintenanceWindow{CronSchedule: "*/15 1-3 1,15 * 0", DurationMinutes: 5}
+	sunday := time.Date(2016, 3, 6, 2, 47, 0, 0, time.UTC)
+	if !monthly.IsActive(time.Date(2016, 3, 15, 1, 32, 0, 0, time.UTC)) || !monthly.IsActive(sunday) ||
+		monthly.IsActive(time.Date(2016, 3, 7, 2, 47, 0, 0, time.UTC)) {
+		t.Error("Window should be active on the 1st, 15th or Sundays.")
+	}
+
+	// A value with a step repeats up to the maximum, at 5, 20, 35 and 50.
+	stepped := database.MaintenanceWindow{CronSchedule: "5/15 * * * *", DurationMinutes: 1}
+	for _, minute := range []int{5, 20, 35, 50} {
+		if !stepped.IsActive(time.Date(2016, 3, 1, 4, minute, 0, 0, time.UTC)) {
+			t.Error("Stepped window should be active at minute", minute)
+		}
+	}
+	if stepped.IsActive(time.Date(2016, 3, 1, 4, 6, 0, 0, time.UTC)) {
+		t.Error("Stepped window should only be active for its duration.")
+	}
+}
+
+// TestValidateCronSchedule tests the checking of the cron schedules.
+func TestValidateCronSchedule(t *testing.T) {
+	for _, valid := range []string{"0 22 * * 2", "*/5 * * * *", "0 1-5/2 1,15 1-12 7"} {
+		if err := database.ValidateCronSchedule(valid); err != nil {
+			t.Error("Schedule", valid, "should be valid:", err)
+		}
+	}
+	for _, invalid := range []string{"", "0 22 * *", "60 * * * *", "* 24 * * *",
+		"* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if err := database.ValidateCronSchedule(invalid); err == nil {
+			t.Error("Schedule", invalid, "should not be valid.")
+		}
+	}
+}
+
+// TestReportExcludesMaintenance verifies that the pings during maintenance are
+// left out of the reports.
+func TestReportExcludesMaintenance(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+
+	s := database.Site{Name: "Test", IsActive: true, URL: "http://www.example.com",
+		PingIntervalSeconds: 60, TimeoutSeconds: 30}
+	err = s.CreateSite(db)
+	if err != nil {
+		t.Fatal("Failed to create new site:", err)
+	}
+	timeRequest := time.Date(2016, 3, 1, 22, 0, 0, 0, time.UTC)
+	pings := []database.Ping{
+		{SiteID: s.SiteID, TimeRequest: timeRequest, Duration: 100},
+		{SiteID: s.SiteID, TimeRequest: timeRequest.Add(time.Minute), SiteDown: true, Maintenance: true},
+		{SiteID: s.SiteID, TimeRequest: timeRequest.Add(2 * time.Minute), Duration: 200, Maintenance: true},
+	}
+	for _, p := range pings {
+		err = p.CreatePing(db)
+		if err != nil {
+			t.Fatal("Failed to create ping:", err)
+		}
+	}
+
+	err = s.GetSitePings(db, s.SiteID, timeRequest, timeRequest.Add(time.Hour))
+	if err != nil {
+		t.Fatal("Failed to get pings:", err)
+	}
+	if len(s.Pings) != 3 || s.Pings[0].Maintenance || !s.Pings[1].Maintenance {
+		t.Error("Maintenance flag not saved as expected:", s.Pings)
+	}
+
+	report, err := database.GetYTDReports(db, 2016)
+	if err != nil {
+		t.Fatal("Failed to get report:", err)
+	}
+	march := report["Test"][2]
+	if march.PingsUp != 1 || march.PingsDown != 0 || march.AvgResponse != 100 {
+		t.Error("Report should leave out the maintenance pings:", march)
+	}
+}
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// The statuses of the outbox messages. A message is dead once it has failed
+// the most attempts allowed, it's only sent again if it's resent from the
+// settings.
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxDead    = "dead"
+)
+
+// OutboxMessage is a notification to deliver to one endpoint. The Payload is
+// the notification as it's sent to the channel type, and the Recipient is the
+// name of the contact or site it's for. The RecipientKey identifies the
+// recipient, such as contact:<id> or site:<id>, for the rate limits.
+type OutboxMessage struct {
+	OutboxMessageID int64
+	SiteID          int64
+	ChannelType     string
+	Address         string
+	Recipient       string
+	RecipientKey    string
+	Subject         string
+	Payload         string
+	Status          string
+	Attempts        int
+	LastError       string
+	CreatedAt       time.Time
+	NextAttempt     time.Time
+	SentAt          time.Time
+}
+
+// OutboxMessages is a slice of outbox messages.
+type OutboxMessages []OutboxMessage
+
+const outboxColumns = `OutboxMessageId, SiteId, ChannelType, Address, Recipient, RecipientKey,
+	Subject, Payload, Status, Attempts, LastError, CreatedAt, NextAttempt, SentAt`
+
+// EnqueueOutboxMessages saves the messages of a notification to the outbox.
+func EnqueueOutboxMessages(db *sql.DB, messages OutboxMessages) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	err = insertOutboxMessages(tx, messages)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertOutboxMessages(tx *sql.Tx, messages OutboxMessages) error {
+	now := time.Now()
+	for i := range messages {
+		m := &messages[i]
+		m.Status = OutboxPending
+		m.CreatedAt = now
+		// A message can be held until a later time, such as the schedule of a contact.
+		if m.NextAttempt.IsZero() {
+			m.NextAttempt = now
+		}
+		result, err := tx.Exec(`INSERT INTO OutboxMessages (SiteId, ChannelType, Address, Recipient,
+			RecipientKey, Subject, Payload, Status, CreatedAt, NextAttempt)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+			m.SiteID, m.ChannelType, m.Address, m.Recipient, m.RecipientKey, m.Subject, m.Payload,
+			m.Status, m.CreatedAt, m.NextAttempt)
+		if err != nil {
+			return err
+		}
+		m.OutboxMessageID, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarkOutboxSent records that the message was delivered.
+func (m *OutboxMessage) MarkOutboxSent(db *sql.DB, sentAt time.Time) error {
+	m.Attempts++
+	m.Status = OutboxSent
+	m.SentAt = sentAt
+	m.LastError = ""
+	_, err := db.Exec(`UPDATE OutboxMessages SET Status = $1, Attempts = $2, SentAt = $3, LastError = ''
+		WHERE OutboxMessageId = $4`, m.Status, m.Attempts, m.SentAt, m.OutboxMessageID)
+	return err
+}
+
+// MarkOutboxFailed records the failed attempt and when to try again, or that
+// the message is dead if the nextAttempt is zero.
+func (m *OutboxMessage) MarkOutboxFailed(db *sql.DB, lastError string, nextAttempt time.Time) error {
+	m.Attempts++
+	m.LastError = lastError
+	m.NextAttempt = nextAttempt
+	m.Status = OutboxPending
+	if nextAttempt.IsZero() {
+		m.Status = OutboxDead
+	}
+	_, err := db.Exec(`UPDATE OutboxMessages SET Status = $1, Attempts = $2, LastError = $3, NextAttempt = $4
+		WHERE OutboxMessageId = $5`, m.Status, m.Attempts, m.LastError, m.NextAttempt, m.OutboxMessageID)
+	return err
+}
+
+// ResendOutboxMessage puts the message back in the outbox to be sent right
+// away with a fresh count of attempts.
+func (m *OutboxMessage) ResendOutboxMessage(db *sql.DB) error {
+	m.Status = OutboxPending
+	m.Attempts = 0
+	m.NextAttempt = time.Now()
+	_, err := db.Exec(`UPDATE OutboxMessages SET Status = $1, Attempts = 0, NextAttempt = $2
+		WHERE OutboxMessageId = $3`, m.Status, m.NextAttempt, m.OutboxMessageID)
+	return err
+}
+
+// GetOutboxMessage gets the outbox message.
+func (m *OutboxMessage) GetOutboxMessage(db *sql.DB, outboxMessageID int64) error {
+	return scanOutboxMessage(db.QueryRow(`SELECT `+outboxColumns+` FROM OutboxMessages
+		WHERE OutboxMessageId = $1`, outboxMessageID), m)
+}
+
+// GetOutboxMessages gets the most recent messages with the status, or of all
+// of the statuses if it's blank, newest first.
+func (o *OutboxMessages) GetOutboxMessages(db *sql.DB, status string, limit int) error {
+	return o.query(db, `SELECT `+outboxColumns+` FROM OutboxMessages
+		WHERE $1 = '' OR Status = $1 ORDER BY OutboxMessageId DESC LIMIT $2`, status, limit)
+}
+
+// GetDueOutboxMessages gets the pending messages that are due to be sent by
+// the time, oldest first.
+func (o *OutboxMessages) GetDueOutboxMessages(db *sql.DB, now time.Time) error {
+	var pending OutboxMessages
+	err := pending.query(db, `SELECT `+outboxColumns+` FROM OutboxMessages
+		WHERE Status = $1 ORDER BY OutboxMessageId`, OutboxPending)
+	if err != nil {
+		return err
+	}
+	// The due time is compared here since the timestamps are saved as text.
+	for _, m := range pending {
+		if !m.NextAttempt.After(now) {
+			*o = append(*o, m)
+		}
+	}
+	return nil
+}
+
+// DeleteSentOutboxMessages deletes the messages that were sent before the time.
+func DeleteSentOutboxMessages(db *sql.DB, before time.Time) error {
+	var sent OutboxMessages
+	err := sent.query(db, `SELECT `+outboxColumns+` FROM OutboxMessages WHERE Status = $1`, OutboxSent)
+	if err != nil {
+		return err
+	}
+	for _, m := range sent {
+		if m.SentAt.Before(before) {
+			_, err = db.Exec(`DELETE FROM OutboxMessages WHERE OutboxMessageId = $1`, m.OutboxMessageID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (o *OutboxMessages) query(db *sql.DB, query string, args ...interface{}) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m OutboxMessage
+		err = scanOutboxMessage(rows, &m)
+		if err != nil {
+			return err
+		}
+		*o = append(*o, m)
+	}
+	return rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanOutboxMessage(row scanner, m *OutboxMessage) error {
+	return row.Scan(&m.OutboxMessageID, &m.SiteID, &m.ChannelType, &m.Address, &m.Recipient,
+		&m.RecipientKey, &m.Subject, &m.Payload, &m.Status, &m.Attempts, &m.LastError, &m.CreatedAt,
+		&m.NextAttempt, &m.SentAt)
+}
@@ -0,0 +1,158 @@
+package database_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// TestOutboxMessages tests that the messages are due until they're sent, that
+// a failed message waits for its next attempt or is dead, and that a resent
+// message is due again.
+func TestOutboxMessages(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+
+	messages := database.OutboxMessages{
+		{SiteID: 1, ChannelType: database.EmailChannel, Address: "joe@test.com", Recipient: "Joe",
+			Subject: "Test: Site is Down", Payload: "{}"},
+		{SiteID: 1, ChannelType: database.SmsChannel, Address: "5125551212", Recipient: "Joe",
+			Subject: "Test: Site is Down", Payload: "{}"},
+		{SiteID: 1, ChannelType: database.WebhookChannel, Address: "http://hooks.test.com", Recipient: "Webhook",
+			Subject: "Test: Site is Down", Payload: "{}"},
+	}
+	err = database.EnqueueOutboxMessages(db, messages)
+	if err != nil {
+		t.Fatal("Failed to enqueue outbox messages:", err)
+	}
+	if messages[0].OutboxMessageID == 0 || messages[0].Status != database.OutboxPending {
+		t.Error("Outbox message not saved as pending:", messages[0])
+	}
+
+	now := time.Now()
+	var due database.OutboxMessages
+	err = due.GetDueOutboxMessages(db, now)
+	if err != nil {
+		t.Fatal("Failed to get due outbox messages:", err)
+	}
+	if len(due) != 3 {
+		t.Fatal("All of the outbox messages should be due:", due)
+	}
+
+	err = due[0].MarkOutboxSent(db, now)
+	if err != nil {
+		t.Fatal("Failed to mark outbox message sent:", err)
+	}
+	err = due[1].MarkOutboxFailed(db, "no response", now.Add(time.Minute))
+	if err != nil {
+		t.Fatal("Failed to mark outbox message failed:", err)
+	}
+	err = due[2].MarkOutboxFailed(db, "connection refused", time.Time{})
+	if err != nil {
+		t.Fatal("Failed to mark outbox message dead:", err)
+	}
+
+	var stillDue database.OutboxMessages
+	err = stillDue.GetDueOutboxMessages(db, now)
+	if err != nil {
+		t.Fatal("Failed to get due outbox messages:", err)
+	}
+	if len(stillDue) != 0 {
+		t.Error("No outbox messages should be due before the next attempt:", stillDue)
+	}
+	err = stillDue.GetDueOutboxMessages(db, now.Add(2*time.Minute))
+	if err != nil {
+		t.Fatal("Failed to get due outbox messages:", err)
+	}
+	if len(stillDue) != 1 || stillDue[0].ChannelType != database.SmsChannel ||
+		stillDue[0].Attempts != 1 || stillDue[0].LastError != "no response" {
+		t.Error("The failed outbox message should be due after its next attempt:", stillDue)
+	}
+
+	var dead database.OutboxMessages
+	err = dead.GetOutboxMessages(db, database.OutboxDead, 10)
+	if err != nil {
+		t.Fatal("Failed to get dead outbox messages:", err)
+	}
+	if len(dead) != 1 || dead[0].ChannelType != database.WebhookChannel {
+		t.Fatal("Dead outbox messages not as expected:", dead)
+	}
+	err = dead[0].ResendOutboxMessage(db)
+	if err != nil {
+		t.Fatal("Failed to resend outbox message:", err)
+	}
+	var resent database.OutboxMessage
+	err = resent.GetOutboxMessage(db, dead[0].OutboxMessageID)
+	if err != nil {
+		t.Fatal("Failed to get outbox message:", err)
+	}
+	if resent.Status != database.OutboxPending || resent.Attempts != 0 || resent.NextAttempt.After(time.Now()) {
+		t.Error("Resent outbox message should be due:", resent)
+	}
+
+	var all database.OutboxMessages
+	err = all.GetOutboxMessages(db, "", 10)
+	if err != nil {
+		t.Fatal("Failed to get outbox messages:", err)
+	}
+	if len(all) != 3 || all[0].OutboxMessageID != messages[2].OutboxMessageID {
+		t.Error("All of the outbox messages should be listed newest first:", all)
+	}
+
+	err = database.DeleteSentOutboxMessages(db, now.Add(time.Second))
+	if err != nil {
+		t.Fatal("Failed to delete sent outbox messages:", err)
+	}
+	var sent database.OutboxMessages
+	err = sent.GetOutboxMessages(db, database.OutboxSent, 10)
+	if err != nil {
+		t.Fatal("Failed to get sent outbox messages:", err)
+	}
+	if len(sent) != 0 {
+		t.Error("The sent outbox messages should be deleted:", sent)
+	}
+}
+
+// TestUpdateSiteStatusOutbox tests that the notification of a status change is
+// saved to the outbox with the site's status.
+func TestUpdateSiteStatusOutbox(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+
+	s := database.Site{Name: "Test", IsActive: true, URL: "http://www.test.com",
+		PingIntervalSeconds: 60, TimeoutSeconds: 30, IsSiteUp: true}
+	err = s.CreateSite(db)
+	if err != nil {
+		t.Fatal("Failed to create new site:", err)
+	}
+	err = s.UpdateSiteStatus(db, false, time.Now(), database.OutboxMessages{{SiteID: s.SiteID,
+		ChannelType: database.EmailChannel, Address: "joe@test.com", Recipient: "Joe",
+		Subject: "Test: Site is Down", Payload: "{}"}})
+	if err != nil {
+		t.Fatal("Failed to update site status:", err)
+	}
+
+	var saved database.Site
+	err = saved.GetSite(db, s.SiteID)
+	if err != nil {
+		t.Fatal("Failed to get site:", err)
+	}
+	if saved.IsSiteUp {
+		t.Error("Site should be down.")
+	}
+	var pending database.OutboxMessages
+	err = pending.GetOutboxMessages(db, database.OutboxPending, 10)
+	if err != nil {
+		t.Fatal("Failed to get pending outbox messages:", err)
+	}
+	if len(pending) != 1 || pending[0].SiteID != s.SiteID {
+		t.Error("The notification should be in the outbox:", pending)
+	}
+}
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ScheduleTimeFormat is the format of the start and end times of the schedules.
+const ScheduleTimeFormat = "15:04"
+
+// AllDays and Weekdays are the Days of the schedules that are every day and
+// Monday to Friday.
+const (
+	AllDays  = 1<<7 - 1
+	Weekdays = 1<<time.Monday | 1<<time.Tuesday | 1<<time.Wednesday | 1<<time.Thursday | 1<<time.Friday
+)
+
+// ContactSchedule is when the contact can be notified on the channel type, on
+// the Days from the StartTime to the EndTime in the contact's time zone. The
+// Days are a bit mask of the weekdays, a window that ends before it starts runs
+// past midnight, and the same start and end is all day. The channel types
+// without a schedule can be notified at any time.
+type ContactSchedule struct {
+	ContactID   int64
+	ChannelType string
+	Days        int
+	StartTime   string
+	EndTime     string
+}
+
+// GetContactSchedules gets the time zone and the schedules of the contact.
+func (c *Contact) GetContactSchedules(db *sql.DB) error {
+	err := db.QueryRow(`SELECT Timezone FROM Contacts WHERE ContactID = $1`, c.ContactID).Scan(&c.Timezone)
+	if err != nil {
+		return err
+	}
+	rows, err := db.Query(`SELECT ChannelType, Days, StartTime, EndTime
+		FROM ContactSchedules WHERE ContactID = $1 ORDER BY ChannelType`, c.ContactID)
+	if err != nil {
+		return err
+	}
+	// nil out the slice in case it is rereading it from the DB.
+	c.Schedules = nil
+	defer rows.Close()
+	for rows.Next() {
+		sch := ContactSchedule{ContactID: c.ContactID}
+		err = rows.Scan(&sch.ChannelType, &sch.Days, &sch.StartTime, &sch.EndTime)
+		if err != nil {
+			return err
+		}
+		c.Schedules = append(c.Schedules, sch)
+	}
+	return rows.Err()
+}
+
+// SetContactSchedules replaces the time zone and the schedules of the contact in the DB.
+func (c *Contact) SetContactSchedules(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE Contacts SET Timezone = $1 WHERE ContactID = $2`, c.Timezone, c.ContactID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM ContactSchedules WHERE ContactID = $1`, c.ContactID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for i, sch := range c.Schedules {
+		_, err = tx.Exec(`INSERT INTO ContactSchedules (ContactID, ChannelType, Days, StartTime, EndTime)
+			VALUES ($1, $2, $3, $4, $5)`, c.ContactID, sch.ChannelType, sch.Days, sch.StartTime, sch.EndTime)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		c.Schedules[i].ContactID = c.ContactID
+	}
+	return tx.Commit()
+}
+
+// Location returns the time zone of the contact, which is the server's if it's
+// not set or not known.
+func (c Contact) Location() *time.Location {
+	if c.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// Schedule returns the schedule of the contact for the channel type.
+func (c Contact) Schedule(channelType string) (ContactSchedule, bool) {
+	for _, sch := range c.Schedules {
+		if sch.ChannelType == channelType {
+			return sch, true
+		}
+	}
+	return ContactSchedule{}, false
+}
+
+// AvailableChannels returns the subscribed channels of the contact that its
+// schedules allow at the time. If there are none then the next time that
+// each of the subscribed channels is allowed is returned instead, by channel type.
+func (c Contact) AvailableChannels(t time.Time) ([]ContactChannel, map[string]time.Time) {
+	var available []ContactChannel
+	next := make(map[string]time.Time)
+	loc := c.Location()
+	for _, ch := range c.SubscribedChannels() {
+		sch, ok := c.Schedule(ch.ChannelType)
+		if !ok || sch.IsAvailable(t.In(loc)) {
+			available = append(available, ch)
+		} else {
+			next[ch.ChannelType] = sch.NextAvailable(t.In(loc))
+		}
+	}
+	if len(available) > 0 {
+		return available, nil
+	}
+	return nil, next
+}
+
+// IsAvailable returns whether the schedule allows the time, which should be in
+// the contact's time zone.
+func (sch ContactSchedule) IsAvailable(t time.Time) bool {
+	start, end, ok := sch.window()
+	if !ok {
+		return true
+	}
+	minute := t.Hour()*60 + t.Minute()
+	today := sch.Days&(1<<t.Weekday()) != 0
+	switch {
+	case start == end:
+		return today
+	case start < end:
+		return today && minute >= start && minute < end
+	}
+	// The window runs past midnight, so the early hours belong to the window
+	// that started the day before.
+	yesterday := sch.Days&(1<<((t.Weekday()+6)%7)) != 0
+	return (today && minute >= start) || (yesterday && minute < end)
+}
+
+// NextAvailable returns the next start of the schedule after the time, which
+// should be in the contact's time zone. It's the time itself if the schedule
+// allows it or can't be worked out.
+func (sch ContactSchedule) NextAvailable(t time.Time) time.Time {
+	start, _, ok := sch.window()
+	if !ok || sch.IsAvailable(t) || sch.Days&AllDays == 0 {
+		return t
+	}
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for i := 0; i <= 7; i++ {
+		day := midnight.AddDate(0, 0, i)
+		next := time.Date(day.Year(), day.Month(), day.Day(), start/60, start%60, 0, 0, t.Location())
+		if sch.Days&(1<<day.Weekday()) != 0 && next.After(t) {
+			return next
+		}
+	}
+	return t
+}
+
+// window returns the start and end of the schedule in minutes after midnight,
+// and false if either can't be parsed.
+func (sch ContactSchedule) window() (int, int, bool) {
+	start, err := time.Parse(ScheduleTimeFormat, sch.StartTime)
+	if err != nil {
+		return 0, 0, false
+	}
+	end, err := time.Parse(ScheduleTimeFormat, sch.EndTime)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start.Hour()*60 + start.Minute(), end.Hour()*60 + end.Minute(), true
+}
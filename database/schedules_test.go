@@ -0,0 +1,131 @@
+package database_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// TestScheduleIsAvailable tests the windows of the schedules, including the
+// ones that run past midnight and the all day ones.
+func TestScheduleIsAvailable(t *testing.T) {
+	weekdays := database.ContactSchedule{Days: database.Weekdays, StartTime: "08:00", EndTime: "22:00"}
+	overnight := database.ContactSchedule{Days: 1 << time.Friday, StartTime: "22:00", EndTime: "06:00"}
+	allDay := database.ContactSchedule{Days: 1 << time.Sunday, StartTime: "00:00", EndTime: "00:00"}
+	// October 16, 2026 is a Friday.
+	friday := func(hour, minute int) time.Time { return time.Date(2026, 10, 16, hour, minute, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name string
+		sch  database.ContactSchedule
+		t    time.Time
+		want bool
+	}{
+		{"weekday in hours", weekdays, friday(8, 0), true},
+		{"weekday before hours", weekdays, friday(7, 59), false},
+		{"weekday at end", weekdays, friday(22, 0), false},
+		{"weekend", weekdays, friday(12, 0).AddDate(0, 0, 1), false},
+		{"overnight start", overnight, friday(23, 0), true},
+		{"overnight before start", overnight, friday(21, 0), false},
+		{"overnight next morning", overnight, friday(5, 59).AddDate(0, 0, 1), true},
+		{"overnight morning of start day", overnight, friday(5, 0), false},
+		{"all day", allDay, friday(3, 0).AddDate(0, 0, 2), true},
+		{"all day other day", allDay, friday(3, 0), false},
+		{"no hours", database.ContactSchedule{}, friday(3, 0), true},
+	}
+	for _, test := range tests {
+		if got := test.sch.IsAvailable(test.t); got != test.want {
+			t.Error(test.name+": availability should be", test.want, "got", got)
+		}
+	}
+
+	if next := weekdays.NextAvailable(friday(23, 0)); !next.Equal(friday(8, 0).AddDate(0, 0, 3)) {
+		t.Error("The next window after Friday night should be Monday morning, got", next)
+	}
+	if next := weekdays.NextAvailable(friday(7, 0)); !next.Equal(friday(8, 0)) {
+		t.Error("The next window on Friday morning should be the same day, got", next)
+	}
+	if next := weekdays.NextAvailable(friday(9, 0)); !next.Equal(friday(9, 0)) {
+		t.Error("The next window should be the time itself in the hours, got", next)
+	}
+}
+
+// TestContactAvailableChannels tests that the channels outside of their
+// schedule are left out, and are held until their next window if there are
+// no channels left.
+func TestContactAvailableChannels(t *testing.T) {
+	c := database.Contact{Name: "Joe Contact", Timezone: "America/Chicago",
+		Schedules: []database.ContactSchedule{{ChannelType: database.SmsChannel, Days: database.Weekdays,
+			StartTime: "08:00", EndTime: "22:00"}},
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "joe@test.com", IsActive: true},
+			{ChannelType: database.SmsChannel, Address: "5125551212", IsActive: true}}}
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skip("The time zone data isn't available:", err)
+	}
+	// 3 AM in Chicago is outside of the SMS hours.
+	night := time.Date(2026, 10, 16, 3, 0, 0, 0, chicago).UTC()
+	channels, held := c.AvailableChannels(night)
+	if len(channels) != 1 || channels[0].ChannelType != database.EmailChannel || held != nil {
+		t.Error("Only the email should be available at night:", channels, held)
+	}
+
+	c.Schedules = append(c.Schedules, database.ContactSchedule{ChannelType: database.EmailChannel,
+		Days: database.Weekdays, StartTime: "09:00", EndTime: "17:00"})
+	channels, held = c.AvailableChannels(night)
+	if len(channels) != 0 || len(held) != 2 ||
+		!held[database.SmsChannel].Equal(time.Date(2026, 10, 16, 8, 0, 0, 0, chicago)) ||
+		!held[database.EmailChannel].Equal(time.Date(2026, 10, 16, 9, 0, 0, 0, chicago)) {
+		t.Error("The channels should be held until their next window:", channels, held)
+	}
+
+	channels, _ = c.AvailableChannels(time.Date(2026, 10, 16, 10, 0, 0, 0, chicago))
+	if len(channels) != 2 {
+		t.Error("Both channels should be available in the day:", channels)
+	}
+}
+
+// TestContactSchedules tests that the time zone and the schedules of a contact
+// are saved with the contact and read back.
+func TestContactSchedules(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+
+	c := database.Contact{Name: "Joe Contact", Timezone: "America/Chicago",
+		Schedules: []database.ContactSchedule{{ChannelType: database.SmsChannel, Days: database.Weekdays,
+			StartTime: "08:00", EndTime: "22:00"}},
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "joe@test.com", IsActive: true}}}
+	err = c.CreateContact(db)
+	if err != nil {
+		t.Fatal("Failed to create new contact:", err)
+	}
+
+	var saved database.Contact
+	err = saved.GetContact(db, c.ContactID)
+	if err != nil {
+		t.Fatal("Failed to get the contact:", err)
+	}
+	if saved.Timezone != "America/Chicago" || len(saved.Schedules) != 1 ||
+		saved.Schedules[0] != (database.ContactSchedule{ContactID: c.ContactID, ChannelType: database.SmsChannel,
+			Days: database.Weekdays, StartTime: "08:00", EndTime: "22:00"}) {
+		t.Error("The schedules should be saved with the contact:", saved.Timezone, saved.Schedules)
+	}
+
+	saved.Timezone = ""
+	saved.Schedules = nil
+	err = saved.UpdateContact(db)
+	if err != nil {
+		t.Fatal("Failed to update the contact:", err)
+	}
+	err = saved.GetContact(db, c.ContactID)
+	if err != nil {
+		t.Fatal("Failed to get the contact:", err)
+	}
+	if saved.Timezone != "" || len(saved.Schedules) != 0 {
+		t.Error("The schedules should be removed from the contact:", saved.Timezone, saved.Schedules)
+	}
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// FlappingEvent is the event of the notifications that a site is flapping and
+// that it has stabilized again.
+const FlappingEvent = "flapping"
+
+// SubscriptionEvents are the events that a contact can be notified of for a
+// site in the order they are shown on the settings.
+var SubscriptionEvents = []string{DownEvent, UpEvent, ReminderEvent, FlappingEvent, DegradedEvent,
+	CertExpiryEvent}
+
+// Subscription is what a contact is notified of for a site, the events and the
+// channel types of the contact used for them. Empty Events or ChannelTypes are
+// all of them, which is what a contact gets when it's added to a site.
+type Subscription struct {
+	Events       []string
+	ChannelTypes []string
+}
+
+// HasEvent returns whether the subscription includes the event. The
+// notifications without an event, such as the tests, are always included.
+func (sub Subscription) HasEvent(event string) bool {
+	return event == "" || len(sub.Events) == 0 || stringInSlice(event, sub.Events)
+}
+
+// HasChannel returns whether the subscription uses the channel type.
+func (sub Subscription) HasChannel(channelType string) bool {
+	return len(sub.ChannelTypes) == 0 || stringInSlice(channelType, sub.ChannelTypes)
+}
+
+// SubscribedChannels returns the active channels of the contact that its
+// subscription for the site it was read with uses.
+func (c Contact) SubscribedChannels() []ContactChannel {
+	var channels []ContactChannel
+	for _, ch := range c.ActiveChannels() {
+		if c.Subscription.HasChannel(ch.ChannelType) {
+			channels = append(channels, ch)
+		}
+	}
+	return channels
+}
+
+// SetContactSubscription sets what the contact is notified of for the site.
+func (s Site) SetContactSubscription(db *sql.DB, contactID int64, sub Subscription) error {
+	_, err := db.Exec(`UPDATE SiteContacts SET Events = $1, ChannelTypes = $2
+		WHERE ContactID = $3 AND SiteID = $4`,
+		strings.Join(sub.Events, ","), strings.Join(sub.ChannelTypes, ","), contactID, s.SiteID)
+	return err
+}
+
+// GetContactSubscriptions gets what the contact is notified of for each of its
+// sites, by the SiteID.
+func (c Contact) GetContactSubscriptions(db *sql.DB) (map[int64]Subscription, error) {
+	rows, err := db.Query(`SELECT SiteID, Events, ChannelTypes FROM SiteContacts
+		WHERE ContactID = $1`, c.ContactID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	subs := make(map[int64]Subscription)
+	for rows.Next() {
+		var siteID int64
+		var events, channelTypes string
+		err = rows.Scan(&siteID, &events, &channelTypes)
+		if err != nil {
+			return nil, err
+		}
+		subs[siteID] = Subscription{Events: splitList(events), ChannelTypes: splitList(channelTypes)}
+	}
+	return subs, rows.Err()
+}
+
+// splitList splits the comma separated list from the DB, which is nil if it's
+// empty.
+func splitList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+func stringInSlice(a string, list []string) bool {
+	for _, b := range list {
+		if b == a {
+			return true
+		}
+	}
+	return false
+}
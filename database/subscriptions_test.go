@@ -0,0 +1,67 @@
+package database_test
+
+import (
+	"testing"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// TestContactSubscriptions tests that what a contact is notified of for a site
+// is saved with the association and read with the site's contacts.
+func TestContactSubscriptions(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+
+	s := database.Site{Name: "Test", IsActive: true, URL: "http://www.test.com",
+		PingIntervalSeconds: 60, TimeoutSeconds: 30}
+	err = s.CreateSite(db)
+	if err != nil {
+		t.Fatal("Failed to create new site:", err)
+	}
+	c := database.Contact{Name: "Joe Contact",
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "joe@test.com", IsActive: true},
+			{ChannelType: database.SmsChannel, Address: "5125551212", IsActive: true}}}
+	err = c.CreateContact(db)
+	if err != nil {
+		t.Fatal("Failed to create new contact:", err)
+	}
+	err = s.AddContactToSite(db, c.ContactID)
+	if err != nil {
+		t.Fatal("Failed to add contact to site:", err)
+	}
+
+	err = s.GetSiteContacts(db, s.SiteID)
+	if err != nil {
+		t.Fatal("Failed to get site contacts:", err)
+	}
+	if len(s.Contacts) != 1 || len(s.Contacts[0].Subscription.Events) != 0 ||
+		len(s.Contacts[0].SubscribedChannels()) != 2 {
+		t.Fatal("A new contact of the site should get everything:", s.Contacts)
+	}
+
+	sub := database.Subscription{Events: []string{database.DownEvent}, ChannelTypes: []string{database.SmsChannel}}
+	err = s.SetContactSubscription(db, c.ContactID, sub)
+	if err != nil {
+		t.Fatal("Failed to set the subscription:", err)
+	}
+	err = s.GetSiteContacts(db, s.SiteID)
+	if err != nil {
+		t.Fatal("Failed to get site contacts:", err)
+	}
+	got := s.Contacts[0]
+	if !got.Subscription.HasEvent(database.DownEvent) || got.Subscription.HasEvent(database.UpEvent) ||
+		len(got.SubscribedChannels()) != 1 || got.SubscribedChannels()[0].ChannelType != database.SmsChannel {
+		t.Error("The subscription should be read with the site's contacts:", got.Subscription)
+	}
+
+	subs, err := c.GetContactSubscriptions(db)
+	if err != nil {
+		t.Fatal("Failed to get the contact's subscriptions:", err)
+	}
+	if len(subs) != 1 || len(subs[s.SiteID].Events) != 1 || subs[s.SiteID].ChannelTypes[0] != database.SmsChannel {
+		t.Error("The subscriptions of the contact not as expected:", subs)
+	}
+}
@@ -0,0 +1,73 @@
+package database
+
+import "database/sql"
+
+// The events that the notification templates are for. A site is degraded
+// while it responds slower than the threshold, and the cert-expiry event warns
+// that the TLS certificate of the site is about to expire.
+const (
+	DownEvent       = "down"
+	UpEvent         = "up"
+	ReminderEvent   = "reminder"
+	DegradedEvent   = "degraded"
+	CertExpiryEvent = "cert-expiry"
+)
+
+// TemplateEvents are the events in the order they are shown on the settings.
+var TemplateEvents = []string{DownEvent, UpEvent, ReminderEvent, DegradedEvent, CertExpiryEvent}
+
+// NotificationTemplate is the Go text/template of the subject and body of the
+// notifications of an event. An empty ChannelType is the template for all of
+// the channels that don't have their own.
+type NotificationTemplate struct {
+	ChannelType string
+	Event       string
+	Subject     string
+	Body        string
+}
+
+// NotificationTemplates is a slice of notification templates.
+type NotificationTemplates []NotificationTemplate
+
+// SaveNotificationTemplate inserts the template in the DB or replaces the one
+// for the channel type and event.
+func (t *NotificationTemplate) SaveNotificationTemplate(db *sql.DB) error {
+	_, err := db.Exec(`INSERT OR REPLACE INTO NotificationTemplates (ChannelType, Event, Subject, Body)
+		VALUES ($1, $2, $3, $4)`, t.ChannelType, t.Event, t.Subject, t.Body)
+	return err
+}
+
+// DeleteNotificationTemplate deletes the template so the notifications go
+// back to the default wording.
+func (t *NotificationTemplate) DeleteNotificationTemplate(db *sql.DB) error {
+	_, err := db.Exec(`DELETE FROM NotificationTemplates WHERE ChannelType = $1 AND Event = $2`,
+		t.ChannelType, t.Event)
+	return err
+}
+
+// GetNotificationTemplate gets the template for the channel type and event,
+// sql.ErrNoRows is returned if it hasn't been customized.
+func (t *NotificationTemplate) GetNotificationTemplate(db *sql.DB, channelType string, event string) error {
+	return db.QueryRow(`SELECT ChannelType, Event, Subject, Body FROM NotificationTemplates
+		WHERE ChannelType = $1 AND Event = $2`, channelType, event).
+		Scan(&t.ChannelType, &t.Event, &t.Subject, &t.Body)
+}
+
+// GetNotificationTemplates gets all of the custom notification templates.
+func (t *NotificationTemplates) GetNotificationTemplates(db *sql.DB) error {
+	rows, err := db.Query(`SELECT ChannelType, Event, Subject, Body FROM NotificationTemplates
+		ORDER BY ChannelType, Event`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var nt NotificationTemplate
+		err = rows.Scan(&nt.ChannelType, &nt.Event, &nt.Subject, &nt.Body)
+		if err != nil {
+			return err
+		}
+		*t = append(*t, nt)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,58 @@
+package database_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// TestNotificationTemplates tests that saving a template replaces the one for
+// the channel type and event, and that it's gone once deleted.
+func TestNotificationTemplates(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+
+	all := database.NotificationTemplate{Event: database.DownEvent, Subject: "{{.Site.Name}} is down",
+		Body: "See the runbook."}
+	email := database.NotificationTemplate{ChannelType: database.EmailChannel, Event: database.DownEvent,
+		Subject: "Outage", Body: "{{.Error}}"}
+	for _, nt := range []*database.NotificationTemplate{&all, &email} {
+		err = nt.SaveNotificationTemplate(db)
+		if err != nil {
+			t.Fatal("Failed to save notification template:", err)
+		}
+	}
+
+	all.Body = "See http://wiki/runbook."
+	err = all.SaveNotificationTemplate(db)
+	if err != nil {
+		t.Fatal("Failed to replace notification template:", err)
+	}
+
+	var templates database.NotificationTemplates
+	err = templates.GetNotificationTemplates(db)
+	if err != nil {
+		t.Fatal("Failed to get notification templates:", err)
+	}
+	if len(templates) != 2 || templates[0] != all || templates[1] != email {
+		t.Error("Notification templates not as expected:", templates)
+	}
+
+	err = email.DeleteNotificationTemplate(db)
+	if err != nil {
+		t.Fatal("Failed to delete notification template:", err)
+	}
+	var nt database.NotificationTemplate
+	err = nt.GetNotificationTemplate(db, database.EmailChannel, database.DownEvent)
+	if err != sql.ErrNoRows {
+		t.Error("Deleted notification template should not be found:", nt, err)
+	}
+	err = nt.GetNotificationTemplate(db, "", database.DownEvent)
+	if err != nil || nt != all {
+		t.Error("Notification template for all channels not as expected:", nt, err)
+	}
+}
@@ -65,8 +65,17 @@ func main() {
 	roles = getRoles()
 	authorizer, err = httpauth.NewAuthorizer(authBackend, cookieKey, "user", roles)
 	createDefaultUser()
-	// Start the Pinger
-	p := pinger.NewPinger(db, pinger.GetSites, pinger.RequestURL, notifier.SendEmail, notifier.SendSms)
+	// Check the SMS provider so that a mistake in its settings is found now
+	// rather than when a site goes down.
+	if _, err = notifier.NewSmsProvider(); err != nil {
+		fatalError("Invalid SMS settings: ", err)
+	}
+	// Start the outbox that delivers the notifications and the summary
+	// digests, then the Pinger.
+	channels := notifier.NewChannels(notifier.SendEmail, notifier.SendSms)
+	notifier.NewOutbox(db, channels).Start()
+	notifier.NewDigester(db, notifier.SendEmail).Start()
+	p := pinger.NewPinger(db, pinger.GetSites, pinger.RequestURL, channels)
 	p.Start()
 	// Start the web server.
 	templates := controllers.PopulateTemplates(templateFiles)
@@ -0,0 +1,77 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+)
+
+// defaultAcknowledgeHours is how long the acknowledge links work if it isn't
+// configured.
+const defaultAcknowledgeHours = 24
+
+// AcknowledgeLink returns the link to acknowledge the outage of the site, or an
+// empty string if the BaseURL of the website isn't configured. The link is
+// signed with the cookie key and expires after the configured hours.
+func AcknowledgeLink(siteID int64, issued time.Time) string {
+	baseURL := strings.TrimRight(config.Settings.Website.BaseURL, "/")
+	if baseURL == "" || siteID == 0 {
+		return ""
+	}
+	t := strconv.FormatInt(issued.Unix(), 10)
+	return baseURL + "/acknowledge/" + strconv.FormatInt(siteID, 10) +
+		"?t=" + t + "&sig=" + acknowledgeSignature(siteID, t)
+}
+
+// CallAcknowledgeLink returns the link that the keypad input of a voice call
+// is posted to, to acknowledge the outage of the site. It's signed the same as
+// the AcknowledgeLink and is empty if the BaseURL of the website isn't
+// configured.
+func CallAcknowledgeLink(siteID int64, issued time.Time) string {
+	baseURL := strings.TrimRight(config.Settings.Website.BaseURL, "/")
+	if baseURL == "" || siteID == 0 {
+		return ""
+	}
+	t := strconv.FormatInt(issued.Unix(), 10)
+	return baseURL + "/calls/" + strconv.FormatInt(siteID, 10) +
+		"/acknowledge?t=" + t + "&sig=" + acknowledgeSignature(siteID, t)
+}
+
+// VerifyAcknowledgeLink checks the signature of the link's parameters and that
+// it hasn't expired, and returns when the link was issued. An outage that
+// started after that is a different one, which the link doesn't acknowledge.
+func VerifyAcknowledgeLink(siteID int64, t string, signature string, now time.Time) (time.Time, error) {
+	if !hmac.Equal([]byte(signature), []byte(acknowledgeSignature(siteID, t))) {
+		return time.Time{}, errors.New("the acknowledge link is not valid")
+	}
+	seconds, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return time.Time{}, errors.New("the acknowledge link is not valid")
+	}
+	issued := time.Unix(seconds, 0)
+	hours := config.Settings.Website.AcknowledgeHours
+	if hours < 1 {
+		hours = defaultAcknowledgeHours
+	}
+	if now.After(issued.Add(time.Duration(hours) * time.Hour)) {
+		return time.Time{}, errors.New("the acknowledge link has expired")
+	}
+	return issued, nil
+}
+
+func acknowledgeSignature(siteID int64, t string) string {
+	return Sign(config.Settings.Website.CookieKey, []byte(strconv.FormatInt(siteID, 10)+":"+t))
+}
+
+// AddAcknowledgeLink adds the link to acknowledge the outage to the message of
+// a down notification.
+func (n *Notifier) AddAcknowledgeLink(issued time.Time) {
+	n.AcknowledgeURL = AcknowledgeLink(n.Site.SiteID, issued)
+	if n.AcknowledgeURL != "" {
+		n.Message += "\nAcknowledge: " + n.AcknowledgeURL
+	}
+}
@@ -0,0 +1,56 @@
+package notifier_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+)
+
+// TestAcknowledgeLink tests that the signed link verifies until it expires and
+// that changing it breaks the signature.
+func TestAcknowledgeLink(t *testing.T) {
+	issued := time.Date(2016, 3, 1, 22, 0, 0, 0, time.UTC)
+	link := notifier.AcknowledgeLink(3, issued)
+	if !strings.HasPrefix(link, "http://localhost:8000/acknowledge/3?") {
+		t.Fatal("Acknowledge link not as expected:", link)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal("Failed to parse acknowledge link:", err)
+	}
+	ts, sig := u.Query().Get("t"), u.Query().Get("sig")
+
+	verified, err := notifier.VerifyAcknowledgeLink(3, ts, sig, issued.Add(time.Hour))
+	if err != nil || !verified.Equal(issued) {
+		t.Error("Acknowledge link should verify:", verified, err)
+	}
+	if _, err = notifier.VerifyAcknowledgeLink(4, ts, sig, issued.Add(time.Hour)); err == nil {
+		t.Error("Acknowledge link for another site should not verify.")
+	}
+	if _, err = notifier.VerifyAcknowledgeLink(3, "1456869660", sig, issued.Add(time.Hour)); err == nil {
+		t.Error("Acknowledge link with a changed time should not verify.")
+	}
+	_, err = notifier.VerifyAcknowledgeLink(3, ts, sig, issued.Add(25*time.Hour))
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Error("Acknowledge link should expire:", err)
+	}
+}
+
+// TestAddAcknowledgeLink tests that the link is added to the message and the
+// chat messages of a down notification.
+func TestAddAcknowledgeLink(t *testing.T) {
+	n := notifier.NewNotifier(database.Site{SiteID: 3, Name: "Test"}, "Test is down.", "Test: Site is Down",
+		notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
+	n.Status = "Down"
+	n.AddAcknowledgeLink(time.Now())
+	if n.AcknowledgeURL == "" || !strings.HasSuffix(n.Message, "\nAcknowledge: "+n.AcknowledgeURL) {
+		t.Error("Acknowledge link not added to the message:", n.Message)
+	}
+	if payload := notifier.NewWebhookPayload(n); payload.AcknowledgeURL != n.AcknowledgeURL {
+		t.Error("Acknowledge link not in the webhook payload:", payload.AcknowledgeURL)
+	}
+}
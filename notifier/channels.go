@@ -0,0 +1,73 @@
+package notifier
+
+import "github.com/turnkey-commerce/go-ping-sites/database"
+
+// Channel sends the notifications to the endpoints of one type, such as the
+// email addresses of the contacts.
+type Channel interface {
+	// Name is how the channel is described in the log.
+	Name() string
+	// Send sends the notification to the address of the endpoint.
+	Send(address string, n *Notifier) error
+}
+
+// Channels is the registry of the channels by the ChannelType of the contact
+// endpoints they send to.
+type Channels map[string]Channel
+
+// NewChannels returns the registry with the email, SMS and voice channels, the
+// chat webhooks, the push services, the signed webhook and the incident
+// management services, other channels can be added with Register.
+func NewChannels(sendEmail EmailSender, sendSms SmsSender) Channels {
+	c := make(Channels)
+	c.Register(database.EmailChannel, sendEmail)
+	c.Register(database.SmsChannel, sendSms)
+	c.Register(database.VoiceChannel, NewTwilioVoice())
+	c.Register(database.SlackChannel, NewSlackWebhook())
+	c.Register(database.TeamsChannel, NewTeamsWebhook())
+	c.Register(database.TelegramChannel, NewTelegram())
+	c.Register(database.DiscordChannel, NewDiscord())
+	c.Register(database.MatrixChannel, NewMatrix())
+	c.Register(database.NtfyChannel, NewNtfy())
+	c.Register(database.GotifyChannel, NewGotify())
+	c.Register(database.WebhookChannel, NewWebhook())
+	c.Register(database.PagerDutyChannel, NewPagerDuty())
+	c.Register(database.OpsgenieChannel, NewOpsgenie())
+	return c
+}
+
+// Register adds the channel that sends to the endpoints of the channel type,
+// replacing any channel already registered for it.
+func (c Channels) Register(channelType string, channel Channel) {
+	c[channelType] = channel
+}
+
+// EmailSender defines a function to do the work to send an email.
+type EmailSender func(recipient string, email Email) error
+
+// Name implements the Channel interface for the EmailSender.
+func (f EmailSender) Name() string {
+	return "email"
+}
+
+// Send implements the Channel interface for the EmailSender.
+func (f EmailSender) Send(address string, n *Notifier) error {
+	email, err := n.Email()
+	if err != nil {
+		return err
+	}
+	return f(address, email)
+}
+
+// SmsSender defines a function to do the work to send an SMS text message.
+type SmsSender func(smsNumber string, message string) error
+
+// Name implements the Channel interface for the SmsSender.
+func (f SmsSender) Name() string {
+	return "SMS"
+}
+
+// Send implements the Channel interface for the SmsSender, which has no subject.
+func (f SmsSender) Send(address string, n *Notifier) error {
+	return f(address, n.Message)
+}
@@ -0,0 +1,205 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// chatTimeout limits how long a post to a chat webhook can take.
+const chatTimeout = 10 * time.Second
+
+// SlackWebhook sends the notifications to Slack incoming webhooks as message
+// blocks, the address of the endpoint is the webhook URL.
+type SlackWebhook struct {
+	Client *http.Client
+}
+
+// NewSlackWebhook returns a SlackWebhook with the default timeout.
+func NewSlackWebhook() *SlackWebhook {
+	return &SlackWebhook{Client: &http.Client{Timeout: chatTimeout}}
+}
+
+// Name implements the Channel interface for the SlackWebhook.
+func (s *SlackWebhook) Name() string {
+	return "Slack"
+}
+
+// Send implements the Channel interface for the SlackWebhook.
+func (s *SlackWebhook) Send(address string, n *Notifier) error {
+	return postJSON(s.Client, address, slackPayload(n))
+}
+
+func slackPayload(n *Notifier) map[string]interface{} {
+	mrkdwn := func(text string) map[string]interface{} {
+		return map[string]interface{}{"type": "mrkdwn", "text": text}
+	}
+	site := n.Site.Name
+	if n.Site.URL != "" {
+		site = "<" + n.Site.URL + "|" + n.Site.Name + ">"
+	}
+	fields := []interface{}{
+		mrkdwn("*Site:*\n" + site),
+		mrkdwn("*Status:*\n" + n.Status),
+	}
+	if n.Downtime > 0 {
+		fields = append(fields, mrkdwn("*Outage Duration:*\n"+formatDowntime(n.Downtime)))
+	}
+	blocks := []interface{}{
+		map[string]interface{}{"type": "header",
+			"text": map[string]interface{}{"type": "plain_text", "text": n.Subject}},
+		map[string]interface{}{"type": "section", "fields": fields},
+	}
+	if n.Detail != "" {
+		blocks = append(blocks, map[string]interface{}{"type": "section", "text": mrkdwn(n.Detail)})
+	}
+	var buttons []interface{}
+	if link := n.SiteLink(); link != "" {
+		buttons = append(buttons, map[string]interface{}{"type": "button",
+			"text": map[string]interface{}{"type": "plain_text", "text": "View Site Details"},
+			"url":  link})
+	}
+	if n.AcknowledgeURL != "" {
+		buttons = append(buttons, map[string]interface{}{"type": "button",
+			"text": map[string]interface{}{"type": "plain_text", "text": "Acknowledge"},
+			"url":  n.AcknowledgeURL, "style": "primary"})
+	}
+	if len(buttons) > 0 {
+		blocks = append(blocks, map[string]interface{}{"type": "actions", "elements": buttons})
+	}
+	return map[string]interface{}{
+		// The text is shown in the notifications where the blocks can't be.
+		"text": n.Subject,
+		"attachments": []interface{}{map[string]interface{}{
+			"color":  statusColor(n.Status),
+			"blocks": blocks,
+		}},
+	}
+}
+
+// TeamsWebhook sends the notifications to Microsoft Teams incoming webhooks as
+// adaptive cards, the address of the endpoint is the webhook URL.
+type TeamsWebhook struct {
+	Client *http.Client
+}
+
+// NewTeamsWebhook returns a TeamsWebhook with the default timeout.
+func NewTeamsWebhook() *TeamsWebhook {
+	return &TeamsWebhook{Client: &http.Client{Timeout: chatTimeout}}
+}
+
+// Name implements the Channel interface for the TeamsWebhook.
+func (t *TeamsWebhook) Name() string {
+	return "Teams"
+}
+
+// Send implements the Channel interface for the TeamsWebhook.
+func (t *TeamsWebhook) Send(address string, n *Notifier) error {
+	return postJSON(t.Client, address, teamsPayload(n))
+}
+
+func teamsPayload(n *Notifier) map[string]interface{} {
+	fact := func(title string, value string) map[string]interface{} {
+		return map[string]interface{}{"title": title, "value": value}
+	}
+	facts := []interface{}{fact("Site", n.Site.Name)}
+	if n.Site.URL != "" {
+		facts = append(facts, fact("URL", n.Site.URL))
+	}
+	facts = append(facts, fact("Status", n.Status))
+	if n.Downtime > 0 {
+		facts = append(facts, fact("Outage Duration", formatDowntime(n.Downtime)))
+	}
+	color := "good"
+	if n.Status == "Down" {
+		color = "attention"
+	} else if n.Status != "Up" {
+		color = "warning"
+	}
+	body := []interface{}{
+		map[string]interface{}{"type": "TextBlock", "text": n.Subject, "size": "Large",
+			"weight": "Bolder", "color": color, "wrap": true},
+		map[string]interface{}{"type": "FactSet", "facts": facts},
+	}
+	if n.Detail != "" {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": n.Detail, "wrap": true})
+	}
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	var actions []interface{}
+	if link := n.SiteLink(); link != "" {
+		actions = append(actions, map[string]interface{}{"type": "Action.OpenUrl",
+			"title": "View Site Details", "url": link})
+	}
+	if n.AcknowledgeURL != "" {
+		actions = append(actions, map[string]interface{}{"type": "Action.OpenUrl",
+			"title": "Acknowledge", "url": n.AcknowledgeURL})
+	}
+	if len(actions) > 0 {
+		card["actions"] = actions
+	}
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{map[string]interface{}{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	}
+}
+
+// postJSON posts the payload to the webhook and returns an error with the
+// start of the response if it wasn't successful.
+func postJSON(client *http.Client, url string, payload interface{}) error {
+	return sendJSON(client, "POST", url, payload, nil)
+}
+
+// sendJSON is postJSON with the method and the extra headers, such as for the
+// authorization.
+func sendJSON(client *http.Client, method string, url string, payload interface{}, header http.Header) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// statusColor returns the color of the bar along the Slack message.
+func statusColor(status string) string {
+	switch status {
+	case "Down":
+		return "#d9534f"
+	case "Up":
+		return "#5cb85c"
+	}
+	return "#f0ad4e"
+}
+
+// formatDowntime rounds the duration of the outage to the second.
+func formatDowntime(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,68 @@
+package notifier_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+)
+
+// TestChatWebhooks tests that the Slack and Teams messages carry the details
+// of the status change and a link to the site.
+func TestChatWebhooks(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		received = append(received, string(body))
+	}))
+	defer server.Close()
+
+	site := database.Site{SiteID: 3, Name: "Test", URL: "http://www.example.com"}
+	n := notifier.NewNotifier(site, "Test at http://www.example.com: Site is now up.",
+		"Test: Site is Up", notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
+	n.Status = "Up"
+	n.Detail = "Site is now up, response time was 120ms."
+	n.Downtime = 65 * time.Minute
+
+	for _, channelType := range []string{database.SlackChannel, database.TeamsChannel} {
+		err := n.SendTo(channelType, server.URL)
+		if err != nil {
+			t.Fatal("Failed to send to", channelType, err)
+		}
+	}
+	if len(received) != 2 {
+		t.Fatal("Expected a post for each webhook, got", len(received))
+	}
+	for i, expected := range []string{`"type":"header"`, `"type":"AdaptiveCard"`} {
+		for _, part := range []string{expected, "Test: Site is Up", "Site is now up, response time was 120ms.",
+			"1h5m0s", "http://localhost:8000/settings/sites/3"} {
+			if !strings.Contains(received[i], part) {
+				t.Errorf("Webhook message %d is missing %s:\n%s", i, part, received[i])
+			}
+		}
+	}
+}
+
+// TestChatWebhookError tests that a failed post returns the webhook's response.
+func TestChatWebhookError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		http.Error(rw, "invalid_token", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	n := notifier.NewNotifier(database.Site{Name: "Test"}, "Test is down.", "Test: Site is Down",
+		notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
+	err := n.SendTo(database.SlackChannel, server.URL)
+	if err == nil || !strings.Contains(err.Error(), "403 Forbidden: invalid_token") {
+		t.Error("Expected the webhook error, got", err)
+	}
+	err = n.SendTo("pager", server.URL)
+	if err == nil {
+		t.Error("Expected an error for a channel type that isn't registered.")
+	}
+}
@@ -0,0 +1,42 @@
+package notifier
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// RecordDelivery saves the attempt to send the notification to the delivery
+// log. It's used by each of the ways a notification is sent, the outbox, the
+// direct sends when it couldn't be saved to the outbox and the test messages.
+// The attempt has the endpoint, and the outbox message if there is one, the
+// rest is filled in from the notification as it was sent and the error.
+// Nothing is recorded without a DB.
+func RecordDelivery(db *sql.DB, a database.DeliveryAttempt, n *Notifier, sendErr error) {
+	if db == nil {
+		return
+	}
+	if a.SiteID == 0 {
+		a.SiteID = n.Site.SiteID
+	}
+	if a.Subject == "" {
+		a.Subject = n.Subject
+	}
+	a.Event = n.Event()
+	if a.Event == "" {
+		a.Event = strings.ToLower(n.Status)
+	}
+	a.Summary = summary(n.Message)
+	a.Success = sendErr == nil
+	if sendErr != nil {
+		a.Error = sendErr.Error()
+	}
+	a.AttemptedAt = time.Now()
+	err := a.CreateDeliveryAttempt(db)
+	if err != nil {
+		log.Println("Error saving the delivery attempt:", err)
+	}
+}
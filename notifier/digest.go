@@ -0,0 +1,253 @@
+package notifier
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"html/template"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// digestInterval is how often the digester checks whether a period has ended.
+const digestInterval = 15 * time.Minute
+
+// digestNames are the names of the frequencies in the subjects.
+var digestNames = map[string]string{
+	database.DailyDigest:   "Daily",
+	database.WeeklyDigest:  "Weekly",
+	database.MonthlyDigest: "Monthly",
+}
+
+var digestTemplate = template.Must(template.New("digest").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: Helvetica, Arial, sans-serif; font-size: 14px; color: #333;">
+<h2>{{.Subject}}</h2>
+<table style="border-collapse: collapse;">
+<tr style="text-align: left; border-bottom: 2px solid #ddd;">
+<th style="padding: 6px 12px;">Site</th>
+<th style="padding: 6px 12px; text-align: right;">Uptime</th>
+<th style="padding: 6px 12px; text-align: right;">Avg Response</th>
+<th style="padding: 6px 12px; text-align: right;">Incidents</th>
+<th style="padding: 6px 12px; text-align: right;">Longest Outage</th>
+</tr>
+{{range .Sites}}<tr style="border-bottom: 1px solid #ddd;">
+<td style="padding: 6px 12px;">{{.Name}}</td>
+<td style="padding: 6px 12px; text-align: right; color: {{.Color}};">{{.Uptime}}</td>
+<td style="padding: 6px 12px; text-align: right;">{{.AvgResponse}}</td>
+<td style="padding: 6px 12px; text-align: right;">{{.Incidents}}</td>
+<td style="padding: 6px 12px; text-align: right;">{{.LongestOutage}}</td>
+</tr>
+{{else}}<tr><td colspan="5" style="padding: 6px 12px;">There are no active sites.</td></tr>
+{{end}}</table>
+{{if .ReportsLink}}<p><a href="{{.ReportsLink}}">Reports</a></p>
+{{end}}</body>
+</html>
+`))
+
+// Digest is the summary of the sites over a period that is emailed to the
+// contacts that get the digests of its frequency.
+type Digest struct {
+	Frequency string
+	Start     time.Time
+	End       time.Time
+	Sites     []database.SiteSummary
+}
+
+// digestSite is a row of the digest formatted for the email.
+type digestSite struct {
+	Name          string
+	Uptime        string
+	Color         string
+	AvgResponse   string
+	Incidents     int
+	LongestOutage string
+}
+
+// DigestPeriod returns the start and end of the last whole period of the
+// frequency before the time, in the time's location. The weeks start on Monday.
+func DigestPeriod(frequency string, now time.Time) (time.Time, time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch frequency {
+	case database.WeeklyDigest:
+		end := today.AddDate(0, 0, -int((now.Weekday()+6)%7))
+		return end.AddDate(0, 0, -7), end
+	case database.MonthlyDigest:
+		end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return end.AddDate(0, -1, 0), end
+	}
+	return today.AddDate(0, 0, -1), today
+}
+
+// Subject returns the subject of the digest with its period.
+func (d Digest) Subject() string {
+	last := d.End.AddDate(0, 0, -1)
+	period := d.Start.Format("Jan 2, 2006")
+	if !last.Equal(d.Start) {
+		period = d.Start.Format("Jan 2") + " - " + last.Format("Jan 2, 2006")
+	}
+	return "Go Ping Sites: " + digestNames[d.Frequency] + " Summary for " + period
+}
+
+// Email returns the email of the digest with a table of the sites in the HTML.
+func (d Digest) Email() (Email, error) {
+	e := Email{Subject: d.Subject(), Date: time.Now(), MessageID: newMessageID()}
+	data := struct {
+		Subject     string
+		Sites       []digestSite
+		ReportsLink string
+	}{Subject: e.Subject}
+	if baseURL := strings.TrimRight(config.Settings.Website.BaseURL, "/"); baseURL != "" {
+		data.ReportsLink = baseURL + "/reports"
+	}
+	lines := []string{e.Subject}
+	for _, s := range d.Sites {
+		row := digestSite{Name: s.Name, Uptime: "-", Color: "#333", AvgResponse: "-",
+			Incidents: s.Incidents, LongestOutage: "-"}
+		if s.PingsUp+s.PingsDown > 0 {
+			row.Uptime = fmt.Sprintf("%.3f%%", s.UptimePercent())
+			row.Color = statusColor("Up")
+			if s.PingsDown > 0 {
+				row.Color = statusColor("Down")
+			}
+		}
+		if s.PingsUp > 0 {
+			row.AvgResponse = fmt.Sprintf("%.0f ms", s.AvgResponse)
+		}
+		if s.LongestOutage > 0 {
+			row.LongestOutage = formatDowntime(s.LongestOutage)
+		}
+		data.Sites = append(data.Sites, row)
+		lines = append(lines, fmt.Sprintf("%s: %s uptime, %s average response, %d incidents, longest outage %s",
+			row.Name, row.Uptime, row.AvgResponse, row.Incidents, row.LongestOutage))
+	}
+	if len(d.Sites) == 0 {
+		lines = append(lines, "There are no active sites.")
+	}
+	if data.ReportsLink != "" {
+		lines = append(lines, "Reports: "+data.ReportsLink)
+	}
+	e.Text = strings.Join(lines, "\n")
+	var buf bytes.Buffer
+	err := digestTemplate.Execute(&buf, data)
+	if err != nil {
+		return e, err
+	}
+	e.HTML = buf.String()
+	return e, nil
+}
+
+// Digester emails the digests to the contacts in the background once each
+// period of their frequency has ended.
+type Digester struct {
+	DB        *sql.DB
+	SendEmail EmailSender
+	wg        sync.WaitGroup
+	stopChan  chan struct{}
+}
+
+// NewDigester returns a new Digester that sends the emails with the sender.
+func NewDigester(db *sql.DB, sendEmail EmailSender) *Digester {
+	return &Digester{DB: db, SendEmail: sendEmail}
+}
+
+// Start begins sending the digests in the background.
+func (d *Digester) Start() {
+	log.Println("Starting the summary digests...")
+	d.stopChan = make(chan struct{})
+	d.wg.Add(1)
+	go d.run()
+}
+
+// Stop stops sending the digests, waiting for any being sent.
+func (d *Digester) Stop() {
+	close(d.stopChan)
+	d.wg.Wait()
+	d.stopChan = nil
+}
+
+func (d *Digester) run() {
+	defer d.wg.Done()
+	for {
+		d.SendDue(time.Now())
+		select {
+		case <-d.stopChan:
+			return
+		case <-time.After(digestInterval):
+		}
+	}
+}
+
+// SendDue sends the digests of the frequencies whose period has ended since
+// they were last sent. The first time it only notes the period, so that a new
+// install doesn't send the summaries of the periods before it.
+func (d *Digester) SendDue(now time.Time) {
+	for _, frequency := range database.DigestFrequencies {
+		start, end := DigestPeriod(frequency, now)
+		last, err := database.GetDigestRun(d.DB, frequency)
+		if err != nil {
+			log.Println("Error getting the last", frequency, "digest:", err)
+			continue
+		}
+		if !last.Before(end) {
+			continue
+		}
+		// The period is saved first so that it isn't sent again if it fails.
+		err = database.SetDigestRun(d.DB, frequency, end)
+		if err != nil {
+			log.Println("Error saving the", frequency, "digest:", err)
+			continue
+		}
+		if last.IsZero() {
+			continue
+		}
+		d.send(Digest{Frequency: frequency, Start: start, End: end})
+	}
+}
+
+// send emails the digest to the contacts that get its frequency and have an
+// active email, logging each delivery.
+func (d *Digester) send(digest Digest) {
+	contacts, err := database.GetDigestContacts(d.DB, digest.Frequency)
+	if err != nil || len(contacts) == 0 {
+		if err != nil {
+			log.Println("Error getting the", digest.Frequency, "digest contacts:", err)
+		}
+		return
+	}
+	digest.Sites, err = database.GetSiteSummaries(d.DB, digest.Start, digest.End)
+	if err != nil {
+		log.Println("Error getting the", digest.Frequency, "digest summaries:", err)
+		return
+	}
+	email, err := digest.Email()
+	if err != nil {
+		log.Println("Error formatting the", digest.Frequency, "digest:", err)
+		return
+	}
+	for _, c := range contacts {
+		endpoint, ok := c.Channel(database.EmailChannel)
+		if !ok || !endpoint.IsActive {
+			log.Println("No active email for the", digest.Frequency, "digest of", c.Name)
+			continue
+		}
+		log.Println("Sending the", digest.Frequency, "digest to", c.Name)
+		sendErr := d.SendEmail(endpoint.Address, email)
+		a := database.DeliveryAttempt{Recipient: c.Name, ChannelType: database.EmailChannel,
+			Address: endpoint.Address, Event: database.DigestEvent, Subject: email.Subject,
+			Summary: summary(email.Text), Success: sendErr == nil, AttemptedAt: time.Now()}
+		if sendErr != nil {
+			a.Error = sendErr.Error()
+			log.Println("Error sending the", digest.Frequency, "digest to", c.Name+":", sendErr)
+		}
+		err = a.CreateDeliveryAttempt(d.DB)
+		if err != nil {
+			log.Println("Error saving the delivery attempt:", err)
+		}
+	}
+}
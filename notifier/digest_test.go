@@ -0,0 +1,118 @@
+package notifier_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+)
+
+// TestDigestPeriod tests that the period of each frequency is the last whole
+// one before the time.
+func TestDigestPeriod(t *testing.T) {
+	// October 21, 2026 is a Wednesday.
+	now := time.Date(2026, 10, 21, 9, 30, 0, 0, time.UTC)
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		frequency  string
+		start, end time.Time
+	}{
+		{database.DailyDigest, day(10, 20), day(10, 21)},
+		{database.WeeklyDigest, day(10, 12), day(10, 19)},
+		{database.MonthlyDigest, day(9, 1), day(10, 1)},
+	}
+	for _, test := range tests {
+		start, end := notifier.DigestPeriod(test.frequency, now)
+		if !start.Equal(test.start) || !end.Equal(test.end) {
+			t.Error(test.frequency, "period should be", test.start, "to", test.end, "got", start, "to", end)
+		}
+	}
+	// On a Monday the week that just ended is the period.
+	start, _ := notifier.DigestPeriod(database.WeeklyDigest, day(10, 19))
+	if !start.Equal(day(10, 12)) {
+		t.Error("Weekly period on a Monday should start the Monday before, got", start)
+	}
+}
+
+// TestDigestEmail tests the subject and the summaries of the sites in the email.
+func TestDigestEmail(t *testing.T) {
+	d := notifier.Digest{Frequency: database.WeeklyDigest,
+		Start: time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), End: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+		Sites: []database.SiteSummary{
+			{Name: "Alpha", PingsUp: 999, PingsDown: 1, AvgResponse: 123.4, Incidents: 1, LongestOutage: 90 * time.Second},
+			{Name: "Beta"},
+		}}
+	e, err := d.Email()
+	if err != nil {
+		t.Fatal("Failed to format the digest:", err)
+	}
+	if e.Subject != "Go Ping Sites: Weekly Summary for Oct 12 - Oct 18, 2026" {
+		t.Error("Digest subject not as expected:", e.Subject)
+	}
+	if !strings.Contains(e.Text, "Alpha: 99.900% uptime, 123 ms average response, 1 incidents, longest outage 1m30s") ||
+		!strings.Contains(e.Text, "Beta: - uptime") {
+		t.Error("Digest text not as expected:", e.Text)
+	}
+	if !strings.Contains(e.HTML, "<td style=\"padding: 6px 12px;\">Alpha</td>") || !strings.Contains(e.HTML, "1m30s") {
+		t.Error("Digest HTML not as expected:", e.HTML)
+	}
+}
+
+// TestDigesterSendDue tests that the digests are only sent once their period
+// has ended, to the contacts that get them.
+func TestDigesterSendDue(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+
+	for _, c := range []database.Contact{
+		{Name: "Joe Contact", Digest: database.WeeklyDigest,
+			Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "joe@test.com", IsActive: true}}},
+		{Name: "Jack Contact",
+			Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "jack@test.com", IsActive: true}}},
+	} {
+		err = c.CreateContact(db)
+		if err != nil {
+			t.Fatal("Failed to create new contact:", err)
+		}
+	}
+
+	var mu sync.Mutex
+	var sent []string
+	d := notifier.NewDigester(db, func(recipient string, email notifier.Email) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, recipient+": "+email.Subject)
+		return nil
+	})
+	// The first run only notes the periods.
+	now := time.Date(2026, 10, 21, 9, 30, 0, 0, time.Local)
+	d.SendDue(now)
+	if len(sent) != 0 {
+		t.Fatal("No digests should be sent on the first run:", sent)
+	}
+	// A day later only the daily period has ended, which Joe doesn't get.
+	d.SendDue(now.AddDate(0, 0, 1))
+	if len(sent) != 0 {
+		t.Fatal("Only the weekly digest should be sent to Joe:", sent)
+	}
+	d.SendDue(now.AddDate(0, 0, 5))
+	d.SendDue(now.AddDate(0, 0, 5).Add(time.Hour))
+	if len(sent) != 1 || sent[0] != "joe@test.com: Go Ping Sites: Weekly Summary for Oct 19 - Oct 25, 2026" {
+		t.Fatal("The weekly digest should be sent to Joe once:", sent)
+	}
+
+	var attempts database.DeliveryAttempts
+	err = attempts.GetDeliveryAttempts(db, database.DeliveryFilter{Limit: 10})
+	if err != nil {
+		t.Fatal("Failed to get delivery attempts:", err)
+	}
+	if len(attempts) != 1 || attempts[0].Event != database.DigestEvent || !attempts[0].Success {
+		t.Error("The digest should be logged:", attempts)
+	}
+}
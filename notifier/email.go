@@ -0,0 +1,166 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// Email is the email that the EmailSender sends, with the Text and HTML as the
+// alternative parts. The InReplyTo is the Message-ID of the email it threads
+// under, such as the down email of the outage that an up email is about.
+type Email struct {
+	Subject   string
+	Text      string
+	HTML      string
+	Date      time.Time
+	MessageID string
+	InReplyTo string
+}
+
+var emailTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: Helvetica, Arial, sans-serif; font-size: 14px; color: #333;">
+<h2 style="color: {{.Color}};">{{.Subject}}</h2>
+{{range .Paragraphs}}<p>{{.}}</p>
+{{end}}{{if or .SiteLink .AcknowledgeURL}}<p>{{if .AcknowledgeURL}}<a href="{{.AcknowledgeURL}}" style="display: inline-block; padding: 6px 12px; background-color: #f0ad4e; color: #fff; text-decoration: none; border-radius: 4px;">Acknowledge</a>&nbsp;&nbsp;{{end}}{{if .SiteLink}}<a href="{{.SiteLink}}">Site details</a>{{end}}</p>
+{{end}}</body>
+</html>
+`))
+
+// Email returns the email of the notification. The down email of an outage has
+// a Message-ID from the site and the start of the outage, so the reminders and
+// the up email can be threaded under it.
+func (n *Notifier) Email() (Email, error) {
+	e := Email{Subject: n.Subject, Text: n.Message, Date: n.Time}
+	if e.Date.IsZero() {
+		e.Date = time.Now()
+	}
+	outageID := n.outageMessageID()
+	switch {
+	case outageID != "" && n.Event() == database.DownEvent:
+		e.MessageID = outageID
+	case outageID != "" && n.Event() != "":
+		e.MessageID = newMessageID()
+		e.InReplyTo = outageID
+	default:
+		e.MessageID = newMessageID()
+	}
+
+	// The acknowledge link is a button in the HTML rather than in the text.
+	text := n.Message
+	if n.AcknowledgeURL != "" {
+		text = strings.Replace(text, "\nAcknowledge: "+n.AcknowledgeURL, "", 1)
+	}
+	data := struct {
+		Subject        string
+		Color          string
+		Paragraphs     []string
+		SiteLink       string
+		AcknowledgeURL string
+	}{Subject: n.Subject, Color: statusColor(n.Status), SiteLink: n.SiteLink(),
+		AcknowledgeURL: n.AcknowledgeURL}
+	for _, p := range strings.Split(text, "\n") {
+		if strings.TrimSpace(p) != "" {
+			data.Paragraphs = append(data.Paragraphs, p)
+		}
+	}
+	var buf bytes.Buffer
+	err := emailTemplate.Execute(&buf, data)
+	if err != nil {
+		return e, err
+	}
+	e.HTML = buf.String()
+	return e, nil
+}
+
+// outageMessageID returns the Message-ID of the down email of the outage, or an
+// empty string if the notification isn't about an outage.
+func (n *Notifier) outageMessageID() string {
+	if n.OutageStart.IsZero() || n.Site.SiteID == 0 {
+		return ""
+	}
+	return fmt.Sprintf("<outage.%d.%d@%s>", n.Site.SiteID, n.OutageStart.Unix(), messageIDDomain())
+}
+
+func newMessageID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(b), messageIDDomain())
+}
+
+// messageIDDomain is the domain of the sending address, which makes the
+// Message-IDs unique to this server.
+func messageIDDomain() string {
+	address := config.Settings.SMTP.EmailAddress
+	if i := strings.LastIndex(address, "@"); i >= 0 && i < len(address)-1 {
+		return address[i+1:]
+	}
+	return "go-ping-sites.localhost"
+}
+
+// Format returns the email as an RFC 5322 multipart/alternative message from
+// the sender to the recipient.
+func (e Email) Format(from string, to string) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", e.Text},
+		{"text/html; charset=utf-8", e.HTML},
+	}
+	for _, p := range parts {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qw := quotedprintable.NewWriter(pw)
+		_, err = qw.Write([]byte(strings.Replace(p.content, "\n", "\r\n", -1)))
+		if err != nil {
+			return nil, err
+		}
+		err = qw.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := mw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	header := func(name string, value string) {
+		msg.WriteString(name + ": " + value + "\r\n")
+	}
+	header("From", from)
+	header("To", to)
+	header("Subject", mime.QEncoding.Encode("utf-8", e.Subject))
+	header("Date", e.Date.Format(time.RFC1123Z))
+	header("Message-ID", e.MessageID)
+	if e.InReplyTo != "" {
+		header("In-Reply-To", e.InReplyTo)
+		header("References", e.InReplyTo)
+	}
+	header("MIME-Version", "1.0")
+	header("Content-Type", "multipart/alternative; boundary=\""+mw.Boundary()+"\"")
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,123 @@
+package notifier_test
+
+import (
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+)
+
+// TestEmailThreading tests that the up email of an outage replies to its down
+// email and that other outages aren't threaded with it.
+func TestEmailThreading(t *testing.T) {
+	s := database.Site{SiteID: 5, Name: "Test", URL: "http://www.test.com"}
+	start := time.Date(2016, 3, 1, 22, 0, 0, 0, time.UTC)
+	down := notifier.NewNotifier(s, "Test at http://www.test.com: Site is down.", "Test: Site is Down", nil)
+	down.Status = "Down"
+	down.PreviousStatus = "Up"
+	down.OutageStart = start
+	up := notifier.NewNotifier(s, "Test at http://www.test.com: Site is now up.", "Test: Site is Up", nil)
+	up.Status = "Up"
+	up.PreviousStatus = "Down"
+	up.OutageStart = start
+
+	downEmail, err := down.Email()
+	if err != nil {
+		t.Fatal("Failed to build the down email:", err)
+	}
+	upEmail, err := up.Email()
+	if err != nil {
+		t.Fatal("Failed to build the up email:", err)
+	}
+	if downEmail.InReplyTo != "" || upEmail.InReplyTo != downEmail.MessageID ||
+		upEmail.MessageID == downEmail.MessageID {
+		t.Error("Up email should reply to the down email:", downEmail.MessageID, upEmail.InReplyTo, upEmail.MessageID)
+	}
+
+	up.OutageStart = start.Add(time.Hour)
+	upEmail, err = up.Email()
+	if err != nil || upEmail.InReplyTo == downEmail.MessageID {
+		t.Error("Up email of another outage should not reply to the down email:", upEmail.InReplyTo, err)
+	}
+
+	test := notifier.NewTestNotifier(s, nil)
+	testEmail, err := test.Email()
+	if err != nil || testEmail.InReplyTo != "" || testEmail.MessageID == "" {
+		t.Error("Test email should have its own Message-ID:", testEmail.MessageID, testEmail.InReplyTo, err)
+	}
+}
+
+// TestEmailFormat tests that the email is a multipart message with the headers
+// and the text and HTML parts.
+func TestEmailFormat(t *testing.T) {
+	s := database.Site{SiteID: 5, Name: "Test <prod>", URL: "http://www.test.com"}
+	n := notifier.NewNotifier(s, "Test <prod> at http://www.test.com: Site is down.", "Test <prod>: Site is Down ✗", nil)
+	n.Status = "Down"
+	n.AcknowledgeURL = "http://localhost:8000/acknowledge/5?t=1&sig=abc"
+	n.Message += "\nAcknowledge: " + n.AcknowledgeURL
+	email, err := n.Email()
+	if err != nil {
+		t.Fatal("Failed to build the email:", err)
+	}
+	email.InReplyTo = "<outage.5.1@test.com>"
+	raw, err := email.Format("alerts@test.com", "joe@test.com")
+	if err != nil {
+		t.Fatal("Failed to format the email:", err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatal("Failed to parse the email:", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Test <prod>: Site is Down ✗" {
+		t.Error("Subject not as expected:", subject, err)
+	}
+	if msg.Header.Get("From") != "alerts@test.com" || msg.Header.Get("To") != "joe@test.com" ||
+		msg.Header.Get("Message-ID") != email.MessageID || msg.Header.Get("MIME-Version") != "1.0" ||
+		msg.Header.Get("In-Reply-To") != "<outage.5.1@test.com>" ||
+		msg.Header.Get("References") != "<outage.5.1@test.com>" {
+		t.Error("Headers not as expected:", msg.Header)
+	}
+	if date, err := msg.Header.Date(); err != nil || !date.Equal(n.Time.Truncate(time.Second)) {
+		t.Error("Date not as expected:", date, err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatal("Content type not as expected:", mediaType, err)
+	}
+	parts := make(map[string]string)
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Failed to read the part:", err)
+		}
+		// The reader decodes the quoted-printable parts.
+		content, err := ioutil.ReadAll(p)
+		if err != nil {
+			t.Fatal("Failed to decode the part:", err)
+		}
+		parts[strings.Split(p.Header.Get("Content-Type"), ";")[0]] = string(content)
+	}
+	if !strings.Contains(parts["text/plain"], "Site is down.\r\nAcknowledge: "+n.AcknowledgeURL) {
+		t.Error("Text part not as expected:", parts["text/plain"])
+	}
+	html := parts["text/html"]
+	if !strings.Contains(html, "<p>Test &lt;prod&gt; at http://www.test.com: Site is down.</p>") ||
+		!strings.Contains(html, `<a href="http://localhost:8000/acknowledge/5?t=1&amp;sig=abc"`) ||
+		strings.Contains(html, "<p>Acknowledge:") {
+		t.Error("HTML part not as expected:", html)
+	}
+}
@@ -0,0 +1,187 @@
+package notifier
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// The default endpoints of the incident management services if they aren't
+// configured.
+const (
+	defaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	defaultOpsgenieURL  = "https://api.opsgenie.com/v2/alerts"
+)
+
+// The actions of the incident events.
+const (
+	triggerAction = "trigger"
+	resolveAction = "resolve"
+)
+
+// IsIncidentChannel returns true for the channel types that open an incident
+// and resolve it on recovery.
+func IsIncidentChannel(channelType string) bool {
+	return channelType == database.PagerDutyChannel || channelType == database.OpsgenieChannel
+}
+
+// DedupKey returns the key that identifies the incident of the site, so the
+// resolve event closes the incident opened by the trigger event.
+func DedupKey(site database.Site) string {
+	return "go-ping-sites-site-" + strconv.FormatInt(site.SiteID, 10)
+}
+
+// incidentAction returns whether the notification opens or closes the
+// incident of the site. The flapping and stable notifications follow the state
+// of the site at the time.
+func incidentAction(n *Notifier) string {
+	switch n.Status {
+	case "Down":
+		return triggerAction
+	case "Up":
+		return resolveAction
+	}
+	if n.Ping.SiteDown {
+		return triggerAction
+	}
+	return resolveAction
+}
+
+// sendIncident sends the trigger or resolve event for the notification. A test
+// notification triggers an incident with its own key and resolves it straight
+// away, so it doesn't affect an open incident of the site. The degraded and
+// certificate expiry warnings are about a site that is up, so they're not sent.
+func sendIncident(n *Notifier, send func(action string, dedupKey string) error) error {
+	if n.Status == "Degraded" || n.Status == "Expiring" {
+		return nil
+	}
+	if n.Status == "Test" {
+		dedupKey := DedupKey(n.Site) + "-test"
+		err := send(triggerAction, dedupKey)
+		if err != nil {
+			return err
+		}
+		return send(resolveAction, dedupKey)
+	}
+	return send(incidentAction(n), DedupKey(n.Site))
+}
+
+// PagerDuty sends the notifications as events to the PagerDuty Events API v2,
+// the address of the endpoint is the routing key of the service integration.
+type PagerDuty struct {
+	Client *http.Client
+	URL    string
+}
+
+// NewPagerDuty returns a PagerDuty with the URL from the config.
+func NewPagerDuty() *PagerDuty {
+	p := PagerDuty{Client: &http.Client{Timeout: chatTimeout}, URL: config.Settings.Incidents.PagerDutyURL}
+	if p.URL == "" {
+		p.URL = defaultPagerDutyURL
+	}
+	return &p
+}
+
+// Name implements the Channel interface for PagerDuty.
+func (p *PagerDuty) Name() string {
+	return "PagerDuty"
+}
+
+// Send implements the Channel interface for PagerDuty.
+func (p *PagerDuty) Send(address string, n *Notifier) error {
+	return sendIncident(n, func(action string, dedupKey string) error {
+		event := map[string]interface{}{
+			"routing_key":  address,
+			"event_action": action,
+			"dedup_key":    dedupKey,
+		}
+		if action == triggerAction {
+			severity := "critical"
+			if n.Status != "Down" {
+				severity = "warning"
+			}
+			source := n.Site.URL
+			if source == "" {
+				source = n.Site.Name
+			}
+			event["payload"] = map[string]interface{}{
+				"summary":   n.Subject,
+				"source":    source,
+				"severity":  severity,
+				"timestamp": n.Time.UTC().Format(time.RFC3339),
+				"component": n.Site.Name,
+				"custom_details": map[string]interface{}{
+					"message": n.Message,
+					"status":  n.Status,
+				},
+			}
+			if link := n.SiteLink(); link != "" {
+				event["links"] = []interface{}{map[string]interface{}{"href": link, "text": "View Site Details"}}
+			}
+		}
+		return postJSON(p.Client, p.URL, event)
+	})
+}
+
+// Opsgenie sends the notifications as alerts to the Opsgenie Alert API, the
+// address of the endpoint is the API key of the integration.
+type Opsgenie struct {
+	Client *http.Client
+	URL    string
+}
+
+// NewOpsgenie returns an Opsgenie with the URL from the config.
+func NewOpsgenie() *Opsgenie {
+	o := Opsgenie{Client: &http.Client{Timeout: chatTimeout}, URL: config.Settings.Incidents.OpsgenieURL}
+	if o.URL == "" {
+		o.URL = defaultOpsgenieURL
+	}
+	return &o
+}
+
+// Name implements the Channel interface for Opsgenie.
+func (o *Opsgenie) Name() string {
+	return "Opsgenie"
+}
+
+// Send implements the Channel interface for Opsgenie. The alert is created with
+// the dedup key as its alias, which is used to close it.
+func (o *Opsgenie) Send(address string, n *Notifier) error {
+	header := http.Header{"Authorization": {"GenieKey " + address}}
+	alertsURL := strings.TrimRight(o.URL, "/")
+	return sendIncident(n, func(action string, dedupKey string) error {
+		if action == resolveAction {
+			closeURL := alertsURL + "/" + url.PathEscape(dedupKey) + "/close?identifierType=alias"
+			return sendJSON(o.Client, "POST", closeURL, map[string]interface{}{
+				"source": "GoPingSites",
+				"note":   n.Message,
+			}, header)
+		}
+		priority := "P1"
+		if n.Status != "Down" {
+			priority = "P3"
+		}
+		// The message of an alert is limited to 130 characters.
+		message := n.Subject
+		if r := []rune(message); len(r) > 130 {
+			message = string(r[:130])
+		}
+		details := map[string]string{"site": n.Site.Name, "url": n.Site.URL, "status": n.Status}
+		if link := n.SiteLink(); link != "" {
+			details["link"] = link
+		}
+		return sendJSON(o.Client, "POST", alertsURL, map[string]interface{}{
+			"message":     message,
+			"alias":       dedupKey,
+			"description": n.Message,
+			"source":      "GoPingSites",
+			"priority":    priority,
+			"details":     details,
+		}, header)
+	})
+}
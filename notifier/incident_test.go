@@ -0,0 +1,153 @@
+package notifier_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+)
+
+// incidentRequest is a request received by the stand-in for the incident
+// management services.
+type incidentRequest struct {
+	Path          string
+	Authorization string
+	Body          map[string]interface{}
+}
+
+func incidentServer(requests *[]incidentRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		r := incidentRequest{Path: req.URL.RequestURI(), Authorization: req.Header.Get("Authorization")}
+		json.NewDecoder(req.Body).Decode(&r.Body)
+		*requests = append(*requests, r)
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+}
+
+// TestPagerDuty tests that going down triggers the incident of the site and
+// coming back up resolves it with the same dedup key.
+func TestPagerDuty(t *testing.T) {
+	var requests []incidentRequest
+	server := incidentServer(&requests)
+	defer server.Close()
+
+	channels := notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock)
+	channels.Register(database.PagerDutyChannel, &notifier.PagerDuty{Client: http.DefaultClient, URL: server.URL})
+	site := database.Site{SiteID: 3, Name: "Test", URL: "http://www.example.com"}
+	down := notifier.NewNotifier(site, "Test at http://www.example.com: Site is down.", "Test: Site is Down", channels)
+	down.Status = "Down"
+	up := notifier.NewNotifier(site, "Test at http://www.example.com: Site is now up.", "Test: Site is Up", channels)
+	up.Status = "Up"
+	for _, n := range []*notifier.Notifier{down, up} {
+		err := n.SendTo(database.PagerDutyChannel, "routingkey")
+		if err != nil {
+			t.Fatal("Failed to send to PagerDuty:", err)
+		}
+	}
+
+	if len(requests) != 2 {
+		t.Fatal("Expected a trigger and a resolve event, got", len(requests))
+	}
+	trigger, resolve := requests[0].Body, requests[1].Body
+	if trigger["event_action"] != "trigger" || trigger["routing_key"] != "routingkey" ||
+		trigger["dedup_key"] != "go-ping-sites-site-3" || trigger["payload"] == nil {
+		t.Error("Trigger event not as expected:", trigger)
+	}
+	if resolve["event_action"] != "resolve" || resolve["dedup_key"] != trigger["dedup_key"] {
+		t.Error("Resolve event not as expected:", resolve)
+	}
+}
+
+// TestOpsgenie tests that the alert is created with the dedup key as its alias
+// and closed by the alias.
+func TestOpsgenie(t *testing.T) {
+	var requests []incidentRequest
+	server := incidentServer(&requests)
+	defer server.Close()
+
+	channels := notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock)
+	channels.Register(database.OpsgenieChannel, &notifier.Opsgenie{Client: http.DefaultClient, URL: server.URL})
+	n := notifier.NewTestNotifier(database.Site{SiteID: 3, Name: "Test"}, channels)
+	err := n.SendTo(database.OpsgenieChannel, "apikey")
+	if err != nil {
+		t.Fatal("Failed to send to Opsgenie:", err)
+	}
+
+	// The test message creates an alert and closes it straight away.
+	if len(requests) != 2 {
+		t.Fatal("Expected the alert to be created and closed, got", len(requests))
+	}
+	if requests[0].Path != "/" || requests[0].Body["alias"] != "go-ping-sites-site-3-test" ||
+		requests[0].Authorization != "GenieKey apikey" {
+		t.Error("Create alert request not as expected:", requests[0])
+	}
+	if requests[1].Path != "/go-ping-sites-site-3-test/close?identifierType=alias" ||
+		requests[1].Authorization != "GenieKey apikey" {
+		t.Error("Close alert request not as expected:", requests[1])
+	}
+
+	// The message is cut to 130 characters without splitting a character.
+	n.Subject = strings.Repeat("é", 140)
+	err = n.SendTo(database.OpsgenieChannel, "apikey")
+	if err != nil {
+		t.Fatal("Failed to send to Opsgenie:", err)
+	}
+	if message := requests[2].Body["message"]; message != strings.Repeat("é", 130) {
+		t.Error("Alert message not cut to 130 characters:", message)
+	}
+}
+
+// TestIncidentResolveIgnoresSubscriptions tests that the incident channels of
+// the site are sent the resolve when none of the contacts are subscribed to
+// the recovery, so the incident opened by the outage is closed.
+func TestIncidentResolveIgnoresSubscriptions(t *testing.T) {
+	site := getTestSite()
+	for i := range site.Contacts {
+		site.Contacts[i].Subscription = database.Subscription{Events: []string{database.DownEvent}}
+	}
+	site.Channels = []database.SiteChannel{{ChannelType: database.OpsgenieChannel, Address: "apikey", IsActive: true}}
+	n := notifier.NewNotifier(site, "Test is up.", "Test: Site is Up", notifier.Channels{})
+	n.Status = "Up"
+	n.PreviousStatus = "Down"
+	messages, err := n.OutboxMessages()
+	if err != nil {
+		t.Fatal("Failed to get the outbox messages:", err)
+	}
+	var incidents int
+	for _, m := range messages {
+		if m.ChannelType == database.OpsgenieChannel {
+			incidents++
+		} else if m.Recipient != "Webhook" {
+			t.Error("Contacts not subscribed to the recovery shouldn't be notified:", m)
+		}
+	}
+	if incidents != 1 {
+		t.Error("The incident should be resolved:", messages)
+	}
+}
+
+// TestIncidentSkipsWarnings tests that the degraded and certificate expiry
+// warnings don't trigger or resolve the incident of the site.
+func TestIncidentSkipsWarnings(t *testing.T) {
+	var requests []incidentRequest
+	server := incidentServer(&requests)
+	defer server.Close()
+
+	channels := notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock)
+	channels.Register(database.PagerDutyChannel, &notifier.PagerDuty{Client: http.DefaultClient, URL: server.URL})
+	for _, status := range []string{"Degraded", "Expiring"} {
+		n := notifier.NewNotifier(database.Site{SiteID: 3, Name: "Test"}, "Test: warning.", "Test: Warning", channels)
+		n.Status = status
+		err := n.SendTo(database.PagerDutyChannel, "routingkey")
+		if err != nil {
+			t.Fatal("Failed to send to PagerDuty:", err)
+		}
+	}
+	if len(requests) != 0 {
+		t.Error("The warnings should not be sent as incident events:", requests)
+	}
+}
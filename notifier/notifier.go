@@ -1,110 +1,288 @@
 package notifier
 
 import (
-	"errors"
+	"database/sql"
+	"fmt"
 	"log"
-	"net/smtp"
+	"strconv"
+	"strings"
 	"sync"
-
-	"github.com/sfreiberg/gotwilio"
+	"time"
 
 	"github.com/turnkey-commerce/go-ping-sites/config"
 	"github.com/turnkey-commerce/go-ping-sites/database"
 )
 
 // Notifier sends the notifications to the recipients on a status change.
+// The Status, PreviousStatus, Detail, Downtime and Ping are for the channels
+// that format the notification rather than sending the Message as is. The
+// Reminder is the number of the reminder while a site stays down, or 0, and
+// the AcknowledgeURL is the signed link to acknowledge an outage. The Error is
+// why the site is down and the Templates customize the wording by channel. The
+// OutageStart is when the outage the notification is about began, to thread
+// its emails. The CertExpires is when the TLS certificate of the site expires
+// for the certificate expiry warnings. A Call is only placed to the voice
+// numbers of the contacts, which aren't used for the other notifications. The
+// DB is where the direct sends are recorded in the delivery log, such as when
+// the notification couldn't be saved to the outbox.
 type Notifier struct {
-	Site      database.Site
-	Message   string
-	Subject   string
-	SendEmail EmailSender
-	SendSms   SmsSender
+	Site           database.Site
+	Message        string
+	Subject        string
+	Status         string
+	PreviousStatus string
+	Detail         string
+	Downtime       time.Duration
+	Reminder       int
+	AcknowledgeURL string
+	Error          string
+	OutageStart    time.Time
+	CertExpires    time.Time
+	Call           bool
+	Ping           database.Ping
+	Time           time.Time
+	Dependents     database.Sites
+	Channels       Channels  `json:"-"`
+	Templates      Templates `json:"-"`
+	DB             *sql.DB   `json:"-"`
 }
 
-// EmailSender defines a function to do the work to send an email.
-type EmailSender func(recipient string, message string, subject string) error
-
-// SmsSender defines a function to do the work to send an SMS text message.
-type SmsSender func(smsNumber string, message string) error
-
 // NewNotifier returns a new Notifier object to perform notifications about status change
-func NewNotifier(site database.Site, message string, subject string,
-	sendEmail EmailSender, sendSms SmsSender) *Notifier {
-	n := Notifier{Site: site, Message: message, Subject: subject,
-		SendEmail: sendEmail, SendSms: sendSms}
+func NewNotifier(site database.Site, message string, subject string, channels Channels) *Notifier {
+	n := Notifier{Site: site, Message: message, Subject: subject, Time: time.Now(),
+		Channels: channels}
 	return &n
 }
 
-// Notify starts the notification for each contact for the site.
+// NewTestNotifier returns a Notifier with a test message about the site, to
+// check that the notifications get through to the channels.
+func NewTestNotifier(site database.Site, channels Channels) *Notifier {
+	name := site.Name
+	if name == "" {
+		name = "Go Ping Sites"
+	}
+	detail := "This is a test message to check that the notifications are received, no action is needed."
+	n := NewNotifier(site, name+": "+detail, name+": Test Message", channels)
+	n.Status = "Test"
+	n.Detail = detail
+	return n
+}
+
+// AddDependents consolidates the notification for the sites that depend on the
+// site. Their names are added to the message and their contacts are added to
+// the recipients, so each contact gets a single alert for the outage.
+func (n *Notifier) AddDependents(dependents database.Sites) {
+	if len(dependents) == 0 {
+		return
+	}
+	var names []string
+	n.Dependents = dependents
+	// Copy the contacts so the site passed in isn't changed.
+	contacts := append([]database.Contact(nil), n.Site.Contacts...)
+	for _, d := range dependents {
+		names = append(names, d.Name)
+		for _, c := range d.Contacts {
+			if !hasContact(contacts, c) {
+				contacts = append(contacts, c)
+			}
+		}
+	}
+	n.Site.Contacts = contacts
+	// Also the chat channels of the dependent sites.
+	for _, d := range dependents {
+		n.AddSiteChannels(d.Channels)
+	}
+	n.Message += " Dependent sites affected: " + strings.Join(names, ", ") + "."
+}
+
+// AddSiteChannels adds the channels to the site's own channels of the
+// notification, the ones it already has aren't added again.
+func (n *Notifier) AddSiteChannels(channels []database.SiteChannel) {
+	// Copy the channels so the site passed in isn't changed.
+	siteChannels := append([]database.SiteChannel(nil), n.Site.Channels...)
+	for _, ch := range channels {
+		if !hasSiteChannel(siteChannels, ch) {
+			siteChannels = append(siteChannels, ch)
+		}
+	}
+	n.Site.Channels = siteChannels
+}
+
+func hasSiteChannel(channels []database.SiteChannel, channel database.SiteChannel) bool {
+	for _, ch := range channels {
+		if ch.ChannelType == channel.ChannelType && ch.Address == channel.Address {
+			return true
+		}
+	}
+	return false
+}
+
+func hasContact(contacts []database.Contact, contact database.Contact) bool {
+	for _, c := range contacts {
+		if c.ContactID == contact.ContactID && c.Name == contact.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify starts the notification for each contact for the site, for the
+// site's own channels and for the webhook in the config. A call is only placed
+// to the contacts. It sends directly rather than by way of the outbox, but the
+// channels that a contact's schedules hold are still saved to the outbox to be
+// sent when they allow, if there's a DB.
 func (n *Notifier) Notify() {
 	var wg sync.WaitGroup
 	log.Println("Sending Notification of Site Contacts about", n.Subject+"...")
+	now := time.Now()
 	for _, c := range n.Site.Contacts {
-		if c.SmsActive || c.EmailActive {
+		if event := n.SubscriptionEvent(); !c.Subscription.HasEvent(event) {
+			log.Println(c.Name, "is not subscribed to", event, "notifications")
+			continue
+		}
+		channels, held := n.ContactChannels(c, now)
+		if len(channels) > 0 {
 			// Notify contact
 			wg.Add(1)
-			go send(c, n.Message, n.Subject, n.SendEmail, n.SendSms, &wg)
-		} else {
+			go send(c, channels, n, &wg)
+		}
+		if len(held) > 0 {
+			n.hold(c, held)
+		} else if len(channels) == 0 {
 			log.Println("No active contact methods for", c.Name)
 		}
 	}
+	if n.Call {
+		wg.Wait()
+		return
+	}
+	for _, ch := range n.Site.Channels {
+		if ch.IsActive && ch.Address != "" {
+			wg.Add(1)
+			go sendSite(ch, n, &wg)
+		}
+	}
+	if url := config.Settings.Webhook.URL; url != "" {
+		wg.Add(1)
+		go sendSite(database.SiteChannel{ChannelType: database.WebhookChannel, Address: url,
+			IsActive: true}, n, &wg)
+	}
 	wg.Wait()
 }
 
-func send(c database.Contact, message string, subject string, sendEmail EmailSender, sendSms SmsSender, wg *sync.WaitGroup) {
-	var err error
-	log.Println("Sending notifications for", c.Name, subject, message)
-	if c.EmailActive && len(c.EmailAddress) > 0 {
-		err = sendEmail(c.EmailAddress, message, subject)
-		if err != nil {
-			log.Println("Error sending email:", err)
-		}
+// hold saves the notifications to the channels of the contact that its
+// schedules hold to the outbox, to be sent when they allow. Without a DB, or
+// if the outbox can't be written, they can't be held and aren't sent.
+func (n *Notifier) hold(c database.Contact, held map[string]time.Time) {
+	if n.DB == nil {
+		log.Println("Outside of the schedules of", c.Name+", notification not sent")
+		return
 	}
-
-	if c.SmsActive && len(c.SmsNumber) > 0 {
-		err = sendSms(c.SmsNumber, message)
-		if err != nil {
-			log.Println("Error sending SMS:", err)
-		}
+	messages, err := n.heldMessages(c, held)
+	if err == nil {
+		err = database.EnqueueOutboxMessages(n.DB, messages)
+	}
+	if err != nil {
+		log.Println("Error holding the notification for", c.Name+", notification not sent:", err)
 	}
-
-	wg.Done()
 }
 
-// SendEmail provides the implementation of the EmailSender type for runtime usage.
-func SendEmail(recipient string, message string, subject string) error {
-	// Set up authentication information.
-
-	// If the Username is not set up then use EmailAddress as the user
-	user := config.Settings.SMTP.EmailAddress
-	if config.Settings.SMTP.UserName != "" {
-		user = config.Settings.SMTP.UserName
+// SiteLink returns the link to the details page of the site, or an empty
+// string if the BaseURL of the website isn't configured.
+func (n *Notifier) SiteLink() string {
+	baseURL := strings.TrimRight(config.Settings.Website.BaseURL, "/")
+	if baseURL == "" || n.Site.SiteID == 0 {
+		return ""
 	}
+	return baseURL + "/settings/sites/" + strconv.FormatInt(n.Site.SiteID, 10)
+}
 
-	auth := smtp.PlainAuth("", user, config.Settings.SMTP.Password,
-		config.Settings.SMTP.Server)
-	server := config.Settings.SMTP.Server + ":" + config.Settings.SMTP.Port
-	to := []string{recipient}
-	from := config.Settings.SMTP.EmailAddress
-	msg := []byte("Subject: " + subject + "\r\n\r\n" +
-		message + "\r\n")
-	err := smtp.SendMail(server, auth, from, to, msg)
+// SendTo sends the notification to the address with the channel registered for
+// the channel type.
+func (n *Notifier) SendTo(channelType string, address string) error {
+	channel, ok := n.Channels[channelType]
+	if !ok {
+		return fmt.Errorf("no channel registered for %s", channelType)
+	}
+	err := channel.Send(address, n.ForChannel(channelType))
 	if err != nil {
-		return err
+		return fmt.Errorf("error sending %s: %v", channel.Name(), err)
 	}
 	return nil
 }
 
-// SendSms provides the implementation of the SmsSender type for runtime usage.
-func SendSms(smsNumber string, message string) error {
-	twilio := gotwilio.NewTwilioClient(config.Settings.Twilio.AccountSid, config.Settings.Twilio.AuthToken)
-	from := config.Settings.Twilio.Number
+// ContactChannels returns the channels to notify the contact on at the time,
+// the ones of its subscription to the event that its schedules allow unless the
+// site is critical. If they allow none of them then the time to hold each
+// channel type until is returned. A call is only to the active voice numbers,
+// which are left out of the other notifications.
+func (n *Notifier) ContactChannels(c database.Contact, t time.Time) ([]database.ContactChannel, map[string]time.Time) {
+	if !c.Subscription.HasEvent(n.SubscriptionEvent()) {
+		return nil, nil
+	}
+	if n.Call {
+		var voice []database.ContactChannel
+		for _, ch := range c.ActiveChannels() {
+			if ch.ChannelType == database.VoiceChannel {
+				voice = append(voice, ch)
+			}
+		}
+		return voice, nil
+	}
+	var channels []database.ContactChannel
+	var held map[string]time.Time
+	if n.Site.IsCritical {
+		channels = c.SubscribedChannels()
+	} else {
+		channels, held = c.AvailableChannels(t)
+	}
+	var result []database.ContactChannel
+	for _, ch := range channels {
+		if ch.ChannelType != database.VoiceChannel {
+			result = append(result, ch)
+		}
+	}
+	delete(held, database.VoiceChannel)
+	return result, held
+}
+
+func send(c database.Contact, channels []database.ContactChannel, n *Notifier, wg *sync.WaitGroup) {
+	log.Println("Sending notifications for", c.Name, n.Subject, n.Message)
+	for _, endpoint := range channels {
+		attempt := database.DeliveryAttempt{Recipient: c.Name, ChannelType: endpoint.ChannelType,
+			Address: endpoint.Address}
+		channel, ok := n.Channels[endpoint.ChannelType]
+		if !ok {
+			log.Println("No channel registered for", endpoint.ChannelType, "to notify", c.Name)
+			RecordDelivery(n.DB, attempt, n, fmt.Errorf("no channel registered for %s", endpoint.ChannelType))
+			continue
+		}
+		rendered := n.ForChannel(endpoint.ChannelType)
+		err := channel.Send(endpoint.Address, rendered)
+		if err != nil {
+			log.Println("Error sending "+channel.Name()+":", err)
+		}
+		RecordDelivery(n.DB, attempt, rendered, err)
+	}
 
-	_, exc, err := twilio.SendSMS(from, smsNumber, message, "", "")
+	wg.Done()
+}
+
+func sendSite(endpoint database.SiteChannel, n *Notifier, wg *sync.WaitGroup) {
+	defer wg.Done()
+	attempt := database.DeliveryAttempt{Recipient: n.Site.Name, ChannelType: endpoint.ChannelType,
+		Address: endpoint.Address}
+	channel, ok := n.Channels[endpoint.ChannelType]
+	if !ok {
+		log.Println("No channel registered for", endpoint.ChannelType, "to notify", n.Site.Name)
+		RecordDelivery(n.DB, attempt, n, fmt.Errorf("no channel registered for %s", endpoint.ChannelType))
+		return
+	}
+	log.Println("Sending", channel.Name(), "notification for", n.Site.Name, n.Subject)
+	rendered := n.ForChannel(endpoint.ChannelType)
+	err := channel.Send(endpoint.Address, rendered)
 	if err != nil {
-		return err
-	} else if exc != nil {
-		return errors.New(exc.Message)
+		log.Println("Error sending "+channel.Name()+":", err)
 	}
-	return nil
+	RecordDelivery(n.DB, attempt, rendered, err)
 }
@@ -1,14 +1,17 @@
 package notifier
 
-import "errors"
+import (
+	"errors"
+	"sync"
+)
 
 // SendEmailMock mocks the normal response of a successful send with no error.
-func SendEmailMock(recipient string, message string, subject string) error {
+func SendEmailMock(recipient string, email Email) error {
 	return nil
 }
 
 // SendEmailErrorMock mocks an error response about no response from the server.
-func SendEmailErrorMock(recipient string, message string, subject string) error {
+func SendEmailErrorMock(recipient string, email Email) error {
 	return errors.New("Error - no response from server.")
 }
 
@@ -21,3 +24,29 @@ func SendSmsMock(smsNumber string, message string) error {
 func SendSmsErrorMock(smsNumber string, message string) error {
 	return errors.New("Error - no response from server.")
 }
+
+// ChannelMock mocks a channel that records the addresses and the messages
+// that it sent, it fails with the Err if it's set.
+type ChannelMock struct {
+	mu       sync.Mutex
+	Sent     []string
+	Messages []string
+	Err      error
+}
+
+// Name implements the Channel interface for the mock.
+func (c *ChannelMock) Name() string {
+	return "mock"
+}
+
+// Send implements the Channel interface for the mock.
+func (c *ChannelMock) Send(address string, n *Notifier) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Err != nil {
+		return c.Err
+	}
+	c.Sent = append(c.Sent, address)
+	c.Messages = append(c.Messages, n.Message)
+	return nil
+}
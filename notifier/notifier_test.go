@@ -13,7 +13,7 @@ import (
 // TestNewNotifier tests building the pinger object.
 func TestNewNotifier(t *testing.T) {
 	site := getTestSite()
-	n := notifier.NewNotifier(site, "Site 1 responding OK", "Site 1 Up", notifier.SendEmailMock, notifier.SendSmsMock)
+	n := notifier.NewNotifier(site, "Site 1 responding OK", "Site 1 Up", notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
 	// Verify the first contact was Loaded with proper attributes and sorted last.
 	if !reflect.DeepEqual(site.Contacts, n.Site.Contacts) {
 		t.Error("Incoming site contacts are not the same as the notifier contacts:\n", site.Contacts, n.Site.Contacts)
@@ -24,7 +24,7 @@ func TestNewNotifier(t *testing.T) {
 func TestNotify(t *testing.T) {
 	pinger.CreatePingerLog("", true)
 	site := getTestSite()
-	n := notifier.NewNotifier(site, "Site 1 responding OK", "Site 1 Up", notifier.SendEmailMock, notifier.SendSmsMock)
+	n := notifier.NewNotifier(site, "Site 1 responding OK", "Site 1 Up", notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
 	n.Notify()
 
 	results, err := pinger.GetLogContent()
@@ -44,7 +44,7 @@ func TestNotify(t *testing.T) {
 // TestNotifyError tests calling the Notifications with errors on each send method.
 func TestNotifyError(t *testing.T) {
 	site := getTestSite()
-	n := notifier.NewNotifier(site, "Site 1 responding OK", "Site 1 Up", notifier.SendEmailErrorMock, notifier.SendSmsErrorMock)
+	n := notifier.NewNotifier(site, "Site 1 responding OK", "Site 1 Up", notifier.NewChannels(notifier.SendEmailErrorMock, notifier.SendSmsErrorMock))
 	n.Notify()
 
 	results, err := pinger.GetLogContent()
@@ -61,17 +61,72 @@ func TestNotifyError(t *testing.T) {
 	}
 }
 
+// TestNotifyChannels tests sending to the channels registered for the contact
+// endpoints and skipping the endpoints without a channel.
+func TestNotifyChannels(t *testing.T) {
+	site := getTestSite()
+	site.Contacts[0].Channels = []database.ContactChannel{
+		{ChannelType: "webhook", Address: "http://hooks.example.com", IsActive: true},
+		{ChannelType: "webhook", Address: "http://inactive.example.com", IsActive: false},
+		{ChannelType: "pager", Address: "joe", IsActive: true}}
+	webhook := new(notifier.ChannelMock)
+	channels := notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock)
+	channels.Register("webhook", webhook)
+	n := notifier.NewNotifier(site, "Site 1 responding OK", "Site 1 Up", channels)
+	n.Notify()
+
+	if !reflect.DeepEqual(webhook.Sent, []string{"http://hooks.example.com"}) {
+		t.Error("Only the active webhook endpoint should be sent to:", webhook.Sent)
+	}
+
+	results, err := pinger.GetLogContent()
+	if err != nil {
+		t.Fatal("Failed to get log results.", err)
+	}
+	if !strings.Contains(results, "No channel registered for pager to notify Joe Contact") {
+		t.Error("Failed to report the endpoint without a channel.")
+	}
+}
+
+// TestAddDependents tests consolidating the notification for dependent sites.
+func TestAddDependents(t *testing.T) {
+	site := getTestSite()
+	dependent := database.Site{Name: "Test App"}
+	c3 := database.Contact{Name: "Jill Contact",
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "jill@test.com", IsActive: true}}}
+	dependent.Contacts = append(dependent.Contacts, site.Contacts[0], c3)
+	dependent.Channels = []database.SiteChannel{{ChannelType: database.SlackChannel,
+		Address: "http://hooks.example.com", IsActive: true}}
+	n := notifier.NewNotifier(site, "Site 1 is down.", "Site 1 Down", notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
+	n.AddDependents(database.Sites{dependent})
+
+	if n.Message != "Site 1 is down. Dependent sites affected: Test App." {
+		t.Error("Dependent sites not added to the message:", n.Message)
+	}
+	if len(n.Site.Contacts) != 3 || n.Site.Contacts[2].Name != "Jill Contact" {
+		t.Error("Dependent site contacts should be added once:", n.Site.Contacts)
+	}
+	if len(n.Site.Channels) != 1 {
+		t.Error("Dependent site channels should be added:", n.Site.Channels)
+	}
+	if len(site.Contacts) != 2 {
+		t.Error("Incoming site contacts should not be changed.")
+	}
+}
+
 // Create the struct for the Site and its contacts used for testing.
 func getTestSite() database.Site {
 	s1 := database.Site{Name: "Test", IsActive: true, URL: "http://www.google.com",
 		PingIntervalSeconds: 2, TimeoutSeconds: 1}
 
 	// Create first contact
-	c1 := database.Contact{Name: "Joe Contact", EmailAddress: "joe@test.com", SmsNumber: "5125551212",
-		SmsActive: false, EmailActive: true}
+	c1 := database.Contact{Name: "Joe Contact",
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "joe@test.com", IsActive: true},
+			{ChannelType: database.SmsChannel, Address: "5125551212"}}}
 	// Create second contact
-	c2 := database.Contact{Name: "Jack Contact", EmailAddress: "jack@test.com", SmsNumber: "5125551213",
-		SmsActive: true, EmailActive: false}
+	c2 := database.Contact{Name: "Jack Contact",
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "jack@test.com"},
+			{ChannelType: database.SmsChannel, Address: "5125551213", IsActive: true}}}
 
 	// Add the contacts to the sites
 	s1.Contacts = append(s1.Contacts, c1, c2)
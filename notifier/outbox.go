@@ -0,0 +1,319 @@
+package notifier
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+const (
+	// outboxInterval is how often the outbox checks for the messages that are
+	// due to be retried.
+	outboxInterval = 15 * time.Second
+	// outboxMaxBackoff is the longest wait between the attempts.
+	outboxMaxBackoff = time.Hour
+	// outboxRetention is how long the sent messages are kept.
+	outboxRetention = 30 * 24 * time.Hour
+	// summaryLength is the most characters of the message in the delivery log.
+	summaryLength = 200
+)
+
+// outboxWake wakes the running outbox to deliver the messages that were just
+// saved, rather than waiting for its next check.
+var outboxWake = make(chan struct{}, 1)
+
+// WakeOutbox has the outbox deliver the messages that were just saved.
+func WakeOutbox() {
+	select {
+	case outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// Outbox delivers the notifications that were saved to the outbox in the
+// background. A message that fails is retried with an exponential backoff
+// until it has failed the MaxAttempts of the config, then it's dead. The
+// messages to each contact's channel are grouped and rate limited by the storm.
+type Outbox struct {
+	DB          *sql.DB
+	Channels    Channels
+	wg          sync.WaitGroup
+	stopChan    chan struct{}
+	lastCleanup time.Time
+	storm       storm
+}
+
+// NewOutbox returns a new Outbox that delivers with the channels.
+func NewOutbox(db *sql.DB, channels Channels) *Outbox {
+	return &Outbox{DB: db, Channels: channels}
+}
+
+// Start begins delivering the messages in the background.
+func (o *Outbox) Start() {
+	log.Println("Starting the notification outbox...")
+	o.stopChan = make(chan struct{})
+	o.wg.Add(1)
+	go o.run()
+}
+
+// Stop stops delivering the messages, waiting for any being delivered.
+func (o *Outbox) Stop() {
+	close(o.stopChan)
+	o.wg.Wait()
+	o.stopChan = nil
+}
+
+func (o *Outbox) run() {
+	defer o.wg.Done()
+	for {
+		o.DeliverDue(time.Now())
+		select {
+		case <-o.stopChan:
+			return
+		case <-outboxWake:
+		case <-time.After(outboxInterval):
+		}
+	}
+}
+
+// DeliverDue delivers the messages that are due by the time and deletes the
+// old sent messages once a day. The messages held back by the grouping or the
+// rate limits stay pending for a later check.
+func (o *Outbox) DeliverDue(now time.Time) {
+	var messages database.OutboxMessages
+	err := messages.GetDueOutboxMessages(o.DB, now)
+	if err != nil {
+		log.Println("Error getting the outbox messages:", err)
+		return
+	}
+	var wg sync.WaitGroup
+	for _, b := range o.storm.batches(messages, now) {
+		wg.Add(1)
+		go func(b stormBatch) {
+			defer wg.Done()
+			if b.combined() {
+				o.deliverCombined(b, now)
+			} else {
+				o.deliver(b.messages[0])
+			}
+		}(b)
+	}
+	wg.Wait()
+
+	if now.Sub(o.lastCleanup) > 24*time.Hour {
+		o.lastCleanup = now
+		err = database.DeleteSentOutboxMessages(o.DB, now.Add(-outboxRetention))
+		if err != nil {
+			log.Println("Error deleting the sent outbox messages:", err)
+		}
+	}
+}
+
+func (o *Outbox) deliver(m *database.OutboxMessage) {
+	log.Println("Sending", m.ChannelType, "notification for", m.Recipient, m.Subject)
+	err := Deliver(*m, o.Channels)
+	o.recordAttempt(m, err)
+	o.settle(m, err)
+}
+
+// deliverCombined sends the messages of the batch as one and settles each of
+// them with the result.
+func (o *Outbox) deliverCombined(b stormBatch, now time.Time) {
+	combined, err := combine(b, now)
+	if err == nil {
+		log.Println("Sending", combined.ChannelType, "notification for", combined.Recipient, combined.Subject)
+		err = Deliver(combined, o.Channels)
+	}
+	for _, m := range b.messages {
+		o.recordAttempt(m, err)
+		o.settle(m, err)
+	}
+}
+
+// settle marks the message as sent, or as failed with the next attempt after
+// the backoff if it has attempts left.
+func (o *Outbox) settle(m *database.OutboxMessage, err error) {
+	if err == nil {
+		err = m.MarkOutboxSent(o.DB, time.Now())
+		if err != nil {
+			log.Println("Error saving the sent outbox message:", err)
+		}
+		return
+	}
+	log.Println("Error delivering outbox message", m.OutboxMessageID, "to", m.Recipient+":", err)
+	var nextAttempt time.Time
+	if m.Attempts+1 < outboxMaxAttempts() {
+		nextAttempt = time.Now().Add(OutboxBackoff(m.Attempts + 1))
+	} else {
+		log.Println("Outbox message", m.OutboxMessageID, "is dead after", m.Attempts+1, "attempts")
+	}
+	err = m.MarkOutboxFailed(o.DB, err.Error(), nextAttempt)
+	if err != nil {
+		log.Println("Error saving the failed outbox message:", err)
+	}
+}
+
+// recordAttempt saves the attempt to deliver the message to the delivery log,
+// with the event and the start of the message from its payload.
+func (o *Outbox) recordAttempt(m *database.OutboxMessage, sendErr error) {
+	n := new(Notifier)
+	if json.Unmarshal([]byte(m.Payload), n) != nil {
+		n = new(Notifier)
+	}
+	RecordDelivery(o.DB, database.DeliveryAttempt{OutboxMessageID: m.OutboxMessageID, SiteID: m.SiteID,
+		Recipient: m.Recipient, ChannelType: m.ChannelType, Address: m.Address, Subject: m.Subject}, n, sendErr)
+}
+
+// summary returns the first line of the message, shortened to fit the log.
+func summary(message string) string {
+	line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(message), "\n", 2)[0])
+	if r := []rune(line); len(r) > summaryLength {
+		return string(r[:summaryLength-3]) + "..."
+	}
+	return line
+}
+
+// OutboxBackoff returns how long to wait after the number of failed attempts,
+// the BackoffSeconds of the config doubled for each attempt after the first.
+func OutboxBackoff(attempts int) time.Duration {
+	backoff := time.Duration(config.Settings.Outbox.BackoffSeconds) * time.Second
+	if backoff <= 0 {
+		backoff = 30 * time.Second
+	}
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > outboxMaxBackoff {
+		backoff = outboxMaxBackoff
+	}
+	return backoff
+}
+
+func outboxMaxAttempts() int {
+	if config.Settings.Outbox.MaxAttempts > 0 {
+		return config.Settings.Outbox.MaxAttempts
+	}
+	return 10
+}
+
+// OutboxMessages returns the messages of the notification for the outbox, one
+// for each endpoint of the contacts, the site's channels and the webhook in the
+// config. The notification is rendered for the channel type of each. A contact
+// that its schedules don't allow to be notified now has its messages held
+// until they do.
+func (n *Notifier) OutboxMessages() (database.OutboxMessages, error) {
+	var messages database.OutboxMessages
+	add := func(channelType string, address string, recipient string, recipientKey string,
+		nextAttempt time.Time) error {
+		m, err := n.outboxMessage(channelType, address, recipient, recipientKey, nextAttempt)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, m)
+		return nil
+	}
+	now := time.Now()
+	for _, c := range n.Site.Contacts {
+		channels, held := n.ContactChannels(c, now)
+		for _, endpoint := range channels {
+			err := add(endpoint.ChannelType, endpoint.Address, c.Name, contactKey(c), time.Time{})
+			if err != nil {
+				return nil, err
+			}
+		}
+		heldMessages, err := n.heldMessages(c, held)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, heldMessages...)
+	}
+	if n.Call {
+		return messages, nil
+	}
+	for _, ch := range n.Site.Channels {
+		if ch.IsActive && ch.Address != "" {
+			err := add(ch.ChannelType, ch.Address, n.Site.Name, siteKey(n.Site), time.Time{})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if url := config.Settings.Webhook.URL; url != "" {
+		err := add(database.WebhookChannel, url, "Webhook", "webhook", time.Time{})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return messages, nil
+}
+
+// heldMessages returns the outbox messages to the channels of the contact that
+// its schedules hold, which are sent at the time each is held until.
+func (n *Notifier) heldMessages(c database.Contact, held map[string]time.Time) (database.OutboxMessages, error) {
+	var messages database.OutboxMessages
+	for _, endpoint := range c.ActiveChannels() {
+		if next, ok := held[endpoint.ChannelType]; ok {
+			log.Println("Holding", endpoint.ChannelType, "notification for", c.Name, "until", next)
+			m, err := n.outboxMessage(endpoint.ChannelType, endpoint.Address, c.Name, contactKey(c), next)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+// outboxMessage returns the outbox message of the notification rendered for
+// the channel type.
+func (n *Notifier) outboxMessage(channelType string, address string, recipient string, recipientKey string,
+	nextAttempt time.Time) (database.OutboxMessage, error) {
+	rendered := n.ForChannel(channelType)
+	payload, err := json.Marshal(rendered)
+	if err != nil {
+		return database.OutboxMessage{}, err
+	}
+	return database.OutboxMessage{SiteID: n.Site.SiteID, ChannelType: channelType, Address: address,
+		Recipient: recipient, RecipientKey: recipientKey, Subject: rendered.Subject, Payload: string(payload),
+		NextAttempt: nextAttempt}, nil
+}
+
+// contactKey returns the key of the contact as the recipient of the messages,
+// the names of the contacts aren't unique.
+func contactKey(c database.Contact) string {
+	return "contact:" + strconv.FormatInt(c.ContactID, 10)
+}
+
+// siteKey returns the key of the site as the recipient of the messages to its
+// own channels.
+func siteKey(s database.Site) string {
+	return "site:" + strconv.FormatInt(s.SiteID, 10)
+}
+
+// Deliver sends the outbox message with the channel registered for its
+// channel type.
+func Deliver(m database.OutboxMessage, channels Channels) error {
+	channel, ok := channels[m.ChannelType]
+	if !ok {
+		return fmt.Errorf("no channel registered for %s", m.ChannelType)
+	}
+	n := new(Notifier)
+	err := json.Unmarshal([]byte(m.Payload), n)
+	if err != nil {
+		return err
+	}
+	n.Channels = channels
+	err = channel.Send(m.Address, n)
+	if err != nil {
+		return fmt.Errorf("error sending %s: %v", channel.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package notifier_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+)
+
+// TestOutboxDelivery tests that the outbox messages of a notification are
+// delivered to each endpoint and marked as sent.
+func TestOutboxDelivery(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+	saved := config.Settings.Webhook.URL
+	defer func() { config.Settings.Webhook.URL = saved }()
+	config.Settings.Webhook.URL = ""
+
+	email := &notifier.ChannelMock{}
+	sms := &notifier.ChannelMock{}
+	channels := notifier.Channels{database.EmailChannel: email, database.SmsChannel: sms}
+	n := notifier.NewNotifier(getTestSite(), "Test at http://www.google.com: Site is down.",
+		"Test: Site is Down", channels)
+	n.Status = "Down"
+	messages, err := n.OutboxMessages()
+	if err != nil {
+		t.Fatal("Failed to get the outbox messages:", err)
+	}
+	if len(messages) != 2 || messages[0].Recipient != "Joe Contact" || messages[1].ChannelType != database.SmsChannel {
+		t.Fatal("Outbox messages not as expected:", messages)
+	}
+	err = database.EnqueueOutboxMessages(db, messages)
+	if err != nil {
+		t.Fatal("Failed to enqueue outbox messages:", err)
+	}
+
+	// The messages are delivered once they have waited for the others of a storm.
+	notifier.NewOutbox(db, channels).DeliverDue(time.Now().Add(10 * time.Second))
+	if len(email.Sent) != 1 || email.Sent[0] != "joe@test.com" || len(sms.Sent) != 1 || sms.Sent[0] != "5125551213" {
+		t.Error("Outbox messages not delivered as expected:", email.Sent, sms.Sent)
+	}
+	var sent database.OutboxMessages
+	err = sent.GetOutboxMessages(db, database.OutboxSent, 10)
+	if err != nil {
+		t.Fatal("Failed to get sent outbox messages:", err)
+	}
+	if len(sent) != 2 {
+		t.Error("The outbox messages should be sent:", sent)
+	}
+
+	var attempts database.DeliveryAttempts
+	err = attempts.GetDeliveryAttempts(db, database.DeliveryFilter{Limit: 10})
+	if err != nil {
+		t.Fatal("Failed to get delivery attempts:", err)
+	}
+	if len(attempts) != 2 || !attempts[0].Success || attempts[0].Event != database.DownEvent ||
+		attempts[0].Summary != "Test at http://www.google.com: Site is down." {
+		t.Error("The deliveries should be logged:", attempts)
+	}
+}
+
+// TestOutboxRetry tests that a failed message waits for the backoff before it's
+// retried, and is dead once it has failed the most attempts.
+func TestOutboxRetry(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+	saved := config.Settings.Outbox
+	defer func() { config.Settings.Outbox = saved }()
+	config.Settings.Outbox.MaxAttempts = 2
+	config.Settings.Outbox.BackoffSeconds = 30
+
+	email := &notifier.ChannelMock{Err: errors.New("no response from server")}
+	channels := notifier.Channels{database.EmailChannel: email}
+	n := notifier.NewNotifier(getTestSite(), "Site is down.", "Test: Site is Down", channels)
+	err = database.EnqueueOutboxMessages(db, database.OutboxMessages{{ChannelType: database.EmailChannel,
+		Address: "joe@test.com", Recipient: "Joe Contact", Subject: n.Subject, Payload: "{}"}})
+	if err != nil {
+		t.Fatal("Failed to enqueue outbox messages:", err)
+	}
+
+	outbox := notifier.NewOutbox(db, channels)
+	outbox.DeliverDue(time.Now().Add(10 * time.Second))
+	var pending database.OutboxMessages
+	err = pending.GetOutboxMessages(db, database.OutboxPending, 10)
+	if err != nil {
+		t.Fatal("Failed to get pending outbox messages:", err)
+	}
+	if len(pending) != 1 || pending[0].Attempts != 1 || pending[0].LastError == "" ||
+		pending[0].NextAttempt.Before(time.Now().Add(25*time.Second)) {
+		t.Fatal("The failed outbox message should wait for the backoff:", pending)
+	}
+
+	outbox.DeliverDue(time.Now().Add(2 * time.Minute))
+	var dead database.OutboxMessages
+	err = dead.GetOutboxMessages(db, database.OutboxDead, 10)
+	if err != nil {
+		t.Fatal("Failed to get dead outbox messages:", err)
+	}
+	if len(dead) != 1 || dead[0].Attempts != 2 {
+		t.Error("The outbox message should be dead after the most attempts:", dead)
+	}
+
+	var failed database.DeliveryAttempts
+	err = failed.GetDeliveryAttempts(db, database.DeliveryFilter{Success: "no", Limit: 10})
+	if err != nil {
+		t.Fatal("Failed to get delivery attempts:", err)
+	}
+	if len(failed) != 2 || !strings.Contains(failed[0].Error, "no response from server") {
+		t.Error("The failed attempts should be logged with the error:", failed)
+	}
+}
+
+// TestOutboxBackoff tests that the backoff doubles with each attempt up to an hour.
+func TestOutboxBackoff(t *testing.T) {
+	saved := config.Settings.Outbox
+	defer func() { config.Settings.Outbox = saved }()
+	config.Settings.Outbox.BackoffSeconds = 30
+
+	tests := map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 3: 2 * time.Minute, 10: time.Hour}
+	for attempts, want := range tests {
+		if got := notifier.OutboxBackoff(attempts); got != want {
+			t.Error("Backoff after", attempts, "attempts should be", want, "got", got)
+		}
+	}
+}
+
+// TestOutboxHeldBySchedule tests that the messages of a contact outside of all
+// of their schedules are held until the next window, unless the site is critical.
+func TestOutboxHeldBySchedule(t *testing.T) {
+	saved := config.Settings.Webhook.URL
+	defer func() { config.Settings.Webhook.URL = saved }()
+	config.Settings.Webhook.URL = ""
+
+	site := getTestSite()
+	// Jack can only be texted two days from now, all day in UTC.
+	later := time.Now().UTC().AddDate(0, 0, 2)
+	site.Contacts[1].Timezone = "UTC"
+	site.Contacts[1].Schedules = []database.ContactSchedule{{ChannelType: database.SmsChannel,
+		Days: 1 << later.Weekday(), StartTime: "00:00", EndTime: "00:00"}}
+	n := notifier.NewNotifier(site, "Site is down.", "Test: Site is Down", notifier.Channels{})
+	messages, err := n.OutboxMessages()
+	if err != nil {
+		t.Fatal("Failed to get the outbox messages:", err)
+	}
+	want := time.Date(later.Year(), later.Month(), later.Day(), 0, 0, 0, 0, time.UTC)
+	if len(messages) != 2 || !messages[0].NextAttempt.IsZero() || messages[1].ChannelType != database.SmsChannel ||
+		!messages[1].NextAttempt.Equal(want) {
+		t.Fatal("The SMS should be held until its window:", messages)
+	}
+
+	n.Site.IsCritical = true
+	messages, err = n.OutboxMessages()
+	if err != nil {
+		t.Fatal("Failed to get the outbox messages:", err)
+	}
+	if len(messages) != 2 || !messages[1].NextAttempt.IsZero() {
+		t.Error("The SMS of a critical site should not be held:", messages)
+	}
+}
+
+// TestOutboxSubscriptions tests that the contacts are only notified of the
+// events they subscribe to for the site, on the channels they chose.
+func TestOutboxSubscriptions(t *testing.T) {
+	saved := config.Settings.Webhook.URL
+	defer func() { config.Settings.Webhook.URL = saved }()
+	config.Settings.Webhook.URL = ""
+
+	site := getTestSite()
+	// Joe only wants to know when the site goes down, and Jack everything but
+	// only by email.
+	site.Contacts[0].Subscription = database.Subscription{Events: []string{database.DownEvent}}
+	site.Contacts[1].SetChannel(database.EmailChannel, "jack@test.com", true)
+	site.Contacts[1].Subscription = database.Subscription{ChannelTypes: []string{database.EmailChannel}}
+
+	tests := []struct {
+		status     string
+		recipients []string
+	}{
+		{"Down", []string{"joe@test.com", "jack@test.com"}},
+		{"Up", []string{"jack@test.com"}},
+		{"Flapping", []string{"jack@test.com"}},
+	}
+	for _, test := range tests {
+		n := notifier.NewNotifier(site, "Site is "+test.status, "Test: Site is "+test.status, notifier.Channels{})
+		n.Status = test.status
+		messages, err := n.OutboxMessages()
+		if err != nil {
+			t.Fatal("Failed to get the outbox messages:", err)
+		}
+		var recipients []string
+		for _, m := range messages {
+			recipients = append(recipients, m.Address)
+		}
+		if strings.Join(recipients, ",") != strings.Join(test.recipients, ",") {
+			t.Error(test.status, "should notify", test.recipients, "got", recipients)
+		}
+	}
+}
+
+// TestNotifyHeldBySchedule tests that a notification sent directly, rather
+// than by way of the outbox, still holds the channels outside of the contact's
+// schedules in the outbox until the next window.
+func TestNotifyHeldBySchedule(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+	saved := config.Settings.Webhook.URL
+	defer func() { config.Settings.Webhook.URL = saved }()
+	config.Settings.Webhook.URL = ""
+
+	site := getTestSite()
+	later := time.Now().UTC().AddDate(0, 0, 2)
+	site.Contacts[1].Timezone = "UTC"
+	site.Contacts[1].Schedules = []database.ContactSchedule{{ChannelType: database.SmsChannel,
+		Days: 1 << later.Weekday(), StartTime: "00:00", EndTime: "00:00"}}
+	email := &notifier.ChannelMock{}
+	sms := &notifier.ChannelMock{}
+	n := notifier.NewNotifier(site, "Site is down.", "Test: Site is Down",
+		notifier.Channels{database.EmailChannel: email, database.SmsChannel: sms})
+	n.Status = "Down"
+	n.DB = db
+	n.Notify()
+	if len(email.Sent) != 1 || len(sms.Sent) != 0 {
+		t.Fatal("Only the email should be sent now:", email.Sent, sms.Sent)
+	}
+
+	var pending database.OutboxMessages
+	err = pending.GetOutboxMessages(db, database.OutboxPending, 10)
+	if err != nil {
+		t.Fatal("Failed to get pending outbox messages:", err)
+	}
+	want := time.Date(later.Year(), later.Month(), later.Day(), 0, 0, 0, 0, time.UTC)
+	if len(pending) != 1 || pending[0].ChannelType != database.SmsChannel || !pending[0].NextAttempt.Equal(want) {
+		t.Error("The SMS should be held in the outbox until its window:", pending)
+	}
+}
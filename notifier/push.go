@@ -0,0 +1,250 @@
+package notifier
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+)
+
+// The default servers of the push notification services if they aren't
+// configured.
+const (
+	defaultTelegramURL = "https://api.telegram.org"
+	defaultMatrixURL   = "https://matrix.org"
+	defaultNtfyURL     = "https://ntfy.sh"
+)
+
+// pushText is the plain text of the notification for the push services that
+// don't format the message.
+func pushText(n *Notifier) string {
+	text := n.Subject + "\n" + n.Message
+	if link := n.SiteLink(); link != "" {
+		text += "\n" + link
+	}
+	return text
+}
+
+// Telegram sends the notifications with the Telegram Bot API, the address of
+// the endpoint is the chat ID that the bot sends to.
+type Telegram struct {
+	Client   *http.Client
+	URL      string
+	BotToken string
+}
+
+// NewTelegram returns a Telegram with the URL and bot token from the config.
+func NewTelegram() *Telegram {
+	t := Telegram{Client: &http.Client{Timeout: chatTimeout}, URL: config.Settings.Push.TelegramURL,
+		BotToken: config.Settings.Push.TelegramBotToken}
+	if t.URL == "" {
+		t.URL = defaultTelegramURL
+	}
+	return &t
+}
+
+// Name implements the Channel interface for Telegram.
+func (t *Telegram) Name() string {
+	return "Telegram"
+}
+
+// Send implements the Channel interface for Telegram. The bot token is part of
+// the URL, so it's taken out of the errors that include it before they're
+// logged and shown in the delivery log.
+func (t *Telegram) Send(address string, n *Notifier) error {
+	if t.BotToken == "" {
+		return errors.New("the Telegram bot token is not configured")
+	}
+	err := postJSON(t.Client, strings.TrimRight(t.URL, "/")+"/bot"+t.BotToken+"/sendMessage",
+		map[string]interface{}{
+			"chat_id":                  address,
+			"text":                     pushText(n),
+			"disable_web_page_preview": true,
+		})
+	if err != nil {
+		return errors.New(strings.Replace(err.Error(), t.BotToken, "<bot token>", -1))
+	}
+	return nil
+}
+
+// Discord sends the notifications to Discord webhooks as embeds, the address
+// of the endpoint is the webhook URL.
+type Discord struct {
+	Client *http.Client
+}
+
+// NewDiscord returns a Discord with the default timeout.
+func NewDiscord() *Discord {
+	return &Discord{Client: &http.Client{Timeout: chatTimeout}}
+}
+
+// Name implements the Channel interface for Discord.
+func (d *Discord) Name() string {
+	return "Discord"
+}
+
+// Send implements the Channel interface for Discord.
+func (d *Discord) Send(address string, n *Notifier) error {
+	color, _ := strconv.ParseInt(strings.TrimPrefix(statusColor(n.Status), "#"), 16, 32)
+	fields := []interface{}{
+		map[string]interface{}{"name": "Site", "value": n.Site.Name + " " + n.Site.URL},
+		map[string]interface{}{"name": "Status", "value": n.Status, "inline": true},
+	}
+	if n.Downtime > 0 {
+		fields = append(fields, map[string]interface{}{"name": "Outage Duration",
+			"value": formatDowntime(n.Downtime), "inline": true})
+	}
+	if n.AcknowledgeURL != "" {
+		fields = append(fields, map[string]interface{}{"name": "Acknowledge",
+			"value": "[Acknowledge the outage](" + n.AcknowledgeURL + ")"})
+	}
+	embed := map[string]interface{}{
+		"title":       n.Subject,
+		"description": n.Detail,
+		"color":       color,
+		"fields":      fields,
+		"timestamp":   n.Time.UTC().Format(time.RFC3339),
+	}
+	if link := n.SiteLink(); link != "" {
+		embed["url"] = link
+	}
+	return postJSON(d.Client, address, map[string]interface{}{
+		"username": "Go Ping Sites",
+		"content":  n.Subject,
+		"embeds":   []interface{}{embed},
+	})
+}
+
+// Matrix sends the notifications as messages to Matrix rooms, the address of
+// the endpoint is the room ID, which the user of the access token has joined.
+type Matrix struct {
+	Client      *http.Client
+	URL         string
+	AccessToken string
+}
+
+// NewMatrix returns a Matrix with the homeserver URL and access token from the
+// config.
+func NewMatrix() *Matrix {
+	m := Matrix{Client: &http.Client{Timeout: chatTimeout}, URL: config.Settings.Push.MatrixURL,
+		AccessToken: config.Settings.Push.MatrixAccessToken}
+	if m.URL == "" {
+		m.URL = defaultMatrixURL
+	}
+	return &m
+}
+
+// Name implements the Channel interface for Matrix.
+func (m *Matrix) Name() string {
+	return "Matrix"
+}
+
+// Send implements the Channel interface for Matrix. The transaction ID only
+// has to be unique for the access token.
+func (m *Matrix) Send(address string, n *Notifier) error {
+	if m.AccessToken == "" {
+		return errors.New("the Matrix access token is not configured")
+	}
+	txnID := "gps" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	sendURL := strings.TrimRight(m.URL, "/") + "/_matrix/client/v3/rooms/" + url.PathEscape(address) +
+		"/send/m.room.message/" + txnID
+	return sendJSON(m.Client, "PUT", sendURL, map[string]interface{}{
+		"msgtype": "m.text",
+		"body":    pushText(n),
+	}, http.Header{"Authorization": {"Bearer " + m.AccessToken}})
+}
+
+// Ntfy publishes the notifications to ntfy topics, the address of the endpoint
+// is the topic that the phones subscribe to.
+type Ntfy struct {
+	Client *http.Client
+	URL    string
+	Token  string
+}
+
+// NewNtfy returns a Ntfy with the server URL and token from the config.
+func NewNtfy() *Ntfy {
+	t := Ntfy{Client: &http.Client{Timeout: chatTimeout}, URL: config.Settings.Push.NtfyURL,
+		Token: config.Settings.Push.NtfyToken}
+	if t.URL == "" {
+		t.URL = defaultNtfyURL
+	}
+	return &t
+}
+
+// Name implements the Channel interface for Ntfy.
+func (t *Ntfy) Name() string {
+	return "ntfy"
+}
+
+// Send implements the Channel interface for Ntfy, an outage is sent with the
+// highest priority.
+func (t *Ntfy) Send(address string, n *Notifier) error {
+	priority, tag := 3, "warning"
+	switch n.Status {
+	case "Down":
+		priority, tag = 5, "rotating_light"
+	case "Up":
+		tag = "white_check_mark"
+	}
+	message := map[string]interface{}{
+		"topic":    address,
+		"title":    n.Subject,
+		"message":  n.Message,
+		"priority": priority,
+		"tags":     []string{tag},
+	}
+	if link := n.SiteLink(); link != "" {
+		message["click"] = link
+	}
+	var header http.Header
+	if t.Token != "" {
+		header = http.Header{"Authorization": {"Bearer " + t.Token}}
+	}
+	return sendJSON(t.Client, "POST", strings.TrimRight(t.URL, "/"), message, header)
+}
+
+// Gotify sends the notifications as messages to a Gotify server, the address
+// of the endpoint is the token of the application the messages are sent as.
+type Gotify struct {
+	Client *http.Client
+	URL    string
+}
+
+// NewGotify returns a Gotify with the server URL from the config.
+func NewGotify() *Gotify {
+	return &Gotify{Client: &http.Client{Timeout: chatTimeout}, URL: config.Settings.Push.GotifyURL}
+}
+
+// Name implements the Channel interface for Gotify.
+func (g *Gotify) Name() string {
+	return "Gotify"
+}
+
+// Send implements the Channel interface for Gotify, an outage is sent with a
+// priority that makes the phone sound.
+func (g *Gotify) Send(address string, n *Notifier) error {
+	if g.URL == "" {
+		return errors.New("the Gotify URL is not configured")
+	}
+	priority := 5
+	if n.Status == "Down" {
+		priority = 8
+	}
+	message := map[string]interface{}{
+		"title":    n.Subject,
+		"message":  n.Message,
+		"priority": priority,
+	}
+	if link := n.SiteLink(); link != "" {
+		message["extras"] = map[string]interface{}{
+			"client::notification": map[string]interface{}{"click": map[string]string{"url": link}},
+		}
+	}
+	return sendJSON(g.Client, "POST", strings.TrimRight(g.URL, "/")+"/message", message,
+		http.Header{"X-Gotify-Key": {address}})
+}
@@ -0,0 +1,111 @@
+package notifier_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+)
+
+// pushRequest is a request received by the stand-in for the push services.
+type pushRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   string
+}
+
+// TestPushChannels tests that each push service is sent the notification at
+// the configured server.
+func TestPushChannels(t *testing.T) {
+	var requests []pushRequest
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		requests = append(requests, pushRequest{Method: req.Method, Path: req.URL.Path,
+			Header: req.Header, Body: string(body)})
+	}))
+	defer server.Close()
+
+	channels := notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock)
+	channels.Register(database.TelegramChannel, &notifier.Telegram{Client: http.DefaultClient,
+		URL: server.URL, BotToken: "123:ABC"})
+	channels.Register(database.MatrixChannel, &notifier.Matrix{Client: http.DefaultClient,
+		URL: server.URL, AccessToken: "syt_token"})
+	channels.Register(database.NtfyChannel, &notifier.Ntfy{Client: http.DefaultClient, URL: server.URL})
+	channels.Register(database.GotifyChannel, &notifier.Gotify{Client: http.DefaultClient, URL: server.URL})
+	site := database.Site{SiteID: 3, Name: "Test", URL: "http://www.example.com"}
+	n := notifier.NewNotifier(site, "Test at http://www.example.com: Site is down.", "Test: Site is Down", channels)
+	n.Status = "Down"
+	n.Detail = "Site is down."
+
+	endpoints := []struct {
+		channelType string
+		address     string
+	}{
+		{database.TelegramChannel, "-1001234"},
+		{database.DiscordChannel, server.URL + "/api/webhooks/1/abc"},
+		{database.MatrixChannel, "!room:example.com"},
+		{database.NtfyChannel, "alerts"},
+		{database.GotifyChannel, "AppToken"},
+	}
+	for _, e := range endpoints {
+		err := n.SendTo(e.channelType, e.address)
+		if err != nil {
+			t.Fatal("Failed to send to", e.channelType, err)
+		}
+	}
+	if len(requests) != len(endpoints) {
+		t.Fatal("Expected a request for each push service, got", len(requests))
+	}
+
+	expected := []struct {
+		method string
+		path   string
+		header string
+		value  string
+		body   string
+	}{
+		{"POST", "/bot123:ABC/sendMessage", "Content-Type", "application/json", `"chat_id":"-1001234"`},
+		{"POST", "/api/webhooks/1/abc", "Content-Type", "application/json", `"title":"Test: Site is Down"`},
+		{"PUT", "/_matrix/client/v3/rooms/!room:example.com/send/m.room.message/", "Authorization", "Bearer syt_token",
+			`"msgtype":"m.text"`},
+		{"POST", "/", "Content-Type", "application/json", `"topic":"alerts"`},
+		{"POST", "/message", "X-Gotify-Key", "AppToken", `"priority":8`},
+	}
+	for i, e := range expected {
+		r := requests[i]
+		if r.Method != e.method || !strings.HasPrefix(r.Path, e.path) || r.Header.Get(e.header) != e.value ||
+			!strings.Contains(r.Body, e.body) || !strings.Contains(r.Body, "http://localhost:8000/settings/sites/3") {
+			t.Errorf("Request to %s not as expected: %s %s %s", endpoints[i].channelType, r.Method, r.Path, r.Body)
+		}
+	}
+}
+
+// TestPushNotConfigured tests that the services that need a token or a server
+// report that they aren't configured.
+func TestPushNotConfigured(t *testing.T) {
+	n := notifier.NewTestNotifier(database.Site{Name: "Test"},
+		notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
+	for _, channelType := range []string{database.TelegramChannel, database.MatrixChannel, database.GotifyChannel} {
+		err := n.SendTo(channelType, "address")
+		if err == nil || !strings.Contains(err.Error(), "is not configured") {
+			t.Error("Expected an error that", channelType, "is not configured, got", err)
+		}
+	}
+}
+
+// TestTelegramErrorHidesToken tests that the bot token in the URL isn't in the
+// error when the request fails.
+func TestTelegramErrorHidesToken(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	telegram := &notifier.Telegram{Client: http.DefaultClient, URL: server.URL, BotToken: "123456:SECRET"}
+	err := telegram.Send("-1001234", notifier.NewTestNotifier(database.Site{Name: "Test"}, notifier.Channels{}))
+	if err == nil || strings.Contains(err.Error(), "SECRET") || !strings.Contains(err.Error(), "/bot<bot token>/sendMessage") {
+		t.Error("The error shouldn't include the bot token:", err)
+	}
+}
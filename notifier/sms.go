@@ -0,0 +1,443 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sfreiberg/gotwilio"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+)
+
+// The SMS providers that can be chosen in the config.
+const (
+	twilioProvider      = "twilio"
+	vonageProvider      = "vonage"
+	snsProvider         = "sns"
+	messageBirdProvider = "messagebird"
+	httpProvider        = "http"
+)
+
+// The default endpoints of the SMS providers if they aren't configured. The
+// SNS endpoint is in the configured region.
+const (
+	defaultVonageURL      = "https://rest.nexmo.com/sms/json"
+	defaultMessageBirdURL = "https://rest.messagebird.com/messages"
+	defaultSnsURL         = "https://sns.%s.amazonaws.com/"
+)
+
+// SmsProvider sends the SMS text messages with the API of a provider.
+type SmsProvider interface {
+	// Name is how the provider is described in the errors.
+	Name() string
+	// SendSms sends the message to the number.
+	SendSms(smsNumber string, message string) error
+}
+
+// NewSmsProvider returns the SMS provider chosen in the config with its
+// settings, which is Twilio if none is chosen.
+func NewSmsProvider() (SmsProvider, error) {
+	switch strings.ToLower(config.Settings.SMS.Provider) {
+	case "", twilioProvider:
+		return NewTwilioSms(), nil
+	case vonageProvider:
+		return NewVonageSms(), nil
+	case snsProvider:
+		return NewSnsSms(), nil
+	case messageBirdProvider:
+		return NewMessageBirdSms(), nil
+	case httpProvider:
+		return NewHTTPSms()
+	}
+	return nil, fmt.Errorf("unknown SMS provider %q, it must be twilio, vonage, sns, messagebird or http",
+		config.Settings.SMS.Provider)
+}
+
+// SendSms provides the implementation of the SmsSender type for runtime usage
+// with the provider chosen in the config.
+func SendSms(smsNumber string, message string) error {
+	provider, err := NewSmsProvider()
+	if err != nil {
+		return err
+	}
+	return provider.SendSms(smsNumber, message)
+}
+
+// doRequest sends the request to the provider and returns the body of the
+// response, or an error with the start of it if it wasn't successful.
+func doRequest(client *http.Client, name string, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if len(body) > 200 {
+			body = body[:200]
+		}
+		return nil, fmt.Errorf("%s returned %s: %s", name, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// TwilioSms sends the messages with the Twilio Programmable Messaging API, the
+// URL is the base of the API such as https://api.twilio.com/2010-04-01.
+type TwilioSms struct {
+	Client     *http.Client
+	URL        string
+	AccountSid string
+	AuthToken  string
+	Number     string
+}
+
+// NewTwilioSms returns a TwilioSms with the account and number from the config.
+func NewTwilioSms() *TwilioSms {
+	return &TwilioSms{Client: &http.Client{Timeout: chatTimeout}, URL: config.Settings.Twilio.URL,
+		AccountSid: config.Settings.Twilio.AccountSid, AuthToken: config.Settings.Twilio.AuthToken,
+		Number: config.Settings.Twilio.Number}
+}
+
+// Name implements the SmsProvider interface for Twilio.
+func (t *TwilioSms) Name() string {
+	return "Twilio"
+}
+
+// SendSms implements the SmsProvider interface for Twilio.
+func (t *TwilioSms) SendSms(smsNumber string, message string) error {
+	twilio := gotwilio.NewTwilioClientCustomHTTP(t.AccountSid, t.AuthToken, t.Client)
+	if t.URL != "" {
+		twilio.BaseUrl = strings.TrimRight(t.URL, "/")
+	}
+	_, exc, err := twilio.SendSMS(t.Number, smsNumber, message, "", "")
+	if err != nil {
+		return err
+	} else if exc != nil {
+		return errors.New(exc.Message)
+	}
+	return nil
+}
+
+// VonageSms sends the messages with the Vonage (Nexmo) SMS API.
+type VonageSms struct {
+	Client    *http.Client
+	URL       string
+	APIKey    string
+	APISecret string
+	From      string
+}
+
+// NewVonageSms returns a VonageSms with the key and sender from the config.
+func NewVonageSms() *VonageSms {
+	v := VonageSms{Client: &http.Client{Timeout: chatTimeout}, URL: config.Settings.Vonage.URL,
+		APIKey: config.Settings.Vonage.APIKey, APISecret: config.Settings.Vonage.APISecret,
+		From: config.Settings.Vonage.From}
+	if v.URL == "" {
+		v.URL = defaultVonageURL
+	}
+	return &v
+}
+
+// Name implements the SmsProvider interface for Vonage.
+func (v *VonageSms) Name() string {
+	return "Vonage"
+}
+
+// SendSms implements the SmsProvider interface for Vonage. The numbers are
+// sent without the leading + and a message that is rejected is returned with
+// a successful status, so the status of each part is checked.
+func (v *VonageSms) SendSms(smsNumber string, message string) error {
+	if v.APIKey == "" {
+		return errors.New("the Vonage API key is not configured")
+	}
+	form := url.Values{
+		"api_key":    {v.APIKey},
+		"api_secret": {v.APISecret},
+		"from":       {strings.TrimPrefix(v.From, "+")},
+		"to":         {strings.TrimPrefix(smsNumber, "+")},
+		"text":       {message},
+	}
+	req, err := http.NewRequest("POST", v.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	body, err := doRequest(v.Client, v.Name(), req)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Messages []struct {
+			Status    string `json:"status"`
+			ErrorText string `json:"error-text"`
+		} `json:"messages"`
+	}
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return fmt.Errorf("Vonage returned an invalid response: %v", err)
+	}
+	for _, m := range result.Messages {
+		if m.Status != "0" {
+			return fmt.Errorf("Vonage rejected the message with status %s: %s", m.Status, m.ErrorText)
+		}
+	}
+	return nil
+}
+
+// SnsSms sends the messages with the Publish action of AWS SNS, signing the
+// requests with AWS Signature Version 4.
+type SnsSms struct {
+	Client          *http.Client
+	URL             string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SenderID        string
+	// Now is the time the requests are signed at, which is the current time if
+	// it's nil.
+	Now func() time.Time
+}
+
+// NewSnsSms returns a SnsSms with the region and credentials from the config.
+func NewSnsSms() *SnsSms {
+	s := SnsSms{Client: &http.Client{Timeout: chatTimeout}, URL: config.Settings.SNS.URL,
+		Region: config.Settings.SNS.Region, AccessKeyID: config.Settings.SNS.AccessKeyID,
+		SecretAccessKey: config.Settings.SNS.SecretAccessKey, SenderID: config.Settings.SNS.SenderID}
+	if s.URL == "" && s.Region != "" {
+		s.URL = fmt.Sprintf(defaultSnsURL, s.Region)
+	}
+	return &s
+}
+
+// Name implements the SmsProvider interface for SNS.
+func (s *SnsSms) Name() string {
+	return "AWS SNS"
+}
+
+// SendSms implements the SmsProvider interface for SNS. The messages are sent
+// as transactional so they are delivered with the highest reliability.
+func (s *SnsSms) SendSms(smsNumber string, message string) error {
+	if s.Region == "" || s.AccessKeyID == "" {
+		return errors.New("the AWS SNS region and access key are not configured")
+	}
+	form := url.Values{
+		"Action":      {"Publish"},
+		"Version":     {"2010-03-31"},
+		"PhoneNumber": {smsNumber},
+		"Message":     {message},
+	}
+	attribute := func(n int, name string, value string) {
+		entry := fmt.Sprintf("MessageAttributes.entry.%d.", n)
+		form.Set(entry+"Name", name)
+		form.Set(entry+"Value.DataType", "String")
+		form.Set(entry+"Value.StringValue", value)
+	}
+	attribute(1, "AWS.SNS.SMS.SMSType", "Transactional")
+	if s.SenderID != "" {
+		attribute(2, "AWS.SNS.SMS.SenderID", s.SenderID)
+	}
+	body := form.Encode()
+	req, err := http.NewRequest("POST", s.URL, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	s.sign(req, body, now().UTC())
+	_, err = doRequest(s.Client, s.Name(), req)
+	return err
+}
+
+// sign adds the AWS Signature Version 4 of the request to its headers.
+func (s *SnsSms) sign(req *http.Request, body string, t time.Time) {
+	amzDate := t.Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	signedHeaders := "content-type;host;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		"content-type:" + req.Header.Get("Content-Type"),
+		"host:" + req.URL.Host,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+	scope := day + "/" + s.Region + "/sns/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex(canonical)
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), day)
+	for _, part := range []string{s.Region, "sns", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+hex.EncodeToString(hmacSHA256(key, stringToSign)))
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
+
+// MessageBirdSms sends the messages with the MessageBird SMS API.
+type MessageBirdSms struct {
+	Client     *http.Client
+	URL        string
+	AccessKey  string
+	Originator string
+}
+
+// NewMessageBirdSms returns a MessageBirdSms with the access key and originator
+// from the config.
+func NewMessageBirdSms() *MessageBirdSms {
+	m := MessageBirdSms{Client: &http.Client{Timeout: chatTimeout}, URL: config.Settings.MessageBird.URL,
+		AccessKey: config.Settings.MessageBird.AccessKey, Originator: config.Settings.MessageBird.Originator}
+	if m.URL == "" {
+		m.URL = defaultMessageBirdURL
+	}
+	return &m
+}
+
+// Name implements the SmsProvider interface for MessageBird.
+func (m *MessageBirdSms) Name() string {
+	return "MessageBird"
+}
+
+// SendSms implements the SmsProvider interface for MessageBird.
+func (m *MessageBirdSms) SendSms(smsNumber string, message string) error {
+	if m.AccessKey == "" {
+		return errors.New("the MessageBird access key is not configured")
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"originator": m.Originator,
+		"recipients": []string{strings.TrimPrefix(smsNumber, "+")},
+		"body":       message,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", m.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "AccessKey "+m.AccessKey)
+	_, err = doRequest(m.Client, m.Name(), req)
+	return err
+}
+
+// HTTPSms sends the messages to any HTTP API of a provider or gateway. The URL
+// and the Body are text/templates of the number in .To and the message in
+// .Message, with json and urlquery functions to escape them, and each of the
+// Headers is a "Name: value" line.
+type HTTPSms struct {
+	Client      *http.Client
+	Method      string
+	URL         *template.Template
+	ContentType string
+	Body        *template.Template
+	Headers     []string
+}
+
+// smsTemplateFuncs are the functions of the templates of the HTTPSms.
+var smsTemplateFuncs = template.FuncMap{
+	"json": func(s string) (string, error) {
+		b, err := json.Marshal(s)
+		return string(b), err
+	},
+}
+
+// NewHTTPSms returns an HTTPSms with the request from the config, which is a
+// POST of JSON by default.
+func NewHTTPSms() (*HTTPSms, error) {
+	settings := config.Settings.SMSHTTP
+	if settings.URL == "" {
+		return nil, errors.New("the URL of the HTTP SMS provider is not configured")
+	}
+	h := HTTPSms{Client: &http.Client{Timeout: chatTimeout}, Method: strings.ToUpper(settings.Method),
+		ContentType: settings.ContentType, Headers: settings.Headers}
+	if h.Method == "" {
+		h.Method = "POST"
+	}
+	if h.ContentType == "" {
+		h.ContentType = "application/json"
+	}
+	var err error
+	h.URL, err = template.New("url").Funcs(smsTemplateFuncs).Parse(settings.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTTP SMS URL template: %v", err)
+	}
+	h.Body, err = template.New("body").Funcs(smsTemplateFuncs).Parse(settings.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTTP SMS body template: %v", err)
+	}
+	return &h, nil
+}
+
+// Name implements the SmsProvider interface for the HTTPSms.
+func (h *HTTPSms) Name() string {
+	return "HTTP SMS"
+}
+
+// SendSms implements the SmsProvider interface for the HTTPSms.
+func (h *HTTPSms) SendSms(smsNumber string, message string) error {
+	data := struct {
+		To      string
+		Message string
+	}{To: smsNumber, Message: message}
+	var sendURL, body bytes.Buffer
+	err := h.URL.Execute(&sendURL, data)
+	if err != nil {
+		return err
+	}
+	if h.Body != nil {
+		err = h.Body.Execute(&body, data)
+		if err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(h.Method, sendURL.String(), &body)
+	if err != nil {
+		return err
+	}
+	if body.Len() > 0 {
+		req.Header.Set("Content-Type", h.ContentType)
+	}
+	for _, header := range h.Headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return fmt.Errorf("invalid HTTP SMS header %q, it must be \"Name: value\"", header)
+		}
+		req.Header.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	_, err = doRequest(h.Client, h.Name(), req)
+	return err
+}
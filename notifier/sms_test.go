@@ -0,0 +1,169 @@
+package notifier_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+)
+
+// smsStandIn returns a stand-in for the SMS providers that records the
+// requests and replies with the status and body.
+func smsStandIn(status int, reply string, requests *[]pushRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		*requests = append(*requests, pushRequest{Method: req.Method, Path: req.URL.RequestURI(),
+			Header: req.Header, Body: string(body)})
+		rw.WriteHeader(status)
+		io.WriteString(rw, reply)
+	}))
+}
+
+// TestSmsProviders tests that each provider sends the message to the number
+// at the configured URL.
+func TestSmsProviders(t *testing.T) {
+	var requests []pushRequest
+	server := smsStandIn(http.StatusCreated, `{"messages": [{"status": "0"}]}`, &requests)
+	defer server.Close()
+
+	bodyTemplate := template.Must(template.New("body").Funcs(template.FuncMap{
+		"json": func(s string) string { b, _ := json.Marshal(s); return string(b) },
+	}).Parse(`{"to": {{json .To}}, "text": {{json .Message}}}`))
+	providers := []notifier.SmsProvider{
+		&notifier.TwilioSms{Client: http.DefaultClient, URL: server.URL + "/2010-04-01",
+			AccountSid: "AC123", AuthToken: "token", Number: "+15125551212"},
+		&notifier.VonageSms{Client: http.DefaultClient, URL: server.URL + "/sms/json",
+			APIKey: "key", APISecret: "secret", From: "GoPingSites"},
+		&notifier.SnsSms{Client: http.DefaultClient, URL: server.URL + "/", Region: "eu-west-1",
+			AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret",
+			Now: func() time.Time { return time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC) }},
+		&notifier.MessageBirdSms{Client: http.DefaultClient, URL: server.URL + "/messages",
+			AccessKey: "live_key", Originator: "GoPingSites"},
+		&notifier.HTTPSms{Client: http.DefaultClient, Method: "PUT",
+			URL:         template.Must(template.New("url").Parse(server.URL + "/send?to={{urlquery .To}}")),
+			ContentType: "application/json", Body: bodyTemplate, Headers: []string{"Authorization: Bearer abc"}},
+	}
+	message := `Test at http://www.example.com: Site is "down".`
+	for _, p := range providers {
+		err := p.SendSms("+15125550000", message)
+		if err != nil {
+			t.Fatal("Failed to send with", p.Name(), err)
+		}
+	}
+	if len(requests) != len(providers) {
+		t.Fatal("Each provider should send one request:", len(requests))
+	}
+
+	twilio := requests[0]
+	form, _ := url.ParseQuery(twilio.Body)
+	if twilio.Path != "/2010-04-01/Accounts/AC123/Messages.json" || form.Get("To") != "+15125550000" ||
+		form.Get("From") != "+15125551212" || form.Get("Body") != message {
+		t.Error("Twilio request not as expected:", twilio.Path, twilio.Body)
+	}
+
+	vonage := requests[1]
+	form, _ = url.ParseQuery(vonage.Body)
+	if vonage.Path != "/sms/json" || form.Get("api_key") != "key" || form.Get("to") != "15125550000" ||
+		form.Get("from") != "GoPingSites" || form.Get("text") != message {
+		t.Error("Vonage request not as expected:", vonage.Path, vonage.Body)
+	}
+
+	sns := requests[2]
+	form, _ = url.ParseQuery(sns.Body)
+	if form.Get("Action") != "Publish" || form.Get("PhoneNumber") != "+15125550000" ||
+		form.Get("Message") != message || form.Get("MessageAttributes.entry.1.Value.StringValue") != "Transactional" {
+		t.Error("SNS request not as expected:", sns.Body)
+	}
+	auth := sns.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20261019/eu-west-1/sns/aws4_request, "+
+		"SignedHeaders=content-type;host;x-amz-date, Signature=") || sns.Header.Get("X-Amz-Date") != "20261019T120000Z" {
+		t.Error("SNS request not signed as expected:", auth, sns.Header.Get("X-Amz-Date"))
+	}
+
+	messageBird := requests[3]
+	var payload map[string]interface{}
+	json.Unmarshal([]byte(messageBird.Body), &payload)
+	if messageBird.Header.Get("Authorization") != "AccessKey live_key" || payload["body"] != message ||
+		payload["originator"] != "GoPingSites" {
+		t.Error("MessageBird request not as expected:", messageBird.Header, messageBird.Body)
+	}
+
+	custom := requests[4]
+	payload = nil
+	json.Unmarshal([]byte(custom.Body), &payload)
+	if custom.Method != "PUT" || custom.Path != "/send?to=%2B15125550000" ||
+		custom.Header.Get("Authorization") != "Bearer abc" || payload["text"] != message {
+		t.Error("HTTP SMS request not as expected:", custom.Method, custom.Path, custom.Header, custom.Body)
+	}
+}
+
+// TestSmsProviderErrors tests that the errors of the providers are returned,
+// including the messages Vonage rejects with a successful status.
+func TestSmsProviderErrors(t *testing.T) {
+	var requests []pushRequest
+	rejected := smsStandIn(http.StatusOK, `{"messages": [{"status": "4", "error-text": "Bad Credentials"}]}`, &requests)
+	defer rejected.Close()
+	failed := smsStandIn(http.StatusUnauthorized, `{"errors": [{"description": "incorrect access_key"}]}`, &requests)
+	defer failed.Close()
+
+	v := &notifier.VonageSms{Client: http.DefaultClient, URL: rejected.URL, APIKey: "key"}
+	err := v.SendSms("+15125550000", "Test")
+	if err == nil || !strings.Contains(err.Error(), "status 4: Bad Credentials") {
+		t.Error("Vonage should return the rejection:", err)
+	}
+
+	m := &notifier.MessageBirdSms{Client: http.DefaultClient, URL: failed.URL, AccessKey: "bad"}
+	err = m.SendSms("+15125550000", "Test")
+	if err == nil || !strings.Contains(err.Error(), "MessageBird returned 401 Unauthorized") ||
+		!strings.Contains(err.Error(), "incorrect access_key") {
+		t.Error("MessageBird should return the error response:", err)
+	}
+
+	err = (&notifier.SnsSms{Client: http.DefaultClient}).SendSms("+15125550000", "Test")
+	if err == nil || !strings.Contains(err.Error(), "not configured") {
+		t.Error("SNS without credentials should return an error:", err)
+	}
+}
+
+// TestNewSmsProvider tests that the provider is chosen from the config.
+func TestNewSmsProvider(t *testing.T) {
+	saved := config.Settings
+	defer func() { config.Settings = saved }()
+
+	provider, err := notifier.NewSmsProvider()
+	if err != nil || provider.Name() != "Twilio" {
+		t.Error("Twilio should be the default provider:", provider, err)
+	}
+
+	config.Settings.SMS.Provider = "MessageBird"
+	provider, err = notifier.NewSmsProvider()
+	if err != nil || provider.Name() != "MessageBird" {
+		t.Error("The provider should be chosen regardless of case:", provider, err)
+	}
+
+	config.Settings.SMS.Provider = "http"
+	_, err = notifier.NewSmsProvider()
+	if err == nil || !strings.Contains(err.Error(), "URL of the HTTP SMS provider is not configured") {
+		t.Error("The HTTP provider should need a URL:", err)
+	}
+	config.Settings.SMSHTTP.URL = "http://localhost/send?to={{urlquery .To}}"
+	config.Settings.SMSHTTP.Body = "{{json .Message"
+	_, err = notifier.NewSmsProvider()
+	if err == nil || !strings.Contains(err.Error(), "invalid HTTP SMS body template") {
+		t.Error("An invalid body template should be an error:", err)
+	}
+
+	config.Settings.SMS.Provider = "carrier-pigeon"
+	_, err = notifier.NewSmsProvider()
+	if err == nil || !strings.Contains(err.Error(), `unknown SMS provider "carrier-pigeon"`) {
+		t.Error("An unknown provider should be an error:", err)
+	}
+}
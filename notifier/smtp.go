@@ -0,0 +1,211 @@
+package notifier
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+)
+
+// The TLS modes of the SMTP connection. StartTLS upgrades the connection when
+// the server offers it, which is the default.
+const (
+	SMTPStartTLS         = "starttls"
+	SMTPStartTLSRequired = "starttls-required"
+	SMTPImplicitTLS      = "tls"
+	SMTPNoTLS            = "none"
+)
+
+// The SMTP auth mechanisms, PLAIN is the default.
+const (
+	SMTPAuthPlain   = "plain"
+	SMTPAuthLogin   = "login"
+	SMTPAuthCRAMMD5 = "cram-md5"
+	SMTPAuthNone    = "none"
+)
+
+// SendEmail provides the implementation of the EmailSender type for runtime usage.
+// The connection is secured and authenticated as set in the SMTP config.
+func SendEmail(recipient string, email Email) error {
+	settings := config.Settings.SMTP
+	auth, err := smtpAuth()
+	if err != nil {
+		return err
+	}
+	from := settings.EmailAddress
+	header := from
+	if settings.FromName != "" {
+		header = (&mail.Address{Name: settings.FromName, Address: from}).String()
+	}
+	msg, err := email.Format(header, recipient)
+	if err != nil {
+		return err
+	}
+
+	c, err := dialSMTP()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if auth != nil {
+		// Relays that don't offer AUTH are sent to without it, as smtp.SendMail does.
+		if ok, _ := c.Extension("AUTH"); ok {
+			err = c.Auth(auth)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err = c.Mail(from)
+	if err != nil {
+		return err
+	}
+	err = c.Rcpt(recipient)
+	if err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// dialSMTP connects to the SMTP server with the TLS mode of the config.
+func dialSMTP() (*smtp.Client, error) {
+	settings := config.Settings.SMTP
+	mode := strings.ToLower(settings.TLSMode)
+	if mode == "" {
+		mode = SMTPStartTLS
+	}
+	tlsConfig, err := smtpTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	addr := net.JoinHostPort(settings.Server, settings.Port)
+
+	var c *smtp.Client
+	switch mode {
+	case SMTPImplicitTLS:
+		conn, err := tls.Dial("tcp", addr, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		c, err = smtp.NewClient(conn, settings.Server)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return c, nil
+	case SMTPStartTLS, SMTPStartTLSRequired, SMTPNoTLS:
+		c, err = smtp.Dial(addr)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown SMTP TLSMode %q", settings.TLSMode)
+	}
+	if mode == SMTPNoTLS {
+		return c, nil
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(tlsConfig)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+	} else if mode == SMTPStartTLSRequired {
+		c.Close()
+		return nil, errors.New("the SMTP server doesn't offer STARTTLS")
+	}
+	return c, nil
+}
+
+// smtpTLSConfig returns the TLS config for the server, trusting the CA in the
+// CAFile of the config if it's set.
+func smtpTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{ServerName: config.Settings.SMTP.Server}
+	if caFile := config.Settings.SMTP.CAFile; caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in the SMTP CAFile %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// smtpAuth returns the auth of the mechanism in the config, or nil for none.
+// If the UserName is not set up then the EmailAddress is used as the user.
+func smtpAuth() (smtp.Auth, error) {
+	settings := config.Settings.SMTP
+	user := settings.EmailAddress
+	if settings.UserName != "" {
+		user = settings.UserName
+	}
+	switch strings.ToLower(settings.Auth) {
+	case "", SMTPAuthPlain:
+		return smtp.PlainAuth("", user, settings.Password, settings.Server), nil
+	case SMTPAuthLogin:
+		return &loginAuth{user: user, password: settings.Password, host: settings.Server}, nil
+	case SMTPAuthCRAMMD5:
+		return smtp.CRAMMD5Auth(user, settings.Password), nil
+	case SMTPAuthNone:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown SMTP Auth %q", settings.Auth)
+}
+
+// loginAuth implements the LOGIN auth mechanism, which like PLAIN only sends
+// the password over TLS or to localhost.
+type loginAuth struct {
+	user     string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.user), nil
+	case "password:":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
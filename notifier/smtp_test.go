@@ -0,0 +1,292 @@
+package notifier_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+)
+
+// smtpStandIn is a local SMTP server for one session that offers STARTTLS and
+// the AUTH mechanisms it's set up with, and records how the email was sent.
+type smtpStandIn struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	implicit  bool
+	startTLS  bool
+	auth      string
+	password  string
+
+	mu        sync.Mutex
+	done      chan struct{}
+	isTLS     bool
+	mechanism string
+	user      string
+	data      string
+}
+
+func newSMTPStandIn(t *testing.T, cert tls.Certificate, implicit bool, startTLS bool, auth string) *smtpStandIn {
+	s := &smtpStandIn{tlsConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+		implicit: implicit, startTLS: startTLS, auth: auth, password: "secret",
+		done: make(chan struct{})}
+	var err error
+	if implicit {
+		s.listener, err = tls.Listen("tcp", "127.0.0.1:0", s.tlsConfig)
+	} else {
+		s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		t.Fatal("Failed to listen:", err)
+	}
+	go func() {
+		defer close(s.done)
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.serve(conn)
+	}()
+	return s
+}
+
+func (s *smtpStandIn) port() string {
+	return fmt.Sprint(s.listener.Addr().(*net.TCPAddr).Port)
+}
+
+// wait closes the listener and waits for the session to finish.
+func (s *smtpStandIn) wait() {
+	s.listener.Close()
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+	}
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	tp := textproto.NewConn(conn)
+	isTLS := s.implicit
+	tp.PrintfLine("220 localhost ESMTP stand-in")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "EHLO", "HELO":
+			lines := []string{"localhost"}
+			if s.startTLS && !isTLS {
+				lines = append(lines, "STARTTLS")
+			}
+			if s.auth != "" {
+				lines = append(lines, "AUTH "+s.auth)
+			}
+			for i, l := range lines {
+				sep := "-"
+				if i == len(lines)-1 {
+					sep = " "
+				}
+				tp.PrintfLine("250%s%s", sep, l)
+			}
+		case "STARTTLS":
+			tp.PrintfLine("220 Ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn = tlsConn
+			tp = textproto.NewConn(conn)
+			isTLS = true
+		case "AUTH":
+			user, ok := s.authenticate(tp, fields)
+			if !ok {
+				tp.PrintfLine("535 Authentication failed")
+				continue
+			}
+			s.mu.Lock()
+			s.mechanism, s.user = strings.ToUpper(fields[1]), user
+			s.mu.Unlock()
+			tp.PrintfLine("235 Authentication successful")
+		case "MAIL", "RCPT", "RSET", "NOOP":
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 Go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data, s.isTLS = string(data), isTLS
+			s.mu.Unlock()
+			tp.PrintfLine("250 Queued")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+// authenticate runs the exchange of the AUTH mechanism and returns the user.
+func (s *smtpStandIn) authenticate(tp *textproto.Conn, fields []string) (string, bool) {
+	if len(fields) < 2 {
+		return "", false
+	}
+	read := func(challenge string) string {
+		tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(challenge)))
+		line, _ := tp.ReadLine()
+		b, _ := base64.StdEncoding.DecodeString(line)
+		return string(b)
+	}
+	switch strings.ToUpper(fields[1]) {
+	case "PLAIN":
+		var response string
+		if len(fields) > 2 {
+			b, _ := base64.StdEncoding.DecodeString(fields[2])
+			response = string(b)
+		} else {
+			response = read("")
+		}
+		parts := strings.Split(response, "\x00")
+		return parts[1], len(parts) == 3 && parts[2] == s.password
+	case "LOGIN":
+		user := read("Username:")
+		return user, read("Password:") == s.password
+	case "CRAM-MD5":
+		challenge := "<1234.5678@localhost>"
+		parts := strings.Fields(read(challenge))
+		d := hmac.New(md5.New, []byte(s.password))
+		d.Write([]byte(challenge))
+		return parts[0], len(parts) == 2 && parts[1] == fmt.Sprintf("%x", d.Sum(nil))
+	}
+	return "", false
+}
+
+// newTestCertificate returns a self-signed certificate for 127.0.0.1 and the
+// file of it to trust as the CA.
+func newTestCertificate(t *testing.T) (tls.Certificate, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate key:", err)
+	}
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "SMTP stand-in"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal("Failed to create certificate:", err)
+	}
+	f, err := ioutil.TempFile("", "smtp-ca")
+	if err != nil {
+		t.Fatal("Failed to create CA file:", err)
+	}
+	pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	f.Close()
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, f.Name()
+}
+
+// TestSendEmail tests sending with each of the TLS modes and auth mechanisms.
+func TestSendEmail(t *testing.T) {
+	cert, caFile := newTestCertificate(t)
+	defer os.Remove(caFile)
+	saved := config.Settings.SMTP
+	defer func() { config.Settings.SMTP = saved }()
+
+	tests := []struct {
+		name      string
+		implicit  bool
+		startTLS  bool
+		offered   string
+		tlsMode   string
+		auth      string
+		caFile    string
+		wantTLS   bool
+		mechanism string
+		wantErr   string
+	}{
+		{name: "STARTTLS when offered", startTLS: true, offered: "PLAIN LOGIN", tlsMode: "starttls",
+			caFile: caFile, wantTLS: true, mechanism: "PLAIN"},
+		{name: "default without STARTTLS", offered: "PLAIN", caFile: caFile, mechanism: "PLAIN"},
+		{name: "STARTTLS required", offered: "PLAIN", tlsMode: "starttls-required", caFile: caFile,
+			wantErr: "doesn't offer STARTTLS"},
+		{name: "implicit TLS with LOGIN", implicit: true, offered: "LOGIN", tlsMode: "tls", auth: "login",
+			caFile: caFile, wantTLS: true, mechanism: "LOGIN"},
+		{name: "no TLS with CRAM-MD5", startTLS: true, offered: "CRAM-MD5", tlsMode: "none", auth: "cram-md5",
+			wantTLS: false, mechanism: "CRAM-MD5"},
+		{name: "relay without auth", startTLS: true, tlsMode: "starttls", auth: "none", caFile: caFile,
+			wantTLS: true},
+		{name: "untrusted certificate", implicit: true, offered: "PLAIN", tlsMode: "tls",
+			wantErr: "certificate"},
+		{name: "unknown TLS mode", tlsMode: "ssl", wantErr: "unknown SMTP TLSMode"},
+		{name: "unknown auth", auth: "ntlm", wantErr: "unknown SMTP Auth"},
+	}
+	for _, test := range tests {
+		s := newSMTPStandIn(t, cert, test.implicit, test.startTLS, test.offered)
+		config.Settings.SMTP.EmailAddress = "alerts@test.com"
+		config.Settings.SMTP.FromName = "Go Ping Sites"
+		config.Settings.SMTP.UserName = "alerts"
+		config.Settings.SMTP.Password = "secret"
+		config.Settings.SMTP.Server = "127.0.0.1"
+		config.Settings.SMTP.Port = s.port()
+		config.Settings.SMTP.TLSMode = test.tlsMode
+		config.Settings.SMTP.Auth = test.auth
+		config.Settings.SMTP.CAFile = test.caFile
+
+		err := notifier.SendEmail("joe@test.com", notifier.Email{Subject: "Test: Site is Down",
+			Text: "Site is down.", HTML: "<p>Site is down.</p>", Date: time.Now(), MessageID: "<1@test.com>"})
+		s.wait()
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Error(test.name+": expected error", test.wantErr, "got", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(test.name+": failed to send:", err)
+			continue
+		}
+		s.mu.Lock()
+		if s.isTLS != test.wantTLS || s.mechanism != test.mechanism ||
+			(test.mechanism != "" && s.user != "alerts") {
+			t.Error(test.name+": not sent as expected:", s.isTLS, s.mechanism, s.user)
+		}
+		// The lines of the data are read without the CRLF.
+		if !strings.Contains(s.data, "From: \"Go Ping Sites\" <alerts@test.com>\n") ||
+			!strings.Contains(s.data, "To: joe@test.com\n") {
+			t.Error(test.name+": headers not as expected:", s.data)
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,224 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+const (
+	// defaultGatherSeconds is how long the first message to a contact's channel
+	// waits for the others of a storm if it isn't set in the config.
+	defaultGatherSeconds = 10
+	// defaultGroupSeconds is how long a contact's channel waits after a message
+	// before the next if it isn't set in the config.
+	defaultGroupSeconds = 60
+	// defaultContactPerHour is the most messages sent to a contact in an hour
+	// if it isn't set in the config.
+	defaultContactPerHour = 20
+	// defaultGlobalPerMinute is the most messages sent to all of the contacts in
+	// a minute if it isn't set in the config.
+	defaultGlobalPerMinute = 60
+	// stormListLength is the most notifications listed in a combined message.
+	stormListLength = 20
+)
+
+// ungroupedChannels are the channel types that get a message per site, since
+// the incidents, the webhook payloads and the calls are each about one site.
+var ungroupedChannels = map[string]bool{
+	database.WebhookChannel:   true,
+	database.PagerDutyChannel: true,
+	database.OpsgenieChannel:  true,
+	database.VoiceChannel:     true,
+}
+
+// storm coordinates the messages of the outbox so that an alert storm, such as
+// many sites going down in a network blip, doesn't flood the contacts. A
+// message to a channel of a contact waits GatherSeconds for the others of the
+// storm, and the channel is sent at most one message per GroupSeconds, the ones
+// due in between are combined into one message. The contacts and all of the
+// messages have rate limits, the messages held by them are sent as one overflow
+// summary once the limit allows. The contacts are told apart by the recipient
+// key of the messages rather than their names, which aren't unique.
+type storm struct {
+	lastSent     map[string]time.Time
+	contactSends map[string][]time.Time
+	globalSends  []time.Time
+	limited      map[string]bool
+}
+
+// stormBatch is the messages to an endpoint that are delivered together.
+type stormBatch struct {
+	messages []*database.OutboxMessage
+	overflow bool
+}
+
+// combined returns true if the batch is delivered as one combined message
+// rather than as its only message.
+func (b stormBatch) combined() bool {
+	return len(b.messages) > 1 || b.overflow
+}
+
+// batches returns the due messages to deliver now. The messages to the
+// channels that were sent to within GroupSeconds, or that are over a rate
+// limit, are left out and stay pending.
+func (s *storm) batches(messages database.OutboxMessages, now time.Time) []stormBatch {
+	if s.lastSent == nil {
+		s.lastSent = make(map[string]time.Time)
+		s.contactSends = make(map[string][]time.Time)
+		s.limited = make(map[string]bool)
+	}
+	var batches []stormBatch
+	var endpoints []string
+	byEndpoint := make(map[string][]*database.OutboxMessage)
+	for i := range messages {
+		m := &messages[i]
+		if ungroupedChannels[m.ChannelType] {
+			batches = append(batches, stormBatch{messages: []*database.OutboxMessage{m}})
+			continue
+		}
+		key := recipientKey(*m) + " " + m.ChannelType + " " + m.Address
+		if _, ok := byEndpoint[key]; !ok {
+			endpoints = append(endpoints, key)
+		}
+		byEndpoint[key] = append(byEndpoint[key], m)
+	}
+
+	gather := time.Duration(stormSetting(config.Settings.Storm.GatherSeconds, defaultGatherSeconds)) * time.Second
+	window := time.Duration(stormSetting(config.Settings.Storm.GroupSeconds, defaultGroupSeconds)) * time.Second
+	contactLimit := stormSetting(config.Settings.Storm.ContactPerHour, defaultContactPerHour)
+	globalLimit := stormSetting(config.Settings.Storm.GlobalPerMinute, defaultGlobalPerMinute)
+	s.globalSends = since(s.globalSends, now.Add(-time.Minute))
+	for _, key := range endpoints {
+		ms := byEndpoint[key]
+		if now.Sub(s.lastSent[key]) < window || now.Sub(firstDue(ms)) < gather {
+			continue
+		}
+		recipient := recipientKey(*ms[0])
+		s.contactSends[recipient] = since(s.contactSends[recipient], now.Add(-time.Hour))
+		if len(s.contactSends[recipient]) >= contactLimit || len(s.globalSends) >= globalLimit {
+			if !s.limited[key] {
+				log.Println("Rate limit reached, holding the", ms[0].ChannelType, "notifications for", ms[0].Recipient)
+				s.limited[key] = true
+			}
+			continue
+		}
+		batches = append(batches, stormBatch{messages: ms, overflow: s.limited[key]})
+		delete(s.limited, key)
+		s.lastSent[key] = now
+		s.contactSends[recipient] = append(s.contactSends[recipient], now)
+		s.globalSends = append(s.globalSends, now)
+	}
+	return batches
+}
+
+// recipientKey returns the key of the contact or site the message is for. The
+// messages saved before the key was added only have the name.
+func recipientKey(m database.OutboxMessage) string {
+	if m.RecipientKey != "" {
+		return m.RecipientKey
+	}
+	return "recipient:" + m.Recipient
+}
+
+// firstDue returns when the first of the messages became due.
+func firstDue(messages []*database.OutboxMessage) time.Time {
+	first := messages[0].NextAttempt
+	for _, m := range messages[1:] {
+		if m.NextAttempt.Before(first) {
+			first = m.NextAttempt
+		}
+	}
+	return first
+}
+
+// since returns the times from the start on.
+func since(times []time.Time, start time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(start) {
+		i++
+	}
+	return times[i:]
+}
+
+func stormSetting(value int, defaultValue int) int {
+	if value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+// combine returns the message that delivers the notifications of the batch as
+// one, listing their subjects under a count of them by status. Each is listed
+// with the link to its site and the link to acknowledge its outage, if it has
+// them.
+func combine(b stormBatch, now time.Time) (database.OutboxMessage, error) {
+	var statuses []string
+	counts := make(map[string]int)
+	var lines []string
+	for i, m := range b.messages {
+		n := new(Notifier)
+		err := json.Unmarshal([]byte(m.Payload), n)
+		if err != nil {
+			return database.OutboxMessage{}, err
+		}
+		if counts[n.Status] == 0 {
+			statuses = append(statuses, n.Status)
+		}
+		counts[n.Status]++
+		if i < stormListLength {
+			lines = append(lines, "- "+m.Subject)
+			if link := n.SiteLink(); link != "" {
+				lines = append(lines, "  Site: "+link)
+			}
+			if n.AcknowledgeURL != "" {
+				lines = append(lines, "  Acknowledge: "+n.AcknowledgeURL)
+			}
+		}
+	}
+	if len(b.messages) > stormListLength {
+		lines = append(lines, fmt.Sprintf("- and %d more.", len(b.messages)-stormListLength))
+	}
+	if b.overflow {
+		lines = append([]string{fmt.Sprintf("The rate limit of the notifications was reached, %d %s held:",
+			len(b.messages), plural(len(b.messages), "was", "were"))}, lines...)
+	}
+
+	combined := Notifier{Time: now, Detail: strings.Join(lines, "\n")}
+	combined.Site.Name = fmt.Sprintf("%d %s", len(b.messages), plural(len(b.messages), "site", "sites"))
+	if len(statuses) == 1 && statuses[0] != "" {
+		combined.Status = statuses[0]
+		combined.Subject = fmt.Sprintf("Go Ping Sites: %d %s %s", len(b.messages), statuses[0],
+			plural(len(b.messages), "alert", "alerts"))
+	} else {
+		var parts []string
+		for _, status := range statuses {
+			label := status
+			if label == "" {
+				label = "other"
+			}
+			parts = append(parts, fmt.Sprintf("%d %s", counts[status], label))
+		}
+		combined.Subject = fmt.Sprintf("Go Ping Sites: %d alerts (%s)", len(b.messages), strings.Join(parts, ", "))
+	}
+	combined.Message = combined.Subject + "\n" + combined.Detail
+	payload, err := json.Marshal(combined)
+	if err != nil {
+		return database.OutboxMessage{}, err
+	}
+	m := b.messages[0]
+	return database.OutboxMessage{ChannelType: m.ChannelType, Address: m.Address, Recipient: m.Recipient,
+		RecipientKey: m.RecipientKey, Subject: combined.Subject, Payload: string(payload)}, nil
+}
+
+func plural(count int, one string, many string) string {
+	if count == 1 {
+		return one
+	}
+	return many
+}
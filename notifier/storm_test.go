@@ -0,0 +1,185 @@
+package notifier_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+)
+
+// TestOutboxStorm tests that the notifications of a storm to a contact's
+// channel are gathered into one message, that those due within the group
+// window are sent when it's over, and that those held by the rate limit are
+// sent as a summary once it allows.
+func TestOutboxStorm(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+	saved := config.Settings
+	defer func() { config.Settings = saved }()
+	config.Settings.Webhook.URL = "http://localhost/webhook"
+	config.Settings.Storm.GroupSeconds = 60
+	config.Settings.Storm.ContactPerHour = 2
+
+	email := &notifier.ChannelMock{}
+	webhook := &notifier.ChannelMock{}
+	channels := notifier.Channels{database.EmailChannel: email, database.WebhookChannel: webhook}
+	enqueue := func(names ...string) {
+		for _, name := range names {
+			site := database.Site{Name: name, URL: "http://www.example.com",
+				Contacts: []database.Contact{{Name: "Joe Contact",
+					Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "joe@test.com", IsActive: true}}}}}
+			n := notifier.NewNotifier(site, name+" at http://www.example.com: Site is down.", name+": Site is Down", channels)
+			n.Status = "Down"
+			messages, err := n.OutboxMessages()
+			if err != nil {
+				t.Fatal("Failed to get the outbox messages:", err)
+			}
+			err = database.EnqueueOutboxMessages(db, messages)
+			if err != nil {
+				t.Fatal("Failed to enqueue outbox messages:", err)
+			}
+		}
+	}
+
+	outbox := notifier.NewOutbox(db, channels)
+	enqueue("A")
+	now := time.Now()
+	outbox.DeliverDue(now)
+	if len(email.Messages) != 0 || len(webhook.Sent) != 1 {
+		t.Fatal("The first email should wait for the others of the storm:", email.Messages, webhook.Sent)
+	}
+	enqueue("B", "C")
+	outbox.DeliverDue(now.Add(10 * time.Second))
+	if len(email.Messages) != 1 || len(webhook.Sent) != 3 ||
+		email.Messages[0] != "Go Ping Sites: 3 Down alerts\n- A: Site is Down\n- B: Site is Down\n- C: Site is Down" {
+		t.Fatal("The emails of the storm should be combined:", email.Messages, webhook.Sent)
+	}
+	var pending database.OutboxMessages
+	err = pending.GetOutboxMessages(db, database.OutboxPending, 10)
+	if err != nil {
+		t.Fatal("Failed to get pending outbox messages:", err)
+	}
+	if len(pending) != 0 {
+		t.Error("The combined messages should be sent:", pending)
+	}
+
+	enqueue("D")
+	outbox.DeliverDue(now.Add(30 * time.Second))
+	if len(email.Messages) != 1 {
+		t.Fatal("Only one email should be sent in the group window:", email.Messages)
+	}
+	outbox.DeliverDue(now.Add(70 * time.Second))
+	if len(email.Messages) != 2 || email.Messages[1] != "D at http://www.example.com: Site is down." {
+		t.Fatal("The email should be sent once the group window is over:", email.Messages)
+	}
+
+	enqueue("E")
+	outbox.DeliverDue(now.Add(5 * time.Minute))
+	if len(email.Messages) != 2 {
+		t.Fatal("The email over the contact's rate limit should be held:", email.Messages)
+	}
+	outbox.DeliverDue(now.Add(time.Hour + 2*time.Minute))
+	if len(email.Messages) != 3 || !strings.Contains(email.Messages[2],
+		"The rate limit of the notifications was reached, 1 was held:\n- E: Site is Down") {
+		t.Error("The held email should be sent as a summary:", email.Messages)
+	}
+}
+
+// TestOutboxStormLinks tests that the combined message keeps the link to each
+// site and the link to acknowledge its outage.
+func TestOutboxStormLinks(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+	saved := config.Settings
+	defer func() { config.Settings = saved }()
+	config.Settings.Webhook.URL = ""
+	config.Settings.Website.BaseURL = "http://pings.example.com"
+	config.Settings.Storm.GroupSeconds = 60
+
+	email := &notifier.ChannelMock{}
+	channels := notifier.Channels{database.EmailChannel: email}
+	outbox := notifier.NewOutbox(db, channels)
+	now := time.Now()
+	var acknowledgeURLs []string
+	for i, name := range []string{"A", "B", "C"} {
+		site := database.Site{SiteID: int64(i + 1), Name: name, URL: "http://www.example.com",
+			Contacts: []database.Contact{{Name: "Joe Contact",
+				Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "joe@test.com", IsActive: true}}}}}
+		n := notifier.NewNotifier(site, name+" at http://www.example.com: Site is down.", name+": Site is Down", channels)
+		n.Status = "Down"
+		n.AddAcknowledgeLink(now)
+		acknowledgeURLs = append(acknowledgeURLs, n.AcknowledgeURL)
+		messages, err := n.OutboxMessages()
+		if err != nil {
+			t.Fatal("Failed to get the outbox messages:", err)
+		}
+		err = database.EnqueueOutboxMessages(db, messages)
+		if err != nil {
+			t.Fatal("Failed to enqueue outbox messages:", err)
+		}
+		if i == 0 {
+			now = time.Now()
+			outbox.DeliverDue(now.Add(10 * time.Second))
+		}
+	}
+	outbox.DeliverDue(now.Add(2 * time.Minute))
+	if len(email.Messages) != 2 {
+		t.Fatal("The emails of the group window should be combined:", email.Messages)
+	}
+	expected := "Go Ping Sites: 2 Down alerts\n" +
+		"- B: Site is Down\n  Site: http://pings.example.com/settings/sites/2\n  Acknowledge: " + acknowledgeURLs[1] + "\n" +
+		"- C: Site is Down\n  Site: http://pings.example.com/settings/sites/3\n  Acknowledge: " + acknowledgeURLs[2]
+	if acknowledgeURLs[1] == "" || email.Messages[1] != expected {
+		t.Error("The combined email should keep the links of each site:", email.Messages[1])
+	}
+}
+
+// TestOutboxStormRecipients tests that the rate limits are kept for each
+// contact and site rather than by their names, which aren't unique.
+func TestOutboxStormRecipients(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+	saved := config.Settings
+	defer func() { config.Settings = saved }()
+	config.Settings.Webhook.URL = ""
+	config.Settings.Storm.ContactPerHour = 1
+
+	email := &notifier.ChannelMock{}
+	slack := &notifier.ChannelMock{}
+	channels := notifier.Channels{database.EmailChannel: email, database.SlackChannel: slack}
+	site := database.Site{SiteID: 1, Name: "Joe Contact", URL: "http://www.example.com",
+		Contacts: []database.Contact{
+			{ContactID: 1, Name: "Joe Contact",
+				Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "joe@test.com", IsActive: true}}},
+			{ContactID: 2, Name: "Joe Contact",
+				Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "joe@example.com", IsActive: true}}}},
+		Channels: []database.SiteChannel{{ChannelType: database.SlackChannel,
+			Address: "https://hooks.slack.com/services/T/B/X", IsActive: true}}}
+	n := notifier.NewNotifier(site, "Site is down.", "Joe Contact: Site is Down", channels)
+	n.Status = "Down"
+	messages, err := n.OutboxMessages()
+	if err != nil {
+		t.Fatal("Failed to get the outbox messages:", err)
+	}
+	err = database.EnqueueOutboxMessages(db, messages)
+	if err != nil {
+		t.Fatal("Failed to enqueue outbox messages:", err)
+	}
+
+	notifier.NewOutbox(db, channels).DeliverDue(time.Now().Add(10 * time.Second))
+	if len(email.Sent) != 2 || len(slack.Sent) != 1 {
+		t.Error("Each contact and the site should have their own rate limit:", email.Sent, slack.Sent)
+	}
+}
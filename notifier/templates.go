@@ -0,0 +1,236 @@
+package notifier
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// Templates are the custom notification templates, the notifications of the
+// channels and events without one keep the default wording.
+type Templates database.NotificationTemplates
+
+// Find returns the template for the channel type and event, or the template
+// for all of the channels if the channel type doesn't have its own.
+func (t Templates) Find(channelType string, event string) (database.NotificationTemplate, bool) {
+	var all database.NotificationTemplate
+	var found bool
+	for _, nt := range t {
+		if nt.Event != event {
+			continue
+		}
+		if nt.ChannelType == channelType {
+			return nt, true
+		}
+		if nt.ChannelType == "" {
+			all, found = nt, true
+		}
+	}
+	return all, found
+}
+
+// TemplateData is what the notification templates are executed with.
+// The Error is why the site is down and the Downtime is how long the outage
+// lasted for a recovery or has lasted so far for a reminder. The CertExpires
+// is when the TLS certificate expires for the certificate expiry warnings.
+type TemplateData struct {
+	Site           database.Site
+	Ping           database.Ping
+	Event          string
+	Status         string
+	PreviousStatus string
+	Detail         string
+	Error          string
+	Downtime       time.Duration
+	Reminder       int
+	CertExpires    time.Time
+	Dependents     database.Sites
+	SiteLink       string
+	AcknowledgeURL string
+	Time           time.Time
+}
+
+// DefaultTemplate returns a template with the default wording of the event,
+// as the starting point for customizing it.
+func DefaultTemplate(channelType string, event string) database.NotificationTemplate {
+	t := database.NotificationTemplate{ChannelType: channelType, Event: event,
+		Subject: "{{.Site.Name}}: Site is {{.Status}}",
+		Body:    "{{.Site.Name}} at {{.Site.URL}}: {{.Detail}}"}
+	switch event {
+	case database.ReminderEvent:
+		t.Subject = "{{.Site.Name}}: Site is still Down"
+	case database.CertExpiryEvent:
+		t.Subject = "{{.Site.Name}}: Certificate expires {{.CertExpires.Format \"Jan 2, 2006\"}}"
+	}
+	if event == database.DownEvent || event == database.ReminderEvent {
+		t.Body += "{{if .AcknowledgeURL}}\nAcknowledge: {{.AcknowledgeURL}}{{end}}"
+	}
+	return t
+}
+
+// Event returns the event of the notification for finding its template, or
+// an empty string for the notifications that aren't templated such as
+// flapping. The escalations are reminders since the site was already down.
+// The slow responses are Degraded and the certificate warnings are Expiring.
+func (n *Notifier) Event() string {
+	switch {
+	case n.Status == "Down" && n.PreviousStatus == "Down":
+		return database.ReminderEvent
+	case n.Status == "Down":
+		return database.DownEvent
+	case n.Status == "Up":
+		return database.UpEvent
+	case n.Status == "Degraded":
+		return database.DegradedEvent
+	case n.Status == "Expiring":
+		return database.CertExpiryEvent
+	}
+	return ""
+}
+
+// SubscriptionEvent returns the event of the notification that the contacts
+// subscribe to, which includes the flapping and stable notifications. The calls
+// are about the outage, so they're for the contacts notified of it going down.
+func (n *Notifier) SubscriptionEvent() string {
+	if n.Call {
+		return database.DownEvent
+	}
+	if n.Status == "Flapping" || n.Status == "Stable" {
+		return database.FlappingEvent
+	}
+	return n.Event()
+}
+
+// TemplateData returns the data of the notification for the templates.
+func (n *Notifier) TemplateData() TemplateData {
+	return TemplateData{
+		Site:           n.Site,
+		Ping:           n.Ping,
+		Event:          n.Event(),
+		Status:         n.Status,
+		PreviousStatus: n.PreviousStatus,
+		Detail:         n.Detail,
+		Error:          n.Error,
+		Downtime:       n.Downtime.Round(time.Second),
+		Reminder:       n.Reminder,
+		CertExpires:    n.CertExpires,
+		Dependents:     n.Dependents,
+		SiteLink:       n.SiteLink(),
+		AcknowledgeURL: n.AcknowledgeURL,
+		Time:           n.Time,
+	}
+}
+
+// ForChannel returns the notification as it is sent to the channel type, with
+// the subject and message from its template if there's one for the event.
+// The message is also the detail for the channels that format the notification.
+// If the template fails the notification is sent with the default wording.
+func (n *Notifier) ForChannel(channelType string) *Notifier {
+	t, ok := n.Templates.Find(channelType, n.Event())
+	if !ok {
+		return n
+	}
+	subject, body, err := RenderTemplate(t, n.TemplateData())
+	if err != nil {
+		log.Println("Error in the", t.Event, "notification template for", channelType+":", err)
+		return n
+	}
+	c := *n
+	if subject != "" {
+		c.Subject = subject
+	}
+	if body != "" {
+		c.Message = body
+		c.Detail = body
+	}
+	return &c
+}
+
+// RenderTemplate executes the subject and body of the template with the data,
+// the subject is kept to one line.
+func RenderTemplate(t database.NotificationTemplate, data TemplateData) (string, string, error) {
+	subject, err := execute("subject", t.Subject, data)
+	if err != nil {
+		return "", "", err
+	}
+	body, err := execute("body", t.Body, data)
+	if err != nil {
+		return "", "", err
+	}
+	subject = strings.Join(strings.Fields(subject), " ")
+	return subject, strings.TrimSpace(body), nil
+}
+
+// ParseTemplate checks that the text is a valid template.
+func ParseTemplate(name string, text string) error {
+	_, err := template.New(name).Parse(text)
+	return err
+}
+
+func execute(name string, text string, data TemplateData) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// NewPreviewNotifier returns a notification of the event about an example site
+// for previewing the templates.
+func NewPreviewNotifier(event string) *Notifier {
+	site := database.Site{SiteID: 1, Name: "Example Site", URL: "http://www.example.com",
+		IsActive: true, PingIntervalSeconds: 60, TimeoutSeconds: 30}
+	now := time.Now()
+	ping := database.Ping{SiteID: site.SiteID, TimeRequest: now, Duration: 250,
+		HTTPStatusCode: 503, SiteDown: true}
+	errorText := "Site is down, HTTP Status Code is 503."
+	n := NewNotifier(site, "", "", nil)
+	n.Status = "Down"
+	n.PreviousStatus = "Up"
+	n.Error = errorText
+	n.Detail = errorText
+	switch event {
+	case database.UpEvent:
+		n.Status = "Up"
+		n.PreviousStatus = "Down"
+		n.Error = ""
+		n.Downtime = 12*time.Minute + 30*time.Second
+		n.Detail = "Site is now up, response time was 250ms."
+		ping.HTTPStatusCode = 200
+		ping.SiteDown = false
+	case database.ReminderEvent:
+		n.PreviousStatus = "Down"
+		n.Downtime = 45 * time.Minute
+		n.Reminder = 2
+		n.Detail = "Site is still down after 45m0s. " + errorText
+	case database.DegradedEvent:
+		n.Status = "Degraded"
+		n.Error = ""
+		n.Detail = "Site is slow, response time was 4.2s over the 2s threshold."
+		ping.Duration = 4200
+		ping.HTTPStatusCode = 200
+		ping.SiteDown = false
+	case database.CertExpiryEvent:
+		n.Status = "Expiring"
+		n.PreviousStatus = "Up"
+		n.Error = ""
+		n.CertExpires = now.AddDate(0, 0, 10)
+		n.Detail = "The TLS certificate expires on " + n.CertExpires.Format("Jan 2, 2006") + ", in 10 days."
+		ping.HTTPStatusCode = 200
+		ping.SiteDown = false
+	}
+	n.Ping = ping
+	if n.Status == "Down" {
+		n.AcknowledgeURL = AcknowledgeLink(site.SiteID, now)
+	}
+	return n
+}
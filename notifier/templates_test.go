@@ -0,0 +1,98 @@
+package notifier_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+)
+
+// TestForChannel tests that the notification is rendered from the template of
+// the channel, then the template for all of the channels, otherwise it's as is.
+func TestForChannel(t *testing.T) {
+	s := database.Site{SiteID: 4, Name: "Test", URL: "http://www.test.com"}
+	n := notifier.NewNotifier(s, "Test at http://www.test.com: Site is down.", "Test: Site is Down", nil)
+	n.Status = "Down"
+	n.PreviousStatus = "Down"
+	n.Error = "Site is down, HTTP Status Code is 503."
+	n.Downtime = 90*time.Second + 300*time.Millisecond
+	n.Templates = notifier.Templates{
+		{Event: database.ReminderEvent, Subject: "{{.Site.Name}}\n still down",
+			Body: "Down for {{.Downtime}}: {{.Error}}\nRunbook: http://wiki/{{.Site.SiteID}}"},
+		{ChannelType: database.SmsChannel, Event: database.ReminderEvent, Body: "{{.Site.Name}} down {{.Downtime}}"},
+		{ChannelType: database.SlackChannel, Event: database.DownEvent, Body: "Not a reminder"},
+	}
+
+	email := n.ForChannel(database.EmailChannel)
+	if email.Subject != "Test still down" ||
+		email.Message != "Down for 1m30s: Site is down, HTTP Status Code is 503.\nRunbook: http://wiki/4" ||
+		email.Detail != email.Message {
+		t.Error("Email notification not rendered from the template for all channels:", email.Subject, email.Message)
+	}
+	sms := n.ForChannel(database.SmsChannel)
+	if sms.Subject != n.Subject || sms.Message != "Test down 1m30s" {
+		t.Error("SMS notification not rendered from its own template:", sms.Subject, sms.Message)
+	}
+	if n.Message != "Test at http://www.test.com: Site is down." {
+		t.Error("Notification should not be changed by rendering it for a channel:", n.Message)
+	}
+
+	n.PreviousStatus = "Up"
+	if slack := n.ForChannel(database.SlackChannel); slack.Message != "Not a reminder" {
+		t.Error("Down notification not rendered from the Slack template:", slack.Message)
+	}
+	if email := n.ForChannel(database.EmailChannel); email != n {
+		t.Error("Notification without a template should be sent as is:", email.Message)
+	}
+
+	n.Templates = notifier.Templates{{Event: database.DownEvent, Body: "{{.Missing}}"}}
+	if email := n.ForChannel(database.EmailChannel); email != n {
+		t.Error("Notification should be sent as is when the template fails:", email.Message)
+	}
+}
+
+// TestDefaultTemplate tests that the default templates give the same wording
+// as the notifications without templates.
+func TestDefaultTemplate(t *testing.T) {
+	n := notifier.NewPreviewNotifier(database.ReminderEvent)
+	subject, body, err := notifier.RenderTemplate(notifier.DefaultTemplate("", database.ReminderEvent), n.TemplateData())
+	if err != nil {
+		t.Fatal("Failed to render the default template:", err)
+	}
+	if subject != "Example Site: Site is still Down" ||
+		body != "Example Site at http://www.example.com: "+n.Detail+"\nAcknowledge: "+n.AcknowledgeURL {
+		t.Error("Default template not rendered as expected:", subject, body)
+	}
+
+	n = notifier.NewPreviewNotifier(database.UpEvent)
+	subject, body, err = notifier.RenderTemplate(notifier.DefaultTemplate("", database.UpEvent), n.TemplateData())
+	if err != nil || subject != "Example Site: Site is Up" ||
+		body != "Example Site at http://www.example.com: Site is now up, response time was 250ms." {
+		t.Error("Default template not rendered as expected:", subject, body, err)
+	}
+}
+
+// TestWarningEvents tests that the degraded and certificate expiry
+// notifications have their own events and default wording.
+func TestWarningEvents(t *testing.T) {
+	n := notifier.NewPreviewNotifier(database.DegradedEvent)
+	if n.Event() != database.DegradedEvent || n.SubscriptionEvent() != database.DegradedEvent {
+		t.Error("Degraded notification should have the degraded event:", n.Event(), n.SubscriptionEvent())
+	}
+	subject, body, err := notifier.RenderTemplate(notifier.DefaultTemplate("", database.DegradedEvent), n.TemplateData())
+	if err != nil || subject != "Example Site: Site is Degraded" ||
+		body != "Example Site at http://www.example.com: "+n.Detail {
+		t.Error("Default degraded template not rendered as expected:", subject, body, err)
+	}
+
+	n = notifier.NewPreviewNotifier(database.CertExpiryEvent)
+	n.CertExpires = time.Date(2030, 3, 7, 12, 0, 0, 0, time.UTC)
+	if n.Event() != database.CertExpiryEvent || n.SubscriptionEvent() != database.CertExpiryEvent {
+		t.Error("Expiring notification should have the cert-expiry event:", n.Event(), n.SubscriptionEvent())
+	}
+	subject, _, err = notifier.RenderTemplate(notifier.DefaultTemplate("", database.CertExpiryEvent), n.TemplateData())
+	if err != nil || subject != "Example Site: Certificate expires Mar 7, 2030" {
+		t.Error("Default cert-expiry template not rendered as expected:", subject, err)
+	}
+}
@@ -0,0 +1,99 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+)
+
+// defaultTwilioURL is the base of the Twilio API if it isn't configured.
+const defaultTwilioURL = "https://api.twilio.com/2010-04-01"
+
+// TwilioVoice places the calls with the Twilio Programmable Voice API, which
+// reads the notification and gathers a keypress to acknowledge the outage. The
+// address of the endpoint is the number to call.
+type TwilioVoice struct {
+	Client     *http.Client
+	URL        string
+	AccountSid string
+	AuthToken  string
+	From       string
+}
+
+// NewTwilioVoice returns a TwilioVoice with the Twilio account and the number
+// from the config.
+func NewTwilioVoice() *TwilioVoice {
+	v := TwilioVoice{Client: &http.Client{Timeout: chatTimeout}, URL: config.Settings.Voice.URL,
+		AccountSid: config.Settings.Twilio.AccountSid, AuthToken: config.Settings.Twilio.AuthToken,
+		From: config.Settings.Voice.From}
+	if v.URL == "" {
+		v.URL = defaultTwilioURL
+	}
+	if v.From == "" {
+		v.From = config.Settings.Twilio.Number
+	}
+	return &v
+}
+
+// Name implements the Channel interface for TwilioVoice.
+func (v *TwilioVoice) Name() string {
+	return "voice call"
+}
+
+// Send implements the Channel interface for TwilioVoice, the TwiML of the call
+// is sent with it so no callback is needed to start it.
+func (v *TwilioVoice) Send(address string, n *Notifier) error {
+	if v.AccountSid == "" || v.AuthToken == "" {
+		return errors.New("the Twilio account is not configured")
+	}
+	form := url.Values{
+		"To":    {address},
+		"From":  {v.From},
+		"Twiml": {CallTwiML(n, CallAcknowledgeLink(n.Site.SiteID, time.Now()))},
+	}
+	callURL := strings.TrimRight(v.URL, "/") + "/Accounts/" + url.PathEscape(v.AccountSid) + "/Calls.json"
+	req, err := http.NewRequest("POST", callURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(v.AccountSid, v.AuthToken)
+	_, err = doRequest(v.Client, "Twilio", req)
+	return err
+}
+
+// CallTwiML returns the TwiML that reads the notification twice. If there's a
+// link to acknowledge the outage the keypad input is posted to it.
+func CallTwiML(n *Notifier, acknowledgeLink string) string {
+	speech := "Go Ping Sites alert. " + strings.TrimSuffix(n.Subject, ".") + ". " + n.Detail
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><Response>`)
+	if acknowledgeLink == "" {
+		b.WriteString(`<Say loop="2">` + escapeXML(speech) + `</Say>`)
+	} else {
+		b.WriteString(`<Gather numDigits="1" timeout="10" method="POST" action="` + escapeXML(acknowledgeLink) + `">`)
+		b.WriteString(`<Say loop="2">` + escapeXML(speech+" Press 1 to acknowledge the outage.") + `</Say>`)
+		b.WriteString(`</Gather><Say>The outage was not acknowledged. Goodbye.</Say>`)
+	}
+	b.WriteString(`</Response>`)
+	return b.String()
+}
+
+// SayTwiML returns the TwiML that says the message and hangs up, for the
+// response to the keypad input.
+func SayTwiML(message string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?><Response><Say>` + escapeXML(message) +
+		`</Say></Response>`
+}
+
+func escapeXML(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
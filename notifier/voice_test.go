@@ -0,0 +1,105 @@
+package notifier_test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+)
+
+// TestVoiceCall tests that the call is placed with the TwiML that reads the
+// notification and posts the keypad input to the acknowledge link.
+func TestVoiceCall(t *testing.T) {
+	var requests []pushRequest
+	server := smsStandIn(http.StatusCreated, `{"sid": "CA123"}`, &requests)
+	defer server.Close()
+
+	site := database.Site{SiteID: 3, Name: "Test", URL: "http://www.example.com"}
+	n := notifier.NewNotifier(site, "Test at http://www.example.com: Site is still down after 10m0s.",
+		"Test: Site is still Down", notifier.Channels{})
+	n.Status = "Down"
+	n.Detail = "Site is still down after 10m0s."
+	n.Call = true
+	v := &notifier.TwilioVoice{Client: http.DefaultClient, URL: server.URL + "/2010-04-01",
+		AccountSid: "AC123", AuthToken: "token", From: "+15125551212"}
+	err := v.Send("+15125550000", n)
+	if err != nil {
+		t.Fatal("Failed to place the call:", err)
+	}
+	if len(requests) != 1 || requests[0].Path != "/2010-04-01/Accounts/AC123/Calls.json" {
+		t.Fatal("The call should be posted to the Calls API:", requests)
+	}
+	form, _ := url.ParseQuery(requests[0].Body)
+	if form.Get("To") != "+15125550000" || form.Get("From") != "+15125551212" {
+		t.Error("Call numbers not as expected:", requests[0].Body)
+	}
+	if user, _, _ := (&http.Request{Header: requests[0].Header}).BasicAuth(); user != "AC123" {
+		t.Error("The call should be authorized with the account:", requests[0].Header)
+	}
+	twiml := form.Get("Twiml")
+	if !strings.Contains(twiml, `action="http://localhost:8000/calls/3/acknowledge?t=`) ||
+		!strings.Contains(twiml, "&amp;sig=") ||
+		!strings.Contains(twiml, "Go Ping Sites alert. Test: Site is still Down. Site is still down after 10m0s. Press 1") {
+		t.Error("Call TwiML not as expected:", twiml)
+	}
+
+	err = (&notifier.TwilioVoice{Client: http.DefaultClient, URL: server.URL}).Send("+15125550000", n)
+	if err == nil || !strings.Contains(err.Error(), "not configured") {
+		t.Error("A call without the account should return an error:", err)
+	}
+}
+
+// TestCallTwiMLWithoutLink tests that the message is only read when the
+// outage can't be acknowledged, such as for a test call.
+func TestCallTwiMLWithoutLink(t *testing.T) {
+	n := notifier.NewTestNotifier(database.Site{}, notifier.Channels{})
+	twiml := notifier.CallTwiML(n, "")
+	if strings.Contains(twiml, "<Gather") || !strings.Contains(twiml, `<Say loop="2">Go Ping Sites alert. Go Ping Sites: Test Message.`) {
+		t.Error("Test call TwiML not as expected:", twiml)
+	}
+	if twiml := notifier.SayTwiML("Acknowledged <b> & done"); !strings.Contains(twiml, "<Say>Acknowledged &lt;b&gt; &amp; done</Say>") {
+		t.Error("The message should be escaped:", twiml)
+	}
+}
+
+// TestCallChannels tests that the voice numbers are only used for the calls,
+// and that the calls are only to them.
+func TestCallChannels(t *testing.T) {
+	saved := config.Settings.Webhook.URL
+	defer func() { config.Settings.Webhook.URL = saved }()
+	config.Settings.Webhook.URL = "http://localhost/webhook"
+
+	site := getTestSite()
+	site.IsCritical = true
+	site.Contacts[1].SetChannel(database.VoiceChannel, "+15125551213", true)
+	site.Channels = []database.SiteChannel{{ChannelType: database.SlackChannel,
+		Address: "https://hooks.slack.com/services/T/B/X", IsActive: true}}
+
+	tests := []struct {
+		call      bool
+		addresses []string
+	}{
+		{false, []string{"joe@test.com", "5125551213", "https://hooks.slack.com/services/T/B/X", "http://localhost/webhook"}},
+		{true, []string{"+15125551213"}},
+	}
+	for _, test := range tests {
+		n := notifier.NewNotifier(site, "Site is Down", "Test: Site is Down", notifier.Channels{})
+		n.Status = "Down"
+		n.Call = test.call
+		messages, err := n.OutboxMessages()
+		if err != nil {
+			t.Fatal("Failed to get the outbox messages:", err)
+		}
+		var addresses []string
+		for _, m := range messages {
+			addresses = append(addresses, m.Address)
+		}
+		if strings.Join(addresses, ",") != strings.Join(test.addresses, ",") {
+			t.Error("Call", test.call, "should notify", test.addresses, "got", addresses)
+		}
+	}
+}
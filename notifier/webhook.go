@@ -0,0 +1,142 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+)
+
+// The headers of the webhook posts. The signature is "sha256=" followed by
+// the hex HMAC-SHA256 of the body using the webhook secret.
+const (
+	SignatureHeader = "X-GoPingSites-Signature"
+	EventHeader     = "X-GoPingSites-Event"
+)
+
+// WebhookPayload is the JSON that is posted to the webhooks on each status
+// change, and as a reminder while a site stays down. The states are "up",
+// "down", "flapping", "stable" or "test". The acknowledge link is included
+// while the site is down.
+type WebhookPayload struct {
+	Event          string        `json:"event"`
+	Reminder       int           `json:"reminder,omitempty"`
+	Timestamp      time.Time     `json:"timestamp"`
+	Site           WebhookSite   `json:"site"`
+	PreviousState  string        `json:"previous_state"`
+	NewState       string        `json:"new_state"`
+	Subject        string        `json:"subject"`
+	Message        string        `json:"message"`
+	Detail         string        `json:"detail"`
+	OutageSeconds  int64         `json:"outage_seconds"`
+	Ping           *WebhookPing  `json:"ping"`
+	AcknowledgeURL string        `json:"acknowledge_url,omitempty"`
+	DependentSites []WebhookSite `json:"dependent_sites,omitempty"`
+}
+
+// WebhookSite is the site in the WebhookPayload, the link is to the site
+// details page if the Website BaseURL is configured.
+type WebhookSite struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Link string `json:"link,omitempty"`
+}
+
+// WebhookPing is the result of the ping that changed the status.
+type WebhookPing struct {
+	TimeRequest    time.Time `json:"time_request"`
+	DurationMs     int       `json:"duration_ms"`
+	HTTPStatusCode int       `json:"http_status_code"`
+	SiteDown       bool      `json:"site_down"`
+}
+
+// Webhook posts the WebhookPayload to the address of the endpoint, signed with
+// the secret. A failed post is retried by the outbox like the other channels.
+type Webhook struct {
+	Client *http.Client
+	Secret string
+}
+
+// NewWebhook returns a Webhook with the secret from the config.
+func NewWebhook() *Webhook {
+	return &Webhook{Client: &http.Client{Timeout: chatTimeout}, Secret: config.Settings.Webhook.Secret}
+}
+
+// Name implements the Channel interface for the Webhook.
+func (w *Webhook) Name() string {
+	return "webhook"
+}
+
+// Send implements the Channel interface for the Webhook.
+func (w *Webhook) Send(address string, n *Notifier) error {
+	payload := NewWebhookPayload(n)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", address, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, payload.Event)
+	if w.Secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(w.Secret, body))
+	}
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+	return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+}
+
+// Sign returns the hex HMAC-SHA256 of the body, for the receivers of the
+// webhook to check the signature header.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewWebhookPayload returns the payload for the notification.
+func NewWebhookPayload(n *Notifier) WebhookPayload {
+	payload := WebhookPayload{
+		Event:          "status_change",
+		Reminder:       n.Reminder,
+		Timestamp:      n.Time.UTC(),
+		Site:           WebhookSite{ID: n.Site.SiteID, Name: n.Site.Name, URL: n.Site.URL, Link: n.SiteLink()},
+		PreviousState:  strings.ToLower(n.PreviousStatus),
+		NewState:       strings.ToLower(n.Status),
+		Subject:        n.Subject,
+		Message:        n.Message,
+		Detail:         n.Detail,
+		OutageSeconds:  int64(n.Downtime.Seconds()),
+		AcknowledgeURL: n.AcknowledgeURL,
+	}
+	if n.Reminder > 0 {
+		payload.Event = "reminder"
+	}
+	if !n.Ping.TimeRequest.IsZero() {
+		payload.Ping = &WebhookPing{TimeRequest: n.Ping.TimeRequest.UTC(), DurationMs: n.Ping.Duration,
+			HTTPStatusCode: n.Ping.HTTPStatusCode, SiteDown: n.Ping.SiteDown}
+	}
+	for _, d := range n.Dependents {
+		payload.DependentSites = append(payload.DependentSites,
+			WebhookSite{ID: d.SiteID, Name: d.Name, URL: d.URL})
+	}
+	return payload
+}
@@ -0,0 +1,119 @@
+package notifier_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+)
+
+// TestWebhookPayload tests that the webhook is posted the signed payload of
+// the status change.
+func TestWebhookPayload(t *testing.T) {
+	var body []byte
+	var signature, event string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ = io.ReadAll(req.Body)
+		signature = req.Header.Get(notifier.SignatureHeader)
+		event = req.Header.Get(notifier.EventHeader)
+	}))
+	defer server.Close()
+
+	site := database.Site{SiteID: 3, Name: "Test", URL: "http://www.example.com"}
+	n := notifier.NewNotifier(site, "Test at http://www.example.com: Site is now down.",
+		"Test: Site is Down", notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
+	n.Status = "Down"
+	n.PreviousStatus = "Up"
+	n.Detail = "Site is now down."
+	n.Ping = database.Ping{TimeRequest: time.Date(2015, time.November, 5, 10, 0, 0, 0, time.UTC),
+		Duration: 30000, HTTPStatusCode: 503, SiteDown: true}
+	n.AddDependents(database.Sites{{SiteID: 4, Name: "Dependent", URL: "http://www.example.com/app"}})
+
+	w := notifier.NewWebhook()
+	w.Secret = "secret"
+	err := w.Send(server.URL, n)
+	if err != nil {
+		t.Fatal("Failed to send to webhook:", err)
+	}
+	if signature != "sha256="+notifier.Sign("secret", body) {
+		t.Error("Webhook signature doesn't match the body:", signature)
+	}
+	if event != "status_change" {
+		t.Error("Webhook event header not set:", event)
+	}
+
+	var payload notifier.WebhookPayload
+	err = json.Unmarshal(body, &payload)
+	if err != nil {
+		t.Fatal("Failed to decode webhook payload:", err)
+	}
+	if payload.Site.ID != 3 || payload.Site.Link != "http://localhost:8000/settings/sites/3" ||
+		payload.PreviousState != "up" || payload.NewState != "down" || payload.Timestamp.IsZero() {
+		t.Error("Webhook payload not as expected:", string(body))
+	}
+	if payload.Ping == nil || payload.Ping.HTTPStatusCode != 503 || !payload.Ping.SiteDown ||
+		payload.Ping.DurationMs != 30000 {
+		t.Error("Webhook payload ping not as expected:", string(body))
+	}
+	if len(payload.DependentSites) != 1 || payload.DependentSites[0].Name != "Dependent" {
+		t.Error("Webhook payload dependent sites not as expected:", string(body))
+	}
+}
+
+// TestWebhookSingleAttempt tests that a failed post is returned as the error
+// after one attempt, since the outbox retries it with its backoff.
+func TestWebhookSingleAttempt(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		http.Error(rw, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	n := notifier.NewNotifier(database.Site{Name: "Test"}, "Test is down.", "Test: Site is Down",
+		notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
+	err := notifier.NewWebhook().Send(server.URL, n)
+	if err == nil || !strings.Contains(err.Error(), "webhook returned 503 Service Unavailable: unavailable") {
+		t.Error("The failed post should be returned:", err)
+	}
+	if attempts != 1 {
+		t.Error("The webhook should be posted once, got", attempts)
+	}
+}
+
+// TestNotifyWebhook tests that the webhook in the config is posted each
+// notification.
+func TestNotifyWebhook(t *testing.T) {
+	posted := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		posted <- string(body)
+	}))
+	defer server.Close()
+	config.Settings.Webhook.URL = server.URL
+	defer func() { config.Settings.Webhook.URL = "" }()
+
+	n := notifier.NewNotifier(database.Site{Name: "Test"}, "Test is up.", "Test: Site is Up",
+		notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
+	n.Status = "Up"
+	n.Notify()
+	select {
+	case body := <-posted:
+		if !strings.Contains(body, `"new_state":"up"`) {
+			t.Error("Webhook payload not as expected:", body)
+		}
+	default:
+		t.Error("Webhook in the config wasn't posted.")
+	}
+}
@@ -0,0 +1,53 @@
+package pinger
+
+import (
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// defaultVoiceDelayMinutes is how long a critical site is down without being
+// acknowledged before its contacts are called if it isn't set in the config.
+const defaultVoiceDelayMinutes = 10
+
+// voiceCall tracks whether the contacts have been called about the current
+// outage of a critical site. They're called once if it hasn't been acknowledged
+// after the delay, since the other channels didn't get an answer.
+type voiceCall struct {
+	delay  time.Duration
+	since  time.Time
+	called bool
+}
+
+// newVoiceCall returns the call of the site using the delay from the config. If
+// the site is already down, such as after a restart, the call is assumed to
+// have been made if it was due.
+func newVoiceCall(s database.Site, now time.Time) *voiceCall {
+	delayMinutes := config.Settings.Voice.DelayMinutes
+	if delayMinutes <= 0 {
+		delayMinutes = defaultVoiceDelayMinutes
+	}
+	v := voiceCall{delay: time.Duration(delayMinutes) * time.Minute}
+	if !s.IsSiteUp && !s.LastStatusChange.IsZero() {
+		v.since = s.LastStatusChange
+		v.called = now.Sub(v.since) >= v.delay
+	}
+	return &v
+}
+
+// start begins waiting to call for an outage that has just been notified.
+func (v *voiceCall) start(t time.Time) {
+	v.since = t
+	v.called = false
+}
+
+// due returns true if the outage has lasted for the delay without the call
+// being made, and records that it has been.
+func (v *voiceCall) due(t time.Time) bool {
+	if v.called || v.since.IsZero() || t.Sub(v.since) < v.delay {
+		return false
+	}
+	v.called = true
+	return true
+}
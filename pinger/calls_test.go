@@ -0,0 +1,42 @@
+package pinger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+func TestVoiceCall(t *testing.T) {
+	v := newVoiceCall(database.Site{IsSiteUp: true}, time.Now())
+	if v.due(time.Now().Add(24 * time.Hour)) {
+		t.Error("No call should be due while the site is up.")
+	}
+	start := time.Date(2016, 3, 1, 22, 0, 0, 0, time.UTC)
+	v.start(start)
+	if v.due(start.Add(v.delay - time.Minute)) {
+		t.Error("The call should not be due before the delay.")
+	}
+	if !v.due(start.Add(v.delay)) {
+		t.Error("The call should be due after the delay.")
+	}
+	if v.due(start.Add(2 * v.delay)) {
+		t.Error("The call should only be made once for an outage.")
+	}
+	v.start(start.Add(time.Hour))
+	if !v.due(start.Add(time.Hour + v.delay)) {
+		t.Error("A new outage should be called again.")
+	}
+}
+
+func TestVoiceCallRestart(t *testing.T) {
+	now := time.Now()
+	v := newVoiceCall(database.Site{LastStatusChange: now.Add(-time.Hour)}, now)
+	if v.due(now) {
+		t.Error("The call of an outage that was already due should be assumed to have been made.")
+	}
+	v = newVoiceCall(database.Site{LastStatusChange: now.Add(-time.Minute)}, now)
+	if !v.due(now.Add(v.delay)) {
+		t.Error("The call of an outage that wasn't due yet should still be made.")
+	}
+}
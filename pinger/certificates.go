@@ -0,0 +1,133 @@
+package pinger
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+)
+
+const (
+	// defaultCertificateWarnDays is how long before the TLS certificate of a
+	// site expires that it's warned about if it isn't set in the config.
+	defaultCertificateWarnDays = 14
+	// certificateCheckInterval is how often the certificate of a site is checked.
+	certificateCheckInterval = 12 * time.Hour
+)
+
+// certificateWarnings are the expiry times of the certificates that have been
+// warned about by site. They're kept across the restarts of the pinger for the
+// settings changes so that each certificate is only warned about once.
+var certificateWarnings = struct {
+	sync.Mutex
+	expires map[int64]time.Time
+}{expires: make(map[int64]time.Time)}
+
+// certificateCheck tracks the checks of the TLS certificate of a site.
+type certificateCheck struct {
+	siteID     int64
+	warnBefore time.Duration
+	next       time.Time
+}
+
+// newCertificateCheck returns the check of the site's certificate using the
+// warning days from the config, the first check is due straight away.
+func newCertificateCheck(siteID int64) *certificateCheck {
+	days := config.Settings.Certificates.WarnDays
+	if days <= 0 {
+		days = defaultCertificateWarnDays
+	}
+	return &certificateCheck{siteID: siteID, warnBefore: time.Duration(days) * 24 * time.Hour}
+}
+
+// due returns true if the certificate should be checked, and records that it
+// has been.
+func (c *certificateCheck) due(t time.Time) bool {
+	if t.Before(c.next) {
+		return false
+	}
+	c.next = t.Add(certificateCheckInterval)
+	return true
+}
+
+// expiring returns true if the certificate that expires at the time should be
+// warned about, which is once for each certificate within the warning days.
+func (c *certificateCheck) expiring(expires time.Time, t time.Time) bool {
+	if expires.Sub(t) > c.warnBefore {
+		return false
+	}
+	certificateWarnings.Lock()
+	defer certificateWarnings.Unlock()
+	if certificateWarnings.expires[c.siteID].Equal(expires) {
+		return false
+	}
+	certificateWarnings.expires[c.siteID] = expires
+	return true
+}
+
+// checkCertificate reads the certificate of the site that was pinged and warns
+// its contacts if it expires within the warning days. It runs in its own
+// goroutine, which is done once the warning has been sent to the outbox.
+func checkCertificate(s database.Site, p database.Ping, c *certificateCheck, db *sql.DB,
+	channels notifier.Channels, templates notifier.Templates, statuses *siteStatuses, wg *sync.WaitGroup) {
+	defer wg.Done()
+	expires, err := certificateExpiry(s.URL, s.TimeoutSeconds)
+	if err != nil {
+		log.Println(s.Name, "Unable to check the certificate -", err)
+		return
+	}
+	if !c.expiring(expires, p.TimeRequest) {
+		return
+	}
+	n := statusNotifier(s, "Expiring", certificateDetails(expires, p.TimeRequest), channels, templates)
+	n.Subject = s.Name + ": Certificate expires " + expires.Format("Jan 2, 2006")
+	n.PreviousStatus = "Up"
+	n.CertExpires = expires
+	n.Ping = p
+	notify(db, n, statuses)
+}
+
+// certificateDetails returns the details of the warning about the certificate.
+func certificateDetails(expires time.Time, t time.Time) string {
+	if expires.Before(t) {
+		return "The TLS certificate expired on " + expires.Format("Jan 2, 2006") + "."
+	}
+	return fmt.Sprintf("The TLS certificate expires on %s, in %d days.", expires.Format("Jan 2, 2006"),
+		int(expires.Sub(t).Hours()/24))
+}
+
+// isHTTPS returns true if the URL has a TLS certificate to check.
+func isHTTPS(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && u.Scheme == "https"
+}
+
+// certificateExpiry returns when the TLS certificate of the https URL expires.
+// The certificate isn't verified so that one that has already expired is still
+// read, nothing is sent over the connection.
+func certificateExpiry(rawURL string, timeout int) (time.Time, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return time.Time{}, err
+	}
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), "443")
+	}
+	dialer := &net.Dialer{Timeout: time.Duration(timeout) * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", address,
+		&tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: true})
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].NotAfter, nil
+}
@@ -0,0 +1,96 @@
+package pinger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+)
+
+func TestCertificateCheck(t *testing.T) {
+	c := &certificateCheck{siteID: 1001, warnBefore: 14 * 24 * time.Hour}
+	now := time.Date(2016, 3, 1, 22, 0, 0, 0, time.UTC)
+	if !c.due(now) || c.due(now.Add(time.Hour)) || !c.due(now.Add(certificateCheckInterval)) {
+		t.Error("Certificate should be checked straight away and then every interval.")
+	}
+
+	expires := now.Add(20 * 24 * time.Hour)
+	if c.expiring(expires, now) {
+		t.Error("Certificate should not be warned about before the warning days.")
+	}
+	if !c.expiring(expires, now.Add(7*24*time.Hour)) {
+		t.Error("Certificate should be warned about within the warning days.")
+	}
+	restarted := &certificateCheck{siteID: 1001, warnBefore: 14 * 24 * time.Hour}
+	if c.expiring(expires, now.Add(8*24*time.Hour)) || restarted.expiring(expires, now.Add(8*24*time.Hour)) {
+		t.Error("Certificate should only be warned about once, even after a restart.")
+	}
+	renewed := now.Add(30 * 24 * time.Hour)
+	if !c.expiring(renewed, now.Add(17*24*time.Hour)) {
+		t.Error("Renewed certificate should be warned about once it's within the warning days.")
+	}
+}
+
+func TestCertificateExpiry(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+	expires, err := certificateExpiry(server.URL, 5)
+	if err != nil {
+		t.Fatal("Failed to get the certificate expiry:", err)
+	}
+	if !expires.Equal(server.Certificate().NotAfter) {
+		t.Error("Certificate expiry not as expected:", expires, server.Certificate().NotAfter)
+	}
+	if !isHTTPS(server.URL) || isHTTPS("http://www.example.com") {
+		t.Error("Only the https sites should have their certificate checked.")
+	}
+	if details := certificateDetails(time.Date(2016, 3, 11, 0, 0, 0, 0, time.UTC),
+		time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC)); details != "The TLS certificate expires on Mar 11, 2016, in 10 days." {
+		t.Error("Certificate details not as expected:", details)
+	}
+}
+
+// TestCheckCertificate tests that the certificate check that runs apart from
+// the pings warns the contacts of the site by way of the outbox.
+func TestCheckCertificate(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+	saved := config.Settings.Webhook.URL
+	defer func() { config.Settings.Webhook.URL = saved }()
+	config.Settings.Webhook.URL = ""
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+	site := database.Site{SiteID: 1002, Name: "Test", URL: server.URL, TimeoutSeconds: 5,
+		Contacts: []database.Contact{{Name: "Joe Contact",
+			Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "joe@test.com", IsActive: true}}}}}
+	p := database.Ping{SiteID: site.SiteID, TimeRequest: time.Now()}
+	// Warn about the test server's certificate, which expires long after now.
+	c := &certificateCheck{siteID: site.SiteID,
+		warnBefore: server.Certificate().NotAfter.Sub(p.TimeRequest) + time.Hour}
+	channels := notifier.Channels{database.EmailChannel: &notifier.ChannelMock{}}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go checkCertificate(site, p, c, db, channels, nil, newSiteStatuses(database.Sites{site}), &wg)
+	wg.Wait()
+
+	var messages database.OutboxMessages
+	err = messages.GetOutboxMessages(db, "", 10)
+	if err != nil {
+		t.Fatal("Failed to get the outbox messages:", err)
+	}
+	if len(messages) != 1 || messages[0].Address != "joe@test.com" ||
+		!strings.HasPrefix(messages[0].Subject, "Test: Certificate expires") {
+		t.Error("The certificate warning should be in the outbox:", messages)
+	}
+}
@@ -0,0 +1,53 @@
+package pinger
+
+import (
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+)
+
+// The number of slow pings in a row that make a site degraded if it isn't set
+// in the config.
+const defaultDegradedPings = 3
+
+// degradedCheck tracks the response times of a site while it's up. The site
+// is degraded once it's slower than the threshold for the number of pings in a
+// row, and it's notified once until it responds within the threshold again.
+// There's no check if the threshold is 0.
+type degradedCheck struct {
+	threshold  time.Duration
+	pings      int
+	slowPings  int
+	isDegraded bool
+}
+
+// newDegradedCheck returns a check using the threshold from the config.
+func newDegradedCheck() *degradedCheck {
+	pings := config.Settings.Degraded.Pings
+	if pings <= 0 {
+		pings = defaultDegradedPings
+	}
+	return &degradedCheck{threshold: time.Duration(config.Settings.Degraded.ResponseMilliseconds) * time.Millisecond,
+		pings: pings}
+}
+
+// response records the response time of a ping of the site while it's up and
+// returns true if the site has just become degraded.
+func (d *degradedCheck) response(responseTime time.Duration) bool {
+	if d.threshold <= 0 || responseTime <= d.threshold {
+		d.reset()
+		return false
+	}
+	d.slowPings++
+	if !d.isDegraded && d.slowPings >= d.pings {
+		d.isDegraded = true
+		return true
+	}
+	return false
+}
+
+// reset starts the check over, such as when the site goes down.
+func (d *degradedCheck) reset() {
+	d.slowPings = 0
+	d.isDegraded = false
+}
@@ -0,0 +1,38 @@
+package pinger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDegradedCheck(t *testing.T) {
+	d := &degradedCheck{threshold: 2 * time.Second, pings: 3}
+	for i := 0; i < 2; i++ {
+		if d.response(3 * time.Second) {
+			t.Fatal("Site should not be degraded before the number of slow pings.")
+		}
+	}
+	if d.response(time.Second) || d.response(3*time.Second) || d.response(3*time.Second) {
+		t.Fatal("A fast ping should start the slow pings over.")
+	}
+	if !d.response(3 * time.Second) {
+		t.Fatal("Site should be degraded after the number of slow pings in a row.")
+	}
+	if d.response(3 * time.Second) {
+		t.Error("Degraded should only be reported when it starts.")
+	}
+	d.reset()
+	for i := 0; i < 2; i++ {
+		d.response(3 * time.Second)
+	}
+	if !d.response(3 * time.Second) {
+		t.Error("Site should be degraded again after it was reset.")
+	}
+}
+
+func TestDegradedCheckOff(t *testing.T) {
+	d := &degradedCheck{pings: 1}
+	if d.response(time.Minute) {
+		t.Error("Site should not be degraded without a threshold.")
+	}
+}
@@ -0,0 +1,191 @@
+package pinger
+
+import (
+	"sync"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+)
+
+// siteStatuses is shared by the pingers of the sites so that a site can find
+// out whether the parent sites it depends on are down.
+type siteStatuses struct {
+	mu           sync.Mutex
+	sites        database.Sites
+	up           map[int64]bool
+	acknowledged map[int64]bool
+	incidents    map[int64][]database.SiteChannel
+}
+
+func newSiteStatuses(sites database.Sites) *siteStatuses {
+	ss := siteStatuses{sites: sites, up: make(map[int64]bool), acknowledged: make(map[int64]bool),
+		incidents: make(map[int64][]database.SiteChannel)}
+	for _, s := range sites {
+		ss.up[s.SiteID] = s.IsSiteUp
+		ss.acknowledged[s.SiteID] = !s.IsSiteUp && s.AcknowledgedBy != ""
+	}
+	return &ss
+}
+
+// setUp records the latest status of a site.
+func (ss *siteStatuses) setUp(siteID int64, up bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.up[siteID] = up
+}
+
+// setAcknowledged records whether the current outage of the site has been
+// acknowledged, which stops its reminders and escalation.
+func (ss *siteStatuses) setAcknowledged(siteID int64, acknowledged bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.acknowledged[siteID] = acknowledged
+}
+
+// isAcknowledged returns true if the current outage of the site has been
+// acknowledged.
+func (ss *siteStatuses) isAcknowledged(siteID int64) bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.acknowledged[siteID]
+}
+
+// setIncidents records the incident channels that were sent the trigger of
+// the outage of the site, including the ones of its dependent sites.
+func (ss *siteStatuses) setIncidents(siteID int64, channels []database.SiteChannel) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.incidents[siteID] = nil
+	for _, ch := range channels {
+		if notifier.IsIncidentChannel(ch.ChannelType) {
+			ss.incidents[siteID] = append(ss.incidents[siteID], ch)
+		}
+	}
+}
+
+// takeIncidents returns the incident channels that were sent the trigger of
+// the outage of the site, which is over.
+func (ss *siteStatuses) takeIncidents(siteID int64) []database.SiteChannel {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	channels := ss.incidents[siteID]
+	delete(ss.incidents, siteID)
+	return channels
+}
+
+// get returns the site and its latest status. The boolean result is false if
+// the site isn't being pinged.
+func (ss *siteStatuses) get(siteID int64) (database.Site, bool, bool) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for _, s := range ss.sites {
+		if s.SiteID == siteID {
+			return s, ss.up[siteID], true
+		}
+	}
+	return database.Site{}, false, false
+}
+
+// downParents returns the names of the parent sites of the site that are down.
+// A parent that was up at its last ping is checked again, since it may have
+// failed since then and the site would otherwise alert before its parent.
+func (ss *siteStatuses) downParents(s database.Site, requestURL URLRequester) []string {
+	var down []string
+	for _, parentSiteID := range s.ParentSiteIDs {
+		parent, up, ok := ss.get(parentSiteID)
+		if !ok {
+			continue
+		}
+		if up {
+			up = isSiteUp(parent, requestURL)
+			ss.setUp(parentSiteID, up)
+		}
+		if !up {
+			down = append(down, parent.Name)
+		}
+	}
+	return down
+}
+
+// dependents returns the sites that depend on the site, directly or through
+// other sites, and are held down by its outage, so that they can be included in
+// its notifications. The ones that are up aren't affected.
+func (ss *siteStatuses) dependents(siteID int64) database.Sites {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	var result database.Sites
+	included := map[int64]bool{siteID: true}
+	pending := []int64{siteID}
+	for len(pending) > 0 {
+		parentSiteID := pending[0]
+		pending = pending[1:]
+		for _, s := range ss.sites {
+			if included[s.SiteID] || ss.up[s.SiteID] || !int64InSlice(parentSiteID, s.ParentSiteIDs) {
+				continue
+			}
+			included[s.SiteID] = true
+			result = append(result, s)
+			pending = append(pending, s.SiteID)
+		}
+	}
+	return result
+}
+
+// children returns the sites that depend directly on the site.
+func (ss *siteStatuses) children(parentSiteID int64) database.Sites {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	var result database.Sites
+	for _, s := range ss.sites {
+		if int64InSlice(parentSiteID, s.ParentSiteIDs) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// checkDependents checks the sites that depend on the site again once its
+// outage has been notified, since they may not have failed at their last ping
+// yet. The ones that are down are then held down by the outage and covered by
+// its recovery alert. The sites aren't checked under the lock.
+func (ss *siteStatuses) checkDependents(siteID int64, requestURL URLRequester) {
+	checked := map[int64]bool{siteID: true}
+	pending := []int64{siteID}
+	for len(pending) > 0 {
+		parentSiteID := pending[0]
+		pending = pending[1:]
+		for _, s := range ss.children(parentSiteID) {
+			if checked[s.SiteID] {
+				continue
+			}
+			checked[s.SiteID] = true
+			_, up, _ := ss.get(s.SiteID)
+			if up {
+				up = isSiteUp(s, requestURL)
+				ss.setUp(s.SiteID, up)
+			}
+			if !up {
+				pending = append(pending, s.SiteID)
+			}
+		}
+	}
+}
+
+// isSiteUp checks a site outside of its own ping interval. If the Internet
+// isn't accessible the status can't be determined so it's treated as up.
+func isSiteUp(s database.Site, requestURL URLRequester) bool {
+	bodyContent, statusCode, _, _, err := checkSite(s, requestURL)
+	if _, ok := err.(InternetAccessError); ok {
+		return true
+	}
+	return evaluateCheck(s, bodyContent, statusCode, err) == ""
+}
+
+func int64InSlice(a int64, list []int64) bool {
+	for _, b := range list {
+		if b == a {
+			return true
+		}
+	}
+	return false
+}
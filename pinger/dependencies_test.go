@@ -0,0 +1,83 @@
+package pinger
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+)
+
+// TestDependentsHeldDown tests that only the dependent sites that are failing
+// are included, and only in the alerts of the parent going down and back up.
+func TestDependentsHeldDown(t *testing.T) {
+	sites := database.Sites{
+		{SiteID: 1, Name: "Load Balancer", URL: "http://lb.example.com", IsSiteUp: true},
+		{SiteID: 2, Name: "App", URL: "http://app.example.com", IsSiteUp: true, ParentSiteIDs: []int64{1}},
+		{SiteID: 3, Name: "Static", URL: "http://static.example.com", IsSiteUp: true, ParentSiteIDs: []int64{1}},
+	}
+	statuses := newSiteStatuses(sites)
+	statuses.setUp(1, false)
+	statuses.checkDependents(1, func(url string, timeout int) (string, int, time.Duration, error) {
+		if url == "http://app.example.com" {
+			return "", 502, 0, nil
+		}
+		return "", 200, 0, nil
+	})
+
+	tests := []struct {
+		status     string
+		previous   string
+		dependents string
+	}{
+		{"Down", "Up", "App"},
+		{"Down", "Down", ""},
+		{"Flapping", "Up", ""},
+		{"Up", "Down", "App"},
+	}
+	for _, test := range tests {
+		n := statusNotifier(sites[0], test.status, "Details", notifier.Channels{}, nil)
+		n.PreviousStatus = test.previous
+		_, err := outboxMessages(n, statuses)
+		if err != nil {
+			t.Fatal("Failed to get the outbox messages:", err)
+		}
+		var names []string
+		for _, d := range n.Dependents {
+			names = append(names, d.Name)
+		}
+		if strings.Join(names, ",") != test.dependents {
+			t.Error(test.status, "after", test.previous, "should include", test.dependents, "got", names)
+		}
+	}
+}
+
+// TestIncidentResolvedForDependents tests that the incident channel of a
+// dependent site that was sent the trigger is sent the resolve, even though
+// the site recovered before its parent.
+func TestIncidentResolvedForDependents(t *testing.T) {
+	pagerDuty := database.SiteChannel{ChannelType: database.PagerDutyChannel, Address: "routingkey", IsActive: true}
+	sites := database.Sites{
+		{SiteID: 1, Name: "Load Balancer", URL: "http://lb.example.com", IsSiteUp: true},
+		{SiteID: 2, Name: "App", URL: "http://app.example.com", IsSiteUp: true, ParentSiteIDs: []int64{1},
+			Channels: []database.SiteChannel{pagerDuty}},
+	}
+	statuses := newSiteStatuses(sites)
+	statuses.setUp(1, false)
+	statuses.setUp(2, false)
+	down := statusNotifier(sites[0], "Down", "Details", notifier.Channels{}, nil)
+	down.PreviousStatus = "Up"
+	messages, err := outboxMessages(down, statuses)
+	if err != nil || len(messages) != 1 || messages[0].ChannelType != database.PagerDutyChannel {
+		t.Fatal("The dependent site's incident should be triggered:", messages, err)
+	}
+
+	statuses.setUp(2, true)
+	up := statusNotifier(sites[0], "Up", "Details", notifier.Channels{}, nil)
+	up.PreviousStatus = "Down"
+	messages, err = outboxMessages(up, statuses)
+	if err != nil || len(messages) != 1 || messages[0].Address != "routingkey" {
+		t.Error("The triggered incident should be resolved:", messages, err)
+	}
+}
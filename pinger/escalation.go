@@ -0,0 +1,70 @@
+package pinger
+
+import (
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// escalation tracks how far the current outage of a site has escalated through
+// the tiers of its escalation policy. The first tier is notified when the site
+// goes down and the level is the last tier that has been notified since.
+type escalation struct {
+	tiers []database.EscalationTier
+	level int
+	since time.Time
+}
+
+// newEscalation returns the escalation of the site. If the site is already
+// down, such as after a restart, the tiers that were due since it went down
+// are assumed to have been notified.
+func newEscalation(s database.Site, now time.Time) *escalation {
+	e := escalation{tiers: s.EscalationTiers}
+	if !s.IsSiteUp && !s.LastStatusChange.IsZero() {
+		e.since = s.LastStatusChange
+		for e.level+1 < len(e.tiers) && e.delay(e.level+1) <= now.Sub(e.since) {
+			e.level++
+		}
+	}
+	return &e
+}
+
+// start begins the escalation for an outage that has just been notified.
+func (e *escalation) start(t time.Time) {
+	e.level = 0
+	e.since = t
+}
+
+// due returns the next tier if the outage has lasted for its delay, and
+// records that it has been notified.
+func (e *escalation) due(t time.Time) (database.EscalationTier, bool) {
+	if e.level+1 >= len(e.tiers) || t.Sub(e.since) < e.delay(e.level+1) {
+		return database.EscalationTier{}, false
+	}
+	e.level++
+	return e.tiers[e.level], true
+}
+
+func (e *escalation) delay(level int) time.Duration {
+	return time.Duration(e.tiers[level].DelayMinutes) * time.Minute
+}
+
+// site returns the site with the contacts of the tiers that have been notified
+// in place of its own contacts, or the site as is if it has no escalation
+// policy.
+func (e *escalation) site(s database.Site) database.Site {
+	if len(e.tiers) == 0 {
+		return s
+	}
+	s.Contacts = nil
+	seen := make(map[int64]bool)
+	for _, tier := range e.tiers[:e.level+1] {
+		for _, c := range tier.Contacts {
+			if !seen[c.ContactID] {
+				seen[c.ContactID] = true
+				s.Contacts = append(s.Contacts, c)
+			}
+		}
+	}
+	return s
+}
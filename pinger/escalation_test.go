@@ -0,0 +1,73 @@
+package pinger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+var escalationTiers = []database.EscalationTier{
+	{DelayMinutes: 0, Contacts: []database.Contact{{ContactID: 1, Name: "On Call"}}},
+	{DelayMinutes: 15, Contacts: []database.Contact{{ContactID: 1, Name: "On Call"}, {ContactID: 2, Name: "Lead"}}},
+	{DelayMinutes: 60, Contacts: []database.Contact{{ContactID: 3, Name: "Manager"}}},
+}
+
+func TestEscalation(t *testing.T) {
+	s := database.Site{IsSiteUp: true, EscalationTiers: escalationTiers,
+		Contacts: []database.Contact{{ContactID: 4, Name: "Site Contact"}}}
+	e := newEscalation(s, time.Now())
+	start := time.Date(2016, 3, 1, 22, 0, 0, 0, time.UTC)
+	e.start(start)
+	if contacts := e.site(s).Contacts; len(contacts) != 1 || contacts[0].Name != "On Call" {
+		t.Error("Only the first tier should be notified when the site goes down:", contacts)
+	}
+	if _, ok := e.due(start.Add(14 * time.Minute)); ok {
+		t.Error("Second tier should not be due before its delay.")
+	}
+	if tier, ok := e.due(start.Add(15 * time.Minute)); !ok || len(tier.Contacts) != 2 {
+		t.Error("Second tier should be due after its delay:", tier)
+	}
+	if _, ok := e.due(start.Add(20 * time.Minute)); ok {
+		t.Error("Third tier should not be due before its delay.")
+	}
+	if tier, ok := e.due(start.Add(60 * time.Minute)); !ok || tier.Contacts[0].Name != "Manager" {
+		t.Error("Third tier should be due after its delay:", tier)
+	}
+	if _, ok := e.due(start.Add(120 * time.Minute)); ok {
+		t.Error("No tier should be due after the last one.")
+	}
+	// The recovery goes to each contact that was escalated to once.
+	if contacts := e.site(s).Contacts; len(contacts) != 3 {
+		t.Error("All of the escalated contacts should be notified:", contacts)
+	}
+	e.start(start.Add(180 * time.Minute))
+	if e.level != 0 {
+		t.Error("A new outage should start at the first tier.")
+	}
+}
+
+func TestEscalationNoPolicy(t *testing.T) {
+	s := database.Site{IsSiteUp: true, Contacts: []database.Contact{{ContactID: 4, Name: "Site Contact"}}}
+	e := newEscalation(s, time.Now())
+	e.start(time.Now())
+	if _, ok := e.due(time.Now().Add(24 * time.Hour)); ok {
+		t.Error("Nothing should be escalated without a policy.")
+	}
+	if contacts := e.site(s).Contacts; len(contacts) != 1 || contacts[0].Name != "Site Contact" {
+		t.Error("The site's own contacts should be notified without a policy:", contacts)
+	}
+}
+
+func TestEscalationAlreadyDown(t *testing.T) {
+	now := time.Date(2016, 3, 1, 22, 0, 0, 0, time.UTC)
+	s := database.Site{IsSiteUp: false, LastStatusChange: now.Add(-20 * time.Minute),
+		EscalationTiers: escalationTiers}
+	e := newEscalation(s, now)
+	if e.level != 1 {
+		t.Error("Escalation should resume at the tier that was due:", e.level)
+	}
+	if tier, ok := e.due(now.Add(40 * time.Minute)); !ok || tier.Contacts[0].Name != "Manager" {
+		t.Error("Escalation should continue from the outage start:", tier)
+	}
+}
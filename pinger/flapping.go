@@ -0,0 +1,77 @@
+package pinger
+
+import (
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+)
+
+// The flapping detection used if it isn't set in the config.
+const (
+	defaultFlappingTransitions   = 5
+	defaultFlappingWindowMinutes = 30
+)
+
+// flapDetector tracks the recent up/down transitions of a site. A site that
+// changes state more than the limit within the window is flapping until it
+// has had no transitions for a whole window.
+type flapDetector struct {
+	transitions []time.Time
+	limit       int
+	window      time.Duration
+	isFlapping  bool
+}
+
+// newFlapDetector returns a detector using the limits from the config. A site
+// that was already flapping has to be stable for a whole window from now.
+func newFlapDetector(isFlapping bool, now time.Time) *flapDetector {
+	limit := config.Settings.Flapping.Transitions
+	if limit <= 0 {
+		limit = defaultFlappingTransitions
+	}
+	windowMinutes := config.Settings.Flapping.WindowMinutes
+	if windowMinutes <= 0 {
+		windowMinutes = defaultFlappingWindowMinutes
+	}
+	f := flapDetector{limit: limit, window: time.Duration(windowMinutes) * time.Minute,
+		isFlapping: isFlapping}
+	if isFlapping {
+		f.transitions = []time.Time{now}
+	}
+	return &f
+}
+
+// transition records a change of state and returns true if the site has just
+// started flapping.
+func (f *flapDetector) transition(t time.Time) bool {
+	f.transitions = append(f.transitions, t)
+	f.prune(t)
+	if !f.isFlapping && len(f.transitions) > f.limit {
+		f.isFlapping = true
+		return true
+	}
+	return false
+}
+
+// stabilized returns true if the site was flapping and has now had no
+// transitions for a whole window.
+func (f *flapDetector) stabilized(t time.Time) bool {
+	if !f.isFlapping {
+		return false
+	}
+	f.prune(t)
+	if len(f.transitions) == 0 {
+		f.isFlapping = false
+		return true
+	}
+	return false
+}
+
+// prune drops the transitions that are older than the window.
+func (f *flapDetector) prune(t time.Time) {
+	i := 0
+	for i < len(f.transitions) && t.Sub(f.transitions[i]) >= f.window {
+		i++
+	}
+	f.transitions = f.transitions[i:]
+}
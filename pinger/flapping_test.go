@@ -0,0 +1,50 @@
+package pinger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFlapDetector(t *testing.T) {
+	f := &flapDetector{limit: 3, window: 10 * time.Minute}
+	start := time.Date(2016, 3, 1, 22, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if f.transition(start.Add(time.Duration(i) * time.Minute)) {
+			t.Fatal("Site should not be flapping at the limit.")
+		}
+	}
+	if !f.transition(start.Add(3 * time.Minute)) {
+		t.Fatal("Site should start flapping above the limit.")
+	}
+	if f.transition(start.Add(4 * time.Minute)) {
+		t.Error("Flapping should only be reported when it starts.")
+	}
+	if f.stabilized(start.Add(13 * time.Minute)) {
+		t.Error("Site should not be stable with transitions in the window.")
+	}
+	if !f.stabilized(start.Add(14*time.Minute)) || f.isFlapping {
+		t.Error("Site should be stable once a window passes without transitions.")
+	}
+}
+
+func TestFlapDetectorSpreadOut(t *testing.T) {
+	f := &flapDetector{limit: 2, window: 10 * time.Minute}
+	start := time.Date(2016, 3, 1, 22, 0, 0, 0, time.UTC)
+	// Transitions further apart than the window never add up to flapping.
+	for i := 0; i < 6; i++ {
+		if f.transition(start.Add(time.Duration(i) * 6 * time.Minute)) {
+			t.Fatal("Site should not be flapping when the transitions are spread out.")
+		}
+	}
+}
+
+func TestNewFlapDetectorAlreadyFlapping(t *testing.T) {
+	now := time.Now()
+	f := newFlapDetector(true, now)
+	if f.limit != defaultFlappingTransitions || f.window != defaultFlappingWindowMinutes*time.Minute {
+		t.Error("Flapping detection should use the defaults when not configured.")
+	}
+	if f.stabilized(now.Add(time.Minute)) {
+		t.Error("A site that was flapping should have to be stable for a whole window.")
+	}
+}
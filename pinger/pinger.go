@@ -26,11 +26,12 @@ type Pinger struct {
 	Sites      database.Sites
 	DB         *sql.DB
 	RequestURL URLRequester
-	SendEmail  notifier.EmailSender
-	SendSms    notifier.SmsSender
+	Channels   notifier.Channels
+	Templates  notifier.Templates
 	getSites   SitesGetter
 	wg         sync.WaitGroup
 	stopChan   chan struct{}
+	statuses   *siteStatuses
 }
 
 // SitesGetter defines a function to get the sites from DB or mock.
@@ -50,7 +51,7 @@ func (e InternetAccessError) Error() string {
 
 // NewPinger returns a new Pinger object
 func NewPinger(db *sql.DB, getSites SitesGetter, requestURL URLRequester,
-	sendEmail notifier.EmailSender, sendSms notifier.SmsSender) *Pinger {
+	channels notifier.Channels) *Pinger {
 	var sites database.Sites
 	var err error
 
@@ -65,8 +66,8 @@ func NewPinger(db *sql.DB, getSites SitesGetter, requestURL URLRequester,
 		log.Println("SITE:", s.Name+",", s.URL)
 	}
 
-	p := Pinger{Sites: sites, DB: db, RequestURL: requestURL, SendEmail: sendEmail,
-		SendSms: sendSms, getSites: getSites}
+	p := Pinger{Sites: sites, DB: db, RequestURL: requestURL, Channels: channels,
+		Templates: getTemplates(db), getSites: getSites}
 	return &p
 }
 
@@ -75,11 +76,12 @@ func (p *Pinger) Start() {
 	log.Println("Requesting start of pingers...")
 	siteCount := 0
 	p.stopChan = make(chan struct{})
+	p.statuses = newSiteStatuses(p.Sites)
 	for _, s := range p.Sites {
 		//log.Println(s)
 		if s.URL != "" {
 			p.wg.Add(1)
-			go ping(s, p.DB, p.RequestURL, p.SendEmail, p.SendSms, &p.wg, p.stopChan)
+			go ping(s, p.DB, p.RequestURL, p.Channels, p.Templates, p.statuses, &p.wg, p.stopChan)
 			siteCount++
 		}
 	}
@@ -101,9 +103,10 @@ func (p *Pinger) Stop() {
 	log.Println("All of the pingers have stopped.")
 }
 
-// UpdateSiteSettings stops the pinger, regets the sites for changes in settings,
-// and restarts the pinger. There could potentially be race conditions if multiple
-// web controllers were trying to update it so a mutex is used to protect it.
+// UpdateSiteSettings stops the pinger, regets the sites and the notification
+// templates for changes in settings, and restarts the pinger. There could
+// potentially be race conditions if multiple web controllers were trying to
+// update it so a mutex is used to protect it.
 func (p *Pinger) UpdateSiteSettings() error {
 	// Lock to avoid race conditions since this is usually called from the website.
 	mu.Lock()
@@ -117,18 +120,51 @@ func (p *Pinger) UpdateSiteSettings() error {
 		return err
 	}
 	p.Sites = sites
+	p.Templates = getTemplates(p.DB)
+	return nil
+}
+
+// Acknowledge records that someone is handling the current outage of the site,
+// which stops its reminders and escalation until the site's status changes.
+// The acknowledgement is saved to the site so that it survives a restart.
+func (p *Pinger) Acknowledge(site *database.Site, acknowledgedBy string, acknowledgedAt time.Time) error {
+	err := site.AcknowledgeSite(p.DB, acknowledgedBy, acknowledgedAt)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for i := range p.Sites {
+		if p.Sites[i].SiteID == site.SiteID {
+			p.Sites[i].AcknowledgedBy = acknowledgedBy
+			p.Sites[i].AcknowledgedAt = acknowledgedAt
+		}
+	}
+	if p.statuses != nil {
+		p.statuses.setAcknowledged(site.SiteID, true)
+	}
 	return nil
 }
 
 // ping does the actual pinging of the site and calls the notifications
 func ping(s database.Site, db *sql.DB, requestURL URLRequester,
-	sendEmail notifier.EmailSender, sendSms notifier.SmsSender, wg *sync.WaitGroup, stop chan struct{}) {
+	channels notifier.Channels, templates notifier.Templates, statuses *siteStatuses,
+	wg *sync.WaitGroup, stop chan struct{}) {
 	defer wg.Done()
 	// Initialize the previous state of site to the database value. On site creation will initialize to true.
 	siteWasUp := s.IsSiteUp
+	// The time of the last status change gives the duration of an outage.
+	lastStatusChange := s.LastStatusChange
+	flapping := newFlapDetector(s.IsFlapping, time.Now())
+	reminders := newReminderSchedule(s, time.Now())
+	esc := newEscalation(s, time.Now())
+	call := newVoiceCall(s, time.Now())
+	degraded := newDegradedCheck()
+	certificate := newCertificateCheck(s.SiteID)
 	var statusChange bool
 	var partialDetails string
 	var partialSubject string
+	var status string
 	for {
 		// initialize statusChange to false and only notify on change of siteWasUp status
 		statusChange = false
@@ -144,66 +180,51 @@ func ping(s database.Site, db *sql.DB, requestURL URLRequester,
 			log.Println(s.Name, "Paused")
 			continue
 		}
-		bodyContent, statusCode, responseTime, err := requestURL(s.URL, s.TimeoutSeconds)
+		bodyContent, statusCode, responseTime, steps, err := checkSite(s, requestURL)
 		log.Println(s.Name, "Pinged")
 		// Setup ping information for recording.
-		p := database.Ping{SiteID: s.SiteID, TimeRequest: time.Now()}
-		if err != nil {
-			// Check if the error is due to the Internet not being Accessible
-			if _, ok := err.(InternetAccessError); ok {
-				log.Println(s.Name, "Unable to determine site status -", err)
-				continue
-			}
-			log.Println(s.Name, "Error", err)
-			if siteWasUp {
-				statusChange = true
-				partialSubject = "Site is Down"
-				partialDetails = "Site is down, Error is " + err.Error()
-			}
-			siteWasUp = false
-
-		} else if statusCode < 200 || statusCode > 299 { // Check if the status code is in the 2xx range.
-			log.Println(s.Name, "Error - HTTP Status Code is", statusCode)
-			if siteWasUp {
-				statusChange = true
-				partialSubject = "Site is Down"
-				partialDetails = "Site is down, HTTP Status Code is " + strconv.Itoa(statusCode) + "."
-			}
-			siteWasUp = false
-
-		} else {
-			siteUp := true
-			// if the site settings require check the content.
-			if siteUp && s.ContentExpected != "" && !strings.Contains(bodyContent, s.ContentExpected) {
-				siteUp = false
-				log.Println(s.Name, "Error - required body content missing: ", s.ContentExpected)
-				if siteWasUp {
-					statusChange = true
-					partialSubject = "Site is Down"
-					partialDetails = "Site is Down, required body content missing: " + s.ContentExpected + "."
-				}
-			}
-			if siteUp && s.ContentUnexpected != "" && strings.Contains(bodyContent, s.ContentUnexpected) {
-				siteUp = false
-				log.Println(s.Name, "Error - body content content has excluded content: ", s.ContentUnexpected)
-				if siteWasUp {
-					statusChange = true
-					partialSubject = "Site is Down"
-					partialDetails = "Site is Down, body content content has excluded content: " + s.ContentUnexpected + "."
-				}
-			}
-			if siteUp && !siteWasUp {
-				statusChange = true
-				partialSubject = "Site is Up"
-				partialDetails = fmt.Sprintf("Site is now up, response time was %v.", responseTime)
-				siteWasUp = true
-			}
-			siteWasUp = siteUp
+		p := database.Ping{SiteID: s.SiteID, TimeRequest: time.Now(), Steps: steps}
+		// Check if the error is due to the Internet not being Accessible
+		if _, ok := err.(InternetAccessError); ok {
+			log.Println(s.Name, "Unable to determine site status -", err)
+			continue
+		}
+		downDetails := evaluateCheck(s, bodyContent, statusCode, err)
+		siteUp := downDetails == ""
+		statuses.setUp(s.SiteID, siteUp)
+		if siteUp && !siteWasUp {
+			statusChange = true
+			status = "Up"
+			partialSubject = "Site is Up"
+			partialDetails = fmt.Sprintf("Site is now up, response time was %v.", responseTime)
+		} else if !siteUp && siteWasUp {
+			statusChange = true
+			status = "Down"
+			partialSubject = "Site is Down"
+			partialDetails = downDetails
 		}
 		// Save the ping details
 		p.Duration = int(responseTime.Nanoseconds() / 1e6)
 		p.HTTPStatusCode = statusCode
-		p.SiteDown = !siteWasUp
+		p.SiteDown = !siteUp
+		if s.InMaintenance(p.TimeRequest) {
+			// During a maintenance window the ping is recorded and flagged, but the
+			// status is held so no notifications are sent. If the site is still down
+			// when the window ends it will be notified then.
+			p.Maintenance = true
+			if statusChange {
+				log.Println(s.Name, "In maintenance, notification suppressed:", partialSubject)
+			}
+			statusChange = false
+		} else if !siteUp {
+			// When a parent site is down the site is unreachable rather than down
+			// itself. Its status is held since the parent's alert covers it, and
+			// if it's still down once the parent is back up it will be notified then.
+			if parents := statuses.downParents(s, requestURL); len(parents) > 0 {
+				log.Println(s.Name, "Unreachable (parent down):", strings.Join(parents, ", "))
+				statusChange = false
+			}
+		}
 		// Save ping to db.
 		err = p.CreatePing(db)
 		if err != nil {
@@ -211,20 +232,260 @@ func ping(s database.Site, db *sql.DB, requestURL URLRequester,
 		}
 		// Do the notifications if applicable
 		if statusChange {
-			// Update the site Status
-			err = s.UpdateSiteStatus(db, siteWasUp)
+			siteWasUp = siteUp
+			var downtime time.Duration
+			// The outage starts now or, on recovery, when the site went down.
+			outageStart := p.TimeRequest
+			if siteUp {
+				outageStart = lastStatusChange
+				if !lastStatusChange.IsZero() {
+					downtime = p.TimeRequest.Sub(lastStatusChange)
+				}
+			}
+			lastStatusChange = p.TimeRequest
+			// The recovery is sent to every tier the outage escalated to.
+			notifySite := esc.site(s)
+			esc.start(p.TimeRequest)
+			if !siteUp {
+				notifySite = esc.site(s)
+			}
+			reminders.start(p.TimeRequest)
+			call.start(p.TimeRequest)
+			statuses.setAcknowledged(s.SiteID, false)
+			var n *notifier.Notifier
+			if flapping.transition(p.TimeRequest) {
+				err = s.UpdateSiteFlapping(db, true)
+				if err != nil {
+					log.Println("Error updating site flapping:", err)
+				}
+				n = statusNotifier(notifySite, "Flapping", fmt.Sprintf("Site changed between up and down more than %d times in %d minutes, "+
+					"further notifications are held until it's stable. Site is now %s.",
+					flapping.limit, int(flapping.window.Minutes()), upOrDown(siteWasUp)), channels, templates)
+				n.PreviousStatus = previousStatus(siteUp)
+				n.Ping = p
+			} else if flapping.isFlapping {
+				log.Println(s.Name, "Flapping, notification suppressed:", partialSubject)
+			} else {
+				n = statusNotifier(notifySite, status, partialDetails, channels, templates)
+				n.PreviousStatus = previousStatus(siteUp)
+				n.Downtime = downtime
+				n.OutageStart = outageStart
+				n.Ping = p
+				if !siteUp {
+					n.Error = downDetails
+					n.AddAcknowledgeLink(p.TimeRequest)
+				}
+			}
+			// Update the site Status, the notification is saved to the outbox
+			// with it so that it isn't lost if it can't be sent right away.
+			var messages database.OutboxMessages
+			var messagesErr error
+			if n != nil {
+				messages, messagesErr = outboxMessages(n, statuses)
+			}
+			err = s.UpdateSiteStatus(db, siteWasUp, p.TimeRequest, messages)
 			if err != nil {
 				log.Println("Error updating site status:", err)
 			}
-			// Do the notifications if applicable
-			subject := s.Name + ": " + partialSubject
-			details := s.Name + " at " + s.URL + ": " + partialDetails
-			log.Println("Will notify status change for", s.Name+":", details)
+			if n != nil {
+				if err != nil || messagesErr != nil {
+					// Send it directly since it's not in the outbox.
+					n.DB = db
+					n.Notify()
+				} else {
+					notifier.WakeOutbox()
+				}
+				if !siteUp {
+					statuses.checkDependents(s.SiteID, requestURL)
+				}
+			}
+		} else if flapping.stabilized(p.TimeRequest) {
+			err = s.UpdateSiteFlapping(db, false)
+			if err != nil {
+				log.Println("Error updating site flapping:", err)
+			}
+			reminders.start(p.TimeRequest)
+			esc.start(p.TimeRequest)
+			call.start(p.TimeRequest)
+			n := statusNotifier(esc.site(s), "Stable", "Site has stopped flapping and is "+upOrDown(siteWasUp)+".", channels, templates)
+			n.PreviousStatus = "Flapping"
+			n.Ping = p
+			notify(db, n, statuses)
+		} else if !siteWasUp && !p.Maintenance && !flapping.isFlapping &&
+			!statuses.isAcknowledged(s.SiteID) {
+			downtime := p.TimeRequest.Sub(lastStatusChange)
+			partialDetails = fmt.Sprintf("Site is still down after %v.", downtime.Round(time.Second))
+			if !siteUp {
+				partialDetails += " " + downDetails
+			}
+			if tier, ok := esc.due(p.TimeRequest); ok {
+				// Escalate to the next tier of contacts only, the site's own
+				// channels were already notified of the outage.
+				escalated := s
+				escalated.Contacts = tier.Contacts
+				escalated.Channels = nil
+				n := statusNotifier(escalated, "Down", partialDetails, channels, templates)
+				n.Subject = s.Name + ": Site is still Down (escalated)"
+				n.PreviousStatus = "Down"
+				n.Downtime = downtime
+				n.Error = downDetails
+				n.OutageStart = lastStatusChange
+				n.Ping = p
+				n.AddAcknowledgeLink(p.TimeRequest)
+				notify(db, n, statuses)
+			} else if reminders.due(p.TimeRequest) {
+				// Remind the contacts while the site stays down.
+				n := statusNotifier(esc.site(s), "Down", partialDetails, channels, templates)
+				n.Subject = s.Name + ": Site is still Down"
+				n.PreviousStatus = "Down"
+				n.Downtime = downtime
+				n.Reminder = reminders.count
+				n.Error = downDetails
+				n.OutageStart = lastStatusChange
+				n.Ping = p
+				n.AddAcknowledgeLink(p.TimeRequest)
+				notify(db, n, statuses)
+			}
+			if s.IsCritical && call.due(p.TimeRequest) {
+				// Call the contacts notified so far since the outage of the
+				// critical site still hasn't been acknowledged.
+				n := statusNotifier(esc.site(s), "Down", partialDetails, channels, templates)
+				n.Subject = s.Name + ": Site is still Down"
+				n.PreviousStatus = "Down"
+				n.Downtime = downtime
+				n.Error = downDetails
+				n.OutageStart = lastStatusChange
+				n.Ping = p
+				n.Call = true
+				notify(db, n, statuses)
+			}
+		}
+		// Warn while the site is up that it's slow or its certificate expires soon.
+		if !siteUp || !siteWasUp || p.Maintenance || flapping.isFlapping {
+			degraded.reset()
+		} else {
+			if degraded.response(responseTime) {
+				n := statusNotifier(esc.site(s), "Degraded", fmt.Sprintf("Site is slow, response time was %v over the %v threshold.",
+					responseTime, degraded.threshold), channels, templates)
+				n.PreviousStatus = "Up"
+				n.Ping = p
+				notify(db, n, statuses)
+			}
+			if isHTTPS(s.URL) && certificate.due(p.TimeRequest) {
+				// The certificate is read apart from the pings so that a slow
+				// TLS handshake doesn't hold them up.
+				wg.Add(1)
+				go checkCertificate(esc.site(s), p, certificate, db, channels, templates, statuses, wg)
+			}
+		}
+	}
+}
+
+// statusNotifier returns the notifier for the status of the site.
+func statusNotifier(s database.Site, status string, partialDetails string,
+	channels notifier.Channels, templates notifier.Templates) *notifier.Notifier {
+	subject := s.Name + ": Site is " + status
+	details := s.Name + " at " + s.URL + ": " + partialDetails
+	n := notifier.NewNotifier(s, details, subject, channels)
+	n.Status = status
+	n.Detail = partialDetails
+	n.Templates = templates
+	return n
+}
+
+// notify sends the notification about the status of the site to its contacts
+// by way of the outbox. If it can't be saved to the outbox it's sent directly.
+func notify(db *sql.DB, n *notifier.Notifier, statuses *siteStatuses) {
+	messages, err := outboxMessages(n, statuses)
+	if err == nil {
+		err = database.EnqueueOutboxMessages(db, messages)
+		if err != nil {
+			log.Println("Error saving the notification to the outbox:", err)
+		}
+	}
+	if err != nil {
+		n.DB = db
+		n.Notify()
+		return
+	}
+	notifier.WakeOutbox()
+}
 
-			n := notifier.NewNotifier(s, details, subject, sendEmail, sendSms)
-			n.Notify()
+// outboxMessages returns the outbox messages of the notification. The sites
+// held down by the outage of the site are covered by its alerts of going down
+// and back up, its other notifications are only for its own contacts. The
+// incident channels that were sent the trigger are always sent the resolve,
+// even if the dependent site they're for has recovered since.
+func outboxMessages(n *notifier.Notifier, statuses *siteStatuses) (database.OutboxMessages, error) {
+	log.Println("Will notify status change for", n.Site.Name+":", n.Message)
+	if event := n.Event(); !n.Call && (event == database.DownEvent || event == database.UpEvent) {
+		n.AddDependents(statuses.dependents(n.Site.SiteID))
+		if event == database.DownEvent {
+			statuses.setIncidents(n.Site.SiteID, n.Site.Channels)
+		} else {
+			n.AddSiteChannels(statuses.takeIncidents(n.Site.SiteID))
 		}
 	}
+	messages, err := n.OutboxMessages()
+	if err != nil {
+		log.Println("Error preparing the notification for the outbox:", err)
+	}
+	return messages, err
+}
+
+// previousStatus returns the status the site changed from.
+func previousStatus(siteUp bool) string {
+	if siteUp {
+		return "Down"
+	}
+	return "Up"
+}
+
+func upOrDown(siteUp bool) string {
+	if siteUp {
+		return "up"
+	}
+	return "down"
+}
+
+// evaluateCheck decides whether the site is up from the result of its check.
+// The details of the failure are returned if it's down, otherwise an empty string.
+// The status codes of a transaction are checked by its steps, which can expect
+// a redirect or an error status.
+func evaluateCheck(s database.Site, bodyContent string, statusCode int, err error) string {
+	if err != nil {
+		log.Println(s.Name, "Error", err)
+		return "Site is down, Error is " + err.Error()
+	}
+	// Check if the status code is in the 2xx range.
+	if s.TransactionSteps == "" && (statusCode < 200 || statusCode > 299) {
+		log.Println(s.Name, "Error - HTTP Status Code is", statusCode)
+		return "Site is down, HTTP Status Code is " + strconv.Itoa(statusCode) + "."
+	}
+	// if the site settings require check the content.
+	if s.ContentExpected != "" && !strings.Contains(bodyContent, s.ContentExpected) {
+		log.Println(s.Name, "Error - required body content missing: ", s.ContentExpected)
+		return "Site is Down, required body content missing: " + s.ContentExpected + "."
+	}
+	if s.ContentUnexpected != "" && strings.Contains(bodyContent, s.ContentUnexpected) {
+		log.Println(s.Name, "Error - body content content has excluded content: ", s.ContentUnexpected)
+		return "Site is Down, body content content has excluded content: " + s.ContentUnexpected + "."
+	}
+	return ""
+}
+
+// checkSite runs the check that fits the site: the scripted steps of a
+// transaction, a protocol conversation for mail and file transfer servers,
+// otherwise an HTTP request.
+func checkSite(s database.Site, requestURL URLRequester) (string, int, time.Duration, []database.PingStep, error) {
+	if s.TransactionSteps != "" {
+		return RequestTransaction(s.URL, s.TransactionSteps, s.TimeoutSeconds)
+	}
+	if IsProtocolURL(s.URL) {
+		return RequestProtocol(s.URL, s.TimeoutSeconds)
+	}
+	bodyContent, statusCode, responseTime, err := requestURL(s.URL, s.TimeoutSeconds)
+	return bodyContent, statusCode, responseTime, nil, err
 }
 
 // RequestURL provides the implementation of the URLRequester type for runtime usage.
@@ -255,6 +516,17 @@ func RequestURL(url string, timeout int) (string, int, time.Duration, error) {
 	return string(content), res.StatusCode, elapsedTime, nil
 }
 
+// getTemplates gets the custom notification templates, the notifications keep
+// the default wording if they can't be read.
+func getTemplates(db *sql.DB) notifier.Templates {
+	var templates database.NotificationTemplates
+	err := templates.GetNotificationTemplates(db)
+	if err != nil {
+		log.Println("Failed to get the notification templates. ", err)
+	}
+	return notifier.Templates(templates)
+}
+
 // GetSites provides the implementation of the SitesGetter type for runtime usage.
 func GetSites(db *sql.DB) (database.Sites, error) {
 	var sites database.Sites
@@ -263,6 +535,26 @@ func GetSites(db *sql.DB) (database.Sites, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Get the maintenance windows and parent sites so the pingers can suppress
+	// the notifications, and the site's own channels and escalation tiers to notify.
+	for i := range sites {
+		err = sites[i].GetSiteMaintenanceWindows(db)
+		if err != nil {
+			return nil, err
+		}
+		err = sites[i].GetSiteParents(db)
+		if err != nil {
+			return nil, err
+		}
+		err = sites[i].GetSiteChannels(db)
+		if err != nil {
+			return nil, err
+		}
+		err = sites[i].GetSiteEscalationTiers(db)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return sites, nil
 }
 
@@ -5,6 +5,10 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/turnkey-commerce/go-ping-sites/config"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
 )
 
 type statusHandler int
@@ -93,3 +97,41 @@ func TestRequestInternetAccessError(t *testing.T) {
 		t.Error("Bad URL and test sites should identify as Internet access error.")
 	}
 }
+
+// TestNotifyWithoutOutbox tests that a notification that can't be saved to the
+// outbox is sent directly and its delivery is still recorded.
+func TestNotifyWithoutOutbox(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+	saved := config.Settings.Webhook.URL
+	defer func() { config.Settings.Webhook.URL = saved }()
+	config.Settings.Webhook.URL = ""
+	_, err = db.Exec("DROP TABLE OutboxMessages")
+	if err != nil {
+		t.Fatal("Failed to drop the outbox:", err)
+	}
+
+	email := &notifier.ChannelMock{}
+	site := database.Site{SiteID: 1, Name: "Test", URL: "http://www.example.com",
+		Contacts: []database.Contact{{Name: "Joe Contact",
+			Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "joe@test.com", IsActive: true}}}}}
+	n := statusNotifier(site, "Down", "Site is down.", notifier.Channels{database.EmailChannel: email}, nil)
+	n.PreviousStatus = "Up"
+	notify(db, n, newSiteStatuses(database.Sites{site}))
+	if len(email.Sent) != 1 {
+		t.Fatal("The notification should be sent directly:", email.Sent)
+	}
+
+	var attempts database.DeliveryAttempts
+	err = attempts.GetDeliveryAttempts(db, database.DeliveryFilter{Limit: 10})
+	if err != nil {
+		t.Fatal("Failed to get delivery attempts:", err)
+	}
+	if len(attempts) != 1 || !attempts[0].Success || attempts[0].OutboxMessageID != 0 ||
+		attempts[0].Recipient != "Joe Contact" || attempts[0].Event != database.DownEvent {
+		t.Error("The direct send should be logged:", attempts)
+	}
+}
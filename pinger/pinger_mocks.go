@@ -3,25 +3,40 @@ package pinger
 import (
 	"database/sql"
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/turnkey-commerce/go-ping-sites/database"
 )
 
-// hitCount is used to vary the outcome of the mock RequestURL
-var hitCount int
+// hitCount is used to vary the outcome of the mock RequestURL, it's counted
+// under the mutex since the sites are pinged concurrently.
+var (
+	hitCount   int
+	hitCountMu sync.Mutex
+)
 
 // ResetHitCount sets the hitcount back to 0 for the tests.
 func ResetHitCount() {
+	hitCountMu.Lock()
+	defer hitCountMu.Unlock()
 	hitCount = 0
 }
 
+// hit counts a request and returns the count.
+func hit() int {
+	hitCountMu.Lock()
+	defer hitCountMu.Unlock()
+	hitCount++
+	return hitCount
+}
+
 // RequestURLMock is a mock of the URL request that pings the site.
 func RequestURLMock(url string, timeout int) (string, int, time.Duration, error) {
 	var responseTime = 300 * time.Millisecond
-	hitCount++
 	// The hitCount allows to vary the response of the request.
-	if url == "http://www.github.com" && hitCount < 4 {
+	count := hit()
+	if url == "http://www.github.com" && count < 4 {
 		return "", 0, responseTime, errors.New("(Client.Timeout exceeded while awaiting headers)")
 	} else if url == "http://www.github.com" {
 		return "Hello", 200, responseTime, nil
@@ -29,6 +44,16 @@ func RequestURLMock(url string, timeout int) (string, int, time.Duration, error)
 	return "Hello", 300, responseTime, nil
 }
 
+// RequestURLFlappingMock is a mock of the URL request for a site that alternates
+// between up and down on every request.
+func RequestURLFlappingMock(url string, timeout int) (string, int, time.Duration, error) {
+	var responseTime = 300 * time.Millisecond
+	if hit()%2 == 1 {
+		return "", 500, responseTime, nil
+	}
+	return "Good response text", 200, responseTime, nil
+}
+
 // RequestURLContentMock is a mock of the URL requests for checking content.
 func RequestURLContentMock(url string, timeout int) (string, int, time.Duration, error) {
 	var responseTime = 300 * time.Millisecond
@@ -60,10 +85,12 @@ func GetSitesMock(db *sql.DB) (database.Sites, error) {
 	s3 := database.Site{Name: "Test 3", IsActive: false, URL: "http://www.test.com",
 		PingIntervalSeconds: 2, TimeoutSeconds: 2}
 	// Contacts are deliberately set as false for SmsActive and EmailActive so as not to trigger Notifier
-	c1 := database.Contact{Name: "Joe Contact", EmailAddress: "joe@test.com", SmsNumber: "5125551212",
-		SmsActive: false, EmailActive: false}
-	c2 := database.Contact{Name: "Jack Contact", EmailAddress: "jack@test.com", SmsNumber: "5125551213",
-		SmsActive: false, EmailActive: false}
+	c1 := database.Contact{Name: "Joe Contact",
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "joe@test.com"},
+			{ChannelType: database.SmsChannel, Address: "5125551212"}}}
+	c2 := database.Contact{Name: "Jack Contact",
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "jack@test.com"},
+			{ChannelType: database.SmsChannel, Address: "5125551213"}}}
 	// Add the contacts to the sites
 	s1.Contacts = append(s1.Contacts, c1, c2)
 	s2.Contacts = append(s2.Contacts, c1)
@@ -93,6 +120,27 @@ func GetSitesContentMock(db *sql.DB) (database.Sites, error) {
 	return sites, nil
 }
 
+// GetSitesDependentMock is a mock of the SQL query to get the sites for testing
+// a site that depends on a parent site.
+func GetSitesDependentMock(db *sql.DB) (database.Sites, error) {
+	var sites database.Sites
+	// Create the parent site, pinged after the site that depends on it fails.
+	s1 := database.Site{SiteID: 1, Name: "Load Balancer", IsActive: true, URL: "http://lb.example.com",
+		PingIntervalSeconds: 2, TimeoutSeconds: 1, IsSiteUp: true}
+	// Create the site that depends on it.
+	s2 := database.Site{SiteID: 2, Name: "App", IsActive: true, URL: "http://app.example.com",
+		PingIntervalSeconds: 1, TimeoutSeconds: 1, IsSiteUp: true, ParentSiteIDs: []int64{1}}
+	c1 := database.Contact{ContactID: 1, Name: "Joe Contact",
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "joe@test.com", IsActive: true}}}
+	c2 := database.Contact{ContactID: 2, Name: "Jack Contact",
+		Channels: []database.ContactChannel{{ChannelType: database.EmailChannel, Address: "jack@test.com", IsActive: true}}}
+	s1.Contacts = append(s1.Contacts, c1)
+	s2.Contacts = append(s2.Contacts, c1, c2)
+
+	sites = append(sites, s1, s2)
+	return sites, nil
+}
+
 // GetEmptySitesMock is a mock of the SQL query to get the sites for pinging
 // In this case the method returns an empty list of sites.
 func GetEmptySitesMock(db *sql.DB) (database.Sites, error) {
@@ -2,11 +2,16 @@ package pinger_test
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
 	_ "github.com/erikstmartin/go-testdb"
+	"github.com/turnkey-commerce/go-ping-sites/config"
 	"github.com/turnkey-commerce/go-ping-sites/database"
 	"github.com/turnkey-commerce/go-ping-sites/notifier"
 	"github.com/turnkey-commerce/go-ping-sites/pinger"
@@ -17,7 +22,7 @@ func TestNewPinger(t *testing.T) {
 	db, _ := sql.Open("testdb", "")
 	pinger.CreatePingerLog("", true)
 	p := pinger.NewPinger(db, pinger.GetSitesMock, pinger.RequestURLMock,
-		notifier.SendEmailMock, notifier.SendSmsMock)
+		notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
 
 	if len(p.Sites) != 3 {
 		t.Fatal("Incorrect number of sites returned in new pinger.")
@@ -45,7 +50,7 @@ func TestStartEmptySitesPinger(t *testing.T) {
 	pinger.CreatePingerLog("", true)
 	pinger.ResetHitCount()
 	p := pinger.NewPinger(db, pinger.GetEmptySitesMock, pinger.RequestURLMock,
-		notifier.SendEmailMock, notifier.SendSmsMock)
+		notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
 	p.Start()
 
 	results, err := pinger.GetLogContent()
@@ -65,7 +70,7 @@ func TestStartPingerErrorWithGetSites(t *testing.T) {
 	pinger.CreatePingerLog("", true)
 	pinger.ResetHitCount()
 	p := pinger.NewPinger(db, pinger.GetSitesErrorMock, pinger.RequestURLMock,
-		notifier.SendEmailMock, notifier.SendSmsMock)
+		notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
 	p.Start()
 
 	results, err := pinger.GetLogContent()
@@ -84,7 +89,7 @@ func TestContentCheck(t *testing.T) {
 	db, _ := sql.Open("testdb", "")
 	pinger.CreatePingerLog("", true)
 	p := pinger.NewPinger(db, pinger.GetSitesContentMock, pinger.RequestURLContentMock,
-		notifier.SendEmailMock, notifier.SendSmsMock)
+		notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
 	p.Start()
 	// Sleep to allow running the tests before stopping.
 	time.Sleep(2 * time.Second)
@@ -111,7 +116,7 @@ func TestStartAndRestartPinger(t *testing.T) {
 	pinger.CreatePingerLog("", true)
 	pinger.ResetHitCount()
 	p := pinger.NewPinger(db, pinger.GetSitesMock, pinger.RequestURLMock,
-		notifier.SendEmailMock, notifier.SendSmsMock)
+		notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
 	p.Start()
 	// Sleep to allow running the tests before stopping.
 	time.Sleep(5 * time.Second)
@@ -141,6 +146,69 @@ func TestStartAndRestartPinger(t *testing.T) {
 	}
 }
 
+// TestDependentSite tests that a site with its parent down is reported as
+// unreachable and covered by the parent's notification.
+func TestDependentSite(t *testing.T) {
+	// Fake db for testing.
+	db, _ := sql.Open("testdb", "")
+	pinger.CreatePingerLog("", true)
+	p := pinger.NewPinger(db, pinger.GetSitesDependentMock, pinger.RequestURLMock,
+		notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
+	p.Start()
+	// Sleep to allow running the tests before stopping.
+	time.Sleep(3 * time.Second)
+	p.Stop()
+
+	results, err := pinger.GetLogContent()
+	if err != nil {
+		t.Fatal("Failed to get log results.", err)
+	}
+
+	if !strings.Contains(results, "App Unreachable (parent down): Load Balancer") {
+		t.Error("Failed to report the site as unreachable.")
+	}
+	if !strings.Contains(results, "Sending notifications for Jack Contact Load Balancer: Site is Down") ||
+		!strings.Contains(results, "Dependent sites affected: App.") {
+		t.Error("Failed to consolidate the notification for the dependent site.")
+	}
+	if strings.Contains(results, "Sending Notification of Site Contacts about App:") {
+		t.Error("Dependent site should not send its own notification.")
+	}
+}
+
+// TestFlappingSite tests that a site alternating between up and down sends one
+// flapping notification instead of one for each change.
+func TestFlappingSite(t *testing.T) {
+	config.Settings.Flapping.Transitions = 2
+	defer func() { config.Settings.Flapping.Transitions = 0 }()
+	// Fake db for testing.
+	db, _ := sql.Open("testdb", "")
+	pinger.CreatePingerLog("", true)
+	pinger.ResetHitCount()
+	p := pinger.NewPinger(db, pinger.GetSitesContentMock, pinger.RequestURLFlappingMock,
+		notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
+	p.Sites = p.Sites[:1]
+	p.Start()
+	// Sleep to allow running the tests before stopping.
+	time.Sleep(5 * time.Second)
+	p.Stop()
+
+	results, err := pinger.GetLogContent()
+	if err != nil {
+		t.Fatal("Failed to get log results.", err)
+	}
+
+	if strings.Count(results, "Sending Notification of Site Contacts about Test: Site is Flapping...") != 1 {
+		t.Error("Failed to send a single flapping notification.")
+	}
+	if strings.Count(results, "Sending Notification of Site Contacts about Test: Site is") != 3 {
+		t.Error("Only the first two changes and the flapping should be notified.")
+	}
+	if !strings.Contains(results, "Test Flapping, notification suppressed: Site is") {
+		t.Error("Failed to report the suppressed notification.")
+	}
+}
+
 // TestUpdateSiteSettings starts up the pinger and then updates the site settings.
 func TestUpdateSiteSettings(t *testing.T) {
 	// Fake db for testing.
@@ -148,7 +216,7 @@ func TestUpdateSiteSettings(t *testing.T) {
 	pinger.CreatePingerLog("", true)
 	pinger.ResetHitCount()
 	p := pinger.NewPinger(db, pinger.GetSitesMock, pinger.RequestURLMock,
-		notifier.SendEmailMock, notifier.SendSmsMock)
+		notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
 	p.Start()
 	// Test UpdateSiteSettings
 	p.UpdateSiteSettings()
@@ -170,7 +238,7 @@ func TestUpdateSiteSettingsError(t *testing.T) {
 	pinger.CreatePingerLog("", true)
 	pinger.ResetHitCount()
 	p := pinger.NewPinger(db, pinger.GetSitesErrorMock, pinger.RequestURLMock,
-		notifier.SendEmailMock, notifier.SendSmsMock)
+		notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
 	p.Start()
 	// Test UpdateSiteSettings with error due to database.
 	err := p.UpdateSiteSettings()
@@ -195,7 +263,7 @@ func TestInternetAccessError(t *testing.T) {
 	db, _ := sql.Open("testdb", "")
 	pinger.CreatePingerLog("", true)
 	p := pinger.NewPinger(db, pinger.GetSitesMock, pinger.RequestURLBadInternetAccessMock,
-		notifier.SendEmailMock, notifier.SendSmsMock)
+		notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
 	p.Start()
 	time.Sleep(5 * time.Second)
 	p.Stop()
@@ -268,7 +336,7 @@ func TestSavePings(t *testing.T) {
 	// For this test will pass the normal GetSites to use the DB...
 	pinger.ResetHitCount()
 	p := pinger.NewPinger(db, pinger.GetSites, pinger.RequestURLMock,
-		notifier.SendEmailMock, notifier.SendSmsMock)
+		notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
 	p.Start()
 	// Sleep to allow running the tests before stopping.
 	time.Sleep(7 * time.Second)
@@ -309,6 +377,66 @@ func TestSavePings(t *testing.T) {
 
 }
 
+// TestSavePingsInMaintenance tests that the pings in a maintenance window are
+// flagged and that the status changes aren't notified.
+func TestSavePingsInMaintenance(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+
+	s1 := database.Site{Name: "Test", IsActive: true, URL: "http://www.github.com",
+		PingIntervalSeconds: 1, TimeoutSeconds: 30}
+	err = s1.CreateSite(db)
+	if err != nil {
+		t.Fatal("Failed to create new site:", err)
+	}
+	m := database.MaintenanceWindow{Description: "Deploy",
+		StartTime: time.Now().Add(-time.Hour), EndTime: time.Now().Add(time.Hour)}
+	err = m.CreateMaintenanceWindow(db)
+	if err != nil {
+		t.Fatal("Failed to create maintenance window:", err)
+	}
+
+	pinger.CreatePingerLog("", true)
+	pinger.ResetHitCount()
+	p := pinger.NewPinger(db, pinger.GetSites, pinger.RequestURLMock,
+		notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock))
+	p.Start()
+	// Sleep to allow running the tests before stopping.
+	time.Sleep(3 * time.Second)
+	p.Stop()
+
+	var saved database.Site
+	err = saved.GetSitePings(db, s1.SiteID, time.Now().Add(-10*time.Second), time.Now())
+	if err != nil {
+		t.Fatal("Failed to retrieve site pings:", err)
+	}
+	if len(saved.Pings) == 0 || !saved.Pings[0].SiteDown || !saved.Pings[0].Maintenance {
+		t.Fatal("First ping should be saved as down during maintenance:", saved.Pings)
+	}
+
+	err = saved.GetSite(db, s1.SiteID)
+	if err != nil {
+		t.Fatal("Failed to retrieve site updates:", err)
+	}
+	if !saved.LastStatusChange.IsZero() {
+		t.Error("Status change should not be saved during maintenance.")
+	}
+
+	results, err := pinger.GetLogContent()
+	if err != nil {
+		t.Fatal("Failed to get log results.", err)
+	}
+	if !strings.Contains(results, "Test In maintenance, notification suppressed: Site is Down") {
+		t.Error("Failed to report the suppressed notification.")
+	}
+	if strings.Contains(results, "Sending Notification of Site Contacts about Test:") {
+		t.Error("Notification should not be sent during maintenance.")
+	}
+}
+
 func TestCreatePingerLogError(t *testing.T) {
 	var logFile = "/bogusFilePath/pinger.log"
 	err := pinger.CreatePingerLog(logFile, true)
@@ -316,3 +444,37 @@ func TestCreatePingerLogError(t *testing.T) {
 		t.Error("Creation of pinger log should throw error for bad path.")
 	}
 }
+
+// TestIncidentResolved tests that the incident opened when the site goes down
+// is resolved when it comes back up.
+func TestIncidentResolved(t *testing.T) {
+	var actions []string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var event map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&event)
+		actions = append(actions, fmt.Sprint(event["event_action"], " ", event["dedup_key"]))
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	// Fake db for testing.
+	db, _ := sql.Open("testdb", "")
+	pinger.CreatePingerLog("", true)
+	pinger.ResetHitCount()
+	channels := notifier.NewChannels(notifier.SendEmailMock, notifier.SendSmsMock)
+	channels.Register(database.PagerDutyChannel, &notifier.PagerDuty{Client: http.DefaultClient, URL: server.URL})
+	p := pinger.NewPinger(db, pinger.GetSitesMock, pinger.RequestURLMock, channels)
+	// Only the site that goes down and comes back up.
+	p.Sites = p.Sites[1:2]
+	p.Sites[0].SiteID = 2
+	p.Sites[0].PingIntervalSeconds = 1
+	p.Sites[0].SetChannel(database.PagerDutyChannel, "routingkey", true)
+	p.Start()
+	// Sleep to allow running the tests before stopping.
+	time.Sleep(5 * time.Second)
+	p.Stop()
+
+	if len(actions) != 2 || actions[0] != "trigger go-ping-sites-site-2" ||
+		actions[1] != "resolve go-ping-sites-site-2" {
+		t.Error("Expected the incident to be triggered and resolved:", actions)
+	}
+}
@@ -0,0 +1,316 @@
+package pinger
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// protocolScheme holds the default port of a protocol and whether the
+// connection uses implicit TLS from the start.
+type protocolScheme struct {
+	protocol    string
+	port        string
+	implicitTLS bool
+}
+
+// protocolSchemes are the URL schemes that are checked with a protocol
+// conversation instead of an HTTP request.
+var protocolSchemes = map[string]protocolScheme{
+	"smtp":  {protocol: "smtp", port: "25"},
+	"smtps": {protocol: "smtp", port: "465", implicitTLS: true},
+	"imap":  {protocol: "imap", port: "143"},
+	"imaps": {protocol: "imap", port: "993", implicitTLS: true},
+	"ftp":   {protocol: "ftp", port: "21"},
+	"ftps":  {protocol: "ftp", port: "990", implicitTLS: true},
+}
+
+// protocolTLSConfig is used for the TLS connections of the protocol checks. It's
+// nil in production so that the certificate is verified against the host name,
+// the tests replace it to trust their local stand-in servers.
+var protocolTLSConfig *tls.Config
+
+// heloName is the name the checks introduce themselves with to SMTP servers.
+const heloName = "go-ping-sites"
+
+// IsProtocolURL returns true if the URL is for an SMTP, IMAP or FTP server
+// that is checked with a protocol conversation rather than an HTTP request.
+func IsProtocolURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	_, ok := protocolSchemes[strings.ToLower(u.Scheme)]
+	return ok
+}
+
+// RequestProtocol connects to an SMTP, IMAP or FTP server, reads the greeting
+// banner, optionally upgrades the connection with STARTTLS when the URL has
+// starttls=true, and issues a harmless command (EHLO, CAPABILITY or NOOP).
+// The server replies are returned as the content, along with the final reply
+// code and the timing of each step.
+func RequestProtocol(rawURL string, timeout int) (string, int, time.Duration, []database.PingStep, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", 0, 0, nil, err
+	}
+	scheme, ok := protocolSchemes[strings.ToLower(u.Scheme)]
+	if !ok {
+		return "", 0, 0, nil, fmt.Errorf("unsupported protocol scheme %q", u.Scheme)
+	}
+	startTLS, _ := strconv.ParseBool(u.Query().Get("starttls"))
+	port := u.Port()
+	if port == "" {
+		port = scheme.port
+	}
+
+	ps := &protocolSession{host: u.Hostname()}
+	to := time.Duration(timeout) * time.Second
+	timeStart := time.Now()
+	err = ps.step("connect", func() error {
+		return ps.connect(net.JoinHostPort(ps.host, port), to, scheme.implicitTLS)
+	})
+	if err != nil {
+		elapsedTime := round(time.Since(timeStart), time.Millisecond)
+		// As with HTTP, check whether the Internet is reachable at all before
+		// deciding that the server is down.
+		if !isInternetAccessible(site1, site2) {
+			return "", 0, elapsedTime, ps.steps, InternetAccessError{msg: err.Error()}
+		}
+		return "", 0, elapsedTime, ps.steps, err
+	}
+	defer ps.conn.Close()
+
+	var statusCode int
+	switch scheme.protocol {
+	case "smtp":
+		statusCode, err = ps.checkSMTP(startTLS)
+	case "imap":
+		statusCode, err = ps.checkIMAP(startTLS)
+	case "ftp":
+		statusCode, err = ps.checkFTP(startTLS)
+	}
+	elapsedTime := round(time.Since(timeStart), time.Millisecond)
+	return strings.Join(ps.replies, "\n"), statusCode, elapsedTime, ps.steps, err
+}
+
+// protocolSession holds the connection and the results of a protocol check.
+type protocolSession struct {
+	host    string
+	conn    net.Conn
+	text    *textproto.Conn
+	steps   []database.PingStep
+	replies []string
+}
+
+// step runs one step of the conversation and records its timing, whether it
+// succeeded or not.
+func (ps *protocolSession) step(name string, fn func() error) error {
+	stepStart := time.Now()
+	err := fn()
+	duration := round(time.Since(stepStart), time.Millisecond)
+	ps.steps = append(ps.steps, database.PingStep{Name: name,
+		Duration: int(duration.Nanoseconds() / 1e6)})
+	return err
+}
+
+func (ps *protocolSession) connect(address string, timeout time.Duration, implicitTLS bool) error {
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if implicitTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, ps.tlsConfig())
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	// The whole conversation has to complete within the site timeout.
+	conn.SetDeadline(time.Now().Add(timeout))
+	ps.conn = conn
+	ps.text = textproto.NewConn(conn)
+	return nil
+}
+
+// startTLS upgrades the connection once the server has agreed to STARTTLS.
+func (ps *protocolSession) startTLS() error {
+	tlsConn := tls.Client(ps.conn, ps.tlsConfig())
+	err := tlsConn.Handshake()
+	if err != nil {
+		return err
+	}
+	ps.conn = tlsConn
+	ps.text = textproto.NewConn(tlsConn)
+	return nil
+}
+
+func (ps *protocolSession) tlsConfig() *tls.Config {
+	if protocolTLSConfig != nil {
+		return protocolTLSConfig.Clone()
+	}
+	return &tls.Config{ServerName: ps.host}
+}
+
+// command sends a command and reads the numbered reply used by SMTP and FTP.
+// An empty command only reads the reply, as for the greeting banner.
+func (ps *protocolSession) command(expectCode int, format string, args ...interface{}) (int, error) {
+	if format != "" {
+		err := ps.text.PrintfLine(format, args...)
+		if err != nil {
+			return 0, err
+		}
+	}
+	code, message, err := ps.text.ReadResponse(expectCode)
+	if code != 0 {
+		reply := strconv.Itoa(code) + " " + message
+		ps.replies = append(ps.replies, reply)
+		if _, ok := err.(*textproto.Error); ok {
+			// Report the reply as the server sent it rather than quoted.
+			err = errors.New(reply)
+		}
+	}
+	return code, err
+}
+
+func (ps *protocolSession) checkSMTP(startTLS bool) (int, error) {
+	var code int
+	err := ps.step("banner", func() (err error) {
+		code, err = ps.command(220, "")
+		return err
+	})
+	if err != nil {
+		return code, fmt.Errorf("SMTP greeting: %v", err)
+	}
+	if startTLS {
+		err = ps.step("ehlo", func() (err error) {
+			code, err = ps.command(250, "EHLO %s", heloName)
+			return err
+		})
+		if err != nil {
+			return code, fmt.Errorf("SMTP EHLO: %v", err)
+		}
+		err = ps.step("starttls", func() (err error) {
+			code, err = ps.command(220, "STARTTLS")
+			if err != nil {
+				return err
+			}
+			return ps.startTLS()
+		})
+		if err != nil {
+			return code, fmt.Errorf("SMTP STARTTLS: %v", err)
+		}
+	}
+	err = ps.step("ehlo", func() (err error) {
+		code, err = ps.command(250, "EHLO %s", heloName)
+		return err
+	})
+	if err != nil {
+		return code, fmt.Errorf("SMTP EHLO: %v", err)
+	}
+	ps.text.PrintfLine("QUIT")
+	return code, nil
+}
+
+func (ps *protocolSession) checkFTP(startTLS bool) (int, error) {
+	var code int
+	err := ps.step("banner", func() (err error) {
+		code, err = ps.command(220, "")
+		return err
+	})
+	if err != nil {
+		return code, fmt.Errorf("FTP greeting: %v", err)
+	}
+	if startTLS {
+		err = ps.step("starttls", func() (err error) {
+			code, err = ps.command(234, "AUTH TLS")
+			if err != nil {
+				return err
+			}
+			return ps.startTLS()
+		})
+		if err != nil {
+			return code, fmt.Errorf("FTP AUTH TLS: %v", err)
+		}
+	}
+	err = ps.step("noop", func() (err error) {
+		code, err = ps.command(200, "NOOP")
+		return err
+	})
+	if err != nil {
+		return code, fmt.Errorf("FTP NOOP: %v", err)
+	}
+	ps.text.PrintfLine("QUIT")
+	return code, nil
+}
+
+// imapOK is recorded as the status code of a successful IMAP check since IMAP
+// replies don't carry numeric codes.
+const imapOK = 200
+
+func (ps *protocolSession) checkIMAP(startTLS bool) (int, error) {
+	err := ps.step("banner", func() error {
+		line, err := ps.text.ReadLine()
+		if err != nil {
+			return err
+		}
+		ps.replies = append(ps.replies, line)
+		if !strings.HasPrefix(line, "* OK") && !strings.HasPrefix(line, "* PREAUTH") {
+			return fmt.Errorf("unexpected reply %q", line)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("IMAP greeting: %v", err)
+	}
+	if startTLS {
+		err = ps.step("starttls", func() error {
+			err := ps.imapCommand("a1", "STARTTLS")
+			if err != nil {
+				return err
+			}
+			return ps.startTLS()
+		})
+		if err != nil {
+			return 0, fmt.Errorf("IMAP STARTTLS: %v", err)
+		}
+	}
+	err = ps.step("capability", func() error {
+		return ps.imapCommand("a2", "CAPABILITY")
+	})
+	if err != nil {
+		return 0, fmt.Errorf("IMAP CAPABILITY: %v", err)
+	}
+	ps.text.PrintfLine("a3 LOGOUT")
+	return imapOK, nil
+}
+
+// imapCommand sends a tagged IMAP command and reads the replies until the
+// tagged completion, which must be OK.
+func (ps *protocolSession) imapCommand(tag string, command string) error {
+	err := ps.text.PrintfLine("%s %s", tag, command)
+	if err != nil {
+		return err
+	}
+	for {
+		line, err := ps.text.ReadLine()
+		if err != nil {
+			return err
+		}
+		ps.replies = append(ps.replies, line)
+		if strings.HasPrefix(line, tag+" ") {
+			if !strings.HasPrefix(line, tag+" OK") {
+				return fmt.Errorf("unexpected reply %q", line)
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package pinger
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// serveProtocol starts a local stand-in server that handles a single connection
+// with the given conversation and returns its address.
+func serveProtocol(t *testing.T, conversation func(conn net.Conn)) (string, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to start stand-in server:", err)
+	}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conversation(conn)
+	}()
+	return ln.Addr().String(), func() { ln.Close() }
+}
+
+// standInTLSConfig borrows the certificate of an httptest TLS server for the
+// STARTTLS upgrades of the stand-in servers.
+func standInTLSConfig() *tls.Config {
+	s := httptest.NewTLSServer(http.NotFoundHandler())
+	defer s.Close()
+	return &tls.Config{Certificates: s.TLS.Certificates}
+}
+
+func TestRequestProtocolSMTPStartTLS(t *testing.T) {
+	serverTLS := standInTLSConfig()
+	protocolTLSConfig = &tls.Config{InsecureSkipVerify: true}
+	defer func() { protocolTLSConfig = nil }()
+
+	addr, stop := serveProtocol(t, func(conn net.Conn) {
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 mail.example.com ESMTP")
+		tp.ReadLine()
+		tp.PrintfLine("250-mail.example.com")
+		tp.PrintfLine("250 STARTTLS")
+		tp.ReadLine()
+		tp.PrintfLine("220 Ready to start TLS")
+		tlsConn := tls.Server(conn, serverTLS)
+		tp = textproto.NewConn(tlsConn)
+		line, _ := tp.ReadLine()
+		if line == "EHLO "+heloName {
+			tp.PrintfLine("250 mail.example.com")
+		} else {
+			tp.PrintfLine("500 Expected EHLO")
+		}
+		tp.ReadLine()
+	})
+	defer stop()
+
+	content, code, _, steps, err := RequestProtocol("smtp://"+addr+"?starttls=true", 5)
+	if err != nil {
+		t.Fatal("SMTP check with STARTTLS should succeed:", err)
+	}
+	if code != 250 {
+		t.Error("SMTP check should report the EHLO reply code, got", code)
+	}
+	if !strings.Contains(content, "220 mail.example.com ESMTP") {
+		t.Error("SMTP check should return the greeting banner:", content)
+	}
+	var names []string
+	for _, s := range steps {
+		names = append(names, s.Name)
+	}
+	if strings.Join(names, ",") != "connect,banner,ehlo,starttls,ehlo" {
+		t.Error("SMTP check steps not recorded as expected:", names)
+	}
+}
+
+func TestRequestProtocolSMTPGreetingError(t *testing.T) {
+	addr, stop := serveProtocol(t, func(conn net.Conn) {
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("421 mail.example.com Service not available")
+	})
+	defer stop()
+
+	_, code, _, steps, err := RequestProtocol("smtp://"+addr, 5)
+	if err == nil {
+		t.Fatal("SMTP check should fail on a 4xx greeting.")
+	}
+	if !strings.Contains(err.Error(), "SMTP greeting: 421 mail.example.com Service not available") {
+		t.Error("SMTP greeting error not reported as expected:", err)
+	}
+	if code != 421 {
+		t.Error("SMTP check should report the greeting reply code, got", code)
+	}
+	if len(steps) != 2 {
+		t.Error("SMTP check should time the connect and banner steps:", steps)
+	}
+}
+
+func TestRequestProtocolIMAP(t *testing.T) {
+	addr, stop := serveProtocol(t, func(conn net.Conn) {
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("* OK IMAP4rev1 Service Ready")
+		tp.ReadLine()
+		tp.PrintfLine("* CAPABILITY IMAP4rev1 STARTTLS")
+		tp.PrintfLine("a2 OK CAPABILITY completed")
+		tp.ReadLine()
+	})
+	defer stop()
+
+	content, code, _, steps, err := RequestProtocol("imap://"+addr, 5)
+	if err != nil {
+		t.Fatal("IMAP check should succeed:", err)
+	}
+	if code != imapOK {
+		t.Error("IMAP check should report OK, got", code)
+	}
+	if !strings.Contains(content, "* CAPABILITY IMAP4rev1 STARTTLS") {
+		t.Error("IMAP check should return the capability reply:", content)
+	}
+	if len(steps) != 3 || steps[2].Name != "capability" {
+		t.Error("IMAP check steps not recorded as expected:", steps)
+	}
+}
+
+func TestRequestProtocolFTP(t *testing.T) {
+	addr, stop := serveProtocol(t, func(conn net.Conn) {
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220-Welcome")
+		tp.PrintfLine("220 FTP Server ready")
+		tp.ReadLine()
+		tp.PrintfLine("502 NOOP not implemented")
+	})
+	defer stop()
+
+	_, code, _, _, err := RequestProtocol("ftp://"+addr, 5)
+	if err == nil || !strings.Contains(err.Error(), "FTP NOOP: 502") {
+		t.Error("FTP check should fail on the NOOP reply:", err)
+	}
+	if code != 502 {
+		t.Error("FTP check should report the NOOP reply code, got", code)
+	}
+}
+
+func TestIsProtocolURL(t *testing.T) {
+	if !IsProtocolURL("smtp://mail.example.com:587?starttls=true") {
+		t.Error("smtp URL should be a protocol URL.")
+	}
+	if !IsProtocolURL("IMAPS://mail.example.com") {
+		t.Error("imaps URL should be a protocol URL.")
+	}
+	if IsProtocolURL("http://www.example.com") {
+		t.Error("http URL should not be a protocol URL.")
+	}
+	if IsProtocolURL("smtp:mail.example.com") {
+		t.Error("URL without a host should not be a protocol URL.")
+	}
+}
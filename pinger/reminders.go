@@ -0,0 +1,45 @@
+package pinger
+
+import (
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// reminderSchedule tracks the reminders of the current outage of a site. No
+// reminders are sent if the interval is 0 and there's no limit if the max is 0.
+type reminderSchedule struct {
+	interval time.Duration
+	max      int
+	count    int
+	last     time.Time
+}
+
+// newReminderSchedule returns the schedule of the site's reminders. If the
+// site is already down, such as after a restart, the reminders are assumed to
+// have been sent on schedule since it went down.
+func newReminderSchedule(s database.Site, now time.Time) *reminderSchedule {
+	r := reminderSchedule{interval: time.Duration(s.ReminderMinutes) * time.Minute, max: s.MaxReminders}
+	if !s.IsSiteUp && r.interval > 0 && !s.LastStatusChange.IsZero() {
+		r.count = int(now.Sub(s.LastStatusChange) / r.interval)
+		r.last = s.LastStatusChange.Add(time.Duration(r.count) * r.interval)
+	}
+	return &r
+}
+
+// start begins the reminders for an outage that has just been notified.
+func (r *reminderSchedule) start(t time.Time) {
+	r.count = 0
+	r.last = t
+}
+
+// due returns true if the next reminder should be sent, and records that it
+// has been.
+func (r *reminderSchedule) due(t time.Time) bool {
+	if r.interval <= 0 || (r.max > 0 && r.count >= r.max) || t.Sub(r.last) < r.interval {
+		return false
+	}
+	r.count++
+	r.last = t
+	return true
+}
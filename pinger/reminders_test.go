@@ -0,0 +1,93 @@
+package pinger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+	"github.com/turnkey-commerce/go-ping-sites/notifier"
+)
+
+func TestReminderSchedule(t *testing.T) {
+	r := newReminderSchedule(database.Site{ReminderMinutes: 30, MaxReminders: 2, IsSiteUp: true}, time.Now())
+	start := time.Date(2016, 3, 1, 22, 0, 0, 0, time.UTC)
+	r.start(start)
+	if r.due(start.Add(29 * time.Minute)) {
+		t.Error("Reminder should not be due before the interval.")
+	}
+	if !r.due(start.Add(30*time.Minute)) || !r.due(start.Add(60*time.Minute)) || r.count != 2 {
+		t.Error("Reminders should be due at each interval.")
+	}
+	if r.due(start.Add(90 * time.Minute)) {
+		t.Error("Reminders should stop at the maximum.")
+	}
+	// A new outage starts the count again.
+	r.start(start.Add(120 * time.Minute))
+	if !r.due(start.Add(150 * time.Minute)) {
+		t.Error("Reminders should start again for a new outage.")
+	}
+}
+
+func TestReminderScheduleDisabled(t *testing.T) {
+	r := newReminderSchedule(database.Site{}, time.Now())
+	start := time.Date(2016, 3, 1, 22, 0, 0, 0, time.UTC)
+	r.start(start)
+	if r.due(start.Add(24 * time.Hour)) {
+		t.Error("Reminders should not be sent without an interval.")
+	}
+}
+
+func TestReminderScheduleAlreadyDown(t *testing.T) {
+	now := time.Date(2016, 3, 1, 22, 0, 0, 0, time.UTC)
+	s := database.Site{ReminderMinutes: 30, MaxReminders: 3, IsSiteUp: false,
+		LastStatusChange: now.Add(-70 * time.Minute)}
+	r := newReminderSchedule(s, now)
+	// Reminders at 30 and 60 minutes are assumed sent, the next is at 90.
+	if r.count != 2 || r.due(now.Add(19*time.Minute)) || !r.due(now.Add(20*time.Minute)) {
+		t.Error("Reminders should carry on the schedule of the outage:", r.count, r.last)
+	}
+}
+
+// TestAcknowledgeStopsReminders tests that the acknowledgement is saved to the
+// site and kept when the pinger is restarted, until a new outage clears it.
+func TestAcknowledgeStopsReminders(t *testing.T) {
+	db, err := database.InitializeTestDB("")
+	if err != nil {
+		t.Fatal("Failed to create database:", err)
+	}
+	defer db.Close()
+	site := database.Site{Name: "Test", IsActive: true, URL: "http://www.example.com",
+		PingIntervalSeconds: 60, TimeoutSeconds: 30}
+	err = site.CreateSite(db)
+	if err != nil {
+		t.Fatal("Failed to create new site:", err)
+	}
+	err = site.UpdateSiteStatus(db, false, time.Now(), nil)
+	if err != nil {
+		t.Fatal("Failed to update the site status:", err)
+	}
+
+	p := NewPinger(db, GetSites, RequestURLMock, notifier.Channels{})
+	p.statuses = newSiteStatuses(p.Sites)
+	err = p.Acknowledge(&site, "jack", time.Now())
+	if err != nil {
+		t.Fatal("Failed to acknowledge the site:", err)
+	}
+	if !p.statuses.isAcknowledged(site.SiteID) || p.statuses.isAcknowledged(site.SiteID+1) {
+		t.Error("Only the acknowledged site should be acknowledged.")
+	}
+
+	// The pinger restarted with the sites it has and with the sites from the DB.
+	if !newSiteStatuses(p.Sites).isAcknowledged(site.SiteID) {
+		t.Error("The acknowledgement should be kept when the pinger restarts.")
+	}
+	restarted := NewPinger(db, GetSites, RequestURLMock, notifier.Channels{})
+	if !newSiteStatuses(restarted.Sites).isAcknowledged(site.SiteID) {
+		t.Error("The acknowledgement should be read from the site after a restart.")
+	}
+
+	p.statuses.setAcknowledged(site.SiteID, false)
+	if p.statuses.isAcknowledged(site.SiteID) {
+		t.Error("A new outage should clear the acknowledgement.")
+	}
+}
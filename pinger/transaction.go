@@ -0,0 +1,293 @@
+package pinger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// Transaction is a scripted sequence of HTTP requests that are run in order
+// for a site, sharing a cookie jar and variables extracted from the responses.
+// It's written by the user in TOML, for example:
+//
+//	[[Steps]]
+//	Name = "login"
+//	Method = "POST"
+//	URL = "/login"
+//	Body = "username=monitor&password=secret"
+//	ContentType = "application/x-www-form-urlencoded"
+//	ExpectContent = "Welcome"
+//	  [Steps.Extract]
+//	  token = 'regex:name="csrf" value="([^"]+)"'
+//
+//	[[Steps]]
+//	Name = "api"
+//	URL = "/api/status"
+//	Headers = { X-CSRF-Token = "${token}" }
+//	ExpectStatus = 200
+type Transaction struct {
+	Steps []TransactionStep
+}
+
+// TransactionStep is one request of a transaction. The URL may be relative to
+// the site URL and defaults to it. Variables are used as ${name} in the URL,
+// Body and Headers. Extract maps a variable name to either "regex:<pattern>",
+// using the first capture group if there is one, or "json:<path>" with a dot
+// separated path such as "data.items.0.id". ExpectStatus defaults to any 2xx
+// status code, a step that expects a 3xx status checks the redirect rather than
+// following it.
+type TransactionStep struct {
+	Name          string
+	Method        string
+	URL           string
+	Body          string
+	ContentType   string
+	Headers       map[string]string
+	ExpectStatus  int
+	ExpectContent string
+	Extract       map[string]string
+}
+
+// StepError reports the step of a transaction that failed so the notification
+// can name it.
+type StepError struct {
+	Step  int
+	Name  string
+	Cause error
+}
+
+func (e StepError) Error() string {
+	return fmt.Sprintf("step %d (%s) failed: %v", e.Step, e.Name, e.Cause)
+}
+
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z0-9_]+)\}`)
+
+// ParseTransaction reads the TOML steps of a transaction and checks that each
+// step can be run.
+func ParseTransaction(script string) (Transaction, error) {
+	var t Transaction
+	if _, err := toml.Decode(script, &t); err != nil {
+		return t, err
+	}
+	if len(t.Steps) == 0 {
+		return t, errors.New("the transaction has no steps")
+	}
+	for i := range t.Steps {
+		step := &t.Steps[i]
+		if step.Name == "" {
+			step.Name = "step " + strconv.Itoa(i+1)
+		}
+		if step.Method == "" {
+			step.Method = "GET"
+		}
+		step.Method = strings.ToUpper(step.Method)
+		for name, rule := range step.Extract {
+			kind, expression := splitExtractRule(rule)
+			switch kind {
+			case "regex":
+				if _, err := regexp.Compile(expression); err != nil {
+					return t, fmt.Errorf("step %d (%s) extract %s: %v", i+1, step.Name, name, err)
+				}
+			case "json":
+				if expression == "" {
+					return t, fmt.Errorf("step %d (%s) extract %s: empty JSON path", i+1, step.Name, name)
+				}
+			default:
+				return t, fmt.Errorf("step %d (%s) extract %s: must start with regex: or json:", i+1, step.Name, name)
+			}
+		}
+	}
+	return t, nil
+}
+
+// RequestTransaction runs the steps of a transaction against the site. The
+// content and status code of the last response are returned along with the
+// timing of each step. The first failing step stops the transaction and is
+// reported as a StepError.
+func RequestTransaction(siteURL string, script string, timeout int) (string, int, time.Duration, []database.PingStep, error) {
+	t, err := ParseTransaction(script)
+	if err != nil {
+		return "", 0, 0, nil, err
+	}
+	base, err := url.Parse(siteURL)
+	if err != nil {
+		return "", 0, 0, nil, err
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return "", 0, 0, nil, err
+	}
+	client := http.Client{
+		Timeout: time.Duration(timeout) * time.Second,
+		Jar:     jar,
+	}
+
+	variables := make(map[string]string)
+	var steps []database.PingStep
+	var content string
+	var statusCode int
+	timeStart := time.Now()
+	for i, step := range t.Steps {
+		stepStart := time.Now()
+		content, statusCode, err = runStep(&client, base, step, variables)
+		stepTime := round(time.Since(stepStart), time.Millisecond)
+		steps = append(steps, database.PingStep{Name: step.Name,
+			Duration: int(stepTime.Nanoseconds() / 1e6)})
+		if err != nil {
+			elapsedTime := round(time.Since(timeStart), time.Millisecond)
+			if _, ok := err.(*url.Error); ok && !isInternetAccessible(site1, site2) {
+				return "", 0, elapsedTime, steps, InternetAccessError{msg: err.Error()}
+			}
+			return content, statusCode, elapsedTime, steps, StepError{Step: i + 1, Name: step.Name, Cause: err}
+		}
+	}
+	elapsedTime := round(time.Since(timeStart), time.Millisecond)
+	return content, statusCode, elapsedTime, steps, nil
+}
+
+// runStep does the request of one step, checks its assertions and extracts
+// its variables.
+func runStep(client *http.Client, base *url.URL, step TransactionStep, variables map[string]string) (string, int, error) {
+	stepURL, err := base.Parse(expandVariables(step.URL, variables))
+	if err != nil {
+		return "", 0, err
+	}
+	body := strings.NewReader(expandVariables(step.Body, variables))
+	req, err := http.NewRequest(step.Method, stepURL.String(), body)
+	if err != nil {
+		return "", 0, err
+	}
+	if step.ContentType != "" {
+		req.Header.Set("Content-Type", step.ContentType)
+	}
+	for name, value := range step.Headers {
+		req.Header.Set(name, expandVariables(value, variables))
+	}
+
+	if step.ExpectStatus >= 300 && step.ExpectStatus <= 399 {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	} else {
+		client.CheckRedirect = nil
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer res.Body.Close()
+	raw, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", res.StatusCode, err
+	}
+	content := string(raw)
+
+	if step.ExpectStatus != 0 && res.StatusCode != step.ExpectStatus {
+		return content, res.StatusCode, fmt.Errorf("HTTP Status Code is %d, expected %d", res.StatusCode, step.ExpectStatus)
+	} else if step.ExpectStatus == 0 && (res.StatusCode < 200 || res.StatusCode > 299) {
+		return content, res.StatusCode, fmt.Errorf("HTTP Status Code is %d", res.StatusCode)
+	}
+	if step.ExpectContent != "" && !strings.Contains(content, expandVariables(step.ExpectContent, variables)) {
+		return content, res.StatusCode, fmt.Errorf("required body content missing: %s", step.ExpectContent)
+	}
+	for name, rule := range step.Extract {
+		value, err := extractValue(content, rule)
+		if err != nil {
+			return content, res.StatusCode, fmt.Errorf("extract %s: %v", name, err)
+		}
+		variables[name] = value
+	}
+	return content, res.StatusCode, nil
+}
+
+// expandVariables replaces the ${name} references with the extracted values.
+// Unknown variables are left as they are.
+func expandVariables(input string, variables map[string]string) string {
+	return variablePattern.ReplaceAllStringFunc(input, func(ref string) string {
+		if value, ok := variables[ref[2:len(ref)-1]]; ok {
+			return value
+		}
+		return ref
+	})
+}
+
+func splitExtractRule(rule string) (string, string) {
+	parts := strings.SplitN(rule, ":", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
+
+// extractValue gets a value from the response content with a regex: or json:
+// rule.
+func extractValue(content string, rule string) (string, error) {
+	kind, expression := splitExtractRule(rule)
+	switch kind {
+	case "regex":
+		re, err := regexp.Compile(expression)
+		if err != nil {
+			return "", err
+		}
+		match := re.FindStringSubmatch(content)
+		if match == nil {
+			return "", errors.New("no match for " + expression)
+		}
+		if len(match) > 1 {
+			return match[1], nil
+		}
+		return match[0], nil
+	case "json":
+		var document interface{}
+		if err := json.Unmarshal([]byte(content), &document); err != nil {
+			return "", err
+		}
+		return jsonPathValue(document, expression)
+	}
+	return "", errors.New("rule must start with regex: or json:")
+}
+
+// jsonPathValue follows a dot separated path of object keys and array indexes.
+func jsonPathValue(document interface{}, path string) (string, error) {
+	current := document
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return "", errors.New("no value at " + path)
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return "", errors.New("no value at " + path)
+			}
+			current = node[index]
+		default:
+			return "", errors.New("no value at " + path)
+		}
+	}
+	switch value := current.(type) {
+	case string:
+		return value, nil
+	case nil:
+		return "", nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case map[string]interface{}, []interface{}:
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
@@ -0,0 +1,163 @@
+package pinger
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// newJourneyServer is a local stand-in for a site with a login, a dashboard
+// that requires the session cookie and an API that requires the token.
+func newJourneyServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.FormValue("username") != "monitor" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		fmt.Fprint(w, `Welcome <input name="csrf" value="tok123">`)
+	})
+	mux.HandleFunc("/dashboard", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("session"); err != nil || c.Value != "abc" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"user": {"id": 42, "name": "monitor"}}`)
+	})
+	mux.HandleFunc("/api/users/42", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-CSRF-Token") != "tok123" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, "OK")
+	})
+	mux.HandleFunc("/logout", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	})
+	return httptest.NewServer(mux)
+}
+
+const journeyScript = `
+[[Steps]]
+Name = "login"
+Method = "POST"
+URL = "/login"
+Body = "username=monitor"
+ContentType = "application/x-www-form-urlencoded"
+ExpectContent = "Welcome"
+  [Steps.Extract]
+  token = 'regex:name="csrf" value="([^"]+)"'
+
+[[Steps]]
+Name = "dashboard"
+URL = "/dashboard"
+  [Steps.Extract]
+  userID = "json:user.id"
+
+[[Steps]]
+Name = "api"
+URL = "/api/users/${userID}"
+Headers = { X-CSRF-Token = "${token}" }
+ExpectStatus = 200
+`
+
+func TestRequestTransaction(t *testing.T) {
+	s := newJourneyServer()
+	defer s.Close()
+
+	content, statusCode, _, steps, err := RequestTransaction(s.URL, journeyScript, 5)
+	if err != nil {
+		t.Fatal("Transaction should succeed:", err)
+	}
+	if content != "OK" || statusCode != 200 {
+		t.Error("Transaction should return the last response:", statusCode, content)
+	}
+	if len(steps) != 3 || steps[0].Name != "login" || steps[2].Name != "api" {
+		t.Error("Transaction step timings not recorded as expected:", steps)
+	}
+}
+
+func TestRequestTransactionFailingStep(t *testing.T) {
+	s := newJourneyServer()
+	defer s.Close()
+
+	// The login is sent with the wrong user so the session cookie is never set.
+	script := strings.Replace(journeyScript, "username=monitor", "username=nobody", 1)
+	_, statusCode, _, steps, err := RequestTransaction(s.URL, script, 5)
+	if err == nil {
+		t.Fatal("Transaction should fail on the login step.")
+	}
+	stepErr, ok := err.(StepError)
+	if !ok || stepErr.Step != 1 || stepErr.Name != "login" {
+		t.Error("Transaction should report the failing step:", err)
+	}
+	if err.Error() != "step 1 (login) failed: HTTP Status Code is 401" {
+		t.Error("Transaction error not reported as expected:", err)
+	}
+	if statusCode != 401 || len(steps) != 1 {
+		t.Error("Transaction should stop at the failing step:", statusCode, steps)
+	}
+}
+
+// TestRequestTransactionExpectedStatus tests that a transaction ending on a
+// step that expects a redirect or an error status is up.
+func TestRequestTransactionExpectedStatus(t *testing.T) {
+	s := newJourneyServer()
+	defer s.Close()
+
+	script := `
+[[Steps]]
+Name = "logout"
+URL = "/logout"
+ExpectStatus = 302
+
+[[Steps]]
+Name = "dashboard"
+URL = "/dashboard"
+ExpectStatus = 403
+`
+	site := database.Site{Name: "Journey", URL: s.URL, TransactionSteps: script, TimeoutSeconds: 5}
+	content, statusCode, _, _, err := checkSite(site, RequestURL)
+	if err != nil || statusCode != 403 {
+		t.Fatal("Transaction should pass on the expected statuses:", statusCode, err)
+	}
+	if details := evaluateCheck(site, content, statusCode, err); details != "" {
+		t.Error("Transaction ending on an expected status should be up:", details)
+	}
+}
+
+func TestParseTransactionErrors(t *testing.T) {
+	if _, err := ParseTransaction(""); err == nil {
+		t.Error("Empty transaction should not be valid.")
+	}
+	if _, err := ParseTransaction("[[Steps]\nName = "); err == nil {
+		t.Error("Malformed TOML should not be valid.")
+	}
+	_, err := ParseTransaction("[[Steps]]\nURL = \"/\"\n[Steps.Extract]\nid = \"xpath://id\"")
+	if err == nil || !strings.Contains(err.Error(), "must start with regex: or json:") {
+		t.Error("Unknown extract rule should not be valid:", err)
+	}
+}
+
+func TestJSONPathValue(t *testing.T) {
+	content := `{"data": {"items": [{"id": 7}, {"id": 1234567, "tags": ["a"]}]}}`
+	for path, expected := range map[string]string{
+		"data.items.0.id":     "7",
+		"data.items.1.id":     "1234567",
+		"data.items.1.tags":   `["a"]`,
+		"data.items.1.tags.0": "a",
+	} {
+		value, err := extractValue(content, "json:"+path)
+		if err != nil || value != expected {
+			t.Error("JSON path", path, "returned", value, err)
+		}
+	}
+	if _, err := extractValue(content, "json:data.items.5.id"); err == nil {
+		t.Error("Missing JSON path should return an error.")
+	}
+}
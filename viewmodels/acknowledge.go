@@ -0,0 +1,67 @@
+package viewmodels
+
+import (
+	"html/template"
+
+	"github.com/apexskier/httpauth"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// AcknowledgeEditViewModel holds the form to acknowledge an outage from the
+// link in a notification, the T and Sig are the signed parameters of the link.
+type AcknowledgeEditViewModel struct {
+	Name string `valid:"required"`
+	T    string `valid:"-"`
+	Sig  string `valid:"-"`
+}
+
+// AcknowledgeViewModel holds the view information for the acknowledge.gohtml
+// template. The LinkError is why the link can't acknowledge the outage.
+type AcknowledgeViewModel struct {
+	Errors         map[string]string
+	Title          string
+	LinkError      string
+	SiteName       string
+	DownSince      string
+	AcknowledgedBy string
+	AcknowledgedAt string
+	Form           AcknowledgeEditViewModel
+	Nav            NavViewModel
+	CsrfField      template.HTML
+}
+
+// GetAcknowledgeViewModel populates the items required by the acknowledge.gohtml view
+func GetAcknowledgeViewModel(site *database.Site, form *AcknowledgeEditViewModel, linkError string,
+	isAuthenticated bool, user httpauth.UserData, errors map[string]string) AcknowledgeViewModel {
+	nav := NavViewModel{
+		Active:          "home",
+		IsAuthenticated: isAuthenticated,
+		User:            user,
+	}
+
+	result := AcknowledgeViewModel{
+		Title:     "Go Ping Sites - Acknowledge Outage",
+		Nav:       nav,
+		Errors:    errors,
+		LinkError: linkError,
+		Form:      *form,
+	}
+	if site != nil {
+		result.SiteName = site.Name
+		result.DownSince = site.LastStatusChange.Local().Format(maintenanceDisplayFormat)
+		result.AcknowledgedBy, result.AcknowledgedAt = acknowledgement(site)
+	}
+	if result.Form.Name == "" && isAuthenticated {
+		result.Form.Name = user.Username
+	}
+	return result
+}
+
+// acknowledgement returns who acknowledged the current outage of the site and
+// when, which are empty if the site is up or it hasn't been acknowledged.
+func acknowledgement(site *database.Site) (string, string) {
+	if site.IsSiteUp || site.AcknowledgedBy == "" {
+		return "", ""
+	}
+	return site.AcknowledgedBy, site.AcknowledgedAt.Local().Format(maintenanceDisplayFormat)
+}
@@ -9,41 +9,78 @@ import (
 
 // ContactsEditViewModel holds the required information about the Contacts to choose for editing.
 type ContactsEditViewModel struct {
-	ContactID     int64   `valid:"-"`
-	Name          string  `valid:"ascii,required"`
-	EmailAddress  string  `valid:"email"`
-	SmsNumber     string  `valid:"-"`
-	SmsActive     bool    `valid:"-"`
-	EmailActive   bool    `valid:"-"`
-	SelectedSites []int64 `valid:"-"`
-	SiteCount     int     `valid:"-"`
+	ContactID      int64                      `valid:"-"`
+	Name           string                     `valid:"ascii,required"`
+	EmailAddress   string                     `valid:"email"`
+	SmsNumber      string                     `valid:"-"`
+	SmsActive      bool                       `valid:"-"`
+	VoiceNumber    string                     `valid:"-"`
+	VoiceActive    bool                       `valid:"-"`
+	EmailActive    bool                       `valid:"-"`
+	SlackWebhook   string                     `valid:"-"`
+	SlackActive    bool                       `valid:"-"`
+	TeamsWebhook   string                     `valid:"-"`
+	TeamsActive    bool                       `valid:"-"`
+	TelegramChatID string                     `valid:"-"`
+	TelegramActive bool                       `valid:"-"`
+	DiscordWebhook string                     `valid:"-"`
+	DiscordActive  bool                       `valid:"-"`
+	MatrixRoom     string                     `valid:"-"`
+	MatrixActive   bool                       `valid:"-"`
+	NtfyTopic      string                     `valid:"-"`
+	NtfyActive     bool                       `valid:"-"`
+	GotifyToken    string                     `valid:"-"`
+	GotifyActive   bool                       `valid:"-"`
+	Timezone       string                     `valid:"-"`
+	Digest         string                     `valid:"-"`
+	Schedules      []ContactScheduleViewModel `valid:"-"`
+	SelectedSites  []int64                    `valid:"-"`
+	Subscriptions  []SubscriptionViewModel    `valid:"-"`
+	SiteCount      int                        `valid:"-"`
 }
 
-// ContactsViewModel holds the view information for the contacts.gohtml template
+// ContactsViewModel holds the view information for the contacts.gohtml template,
+// with the TestResults of the test message sent to the contact of the TestContactID.
 type ContactsViewModel struct {
-	Error    error
-	Title    string
-	Contacts []ContactsEditViewModel
-	Nav      NavViewModel
+	Error         error
+	Title         string
+	Contacts      []ContactsEditViewModel
+	TestContactID int64
+	TestResults   []ChannelTestViewModel
+	Nav           NavViewModel
+	CsrfField     template.HTML
 }
 
 // ContactViewModel holds the view information for the contact_edit.gohtml template
 type ContactViewModel struct {
-	Errors    map[string]string
-	Title     string
-	Contact   ContactsEditViewModel
-	AllSites  []ContactsAllSitesViewModel
-	Nav       NavViewModel
-	CsrfField template.HTML
+	Errors      map[string]string
+	Title       string
+	Contact     ContactsEditViewModel
+	AllSites    []ContactsAllSitesViewModel
+	TestSent    bool
+	TestResults []ChannelTestViewModel
+	Nav         NavViewModel
+	CsrfField   template.HTML
 }
 
-// ContactsAllSitesViewModel has all of the sites available to assign the contact.
+// ChannelTestViewModel has the result of sending a test message to one of the
+// channel endpoints, the Error is empty if it was sent.
+type ChannelTestViewModel struct {
+	Channel string
+	Address string
+	Error   string
+}
+
+// ContactsAllSitesViewModel has all of the sites available to assign the contact
+// with the events and channel types of the contact's subscription to them.
 type ContactsAllSitesViewModel struct {
-	SiteID     int64
-	Name       string
-	IsActive   bool
-	URL        string
-	IsAssigned bool
+	SiteID       int64
+	Name         string
+	IsActive     bool
+	URL          string
+	IsAssigned   bool
+	Events       []SubscriptionOption
+	ChannelTypes []SubscriptionOption
 }
 
 // GetContactsViewModel populates the items required by the contacts.gohtml view
@@ -64,10 +101,11 @@ func GetContactsViewModel(contacts database.Contacts, isAuthenticated bool,
 		contactVM := new(ContactsEditViewModel)
 		contactVM.ContactID = contact.ContactID
 		contactVM.Name = contact.Name
-		contactVM.EmailAddress = contact.EmailAddress
-		contactVM.EmailActive = contact.EmailActive
-		contactVM.SmsNumber = contact.SmsNumber
-		contactVM.SmsActive = contact.SmsActive
+		email, sms := contactEndpoints(contact)
+		contactVM.EmailAddress = email.Address
+		contactVM.EmailActive = email.IsActive
+		contactVM.SmsNumber = sms.Address
+		contactVM.SmsActive = sms.IsActive
 		contactVM.SiteCount = contact.SiteCount
 
 		result.Contacts = append(result.Contacts, *contactVM)
@@ -98,10 +136,33 @@ func EditContactViewModel(formContact *ContactsEditViewModel, allSites database.
 	contactVM.EmailActive = formContact.EmailActive
 	contactVM.SmsNumber = formContact.SmsNumber
 	contactVM.SmsActive = formContact.SmsActive
+	contactVM.VoiceNumber = formContact.VoiceNumber
+	contactVM.VoiceActive = formContact.VoiceActive
+	contactVM.SlackWebhook = formContact.SlackWebhook
+	contactVM.SlackActive = formContact.SlackActive
+	contactVM.TeamsWebhook = formContact.TeamsWebhook
+	contactVM.TeamsActive = formContact.TeamsActive
+	contactVM.TelegramChatID = formContact.TelegramChatID
+	contactVM.TelegramActive = formContact.TelegramActive
+	contactVM.DiscordWebhook = formContact.DiscordWebhook
+	contactVM.DiscordActive = formContact.DiscordActive
+	contactVM.MatrixRoom = formContact.MatrixRoom
+	contactVM.MatrixActive = formContact.MatrixActive
+	contactVM.NtfyTopic = formContact.NtfyTopic
+	contactVM.NtfyActive = formContact.NtfyActive
+	contactVM.GotifyToken = formContact.GotifyToken
+	contactVM.GotifyActive = formContact.GotifyActive
+	contactVM.Timezone = formContact.Timezone
+	contactVM.Digest = formContact.Digest
+	contactVM.Schedules = populateSchedules(formContact.Schedules)
 
 	result.Contact = *contactVM
 	result.AllSites = PopulateAllSitesVM(allSites, formContact.SelectedSites,
 		false)
+	for i := range result.AllSites {
+		result.AllSites[i].Events, result.AllSites[i].ChannelTypes =
+			subscriptionOptions(subscriptionFor(formContact.Subscriptions, result.AllSites[i].SiteID))
+	}
 
 	return result
 }
@@ -129,10 +190,33 @@ func NewContactViewModel(formContact *ContactsEditViewModel, allSites database.S
 	contactVM.EmailActive = formContact.EmailActive
 	contactVM.SmsNumber = formContact.SmsNumber
 	contactVM.SmsActive = formContact.SmsActive
+	contactVM.VoiceNumber = formContact.VoiceNumber
+	contactVM.VoiceActive = formContact.VoiceActive
+	contactVM.SlackWebhook = formContact.SlackWebhook
+	contactVM.SlackActive = formContact.SlackActive
+	contactVM.TeamsWebhook = formContact.TeamsWebhook
+	contactVM.TeamsActive = formContact.TeamsActive
+	contactVM.TelegramChatID = formContact.TelegramChatID
+	contactVM.TelegramActive = formContact.TelegramActive
+	contactVM.DiscordWebhook = formContact.DiscordWebhook
+	contactVM.DiscordActive = formContact.DiscordActive
+	contactVM.MatrixRoom = formContact.MatrixRoom
+	contactVM.MatrixActive = formContact.MatrixActive
+	contactVM.NtfyTopic = formContact.NtfyTopic
+	contactVM.NtfyActive = formContact.NtfyActive
+	contactVM.GotifyToken = formContact.GotifyToken
+	contactVM.GotifyActive = formContact.GotifyActive
+	contactVM.Timezone = formContact.Timezone
+	contactVM.Digest = formContact.Digest
+	contactVM.Schedules = populateSchedules(formContact.Schedules)
 
 	result.Contact = *contactVM
 	result.AllSites = PopulateAllSitesVM(allSites, formContact.SelectedSites,
 		selectAllSites)
+	for i := range result.AllSites {
+		result.AllSites[i].Events, result.AllSites[i].ChannelTypes =
+			subscriptionOptions(subscriptionFor(formContact.Subscriptions, result.AllSites[i].SiteID))
+	}
 
 	return result
 }
@@ -164,3 +248,11 @@ func PopulateAllSitesVM(allSites database.Sites, selectedSiteIDs []int64,
 	}
 	return allSitesVM
 }
+
+// contactEndpoints returns the email and SMS channels of the contact, which
+// are empty if it doesn't have them.
+func contactEndpoints(contact database.Contact) (email database.ContactChannel, sms database.ContactChannel) {
+	email, _ = contact.Channel(database.EmailChannel)
+	sms, _ = contact.Channel(database.SmsChannel)
+	return email, sms
+}
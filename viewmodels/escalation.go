@@ -0,0 +1,209 @@
+package viewmodels
+
+import (
+	"html/template"
+	"strconv"
+	"strings"
+
+	"github.com/apexskier/httpauth"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// minEscalationRows is the least number of tier rows shown on the form, there
+// are always two empty rows to add tiers with.
+const minEscalationRows = 3
+
+// EscalationEditViewModel holds the required information about the escalation
+// policy to choose for editing.
+type EscalationEditViewModel struct {
+	EscalationPolicyID int64                         `valid:"-"`
+	Name               string                        `valid:"ascii,required"`
+	Tiers              []EscalationTierEditViewModel `valid:"-"`
+	SiteCount          int                           `valid:"-"`
+}
+
+// EscalationTierEditViewModel holds a tier of the escalation policy. The
+// DelayMinutes is a string to allow the form validation.
+type EscalationTierEditViewModel struct {
+	DelayMinutes string
+	ContactIDs   []int64
+	ContactNames string
+	When         string
+}
+
+// EscalationTierRowViewModel is a row of the tiers on the escalation form,
+// with all of the contacts and the ones in the tier having IsAssigned set. The
+// Index is of the form fields and the Tier is the number that is shown.
+type EscalationTierRowViewModel struct {
+	Index        int
+	Tier         int
+	DelayMinutes string
+	Contacts     []SitesAllContactsViewModel
+}
+
+// EscalationPoliciesViewModel holds the view information for the escalation.gohtml template
+type EscalationPoliciesViewModel struct {
+	Title    string
+	Policies []EscalationEditViewModel
+	Nav      NavViewModel
+}
+
+// EscalationViewModel holds the view information for the escalation_edit.gohtml template
+type EscalationViewModel struct {
+	Errors    map[string]string
+	Title     string
+	Policy    EscalationEditViewModel
+	Rows      []EscalationTierRowViewModel
+	Nav       NavViewModel
+	CsrfField template.HTML
+}
+
+// EscalationPolicyOptionViewModel is an escalation policy that can be chosen
+// for a site, with the site's policy having IsAssigned set to true.
+type EscalationPolicyOptionViewModel struct {
+	EscalationPolicyID int64
+	Name               string
+	IsAssigned         bool
+}
+
+// GetEscalationPoliciesViewModel populates the items required by the escalation.gohtml view
+func GetEscalationPoliciesViewModel(policies database.EscalationPolicies,
+	isAuthenticated bool, user httpauth.UserData) EscalationPoliciesViewModel {
+	nav := NavViewModel{
+		Active:          "settings",
+		IsAuthenticated: isAuthenticated,
+		User:            user,
+	}
+
+	result := EscalationPoliciesViewModel{
+		Title: "Go Ping Sites - Settings - Escalation Policies",
+		Nav:   nav,
+	}
+
+	for _, policy := range policies {
+		policyVM := new(EscalationEditViewModel)
+		MapEscalationDBtoVM(&policy, policyVM)
+		result.Policies = append(result.Policies, *policyVM)
+	}
+
+	return result
+}
+
+// EditEscalationViewModel populates the items required by the escalation_edit.gohtml view
+func EditEscalationViewModel(policyVM *EscalationEditViewModel, allContacts database.Contacts,
+	isAuthenticated bool, user httpauth.UserData, errors map[string]string) EscalationViewModel {
+	return escalationViewModel("Go Ping Sites - Settings - Edit Escalation Policy",
+		policyVM, allContacts, isAuthenticated, user, errors)
+}
+
+// NewEscalationViewModel populates the items required by the escalation_new.gohtml view
+func NewEscalationViewModel(policyVM *EscalationEditViewModel, allContacts database.Contacts,
+	isAuthenticated bool, user httpauth.UserData, errors map[string]string) EscalationViewModel {
+	return escalationViewModel("Go Ping Sites - Settings - New Escalation Policy",
+		policyVM, allContacts, isAuthenticated, user, errors)
+}
+
+func escalationViewModel(title string, policyVM *EscalationEditViewModel, allContacts database.Contacts,
+	isAuthenticated bool, user httpauth.UserData, errors map[string]string) EscalationViewModel {
+	nav := NavViewModel{
+		Active:          "settings",
+		IsAuthenticated: isAuthenticated,
+		User:            user,
+	}
+
+	result := EscalationViewModel{
+		Title:  title,
+		Nav:    nav,
+		Errors: errors,
+		Policy: *policyVM,
+	}
+	rows := len(policyVM.Tiers) + 2
+	if rows < minEscalationRows {
+		rows = minEscalationRows
+	}
+	for i := 0; i < rows; i++ {
+		row := EscalationTierRowViewModel{Index: i, Tier: i + 1}
+		var contactIDs []int64
+		if i < len(policyVM.Tiers) {
+			row.DelayMinutes = policyVM.Tiers[i].DelayMinutes
+			contactIDs = policyVM.Tiers[i].ContactIDs
+		}
+		row.Contacts = PopulateAllContactsVM(allContacts, contactIDs)
+		result.Rows = append(result.Rows, row)
+	}
+	return result
+}
+
+// PopulateEscalationPoliciesVM returns the escalation policies that can be
+// chosen for a site with the site's policy assigned.
+func PopulateEscalationPoliciesVM(policies database.EscalationPolicies,
+	escalationPolicyID int64) []EscalationPolicyOptionViewModel {
+	var policiesVM = []EscalationPolicyOptionViewModel{}
+	for _, policy := range policies {
+		policiesVM = append(policiesVM, EscalationPolicyOptionViewModel{
+			EscalationPolicyID: policy.EscalationPolicyID,
+			Name:               policy.Name,
+			IsAssigned:         policy.EscalationPolicyID == escalationPolicyID,
+		})
+	}
+	return policiesVM
+}
+
+// EscalationTiers returns the tiers of the form that have contacts, the blank
+// rows are left out.
+func (policyVM *EscalationEditViewModel) EscalationTiers() []EscalationTierEditViewModel {
+	var tiers []EscalationTierEditViewModel
+	for _, tier := range policyVM.Tiers {
+		if len(tier.ContactIDs) > 0 {
+			tiers = append(tiers, tier)
+		}
+	}
+	return tiers
+}
+
+// MapEscalationVMtoDB maps the escalation policy view model properties to the
+// escalation policy database properties. The first tier is notified as soon as
+// the site goes down.
+func MapEscalationVMtoDB(policyVM *EscalationEditViewModel, policy *database.EscalationPolicy) error {
+	policy.EscalationPolicyID = policyVM.EscalationPolicyID
+	policy.Name = strings.TrimSpace(policyVM.Name)
+	policy.Tiers = nil
+	for i, tierVM := range policyVM.EscalationTiers() {
+		tier := database.EscalationTier{}
+		if i > 0 {
+			delay, err := strconv.Atoi(strings.TrimSpace(tierVM.DelayMinutes))
+			if err != nil {
+				return err
+			}
+			tier.DelayMinutes = delay
+		}
+		for _, contactID := range tierVM.ContactIDs {
+			tier.Contacts = append(tier.Contacts, database.Contact{ContactID: contactID})
+		}
+		policy.Tiers = append(policy.Tiers, tier)
+	}
+	return nil
+}
+
+// MapEscalationDBtoVM maps the escalation policy database properties to the
+// escalation policy view model properties.
+func MapEscalationDBtoVM(policy *database.EscalationPolicy, policyVM *EscalationEditViewModel) {
+	policyVM.EscalationPolicyID = policy.EscalationPolicyID
+	policyVM.Name = policy.Name
+	policyVM.SiteCount = policy.SiteCount
+	policyVM.Tiers = nil
+	for _, tier := range policy.Tiers {
+		tierVM := EscalationTierEditViewModel{DelayMinutes: strconv.Itoa(tier.DelayMinutes),
+			When: "Immediately"}
+		if tier.DelayMinutes > 0 {
+			tierVM.When = "After " + tierVM.DelayMinutes + " minutes"
+		}
+		var names []string
+		for _, contact := range tier.Contacts {
+			tierVM.ContactIDs = append(tierVM.ContactIDs, contact.ContactID)
+			names = append(names, contact.Name)
+		}
+		tierVM.ContactNames = strings.Join(names, ", ")
+		policyVM.Tiers = append(policyVM.Tiers, tierVM)
+	}
+}
@@ -14,6 +14,7 @@ type HomeViewModel struct {
 	Sites                      []SiteDashboardViewModel
 	Nav                        NavViewModel
 	Messages                   []string
+	Maintenance                []string
 	HasSiteWithNoStatusChanges bool
 }
 
@@ -26,6 +27,9 @@ type SiteDashboardViewModel struct {
 	CSSClass           string
 	LastChecked        string
 	HasNoStatusChanges bool
+	InMaintenance      bool
+	AcknowledgedBy     string
+	AcknowledgedAt     string
 }
 
 // NavViewModel holds the information for the nav bar.
@@ -63,6 +67,14 @@ func GetHomeViewModel(sites database.Sites, isAuthenticated bool, user httpauth.
 			siteVM.Status = "Down"
 			siteVM.CSSClass = "danger"
 		}
+		if !site.IsSiteUp && site.AcknowledgedBy != "" {
+			siteVM.AcknowledgedBy = site.AcknowledgedBy
+			siteVM.AcknowledgedAt = humanize.Time(site.AcknowledgedAt)
+		}
+		if site.IsFlapping {
+			siteVM.Status = "Flapping"
+			siteVM.CSSClass = "warning"
+		}
 
 		if site.LastStatusChange.IsZero() {
 			siteVM.HasNoStatusChanges = true
@@ -90,3 +102,37 @@ func GetHomeViewModel(sites database.Sites, isAuthenticated bool, user httpauth.
 
 	return result
 }
+
+// AddDependencies marks the sites that are failing while a parent site is down
+// as unreachable, their status is held until the parent is back up. A parent
+// that is itself unreachable counts as down for the sites that depend on it.
+func (vm *HomeViewModel) AddDependencies(sites database.Sites, dependencies database.SiteDependencies,
+	lastPingDown map[int64]bool) {
+	down := make(map[int64]bool)
+	for _, site := range sites {
+		down[site.SiteID] = !site.IsSiteUp
+	}
+	unreachable := make(map[int64]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, site := range sites {
+			if down[site.SiteID] || !lastPingDown[site.SiteID] {
+				continue
+			}
+			for _, parentSiteID := range dependencies[site.SiteID] {
+				if down[parentSiteID] {
+					down[site.SiteID] = true
+					unreachable[site.SiteID] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	for i := range vm.Sites {
+		if unreachable[vm.Sites[i].SiteID] {
+			vm.Sites[i].Status = "Unreachable (parent down)"
+			vm.Sites[i].CSSClass = "warning"
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package viewmodels_test
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -107,3 +108,82 @@ func TestGetHomeViewModelWithStatusChanges(t *testing.T) {
 		t.Error("Should NOT indicate has site with no status change.")
 	}
 }
+
+// TestHomeViewModelMaintenance tests the banners and site flags for the active
+// maintenance windows.
+func TestHomeViewModelMaintenance(t *testing.T) {
+	sites := database.Sites{
+		{SiteID: 1, Name: "Test 1", IsSiteUp: true},
+		{SiteID: 2, Name: "Test 2", IsSiteUp: true},
+	}
+	now := time.Now()
+	windows := database.MaintenanceWindows{
+		{SiteID: 2, SiteName: "Test 2", Description: "Deploy",
+			StartTime: now.Add(-time.Hour), EndTime: now.Add(time.Hour)},
+		{SiteID: 1, SiteName: "Test 1", Description: "Later",
+			StartTime: now.Add(time.Hour), EndTime: now.Add(2 * time.Hour)},
+	}
+
+	result := viewmodels.GetHomeViewModel(sites, false, httpauth.UserData{}, nil)
+	result.AddMaintenance(windows, now)
+	if len(result.Maintenance) != 1 ||
+		!strings.HasPrefix(result.Maintenance[0], "Maintenance in progress for Test 2 until ") ||
+		!strings.HasSuffix(result.Maintenance[0], ": Deploy") {
+		t.Error("Maintenance banner returned incorrect value:", result.Maintenance)
+	}
+	if result.Sites[0].InMaintenance || !result.Sites[1].InMaintenance {
+		t.Error("Only the second site should be in maintenance.")
+	}
+}
+
+// TestHomeViewModelDependencies tests that the sites failing while a parent is
+// down are shown as unreachable, and the ones that are still up aren't.
+func TestHomeViewModelDependencies(t *testing.T) {
+	sites := database.Sites{
+		{SiteID: 1, Name: "Load Balancer", IsSiteUp: false},
+		{SiteID: 2, Name: "App", IsSiteUp: true},
+		{SiteID: 3, Name: "Other", IsSiteUp: true},
+		{SiteID: 4, Name: "Reports", IsSiteUp: true},
+		{SiteID: 5, Name: "Static", IsSiteUp: true},
+	}
+	dependencies := database.SiteDependencies{2: {1}, 4: {2}, 5: {1}}
+	lastPingDown := map[int64]bool{1: true, 2: true, 3: true, 4: true}
+
+	result := viewmodels.GetHomeViewModel(sites, false, httpauth.UserData{}, nil)
+	result.AddDependencies(sites, dependencies, lastPingDown)
+	if result.Sites[0].Status != "Down" || result.Sites[2].Status != "Up" {
+		t.Error("Sites without a parent down should keep their status.")
+	}
+	if result.Sites[1].Status != "Unreachable (parent down)" || result.Sites[1].CSSClass != "warning" ||
+		result.Sites[3].Status != "Unreachable (parent down)" {
+		t.Error("Sites failing with a parent down should be unreachable:", result.Sites[1], result.Sites[3])
+	}
+	if result.Sites[4].Status != "Up" {
+		t.Error("Site that is still up should keep its status:", result.Sites[4])
+	}
+}
+
+// TestHomeViewModelFlapping tests that a flapping site is shown as flapping.
+func TestHomeViewModelFlapping(t *testing.T) {
+	sites := database.Sites{{SiteID: 1, Name: "Test 1", IsSiteUp: false, IsFlapping: true}}
+	result := viewmodels.GetHomeViewModel(sites, false, httpauth.UserData{}, nil)
+	if result.Sites[0].Status != "Flapping" || result.Sites[0].CSSClass != "warning" {
+		t.Error("Flapping site returned incorrect values:", result.Sites[0])
+	}
+}
+
+// TestHomeViewModelAcknowledged tests that who acknowledged an outage is shown
+// while the site is down.
+func TestHomeViewModelAcknowledged(t *testing.T) {
+	sites := database.Sites{
+		{SiteID: 1, Name: "Test 1", IsSiteUp: false, AcknowledgedBy: "jack", AcknowledgedAt: time.Now()},
+		{SiteID: 2, Name: "Test 2", IsSiteUp: true, AcknowledgedBy: "jack", AcknowledgedAt: time.Now()},
+	}
+	result := viewmodels.GetHomeViewModel(sites, false, httpauth.UserData{}, nil)
+	if result.Sites[0].AcknowledgedBy != "jack" || result.Sites[0].AcknowledgedAt != "now" {
+		t.Error("Acknowledged site returned incorrect values:", result.Sites[0])
+	}
+	if result.Sites[1].AcknowledgedBy != "" {
+		t.Error("Site that is up should not show an acknowledgement:", result.Sites[1])
+	}
+}
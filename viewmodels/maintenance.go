@@ -0,0 +1,190 @@
+package viewmodels
+
+import (
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apexskier/httpauth"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// MaintenanceTimeFormat is the format of the datetime-local inputs for the
+// one-off maintenance windows.
+const MaintenanceTimeFormat = "2006-01-02T15:04"
+
+// maintenanceDisplayFormat is how the maintenance times are shown to the user.
+const maintenanceDisplayFormat = "Jan 2, 2006 3:04 PM"
+
+// MaintenanceEditViewModel holds the required information about the maintenance
+// window to choose for editing. The times and DurationMinutes are strings to
+// allow the form validation.
+type MaintenanceEditViewModel struct {
+	MaintenanceWindowID int64  `valid:"-"`
+	SiteID              int64  `valid:"-"`
+	SiteName            string `valid:"-"`
+	Description         string `valid:"ascii,required"`
+	IsRecurring         bool   `valid:"-"`
+	StartTime           string `valid:"-"`
+	EndTime             string `valid:"-"`
+	CronSchedule        string `valid:"-"`
+	DurationMinutes     string `valid:"-"`
+	Schedule            string `valid:"-"`
+	IsActive            bool   `valid:"-"`
+}
+
+// MaintenanceWindowsViewModel holds the view information for the maintenance.gohtml template
+type MaintenanceWindowsViewModel struct {
+	Title   string
+	Windows []MaintenanceEditViewModel
+	Nav     NavViewModel
+}
+
+// MaintenanceViewModel holds the view information for the maintenance_edit.gohtml template
+type MaintenanceViewModel struct {
+	Errors    map[string]string
+	Title     string
+	Window    MaintenanceEditViewModel
+	AllSites  []ContactsAllSitesViewModel
+	Nav       NavViewModel
+	CsrfField template.HTML
+}
+
+// GetMaintenanceWindowsViewModel populates the items required by the maintenance.gohtml view
+func GetMaintenanceWindowsViewModel(windows database.MaintenanceWindows, now time.Time,
+	isAuthenticated bool, user httpauth.UserData) MaintenanceWindowsViewModel {
+	nav := NavViewModel{
+		Active:          "settings",
+		IsAuthenticated: isAuthenticated,
+		User:            user,
+	}
+
+	result := MaintenanceWindowsViewModel{
+		Title: "Go Ping Sites - Settings - Maintenance Windows",
+		Nav:   nav,
+	}
+
+	for _, window := range windows {
+		windowVM := new(MaintenanceEditViewModel)
+		MapMaintenanceDBtoVM(&window, windowVM)
+		windowVM.IsActive = window.IsActive(now)
+		result.Windows = append(result.Windows, *windowVM)
+	}
+
+	return result
+}
+
+// EditMaintenanceViewModel populates the items required by the maintenance_edit.gohtml view
+func EditMaintenanceViewModel(windowVM *MaintenanceEditViewModel, allSites database.Sites,
+	isAuthenticated bool, user httpauth.UserData, errors map[string]string) MaintenanceViewModel {
+	return maintenanceViewModel("Go Ping Sites - Settings - Edit Maintenance Window",
+		windowVM, allSites, isAuthenticated, user, errors)
+}
+
+// NewMaintenanceViewModel populates the items required by the maintenance_new.gohtml view
+func NewMaintenanceViewModel(windowVM *MaintenanceEditViewModel, allSites database.Sites,
+	isAuthenticated bool, user httpauth.UserData, errors map[string]string) MaintenanceViewModel {
+	return maintenanceViewModel("Go Ping Sites - Settings - New Maintenance Window",
+		windowVM, allSites, isAuthenticated, user, errors)
+}
+
+func maintenanceViewModel(title string, windowVM *MaintenanceEditViewModel, allSites database.Sites,
+	isAuthenticated bool, user httpauth.UserData, errors map[string]string) MaintenanceViewModel {
+	nav := NavViewModel{
+		Active:          "settings",
+		IsAuthenticated: isAuthenticated,
+		User:            user,
+	}
+
+	result := MaintenanceViewModel{
+		Title:  title,
+		Nav:    nav,
+		Errors: errors,
+		Window: *windowVM,
+	}
+	result.AllSites = PopulateAllSitesVM(allSites, []int64{windowVM.SiteID}, false)
+	return result
+}
+
+// MapMaintenanceVMtoDB maps the maintenance window view model properties to the
+// maintenance window database properties.
+func MapMaintenanceVMtoDB(windowVM *MaintenanceEditViewModel, window *database.MaintenanceWindow) error {
+	window.MaintenanceWindowID = windowVM.MaintenanceWindowID
+	window.SiteID = windowVM.SiteID
+	window.Description = strings.TrimSpace(windowVM.Description)
+	window.StartTime = time.Time{}
+	window.EndTime = time.Time{}
+	window.CronSchedule = ""
+	window.DurationMinutes = 0
+	if windowVM.IsRecurring {
+		window.CronSchedule = strings.Join(strings.Fields(windowVM.CronSchedule), " ")
+		duration, err := strconv.Atoi(strings.TrimSpace(windowVM.DurationMinutes))
+		if err != nil {
+			return err
+		}
+		window.DurationMinutes = duration
+		return nil
+	}
+	start, err := time.ParseInLocation(MaintenanceTimeFormat, windowVM.StartTime, time.Local)
+	if err != nil {
+		return err
+	}
+	end, err := time.ParseInLocation(MaintenanceTimeFormat, windowVM.EndTime, time.Local)
+	if err != nil {
+		return err
+	}
+	window.StartTime = start
+	window.EndTime = end
+	return nil
+}
+
+// MapMaintenanceDBtoVM maps the maintenance window database properties to the
+// maintenance window view model properties.
+func MapMaintenanceDBtoVM(window *database.MaintenanceWindow, windowVM *MaintenanceEditViewModel) {
+	windowVM.MaintenanceWindowID = window.MaintenanceWindowID
+	windowVM.SiteID = window.SiteID
+	windowVM.SiteName = window.SiteName
+	if window.SiteID == 0 {
+		windowVM.SiteName = "All Sites"
+	}
+	windowVM.Description = window.Description
+	windowVM.IsRecurring = window.IsRecurring()
+	if window.IsRecurring() {
+		windowVM.CronSchedule = window.CronSchedule
+		windowVM.DurationMinutes = strconv.Itoa(window.DurationMinutes)
+		windowVM.Schedule = "\"" + window.CronSchedule + "\" for " +
+			windowVM.DurationMinutes + " minutes"
+	} else {
+		windowVM.StartTime = window.StartTime.Local().Format(MaintenanceTimeFormat)
+		windowVM.EndTime = window.EndTime.Local().Format(MaintenanceTimeFormat)
+		windowVM.Schedule = window.StartTime.Local().Format(maintenanceDisplayFormat) +
+			" to " + window.EndTime.Local().Format(maintenanceDisplayFormat)
+	}
+}
+
+// AddMaintenance adds a banner for each of the maintenance windows that is
+// active now and marks the sites that they cover.
+func (vm *HomeViewModel) AddMaintenance(windows database.MaintenanceWindows, now time.Time) {
+	for _, window := range windows {
+		until, active := window.ActiveUntil(now)
+		if !active {
+			continue
+		}
+		target := window.SiteName
+		if window.SiteID == 0 {
+			target = "all sites"
+		}
+		banner := "Maintenance in progress for " + target + " until " +
+			until.Local().Format(maintenanceDisplayFormat)
+		if window.Description != "" {
+			banner += ": " + window.Description
+		}
+		vm.Maintenance = append(vm.Maintenance, banner)
+		for i := range vm.Sites {
+			if window.SiteID == 0 || window.SiteID == vm.Sites[i].SiteID {
+				vm.Sites[i].InMaintenance = true
+			}
+		}
+	}
+}
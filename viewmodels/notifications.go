@@ -0,0 +1,95 @@
+package viewmodels
+
+import (
+	"github.com/apexskier/httpauth"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// NotificationsFilterViewModel holds the filter of the delivery log from the
+// query string of the notifications page.
+type NotificationsFilterViewModel struct {
+	SiteID      int64
+	ChannelType string
+	Recipient   string
+	Success     string
+}
+
+// NotificationChannelViewModel is a channel that the delivery log can be
+// filtered by.
+type NotificationChannelViewModel struct {
+	ChannelType string
+	Name        string
+}
+
+// DeliveryAttemptViewModel is a delivery attempt as it's shown on the
+// notifications page.
+type DeliveryAttemptViewModel struct {
+	AttemptedAt     string
+	SiteName        string
+	Recipient       string
+	ChannelType     string
+	Address         string
+	Event           string
+	Subject         string
+	Summary         string
+	Success         bool
+	Error           string
+	OutboxMessageID int64
+}
+
+// NotificationsViewModel holds the view information for the notifications.gohtml template
+type NotificationsViewModel struct {
+	Title    string
+	Filter   NotificationsFilterViewModel
+	AllSites []ContactsAllSitesViewModel
+	Channels []NotificationChannelViewModel
+	Attempts []DeliveryAttemptViewModel
+	Nav      NavViewModel
+}
+
+// GetNotificationsViewModel populates the items required by the notifications.gohtml view
+func GetNotificationsViewModel(attempts database.DeliveryAttempts, filter NotificationsFilterViewModel,
+	allSites database.Sites, channels []NotificationChannelViewModel, isAuthenticated bool,
+	user httpauth.UserData) NotificationsViewModel {
+	nav := NavViewModel{
+		Active:          "settings",
+		IsAuthenticated: isAuthenticated,
+		User:            user,
+	}
+
+	result := NotificationsViewModel{
+		Title:    "Go Ping Sites - Settings - Notification Log",
+		Filter:   filter,
+		AllSites: PopulateAllSitesVM(allSites, []int64{filter.SiteID}, false),
+		Channels: channels,
+		Nav:      nav,
+	}
+	for _, a := range attempts {
+		result.Attempts = append(result.Attempts, DeliveryAttemptViewModel{
+			AttemptedAt:     a.AttemptedAt.Local().Format(maintenanceDisplayFormat),
+			SiteName:        a.SiteName,
+			Recipient:       a.Recipient,
+			ChannelType:     a.ChannelType,
+			Address:         a.Address,
+			Event:           a.Event,
+			Subject:         a.Subject,
+			Summary:         a.Summary,
+			Success:         a.Success,
+			Error:           a.Error,
+			OutboxMessageID: a.OutboxMessageID,
+		})
+	}
+	return result
+}
+
+// MapNotificationsFilterVMtoDB maps the filter of the notifications page to
+// the filter of the delivery attempts.
+func MapNotificationsFilterVMtoDB(filterVM NotificationsFilterViewModel, limit int) database.DeliveryFilter {
+	return database.DeliveryFilter{
+		SiteID:      filterVM.SiteID,
+		ChannelType: filterVM.ChannelType,
+		Recipient:   filterVM.Recipient,
+		Success:     filterVM.Success,
+		Limit:       limit,
+	}
+}
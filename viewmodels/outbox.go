@@ -0,0 +1,111 @@
+package viewmodels
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+
+	"github.com/apexskier/httpauth"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// OutboxStatuses are the statuses the outbox can be filtered by, a blank
+// status shows all of them.
+var OutboxStatuses = []string{database.OutboxPending, database.OutboxSent, database.OutboxDead}
+
+// OutboxMessageViewModel is an outbox message as it's shown to the user.
+type OutboxMessageViewModel struct {
+	OutboxMessageID int64
+	Recipient       string
+	ChannelType     string
+	Address         string
+	Subject         string
+	Payload         string
+	Status          string
+	Attempts        int
+	LastError       string
+	CreatedAt       string
+	NextAttempt     string
+	SentAt          string
+}
+
+// OutboxViewModel holds the view information for the outbox.gohtml template
+type OutboxViewModel struct {
+	Title    string
+	Status   string
+	Messages []OutboxMessageViewModel
+	Nav      NavViewModel
+}
+
+// OutboxMessageDetailsViewModel holds the view information for the
+// outbox_message.gohtml template
+type OutboxMessageDetailsViewModel struct {
+	Title     string
+	Message   OutboxMessageViewModel
+	Nav       NavViewModel
+	CsrfField template.HTML
+}
+
+// GetOutboxViewModel populates the items required by the outbox.gohtml view
+func GetOutboxViewModel(messages database.OutboxMessages, status string, isAuthenticated bool,
+	user httpauth.UserData) OutboxViewModel {
+	nav := NavViewModel{
+		Active:          "settings",
+		IsAuthenticated: isAuthenticated,
+		User:            user,
+	}
+
+	result := OutboxViewModel{
+		Title:  "Go Ping Sites - Settings - Notification Outbox",
+		Status: status,
+		Nav:    nav,
+	}
+	for _, m := range messages {
+		result.Messages = append(result.Messages, mapOutboxMessage(m))
+	}
+	return result
+}
+
+// GetOutboxMessageDetailsViewModel populates the items required by the
+// outbox_message.gohtml view
+func GetOutboxMessageDetailsViewModel(message database.OutboxMessage, isAuthenticated bool,
+	user httpauth.UserData) OutboxMessageDetailsViewModel {
+	nav := NavViewModel{
+		Active:          "settings",
+		IsAuthenticated: isAuthenticated,
+		User:            user,
+	}
+
+	return OutboxMessageDetailsViewModel{
+		Title:   "Go Ping Sites - Settings - Outbox Message",
+		Message: mapOutboxMessage(message),
+		Nav:     nav,
+	}
+}
+
+func mapOutboxMessage(m database.OutboxMessage) OutboxMessageViewModel {
+	result := OutboxMessageViewModel{
+		OutboxMessageID: m.OutboxMessageID,
+		Recipient:       m.Recipient,
+		ChannelType:     m.ChannelType,
+		Address:         m.Address,
+		Subject:         m.Subject,
+		Payload:         m.Payload,
+		Status:          m.Status,
+		Attempts:        m.Attempts,
+		LastError:       m.LastError,
+		CreatedAt:       m.CreatedAt.Local().Format(maintenanceDisplayFormat),
+	}
+	if m.Status == database.OutboxPending && !m.NextAttempt.IsZero() {
+		result.NextAttempt = m.NextAttempt.Local().Format(maintenanceDisplayFormat)
+	}
+	// The payload is indented to make it readable, as is if it's not JSON.
+	var payload bytes.Buffer
+	if json.Indent(&payload, []byte(m.Payload), "", "  ") == nil {
+		result.Payload = payload.String()
+	}
+	if m.Status == database.OutboxSent {
+		result.SentAt = m.SentAt.Local().Format(maintenanceDisplayFormat)
+	}
+	return result
+}
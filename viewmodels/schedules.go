@@ -0,0 +1,112 @@
+package viewmodels
+
+import (
+	"strings"
+	"time"
+
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// ContactScheduleViewModel is the schedule of one of the contact's channel
+// types on the contact form. The Days are the weekday numbers from Sunday, and
+// the schedule is left out if the times are blank.
+type ContactScheduleViewModel struct {
+	ChannelType string        `valid:"-"`
+	Name        string        `valid:"-"`
+	Days        []int         `valid:"-"`
+	StartTime   string        `valid:"-"`
+	EndTime     string        `valid:"-"`
+	DayOptions  []ScheduleDay `valid:"-"`
+}
+
+// ScheduleDay is a checkbox of a weekday on the schedule.
+type ScheduleDay struct {
+	Day       int
+	Name      string
+	IsChecked bool
+}
+
+// contactChannelTypes are the channel types of the contacts, in the order
+// they're shown on the schedules and the subscriptions.
+var contactChannelTypes = []struct {
+	channelType string
+	name        string
+}{
+	{database.EmailChannel, "Email"},
+	{database.SmsChannel, "SMS"},
+	{database.SlackChannel, "Slack"},
+	{database.TeamsChannel, "Teams"},
+	{database.TelegramChannel, "Telegram"},
+	{database.DiscordChannel, "Discord"},
+	{database.MatrixChannel, "Matrix"},
+	{database.NtfyChannel, "ntfy"},
+	{database.GotifyChannel, "Gotify"},
+}
+
+// HasSchedule returns whether the hours of the schedule are set.
+func (sch ContactScheduleViewModel) HasSchedule() bool {
+	return strings.TrimSpace(sch.StartTime) != "" || strings.TrimSpace(sch.EndTime) != ""
+}
+
+// populateSchedules returns the schedules of all of the channel types for the
+// form, with the ones from the form or the DB filled in.
+func populateSchedules(schedules []ContactScheduleViewModel) []ContactScheduleViewModel {
+	var result []ContactScheduleViewModel
+	for _, ch := range contactChannelTypes {
+		sch := ContactScheduleViewModel{ChannelType: ch.channelType, Name: ch.name}
+		for _, s := range schedules {
+			if s.ChannelType == ch.channelType {
+				sch.Days, sch.StartTime, sch.EndTime = s.Days, s.StartTime, s.EndTime
+			}
+		}
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			sch.DayOptions = append(sch.DayOptions, ScheduleDay{Day: int(day), Name: day.String()[:3],
+				IsChecked: intInSlice(int(day), sch.Days)})
+		}
+		result = append(result, sch)
+	}
+	return result
+}
+
+// MapSchedulesVMtoDB maps the schedules of the form that have their hours set
+// to the contact schedules.
+func MapSchedulesVMtoDB(schedules []ContactScheduleViewModel) []database.ContactSchedule {
+	var result []database.ContactSchedule
+	for _, sch := range schedules {
+		if !sch.HasSchedule() {
+			continue
+		}
+		days := 0
+		for _, day := range sch.Days {
+			days |= 1 << uint(day)
+		}
+		result = append(result, database.ContactSchedule{ChannelType: sch.ChannelType, Days: days,
+			StartTime: strings.TrimSpace(sch.StartTime), EndTime: strings.TrimSpace(sch.EndTime)})
+	}
+	return result
+}
+
+// MapSchedulesDBtoVM maps the contact schedules to the schedules of the form.
+func MapSchedulesDBtoVM(schedules []database.ContactSchedule) []ContactScheduleViewModel {
+	var result []ContactScheduleViewModel
+	for _, sch := range schedules {
+		schVM := ContactScheduleViewModel{ChannelType: sch.ChannelType, StartTime: sch.StartTime,
+			EndTime: sch.EndTime}
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if sch.Days&(1<<uint(day)) != 0 {
+				schVM.Days = append(schVM.Days, int(day))
+			}
+		}
+		result = append(result, schVM)
+	}
+	return result
+}
+
+func intInSlice(a int, list []int) bool {
+	for _, b := range list {
+		if b == a {
+			return true
+		}
+	}
+	return false
+}
@@ -10,18 +10,33 @@ import (
 )
 
 // SitesEditViewModel holds the required information about the Sites to choose for editing.
-// The PingIntervalSeconds and TimeoutSeconds are strings to allow the form validation.
+// The PingIntervalSeconds, TimeoutSeconds and reminders are strings to allow the form validation.
 type SitesEditViewModel struct {
-	SiteID              int64   `valid:"-"`
-	Name                string  `valid:"ascii,required"`
-	IsActive            bool    `valid:"-"`
-	URL                 string  `valid:"url,required"`
-	PingIntervalSeconds string  `valid:"int,required"`
-	TimeoutSeconds      string  `valid:"int,required"`
-	ContentExpected     string  `valid:"-"`
-	ContentUnexpected   string  `valid:"-"`
-	SelectedContacts    []int64 `valid:"-"`
-	SiteContacts        []int64 `valid:"-"`
+	SiteID              int64                   `valid:"-"`
+	Name                string                  `valid:"ascii,required"`
+	IsActive            bool                    `valid:"-"`
+	IsCritical          bool                    `valid:"-"`
+	URL                 string                  `valid:"required"`
+	PingIntervalSeconds string                  `valid:"int,required"`
+	TimeoutSeconds      string                  `valid:"int,required"`
+	ContentExpected     string                  `valid:"-"`
+	ContentUnexpected   string                  `valid:"-"`
+	TransactionSteps    string                  `valid:"-"`
+	ReminderMinutes     string                  `valid:"int"`
+	MaxReminders        string                  `valid:"int"`
+	SlackWebhook        string                  `valid:"-"`
+	TeamsWebhook        string                  `valid:"-"`
+	PagerDutyKey        string                  `valid:"-"`
+	OpsgenieKey         string                  `valid:"-"`
+	EscalationPolicyID  int64                   `valid:"-"`
+	EscalationPolicy    string                  `valid:"-"`
+	IsSiteUp            bool                    `valid:"-"`
+	AcknowledgedBy      string                  `valid:"-"`
+	AcknowledgedAt      string                  `valid:"-"`
+	SelectedContacts    []int64                 `valid:"-"`
+	SiteContacts        []int64                 `valid:"-"`
+	SelectedParents     []int64                 `valid:"-"`
+	Subscriptions       []SubscriptionViewModel `valid:"-"`
 }
 
 // SitesAllContactsViewModel has all of the sites available and carries whether
@@ -34,6 +49,27 @@ type SitesAllContactsViewModel struct {
 	SmsNumber    string
 	SmsActive    bool
 	EmailActive  bool
+	Events       []SubscriptionOption
+	ChannelTypes []SubscriptionOption
+}
+
+// SiteParentViewModel has the sites that can be chosen as parents of the site
+// with the ones it depends on having IsAssigned set to true.
+type SiteParentViewModel struct {
+	SiteID     int64
+	Name       string
+	URL        string
+	IsAssigned bool
+}
+
+// SiteContactViewModel is a contact of the site with its email and SMS for the
+// site_details.gohtml template.
+type SiteContactViewModel struct {
+	database.Contact
+	EmailAddress string
+	SmsNumber    string
+	SmsActive    bool
+	EmailActive  bool
 }
 
 // SiteContactsSelectedViewModel holds the selections when contacts are changed.
@@ -49,8 +85,11 @@ type SiteViewModel struct {
 	Errors      map[string]string
 	Title       string
 	Site        SitesEditViewModel
-	Contacts    []database.Contact
+	Contacts    []SiteContactViewModel
 	AllContacts []SitesAllContactsViewModel
+	AllParents  []SiteParentViewModel
+	AllPolicies []EscalationPolicyOptionViewModel
+	TestResults []ChannelTestViewModel
 	Nav         NavViewModel
 	CsrfField   template.HTML
 }
@@ -72,7 +111,12 @@ func GetSiteDetailsViewModel(site *database.Site, isAuthenticated bool,
 	siteVM := new(SitesEditViewModel)
 	MapSiteDBtoVM(site, siteVM)
 	result.Site = *siteVM
-	result.Contacts = site.Contacts
+	for _, contact := range site.Contacts {
+		email, sms := contactEndpoints(contact)
+		result.Contacts = append(result.Contacts, SiteContactViewModel{Contact: contact,
+			EmailAddress: email.Address, EmailActive: email.IsActive,
+			SmsNumber: sms.Address, SmsActive: sms.IsActive})
+	}
 
 	return result
 }
@@ -94,6 +138,10 @@ func EditSiteViewModel(siteVM *SitesEditViewModel, allContacts database.Contacts
 
 	result.Site = *siteVM
 	result.AllContacts = PopulateAllContactsVM(allContacts, siteVM.SelectedContacts)
+	for i := range result.AllContacts {
+		result.AllContacts[i].Events, result.AllContacts[i].ChannelTypes =
+			subscriptionOptions(subscriptionFor(siteVM.Subscriptions, result.AllContacts[i].ContactID))
+	}
 	return result
 }
 
@@ -113,6 +161,10 @@ func NewSiteViewModel(siteVM *SitesEditViewModel, allContacts database.Contacts,
 	}
 	result.Site = *siteVM
 	result.AllContacts = PopulateAllContactsVM(allContacts, siteVM.SelectedContacts)
+	for i := range result.AllContacts {
+		result.AllContacts[i].Events, result.AllContacts[i].ChannelTypes =
+			subscriptionOptions(subscriptionFor(siteVM.Subscriptions, result.AllContacts[i].ContactID))
+	}
 	return result
 }
 
@@ -121,9 +173,16 @@ func MapSiteVMtoDB(siteVM *SitesEditViewModel, site *database.Site) error {
 	site.SiteID = siteVM.SiteID
 	site.Name = siteVM.Name
 	site.IsActive = siteVM.IsActive
+	site.IsCritical = siteVM.IsCritical
 	site.URL = strings.TrimSpace(siteVM.URL)
 	site.ContentExpected = strings.TrimSpace(siteVM.ContentExpected)
 	site.ContentUnexpected = strings.TrimSpace(siteVM.ContentUnexpected)
+	site.TransactionSteps = strings.TrimSpace(siteVM.TransactionSteps)
+	site.EscalationPolicyID = siteVM.EscalationPolicyID
+	site.SetChannel(database.SlackChannel, strings.TrimSpace(siteVM.SlackWebhook), true)
+	site.SetChannel(database.TeamsChannel, strings.TrimSpace(siteVM.TeamsWebhook), true)
+	site.SetChannel(database.PagerDutyChannel, strings.TrimSpace(siteVM.PagerDutyKey), true)
+	site.SetChannel(database.OpsgenieChannel, strings.TrimSpace(siteVM.OpsgenieKey), true)
 	// Conversion on these two is necessary because they are a string in the
 	// view model to allow the validation to work
 	pingInterval, err := strconv.Atoi(siteVM.PingIntervalSeconds)
@@ -136,18 +195,56 @@ func MapSiteVMtoDB(siteVM *SitesEditViewModel, site *database.Site) error {
 		return err
 	}
 	site.TimeoutSeconds = timeout
+	site.ReminderMinutes, err = optionalInt(siteVM.ReminderMinutes)
+	if err != nil {
+		return err
+	}
+	site.MaxReminders, err = optionalInt(siteVM.MaxReminders)
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// optionalInt converts the number from the form, which is 0 if it's left blank.
+func optionalInt(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
 // MapSiteDBtoVM maps the site database properties to the site view model properties.
 func MapSiteDBtoVM(site *database.Site, siteVM *SitesEditViewModel) {
 	siteVM.SiteID = site.SiteID
 	siteVM.Name = site.Name
 	siteVM.IsActive = site.IsActive
+	siteVM.IsCritical = site.IsCritical
 	siteVM.URL = site.URL
 	siteVM.ContentExpected = site.ContentExpected
 	siteVM.ContentUnexpected = site.ContentUnexpected
+	siteVM.TransactionSteps = site.TransactionSteps
+	siteVM.EscalationPolicyID = site.EscalationPolicyID
+	siteVM.IsSiteUp = site.IsSiteUp
+	siteVM.AcknowledgedBy, siteVM.AcknowledgedAt = acknowledgement(site)
+	if site.ReminderMinutes > 0 {
+		siteVM.ReminderMinutes = strconv.Itoa(site.ReminderMinutes)
+		siteVM.MaxReminders = strconv.Itoa(site.MaxReminders)
+	}
+	if slack, ok := site.Channel(database.SlackChannel); ok {
+		siteVM.SlackWebhook = slack.Address
+	}
+	if teams, ok := site.Channel(database.TeamsChannel); ok {
+		siteVM.TeamsWebhook = teams.Address
+	}
+	if pagerDuty, ok := site.Channel(database.PagerDutyChannel); ok {
+		siteVM.PagerDutyKey = pagerDuty.Address
+	}
+	if opsgenie, ok := site.Channel(database.OpsgenieChannel); ok {
+		siteVM.OpsgenieKey = opsgenie.Address
+	}
 	// Conversion on these two is necessary because they are a string in the
 	// view model to allow the validation to work
 	siteVM.PingIntervalSeconds = strconv.Itoa(site.PingIntervalSeconds)
@@ -167,16 +264,43 @@ func PopulateAllContactsVM(allContacts database.Contacts,
 				break
 			}
 		}
+		email, sms := contactEndpoints(contact)
 		contactVM := SitesAllContactsViewModel{
 			ContactID:    contact.ContactID,
 			Name:         contact.Name,
 			IsAssigned:   hasMatch,
-			EmailAddress: contact.EmailAddress,
-			EmailActive:  contact.EmailActive,
-			SmsNumber:    contact.SmsNumber,
-			SmsActive:    contact.SmsActive,
+			EmailAddress: email.Address,
+			EmailActive:  email.IsActive,
+			SmsNumber:    sms.Address,
+			SmsActive:    sms.IsActive,
 		}
 		allContactsVM = append(allContactsVM, contactVM)
 	}
 	return allContactsVM
 }
+
+// PopulateParentSitesVM returns the view model for the sites that can be
+// parents of the site, leaving out the site itself.
+func PopulateParentSitesVM(allSites database.Sites, siteID int64,
+	parentSiteIDs []int64) []SiteParentViewModel {
+	var parentsVM = []SiteParentViewModel{}
+	for _, site := range allSites {
+		if site.SiteID == siteID {
+			continue
+		}
+		hasMatch := false
+		for _, parentSiteID := range parentSiteIDs {
+			if parentSiteID == site.SiteID {
+				hasMatch = true
+				break
+			}
+		}
+		parentsVM = append(parentsVM, SiteParentViewModel{
+			SiteID:     site.SiteID,
+			Name:       site.Name,
+			URL:        site.URL,
+			IsAssigned: hasMatch,
+		})
+	}
+	return parentsVM
+}
@@ -0,0 +1,104 @@
+package viewmodels
+
+import (
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// SubscriptionViewModel is what a contact is notified of for a site on the
+// contact and site forms. The ID is the site on the contact form and the
+// contact on the site form.
+type SubscriptionViewModel struct {
+	ID           int64    `valid:"-"`
+	Events       []string `valid:"-"`
+	ChannelTypes []string `valid:"-"`
+}
+
+// SubscriptionOption is a checkbox of an event or a channel type of a
+// subscription.
+type SubscriptionOption struct {
+	Value     string
+	Name      string
+	IsChecked bool
+}
+
+// subscriptionEventNames are the names of the events shown on the forms.
+var subscriptionEventNames = map[string]string{
+	database.DownEvent:       "Down",
+	database.UpEvent:         "Up",
+	database.ReminderEvent:   "Reminders",
+	database.FlappingEvent:   "Flapping",
+	database.DegradedEvent:   "Degraded",
+	database.CertExpiryEvent: "Certificate Expiry",
+}
+
+// subscriptionFor returns the subscription of the ID, which is nil if the
+// association is new.
+func subscriptionFor(subs []SubscriptionViewModel, id int64) *SubscriptionViewModel {
+	for i := range subs {
+		if subs[i].ID == id {
+			return &subs[i]
+		}
+	}
+	return nil
+}
+
+// subscriptionOptions returns the checkboxes of the events and the channel
+// types of the subscription, which are all checked for a new association.
+func subscriptionOptions(sub *SubscriptionViewModel) ([]SubscriptionOption, []SubscriptionOption) {
+	var events, channelTypes []SubscriptionOption
+	for _, event := range database.SubscriptionEvents {
+		events = append(events, SubscriptionOption{Value: event, Name: subscriptionEventNames[event],
+			IsChecked: sub == nil || stringInSlice(event, sub.Events)})
+	}
+	for _, ch := range contactChannelTypes {
+		channelTypes = append(channelTypes, SubscriptionOption{Value: ch.channelType, Name: ch.name,
+			IsChecked: sub == nil || stringInSlice(ch.channelType, sub.ChannelTypes)})
+	}
+	return events, channelTypes
+}
+
+// MapSubscriptionVMtoDB maps the subscription of the ID on the form to the
+// database, where the events or channel types that are all checked are saved
+// as all of them so that new ones are included.
+func MapSubscriptionVMtoDB(subs []SubscriptionViewModel, id int64) database.Subscription {
+	var result database.Subscription
+	sub := subscriptionFor(subs, id)
+	if sub == nil {
+		return result
+	}
+	if len(sub.Events) < len(database.SubscriptionEvents) {
+		result.Events = sub.Events
+	}
+	if len(sub.ChannelTypes) < len(contactChannelTypes) {
+		result.ChannelTypes = sub.ChannelTypes
+	}
+	return result
+}
+
+// MapSubscriptionsDBtoVM maps the subscriptions from the database by their ID
+// to the form, filling in all of the events or channel types when they're empty.
+func MapSubscriptionsDBtoVM(subs map[int64]database.Subscription) []SubscriptionViewModel {
+	var result []SubscriptionViewModel
+	for id, sub := range subs {
+		subVM := SubscriptionViewModel{ID: id, Events: sub.Events, ChannelTypes: sub.ChannelTypes}
+		if len(subVM.Events) == 0 {
+			subVM.Events = database.SubscriptionEvents
+		}
+		if len(subVM.ChannelTypes) == 0 {
+			for _, ch := range contactChannelTypes {
+				subVM.ChannelTypes = append(subVM.ChannelTypes, ch.channelType)
+			}
+		}
+		result = append(result, subVM)
+	}
+	return result
+}
+
+func stringInSlice(a string, list []string) bool {
+	for _, b := range list {
+		if b == a {
+			return true
+		}
+	}
+	return false
+}
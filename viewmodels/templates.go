@@ -0,0 +1,155 @@
+package viewmodels
+
+import (
+	"html/template"
+	"strings"
+
+	"github.com/apexskier/httpauth"
+	"github.com/turnkey-commerce/go-ping-sites/database"
+)
+
+// AllChannels is how the templates for all of the channels are identified in
+// the routes, they have an empty ChannelType in the DB.
+const AllChannels = "all"
+
+// TemplateEditViewModel holds the notification template of the channel and
+// event for editing. The Preview is set by the preview button of the form.
+type TemplateEditViewModel struct {
+	ChannelType string `valid:"-"`
+	Event       string `valid:"-"`
+	Subject     string `valid:"-"`
+	Body        string `valid:"required"`
+	Preview     string `valid:"-"`
+}
+
+// TemplateChannelViewModel is a channel that can have its own templates, the
+// Name is how it's shown.
+type TemplateChannelViewModel struct {
+	ChannelType string
+	Name        string
+}
+
+// TemplateRowViewModel is a row of the templates list with whether each event
+// of the channel has been customized.
+type TemplateRowViewModel struct {
+	Channel TemplateChannelViewModel
+	Events  []TemplateEventViewModel
+}
+
+// TemplateEventViewModel is an event of a channel on the templates list.
+type TemplateEventViewModel struct {
+	Event    string
+	IsCustom bool
+}
+
+// TemplatePreviewViewModel is the notification rendered from the template
+// about an example site.
+type TemplatePreviewViewModel struct {
+	Subject string
+	Body    string
+}
+
+// TemplatesViewModel holds the view information for the templates.gohtml template
+type TemplatesViewModel struct {
+	Title  string
+	Events []string
+	Rows   []TemplateRowViewModel
+	Nav    NavViewModel
+}
+
+// TemplateViewModel holds the view information for the template_edit.gohtml template
+type TemplateViewModel struct {
+	Errors    map[string]string
+	Title     string
+	Channel   TemplateChannelViewModel
+	Template  TemplateEditViewModel
+	IsCustom  bool
+	Preview   *TemplatePreviewViewModel
+	Nav       NavViewModel
+	CsrfField template.HTML
+}
+
+// GetTemplatesViewModel populates the items required by the templates.gohtml view
+func GetTemplatesViewModel(templates database.NotificationTemplates, channels []TemplateChannelViewModel,
+	isAuthenticated bool, user httpauth.UserData) TemplatesViewModel {
+	nav := NavViewModel{
+		Active:          "settings",
+		IsAuthenticated: isAuthenticated,
+		User:            user,
+	}
+
+	result := TemplatesViewModel{
+		Title:  "Go Ping Sites - Settings - Notification Templates",
+		Events: database.TemplateEvents,
+		Nav:    nav,
+	}
+
+	for _, channel := range channels {
+		row := TemplateRowViewModel{Channel: channel}
+		for _, event := range database.TemplateEvents {
+			row.Events = append(row.Events, TemplateEventViewModel{Event: event,
+				IsCustom: hasTemplate(templates, channelTypeToDB(channel.ChannelType), event)})
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	return result
+}
+
+// EditTemplateViewModel populates the items required by the template_edit.gohtml
+// and template_delete.gohtml views.
+func EditTemplateViewModel(templateVM *TemplateEditViewModel, channel TemplateChannelViewModel,
+	isCustom bool, isAuthenticated bool, user httpauth.UserData, errors map[string]string) TemplateViewModel {
+	nav := NavViewModel{
+		Active:          "settings",
+		IsAuthenticated: isAuthenticated,
+		User:            user,
+	}
+
+	return TemplateViewModel{
+		Title:    "Go Ping Sites - Settings - Edit Notification Template",
+		Nav:      nav,
+		Errors:   errors,
+		Channel:  channel,
+		Template: *templateVM,
+		IsCustom: isCustom,
+	}
+}
+
+func hasTemplate(templates database.NotificationTemplates, channelType string, event string) bool {
+	for _, t := range templates {
+		if t.ChannelType == channelType && t.Event == event {
+			return true
+		}
+	}
+	return false
+}
+
+// channelTypeToDB returns the channel type of the route as it's saved in the DB.
+func channelTypeToDB(channelType string) string {
+	if channelType == AllChannels {
+		return ""
+	}
+	return channelType
+}
+
+// MapTemplateVMtoDB maps the notification template view model properties to the
+// notification template database properties.
+func MapTemplateVMtoDB(templateVM *TemplateEditViewModel, t *database.NotificationTemplate) {
+	t.ChannelType = channelTypeToDB(templateVM.ChannelType)
+	t.Event = templateVM.Event
+	t.Subject = strings.TrimSpace(templateVM.Subject)
+	t.Body = strings.TrimSpace(templateVM.Body)
+}
+
+// MapTemplateDBtoVM maps the notification template database properties to the
+// notification template view model properties.
+func MapTemplateDBtoVM(t *database.NotificationTemplate, templateVM *TemplateEditViewModel) {
+	templateVM.ChannelType = t.ChannelType
+	if templateVM.ChannelType == "" {
+		templateVM.ChannelType = AllChannels
+	}
+	templateVM.Event = t.Event
+	templateVM.Subject = t.Subject
+	templateVM.Body = t.Body
+}